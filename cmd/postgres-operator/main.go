@@ -55,8 +55,13 @@ func initLogging() {
 }
 
 func main() {
-	// Set any supplied feature gates; panic on any unrecognized feature gate
-	err := util.AddAndSetFeatureGates(os.Getenv("PGO_FEATURE_GATES"))
+	// Set any supplied feature gates; panic on any unrecognized feature gate.
+	// Kustomize components like config/webhook cannot append to the value of
+	// PGO_FEATURE_GATES, so they set their gates in a variable of their own.
+	err := util.AddAndSetFeatureGates(strings.Join([]string{
+		os.Getenv("PGO_FEATURE_GATES"),
+		os.Getenv("PGO_FEATURE_GATES_WEBHOOK"),
+	}, ","))
 	assertNoError(err)
 
 	otelFlush, err := initOpenTelemetry()
@@ -146,6 +151,17 @@ func addControllersToManager(mgr manager.Manager, openshift bool, log logr.Logge
 		log.Error(err, "unable to create PGUpgrade controller")
 		os.Exit(1)
 	}
+
+	if util.DefaultMutableFeatureGate.Enabled(util.AdmissionWebhooks) {
+		if err := pgReconciler.SetupWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create PostgresCluster webhooks")
+			os.Exit(1)
+		}
+		if err := upgradeReconciler.SetupWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create PGUpgrade webhooks")
+			os.Exit(1)
+		}
+	}
 }
 
 func isOpenshift(cfg *rest.Config) bool {
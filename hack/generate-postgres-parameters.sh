@@ -0,0 +1,62 @@
+#!/usr/bin/env bash
+
+# Copyright 2023 Crunchy Data Solutions, Inc.
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+# http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# This script writes the parameters of each major version of PostgreSQL to
+# internal/postgres/parameters/pg{version}.csv by starting that version in a
+# container and reading its "pg_settings" view.
+#
+# Usage: generate-postgres-parameters.sh [version ...]
+
+set -eu
+
+directory=$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )
+output="${directory}/../internal/postgres/parameters"
+
+CONTAINER="${CONTAINER:-docker}"
+POSTGRES_IMAGE="${POSTGRES_IMAGE:-docker.io/library/postgres}"
+
+if [ "$#" -eq 0 ]; then
+	set -- 10 11 12 13 14 15
+fi
+
+# Customized options, which have a dot in their name, come from extensions and
+# are not part of PostgreSQL.
+read -r -d '' query << 'SQL' || true
+COPY (
+  SELECT name, vartype, context, unit, min_val, max_val, enumvals
+    FROM pg_catalog.pg_settings
+   WHERE name NOT LIKE '%.%'
+   ORDER BY name
+) TO STDOUT WITH (FORMAT csv, HEADER)
+SQL
+
+for version in "$@"; do
+	name="generate-postgres-parameters-${version}"
+	trap '"${CONTAINER}" rm --force "${name}" > /dev/null' EXIT
+
+	"${CONTAINER}" run --detach --name "${name}" \
+		--env POSTGRES_HOST_AUTH_METHOD=trust \
+		"${POSTGRES_IMAGE}:${version}" > /dev/null
+
+	until "${CONTAINER}" exec "${name}" pg_isready --quiet --host=localhost --username=postgres; do
+		sleep 1
+	done
+
+	"${CONTAINER}" exec "${name}" psql --quiet --no-psqlrc --host=localhost \
+		--username=postgres --command="${query}" > "${output}/pg${version}.csv"
+
+	"${CONTAINER}" rm --force "${name}" > /dev/null
+	trap - EXIT
+done
@@ -126,6 +126,7 @@ func (r *PGUpgradeReconciler) watchPostgresClusters() handler.Funcs {
 //+kubebuilder:rbac:groups="batch",resources="jobs",verbs={list}
 //+kubebuilder:rbac:groups="",resources="endpoints",verbs={get}
 //+kubebuilder:rbac:groups="",resources="endpoints",verbs={delete}
+//+kubebuilder:rbac:groups="",resources="configmaps",verbs={delete}
 
 // Reconcile does the work to move the current state of the world toward the
 // desired state described in a [v1beta1.PGUpgrade] identified by req.
@@ -447,22 +448,27 @@ func (r *PGUpgradeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 
 	// The upgrade job generates a new system identifier for this cluster.
-	// Clear the old identifier from Patroni by deleting its DCS Endpoints.
-	// This is safe to do this when all Patroni processes are stopped
-	// (ClusterShutdown) and PGO has identified a leader to start first
-	// (ClusterPrimary).
+	// Clear the old identifier from Patroni by deleting its DCS Endpoints or
+	// ConfigMaps, whichever it is using. This is safe to do this when all
+	// Patroni processes are stopped (ClusterShutdown) and PGO has identified
+	// a leader to start first (ClusterPrimary).
 	// - https://github.com/zalando/patroni/blob/v2.1.2/docs/existing_data.rst
-	//
-	// TODO(cbandy): This works only when using Kubernetes Endpoints for DCS.
-	if len(world.PatroniEndpoints) > 0 {
-		for _, object := range world.PatroniEndpoints {
+	var patroniObjects []client.Object
+	for _, object := range world.PatroniEndpoints {
+		patroniObjects = append(patroniObjects, object)
+	}
+	for _, object := range world.PatroniConfigMaps {
+		patroniObjects = append(patroniObjects, object)
+	}
+	if len(patroniObjects) > 0 {
+		for _, object := range patroniObjects {
 			uid := object.GetUID()
 			version := object.GetResourceVersion()
 			exactly := client.Preconditions{UID: &uid, ResourceVersion: &version}
 			err = client.IgnoreNotFound(r.Client.Delete(ctx, object, exactly))
 		}
 
-		// Requeue to verify that Patroni endpoints are deleted
+		// Requeue to verify that Patroni objects are deleted
 		return ctrl.Result{Requeue: true}, err // FIXME
 	}
 
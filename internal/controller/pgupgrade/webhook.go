@@ -0,0 +1,118 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgupgrade
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crunchydata/postgres-operator/internal/kubeapi"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// +kubebuilder:webhook:path=/validate-postgres-operator-crunchydata-com-v1beta1-pgupgrade,mutating=false,failurePolicy=fail,sideEffects=None,groups=postgres-operator.crunchydata.com,resources=pgupgrades,verbs=create;update,versions=v1beta1,name=vpgupgrade.postgres-operator.crunchydata.com,admissionReviewVersions=v1
+
+// SetupWebhookWithManager registers the PGUpgrade validating admission webhook
+// with the webhook server of mgr. PGUpgrade has no defaults outside of its
+// OpenAPI schema, so there is no mutating webhook.
+func (r *PGUpgradeReconciler) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1beta1.PGUpgrade{}).
+		WithValidator(webhook{}).
+		Complete()
+}
+
+// webhook implements admission.CustomValidator for PGUpgrade.
+type webhook struct{}
+
+var _ admission.CustomValidator = webhook{}
+
+// ValidateCreate rejects a PGUpgrade with an invalid spec.
+func (webhook) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	upgrade, ok := obj.(*v1beta1.PGUpgrade)
+	if !ok {
+		return apierrors.NewBadRequest(
+			fmt.Sprintf("expected a PGUpgrade but got a %T", obj))
+	}
+	return invalidUpgrade(upgrade, validateUpgrade(upgrade))
+}
+
+// ValidateUpdate rejects changes that would make a PGUpgrade spec invalid.
+// Problems that were already present in the old spec are allowed so that the
+// operator can continue to update an upgrade after its environment changes.
+func (webhook) ValidateUpdate(_ context.Context, oldObj, obj runtime.Object) error {
+	upgrade, ok := obj.(*v1beta1.PGUpgrade)
+	if !ok {
+		return apierrors.NewBadRequest(
+			fmt.Sprintf("expected a PGUpgrade but got a %T", obj))
+	}
+	old, ok := oldObj.(*v1beta1.PGUpgrade)
+	if !ok {
+		return apierrors.NewBadRequest(
+			fmt.Sprintf("expected a PGUpgrade but got a %T", oldObj))
+	}
+
+	// Allow finalizers to be removed from an upgrade that is being deleted,
+	// regardless of its spec.
+	if upgrade.DeletionTimestamp != nil {
+		return nil
+	}
+
+	return invalidUpgrade(upgrade, kubeapi.IntroducedErrors(
+		validateUpgrade(old), validateUpgrade(upgrade)))
+}
+
+// ValidateDelete allows every PGUpgrade to be deleted.
+func (webhook) ValidateDelete(context.Context, runtime.Object) error { return nil }
+
+// invalidUpgrade returns an Invalid API error for upgrade when there are any
+// errors in allErrors.
+func invalidUpgrade(upgrade *v1beta1.PGUpgrade, allErrors field.ErrorList) error {
+	if len(allErrors) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		v1beta1.GroupVersion.WithKind("PGUpgrade").GroupKind(),
+		upgrade.Name, allErrors)
+}
+
+// validateUpgrade checks the parts of a PGUpgrade spec that cannot be
+// expressed in its OpenAPI schema. Reconcile reports an invalid version range
+// as a condition, too, for upgrades that were not validated by a webhook.
+func validateUpgrade(upgrade *v1beta1.PGUpgrade) field.ErrorList {
+	spec := field.NewPath("spec")
+	allErrors := field.ErrorList{}
+
+	if upgrade.Spec.FromPostgresVersion >= upgrade.Spec.ToPostgresVersion {
+		allErrors = append(allErrors, field.Invalid(
+			spec.Child("toPostgresVersion"), upgrade.Spec.ToPostgresVersion,
+			fmt.Sprintf("must be greater than fromPostgresVersion (%d)",
+				upgrade.Spec.FromPostgresVersion)))
+	}
+
+	if pgUpgradeContainerImage(upgrade) == "" {
+		allErrors = append(allErrors, field.Required(spec.Child("image"),
+			"no image specified and the operator has no RELATED_IMAGE_PGUPGRADE environment variable"))
+	}
+
+	return allErrors
+}
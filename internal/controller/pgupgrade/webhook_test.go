@@ -0,0 +1,82 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package pgupgrade
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestWebhookValidate(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("RELATED_IMAGE_PGUPGRADE", "")
+
+	t.Run("WrongType", func(t *testing.T) {
+		err := webhook{}.ValidateCreate(ctx, &corev1.Pod{})
+		assert.Assert(t, apierrors.IsBadRequest(err), "got %#v", err)
+	})
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Name = "pgu1"
+	upgrade.Spec.Image = initialize.Pointer("img2")
+	upgrade.Spec.PostgresClusterName = "pg3"
+	upgrade.Spec.FromPostgresVersion = 13
+	upgrade.Spec.ToPostgresVersion = 14
+
+	assert.NilError(t, webhook{}.ValidateCreate(ctx, upgrade))
+	assert.NilError(t, webhook{}.ValidateUpdate(ctx, upgrade, upgrade))
+	assert.NilError(t, webhook{}.ValidateDelete(ctx, upgrade))
+
+	t.Run("Versions", func(t *testing.T) {
+		upgrade := upgrade.DeepCopy()
+		upgrade.Spec.ToPostgresVersion = 13
+
+		err := webhook{}.ValidateCreate(ctx, upgrade)
+		assert.Assert(t, apierrors.IsInvalid(err), "got %#v", err)
+		assert.ErrorContains(t, err,
+			`spec.toPostgresVersion: Invalid value: 13: must be greater than fromPostgresVersion (13)`)
+
+		// Deletion is allowed to proceed.
+		upgrade.DeletionTimestamp = &metav1.Time{}
+		assert.NilError(t, webhook{}.ValidateUpdate(ctx, upgrade, upgrade))
+	})
+
+	t.Run("Image", func(t *testing.T) {
+		upgrade := upgrade.DeepCopy()
+		upgrade.Spec.Image = nil
+
+		err := webhook{}.ValidateUpdate(ctx, upgrade.DeepCopy(), upgrade)
+		assert.NilError(t, err, "expected an existing problem to be allowed")
+
+		old := upgrade.DeepCopy()
+		old.Spec.Image = initialize.String("img3")
+
+		err = webhook{}.ValidateUpdate(ctx, old, upgrade)
+		assert.Assert(t, apierrors.IsInvalid(err), "got %#v", err)
+		assert.ErrorContains(t, err, `spec.image: Required value: no image specified`)
+
+		t.Setenv("RELATED_IMAGE_PGUPGRADE", "img4")
+		assert.NilError(t, webhook{}.ValidateUpdate(ctx, old, upgrade))
+	})
+}
@@ -33,6 +33,7 @@ import (
 // - https://github.com/kubernetes-sigs/controller-runtime/issues/1249
 // - https://github.com/kubernetes-sigs/controller-runtime/issues/1454
 //+kubebuilder:rbac:groups="postgres-operator.crunchydata.com",resources="postgresclusters",verbs={get,watch}
+//+kubebuilder:rbac:groups="",resources="configmaps",verbs={list,watch}
 //+kubebuilder:rbac:groups="",resources="endpoints",verbs={list,watch}
 //+kubebuilder:rbac:groups="batch",resources="jobs",verbs={list,watch}
 //+kubebuilder:rbac:groups="apps",resources="statefulsets",verbs={list,watch}
@@ -65,6 +66,16 @@ func (r *PGUpgradeReconciler) observeWorld(
 		world.populatePatroniEndpoints(endpoints.Items)
 	}
 
+	if err == nil {
+		var configmaps corev1.ConfigMapList
+		err = errors.WithStack(
+			r.List(ctx, &configmaps,
+				client.InNamespace(upgrade.Namespace),
+				client.MatchingLabelsSelector{Selector: selectCluster},
+			))
+		world.populatePatroniConfigMaps(configmaps.Items)
+	}
+
 	if err == nil {
 		var jobs batchv1.JobList
 		err = errors.WithStack(
@@ -115,6 +126,16 @@ func (w *World) populatePatroniEndpoints(endpoints []corev1.Endpoints) {
 	}
 }
 
+// populatePatroniConfigMaps assigns the ConfigMaps that Patroni uses for its
+// DCS when the cluster has spec.patroni.dcs set to ConfigMaps.
+func (w *World) populatePatroniConfigMaps(configmaps []corev1.ConfigMap) {
+	for index, configmap := range configmaps {
+		if configmap.Labels[LabelPatroni] != "" {
+			w.PatroniConfigMaps = append(w.PatroniConfigMaps, &configmaps[index])
+		}
+	}
+}
+
 // populateStatefulSets assigns
 // a) the expected number of replicas -- the number of StatefulSets that have the expected
 // LabelInstance label, minus 1 (for the primary)
@@ -174,8 +195,9 @@ type World struct {
 	ClusterShutdown  bool
 	ReplicasExpected int
 
-	PatroniEndpoints []*corev1.Endpoints
-	Jobs             map[string]*batchv1.Job
+	PatroniConfigMaps []*corev1.ConfigMap
+	PatroniEndpoints  []*corev1.Endpoints
+	Jobs              map[string]*batchv1.Job
 }
 
 func NewWorld() *World {
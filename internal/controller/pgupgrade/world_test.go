@@ -15,6 +15,7 @@
 package pgupgrade
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -24,7 +25,9 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
@@ -102,6 +105,73 @@ func TestPopulatePatroniEndpoint(t *testing.T) {
 	})
 }
 
+func TestPopulatePatroniConfigMaps(t *testing.T) {
+	configmaps := []corev1.ConfigMap{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					LabelPatroni: "west",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					"different-label": "north",
+				},
+			},
+		},
+	}
+
+	world := NewWorld()
+	world.populatePatroniConfigMaps(configmaps)
+
+	// Only the first has the correct label.
+	assert.DeepEqual(t, world.PatroniConfigMaps, []*corev1.ConfigMap{
+		&configmaps[0],
+	})
+}
+
+func TestObserveWorldPatroniConfigMaps(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := v1beta1.NewPostgresCluster()
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+
+	upgrade := &v1beta1.PGUpgrade{}
+	upgrade.Namespace, upgrade.Name = "ns1", "upgrade"
+	upgrade.Spec.PostgresClusterName = "hippo"
+
+	configmap := func(name string, labels map[string]string) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{}
+		cm.Namespace, cm.Name, cm.Labels = "ns1", name, labels
+		return cm
+	}
+
+	// Patroni keeps the system identifier in an annotation of its "config"
+	// ConfigMap when using ConfigMaps for DCS.
+	dcs := configmap("hippo-ha-config", map[string]string{
+		LabelCluster: "hippo", LabelPatroni: "hippo-ha",
+	})
+	dcs.Annotations = map[string]string{"initialize": "7140000000000000000"}
+
+	r := &PGUpgradeReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		cluster, dcs,
+		configmap("hippo-config", map[string]string{LabelCluster: "hippo"}),
+		configmap("other-ha-config", map[string]string{
+			LabelCluster: "other", LabelPatroni: "other-ha",
+		}),
+	).Build()}
+
+	world, err := r.observeWorld(ctx, upgrade)
+	assert.NilError(t, err)
+	assert.Equal(t, len(world.PatroniConfigMaps), 1)
+	assert.Equal(t, world.PatroniConfigMaps[0].Name, "hippo-ha-config")
+	assert.Equal(t, len(world.PatroniEndpoints), 0)
+}
+
 func TestPopulateShutdown(t *testing.T) {
 	t.Run("NoCluster", func(t *testing.T) {
 		world := NewWorld()
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/pkg/errors"
@@ -27,6 +28,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -116,6 +118,74 @@ func (r *Reconciler) handleServiceError(
 		}
 	}
 
+	// Service.Spec.SessionAffinityConfig is defaulted for ClientIP and must be
+	// cleared for None. When all the errors are about "sessionAffinityConfig",
+	// run a json-patch on the apply-patch to set it to null.
+	if service.Spec.SessionAffinity != corev1.ServiceAffinityClientIP &&
+		apierrors.IsInvalid(err) && status.Details != nil && len(status.Details.Causes) > 0 {
+		cleared := true
+		for _, cause := range status.Details.Causes {
+			cleared = cleared && strings.HasPrefix(cause.Field, "spec.sessionAffinityConfig")
+		}
+
+		if cleared {
+			add := json.RawMessage(`"add"`)
+			null := json.RawMessage(`null`)
+			path := json.RawMessage(`"/spec/sessionAffinityConfig"`)
+			patch := jsonpatch.Patch{{"op": &add, "value": &null, "path": &path}}
+
+			apply, err = patch.Apply(apply)
+
+			// Send the apply-patch with force=true.
+			if err == nil {
+				patch := client.RawPatch(client.Apply.Type(), apply)
+				err = r.patch(ctx, service, patch, client.ForceOwnership)
+			}
+		}
+	}
+
+	// Service.Spec.LoadBalancerClass cannot change once set. When all the
+	// errors are about "loadBalancerClass", delete the Service and send the
+	// apply-patch again to create it with the new class.
+	// - https://docs.k8s.io/concepts/services-networking/service/#load-balancer-class
+	if service.Spec.Type == corev1.ServiceTypeLoadBalancer &&
+		apierrors.IsInvalid(err) && status.Details != nil && len(status.Details.Causes) > 0 {
+		immutable := true
+		for _, cause := range status.Details.Causes {
+			immutable = immutable && cause.Field == "spec.loadBalancerClass"
+		}
+
+		if immutable {
+			existing := &corev1.Service{}
+			err = errors.WithStack(
+				r.Client.Get(ctx, client.ObjectKeyFromObject(service), existing))
+
+			// Delete only the Service that has the same controller as the intent.
+			var have, want types.UID
+			if ref := metav1.GetControllerOfNoCopy(existing); ref != nil {
+				have = ref.UID
+			}
+			if ref := metav1.GetControllerOfNoCopy(service); ref != nil {
+				want = ref.UID
+			}
+
+			if err == nil && have == want {
+				uid := existing.GetUID()
+				version := existing.GetResourceVersion()
+				exactly := client.Preconditions{UID: &uid, ResourceVersion: &version}
+
+				err = errors.WithStack(client.IgnoreNotFound(
+					r.Client.Delete(ctx, existing, exactly)))
+
+				// Send the apply-patch with force=true.
+				if err == nil {
+					patch := client.RawPatch(client.Apply.Type(), apply)
+					err = r.patch(ctx, service, patch, client.ForceOwnership)
+				}
+			}
+		}
+	}
+
 	return err
 }
 
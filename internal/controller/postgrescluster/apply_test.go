@@ -38,6 +38,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 )
 
@@ -354,4 +355,39 @@ func TestServerSideApply(t *testing.T) {
 		assert.Equal(t, again.Spec.ClusterIP, before.Spec.ClusterIP,
 			"expected to keep the same ClusterIP")
 	})
+
+	t.Run("ServiceLoadBalancerClass", func(t *testing.T) {
+		if serverVersion.LessThan(version.MustParseGeneric("1.22")) {
+			t.Skip("requires the ServiceLoadBalancerClass feature of Kubernetes 1.22")
+		}
+
+		reconciler := Reconciler{Client: cc, Owner: client.FieldOwner(t.Name())}
+
+		intent := new(corev1.Service)
+		intent.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+		intent.Namespace, intent.Name = ns.Name, "load-balancer-class"
+		intent.Spec.Type = corev1.ServiceTypeLoadBalancer
+		intent.Spec.LoadBalancerClass = initialize.String("example.com/one")
+		intent.Spec.Ports = []corev1.ServicePort{
+			{Name: "one", Port: 9999, Protocol: corev1.ProtocolTCP},
+		}
+
+		// Create the Service.
+		before := intent.DeepCopy()
+		assert.NilError(t, reconciler.apply(ctx, before))
+
+		// Change the class.
+		intent.Spec.LoadBalancerClass = initialize.String("example.com/two")
+
+		// client.Apply cannot change it.
+		after := intent.DeepCopy()
+		err := cc.Patch(ctx, after, client.Apply, client.ForceOwnership, reconciler.Owner)
+		assert.ErrorContains(t, err, "may not change once set")
+
+		// Our apply method recreates the Service.
+		again := intent.DeepCopy()
+		assert.NilError(t, reconciler.apply(ctx, again))
+		assert.DeepEqual(t, again.Spec.LoadBalancerClass, intent.Spec.LoadBalancerClass)
+		assert.Assert(t, again.UID != before.UID, "expected a new Service")
+	})
 }
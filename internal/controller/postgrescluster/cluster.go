@@ -17,6 +17,7 @@ package postgrescluster
 
 import (
 	"context"
+	"fmt"
 	"io"
 
 	"github.com/pkg/errors"
@@ -24,6 +25,8 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/patroni"
@@ -99,7 +102,8 @@ func (r *Reconciler) reconcileClusterPodService(
 }
 
 // generateClusterPrimaryService returns a v1.Service and v1.Endpoints that
-// resolve to the PostgreSQL primary instance.
+// resolve to the PostgreSQL primary instance. The Endpoints are nil when
+// Kubernetes should manage them.
 func (r *Reconciler) generateClusterPrimaryService(
 	cluster *v1beta1.PostgresCluster, leader *corev1.Service,
 ) (*corev1.Service, *corev1.Endpoints, error) {
@@ -109,7 +113,8 @@ func (r *Reconciler) generateClusterPrimaryService(
 	//
 	// To stay free from those constraints, our primary Service resolves to the
 	// ClusterIP of the Service created in Reconciler.reconcilePatroniLeaderLease
-	// when Patroni is using Endpoints.
+	// when Patroni is using Endpoints. When Patroni is using ConfigMaps, our
+	// primary Service selects the leader Pod by its label.
 
 	service := &corev1.Service{ObjectMeta: naming.ClusterPrimaryService(cluster)}
 	service.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
@@ -131,14 +136,8 @@ func (r *Reconciler) generateClusterPrimaryService(
 	service.ObjectMeta.DeepCopyInto(&endpoints.ObjectMeta)
 	endpoints.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Endpoints"))
 
-	if leader == nil {
-		// TODO(cbandy): We need to build a different kind of Service here.
-		return nil, nil, errors.New("Patroni DCS other than Kubernetes Endpoints is not implemented")
-	}
-
-	// Allocate no IP address (headless) and manage the Endpoints ourselves.
+	// Allocate no IP address (headless).
 	// - https://docs.k8s.io/concepts/services-networking/service/#headless-services
-	// - https://docs.k8s.io/concepts/services-networking/service/#services-without-selectors
 	service.Spec.ClusterIP = corev1.ClusterIPNone
 	service.Spec.Selector = nil
 
@@ -149,6 +148,23 @@ func (r *Reconciler) generateClusterPrimaryService(
 		TargetPort: intstr.FromString(naming.PortPostgreSQL),
 	}}
 
+	// Let Kubernetes manage the Endpoints by selecting the Pod that Patroni
+	// labels as leader.
+	if patroni.UsesConfigMaps(cluster) {
+		service.Spec.Selector = map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RolePatroniLeader,
+		}
+		return service, nil, err
+	}
+
+	if leader == nil {
+		return nil, nil, errors.New("Patroni DCS using Kubernetes Endpoints requires a leader Service")
+	}
+
+	// Manage the Endpoints ourselves.
+	// - https://docs.k8s.io/concepts/services-networking/service/#services-without-selectors
+
 	// Resolve to the ClusterIP for which Patroni has configured the Endpoints.
 	endpoints.Subsets = []corev1.EndpointSubset{{
 		Addresses: []corev1.EndpointAddress{{IP: leader.Spec.ClusterIP}},
@@ -185,7 +201,7 @@ func (r *Reconciler) reconcileClusterPrimaryService(
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, service))
 	}
-	if err == nil {
+	if err == nil && endpoints != nil {
 		err = errors.WithStack(r.apply(ctx, endpoints))
 	}
 	return service, err
@@ -202,7 +218,17 @@ func (r *Reconciler) generateClusterReplicaService(
 	service.Annotations = naming.Merge(
 		cluster.Spec.Metadata.GetAnnotationsOrNil())
 	service.Labels = naming.Merge(
-		cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.Metadata.GetLabelsOrNil())
+
+	if spec := cluster.Spec.ReplicaService; spec != nil {
+		service.Annotations = naming.Merge(service.Annotations,
+			spec.Metadata.GetAnnotationsOrNil())
+		service.Labels = naming.Merge(service.Labels,
+			spec.Metadata.GetLabelsOrNil())
+	}
+
+	// add our labels last so they aren't overwritten
+	service.Labels = naming.Merge(service.Labels,
 		map[string]string{
 			naming.LabelCluster: cluster.Name,
 			naming.LabelRole:    naming.RoleReplica,
@@ -211,25 +237,146 @@ func (r *Reconciler) generateClusterReplicaService(
 	// Allocate an IP address and let Kubernetes manage the Endpoints by
 	// selecting Pods with the Patroni replica role.
 	// - https://docs.k8s.io/concepts/services-networking/service/#defining-a-service
-	service.Spec.Type = corev1.ServiceTypeClusterIP
 	service.Spec.Selector = map[string]string{
 		naming.LabelCluster: cluster.Name,
 		naming.LabelRole:    naming.RolePatroniReplica,
 	}
 
+	// Leave out instances that Patroni should not load balance. Selectors
+	// cannot exclude a label, so select those that are load balanced instead.
+	if excludesLoadBalance(cluster) {
+		service.Spec.Selector[naming.LabelLoadBalance] = "true"
+	}
+
+	if err := r.setReplicaServiceType(cluster, cluster.Spec.ReplicaService, service); err != nil {
+		return nil, err
+	}
+
+	err := errors.WithStack(r.setControllerReference(cluster, service))
+	return service, err
+}
+
+// excludesLoadBalance returns true when some instance set of cluster is excluded
+// from load balancing. See [loadBalanced].
+func excludesLoadBalance(cluster *v1beta1.PostgresCluster) bool {
+	for i := range cluster.Spec.InstanceSets {
+		if !loadBalanced(&cluster.Spec.InstanceSets[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadBalanced returns false when instances of set are excluded from the
+// replica Service of the cluster, either by their Patroni tags or because
+// they are delayed replicas.
+func loadBalanced(set *v1beta1.PostgresInstanceSetSpec) bool {
+	return set.ReplicationDelaySeconds == nil &&
+		(set.PatroniTags == nil || !set.PatroniTags.NoLoadBalance)
+}
+
+// generateInstanceSetReplicaService returns a v1.Service that exposes the
+// PostgreSQL replica instances of set.
+func (r *Reconciler) generateInstanceSetReplicaService(
+	cluster *v1beta1.PostgresCluster, set *v1beta1.PostgresInstanceSetSpec,
+) (*corev1.Service, error) {
+	service := &corev1.Service{ObjectMeta: naming.InstanceSetReplicaService(cluster, set)}
+	service.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+
+	service.Annotations = naming.Merge(
+		cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		set.Metadata.GetAnnotationsOrNil(),
+		set.ReplicaService.Metadata.GetAnnotationsOrNil())
+	service.Labels = naming.Merge(
+		cluster.Spec.Metadata.GetLabelsOrNil(),
+		set.Metadata.GetLabelsOrNil(),
+		set.ReplicaService.Metadata.GetLabelsOrNil(),
+		map[string]string{
+			naming.LabelCluster:     cluster.Name,
+			naming.LabelInstanceSet: set.Name,
+			naming.LabelRole:        naming.RoleReplica,
+		})
+
+	// Select Pods of this set with the Patroni replica role.
+	service.Spec.Selector = map[string]string{
+		naming.LabelCluster:     cluster.Name,
+		naming.LabelInstanceSet: set.Name,
+		naming.LabelRole:        naming.RolePatroniReplica,
+	}
+
+	if err := r.setReplicaServiceType(cluster, set.ReplicaService, service); err != nil {
+		return nil, err
+	}
+
+	err := errors.WithStack(r.setControllerReference(cluster, service))
+	return service, err
+}
+
+// setReplicaServiceType sets the type and port of service according to spec.
+func (r *Reconciler) setReplicaServiceType(
+	cluster *v1beta1.PostgresCluster, spec *v1beta1.ServiceSpec, service *corev1.Service,
+) error {
 	// The TargetPort must be the name (not the number) of the PostgreSQL
 	// ContainerPort. This name allows the port number to differ between Pods,
 	// which can happen during a rolling update.
-	service.Spec.Ports = []corev1.ServicePort{{
+	servicePort := corev1.ServicePort{
 		Name:       naming.PortPostgreSQL,
 		Port:       *cluster.Spec.Port,
 		Protocol:   corev1.ProtocolTCP,
 		TargetPort: intstr.FromString(naming.PortPostgreSQL),
-	}}
+	}
 
-	err := errors.WithStack(r.setControllerReference(cluster, service))
+	if spec == nil {
+		service.Spec.Type = corev1.ServiceTypeClusterIP
+	} else {
+		service.Spec.Type = corev1.ServiceType(spec.Type)
+		if spec.NodePort != nil {
+			if service.Spec.Type == corev1.ServiceTypeClusterIP {
+				// The NodePort can only be set when the Service type is NodePort or
+				// LoadBalancer. However, due to a known issue prior to Kubernetes
+				// 1.20, we clear these errors during our apply. To preserve the
+				// appropriate behavior, we log an Event and return an error.
+				// TODO(tjmoore4): Once Validation Rules are available, this check
+				// and event could potentially be removed in favor of that validation
+				r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "MisconfiguredClusterIP",
+					"NodePort cannot be set with type ClusterIP on Service %q", service.Name)
+				return fmt.Errorf("NodePort cannot be set with type ClusterIP on Service %q", service.Name)
+			}
+			servicePort.NodePort = *spec.NodePort
+		}
+	}
+	service.Spec.Ports = []corev1.ServicePort{servicePort}
+	setServiceOptions(service, spec)
 
-	return service, err
+	return nil
+}
+
+// setServiceOptions copies the traffic, IP family, load balancer, and session
+// affinity fields of spec to service. The type and ports are set by callers.
+func setServiceOptions(service *corev1.Service, spec *v1beta1.ServiceSpec) {
+	if spec == nil {
+		return
+	}
+
+	if spec.ExternalTrafficPolicy != nil {
+		service.Spec.ExternalTrafficPolicy = *spec.ExternalTrafficPolicy
+	}
+
+	service.Spec.IPFamilies = spec.IPFamilies
+	service.Spec.IPFamilyPolicy = spec.IPFamilyPolicy
+	service.Spec.LoadBalancerClass = spec.LoadBalancerClass
+	service.Spec.LoadBalancerSourceRanges = spec.LoadBalancerSourceRanges
+
+	if spec.SessionAffinity != nil {
+		service.Spec.SessionAffinity = *spec.SessionAffinity
+	}
+	if spec.SessionAffinityTimeoutSeconds != nil {
+		service.Spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{
+			ClientIP: &corev1.ClientIPConfig{
+				TimeoutSeconds: spec.SessionAffinityTimeoutSeconds,
+			},
+		}
+	}
 }
 
 // +kubebuilder:rbac:groups="",resources="services",verbs={create,patch}
@@ -247,6 +394,52 @@ func (r *Reconciler) reconcileClusterReplicaService(
 	return err
 }
 
+// +kubebuilder:rbac:groups="",resources="services",verbs={list}
+// +kubebuilder:rbac:groups="",resources="services",verbs={create,delete,patch}
+
+// reconcileInstanceSetReplicaServices writes the Services that expose the
+// replica instances of each instance set that has one. It deletes those of
+// instance sets that no longer do.
+func (r *Reconciler) reconcileInstanceSetReplicaServices(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	existing := &corev1.ServiceList{}
+	selector := naming.ClusterInstanceSets(cluster.Name)
+	selector.MatchLabels[naming.LabelRole] = naming.RoleReplica
+
+	labels, err := naming.AsSelector(selector)
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.List(ctx, existing,
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: labels},
+			))
+	}
+
+	intended := sets.NewString()
+	for i := range cluster.Spec.InstanceSets {
+		set := &cluster.Spec.InstanceSets[i]
+		if err != nil || set.ReplicaService == nil {
+			continue
+		}
+
+		var service *corev1.Service
+		service, err = r.generateInstanceSetReplicaService(cluster, set)
+		if err == nil {
+			err = errors.WithStack(r.apply(ctx, service))
+		}
+		intended.Insert(naming.InstanceSetReplicaService(cluster, set).Name)
+	}
+
+	for i := range existing.Items {
+		if err == nil && !intended.Has(existing.Items[i].Name) {
+			err = client.IgnoreNotFound(r.deleteControlled(ctx, cluster, &existing.Items[i]))
+		}
+	}
+
+	return err
+}
+
 // reconcileDataSource is responsible for reconciling the data source for a PostgreSQL cluster.
 // This involves ensuring the PostgreSQL data directory for the cluster is properly populated
 // prior to bootstrapping the cluster, specifically according to any data source configured in the
@@ -288,6 +481,23 @@ func (r *Reconciler) reconcileDataSource(ctx context.Context,
 		cluster.Spec.Backups.PGBackRest.Restore != nil &&
 		*cluster.Spec.Backups.PGBackRest.Restore.Enabled
 
+	// A PostgresRestore requests an in-place restore when the annotation does not.
+	// Check it against the cluster and its backups before shutting anything down.
+	var restore *v1beta1.PostgresRestore
+	if !restoreInPlaceRequested {
+		var proceed bool
+		restore, err = r.observePostgresRestore(ctx, cluster)
+		if err == nil && restore != nil {
+			proceed, err = r.reconcilePostgresRestore(ctx, cluster, restore)
+		}
+		if err != nil {
+			return false, err
+		}
+		if !proceed {
+			restore = nil
+		}
+	}
+
 	// Set the proper data source for the restore based on whether we're initializing the PG
 	// data directory (e.g. for a new PostgreSQL cluster), or restoring an existing cluster
 	// in place (and therefore recreating the data directory).  If the user hasn't requested
@@ -297,6 +507,9 @@ func (r *Reconciler) reconcileDataSource(ctx context.Context,
 	switch {
 	case restoreInPlaceRequested:
 		dataSource = cluster.Spec.Backups.PGBackRest.Restore.PostgresClusterDataSource
+	case restore != nil:
+		restoreID = postgresRestoreID(restore)
+		dataSource = postgresRestoreDataSource(restore)
 	case postgresDataInitRequested:
 		// there is no restore annotation when initializing a new cluster, so we create a
 		// restore ID for bootstrap
@@ -611,7 +611,7 @@ func TestGenerateClusterPrimaryService(t *testing.T) {
 	leader.Spec.ClusterIP = "1.9.8.3"
 
 	_, _, err := reconciler.generateClusterPrimaryService(cluster, nil)
-	assert.ErrorContains(t, err, "not implemented")
+	assert.ErrorContains(t, err, "requires a leader Service")
 
 	alwaysExpect := func(t testing.TB, service *corev1.Service, endpoints *corev1.Endpoints) {
 		assert.Assert(t, marshalMatches(service.TypeMeta, `
@@ -694,6 +694,26 @@ subsets:
 		assert.Equal(t, len(service.Spec.ExternalIPs), 0)
 		assert.Equal(t, service.Spec.ExternalName, "")
 	})
+
+	t.Run("ConfigMaps", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.Patroni.DCS = v1beta1.PatroniDCSConfigMaps
+
+		service, endpoints, err := reconciler.generateClusterPrimaryService(cluster, leader)
+		assert.NilError(t, err)
+		assert.Assert(t, endpoints == nil, "expected Kubernetes to manage Endpoints")
+		assert.Assert(t, marshalMatches(service.Spec, `
+clusterIP: None
+ports:
+- name: postgres
+  port: 2600
+  protocol: TCP
+  targetPort: postgres
+selector:
+  postgres-operator.crunchydata.com/cluster: pg5
+  postgres-operator.crunchydata.com/role: master
+		`))
+	})
 }
 
 func TestReconcileClusterPrimaryService(t *testing.T) {
@@ -708,7 +728,7 @@ func TestReconcileClusterPrimaryService(t *testing.T) {
 	assert.NilError(t, cc.Create(ctx, cluster))
 
 	_, err := reconciler.reconcileClusterPrimaryService(ctx, cluster, nil)
-	assert.ErrorContains(t, err, "not implemented")
+	assert.ErrorContains(t, err, "requires a leader Service")
 
 	leader := &corev1.Service{}
 	leader.Spec.ClusterIP = "192.0.2.10"
@@ -722,7 +742,10 @@ func TestGenerateClusterReplicaServiceIntent(t *testing.T) {
 	_, cc := setupKubernetes(t)
 	require.ParallelCapacity(t, 0)
 
-	reconciler := &Reconciler{Client: cc}
+	reconciler := &Reconciler{
+		Client:   cc,
+		Recorder: new(record.FakeRecorder),
+	}
 
 	cluster := &v1beta1.PostgresCluster{}
 	cluster.Namespace = "ns1"
@@ -788,7 +811,228 @@ postgres-operator.crunchydata.com/role: replica
 		// Labels not in the selector.
 		assert.Assert(t, marshalMatches(service.Spec.Selector, `
 postgres-operator.crunchydata.com/cluster: pg2
+postgres-operator.crunchydata.com/role: replica
+		`))
+
+		// Add metadata to the replica service.
+		cluster.Spec.ReplicaService = &v1beta1.ServiceSpec{
+			Metadata: &v1beta1.Metadata{
+				Annotations: map[string]string{"more": "notes"},
+				Labels: map[string]string{"also": "happy",
+					"postgres-operator.crunchydata.com/role": "wrong"},
+			},
+		}
+
+		service, err = reconciler.generateClusterReplicaService(cluster)
+		assert.NilError(t, err)
+
+		assert.Assert(t, marshalMatches(service.ObjectMeta.Annotations, `
+more: notes
+some: note
+		`))
+		assert.Assert(t, marshalMatches(service.ObjectMeta.Labels, `
+also: happy
+happy: label
+postgres-operator.crunchydata.com/cluster: pg2
 postgres-operator.crunchydata.com/role: replica
 		`))
 	})
+
+	t.Run("Types", func(t *testing.T) {
+		for _, serviceType := range []string{"ClusterIP", "NodePort", "LoadBalancer"} {
+			cluster := cluster.DeepCopy()
+			cluster.Spec.ReplicaService = &v1beta1.ServiceSpec{Type: serviceType}
+
+			service, err := reconciler.generateClusterReplicaService(cluster)
+			assert.NilError(t, err)
+			assert.Equal(t, string(service.Spec.Type), serviceType)
+		}
+	})
+
+	t.Run("NodePort", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.ReplicaService = &v1beta1.ServiceSpec{
+			Type: "NodePort", NodePort: initialize.Int32(32001),
+		}
+
+		service, err := reconciler.generateClusterReplicaService(cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, marshalMatches(service.Spec.Ports, `
+- name: postgres
+  nodePort: 32001
+  port: 9876
+  protocol: TCP
+  targetPort: postgres
+		`))
+
+		cluster.Spec.ReplicaService.Type = "ClusterIP"
+		service, err = reconciler.generateClusterReplicaService(cluster)
+		assert.ErrorContains(t, err, `NodePort cannot be set with type ClusterIP on Service "pg2-replicas"`)
+		assert.Assert(t, service == nil)
+	})
+
+	t.Run("NoLoadBalance", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+			{Name: "one"},
+			{Name: "two", PatroniTags: &v1beta1.PatroniTags{NoLoadBalance: true}},
+		}
+
+		service, err := reconciler.generateClusterReplicaService(cluster)
+		assert.NilError(t, err)
+		assert.Assert(t, marshalMatches(service.Spec.Selector, `
+postgres-operator.crunchydata.com/cluster: pg2
+postgres-operator.crunchydata.com/load-balance: "true"
+postgres-operator.crunchydata.com/role: replica
+		`))
+	})
+}
+
+func TestGenerateInstanceSetReplicaService(t *testing.T) {
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	reconciler := &Reconciler{
+		Client:   cc,
+		Recorder: new(record.FakeRecorder),
+	}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace = "ns1"
+	cluster.Name = "pg2"
+	cluster.Spec.Port = initialize.Int32(9876)
+	cluster.Spec.Metadata = &v1beta1.Metadata{
+		Annotations: map[string]string{"some": "note"},
+	}
+
+	set := &v1beta1.PostgresInstanceSetSpec{Name: "analytics"}
+	set.Metadata = &v1beta1.Metadata{Labels: map[string]string{"set": "label"}}
+	set.ReplicaService = &v1beta1.ServiceSpec{
+		Type:     "LoadBalancer",
+		Metadata: &v1beta1.Metadata{Labels: map[string]string{"service": "label"}},
+	}
+
+	service, err := reconciler.generateInstanceSetReplicaService(cluster, set)
+	assert.NilError(t, err)
+
+	assert.Assert(t, marshalMatches(service.ObjectMeta, `
+annotations:
+  some: note
+creationTimestamp: null
+labels:
+  postgres-operator.crunchydata.com/cluster: pg2
+  postgres-operator.crunchydata.com/instance-set: analytics
+  postgres-operator.crunchydata.com/role: replica
+  service: label
+  set: label
+name: pg2-set-analytics-replicas
+namespace: ns1
+ownerReferences:
+- apiVersion: postgres-operator.crunchydata.com/v1beta1
+  blockOwnerDeletion: true
+  controller: true
+  kind: PostgresCluster
+  name: pg2
+  uid: ""
+	`))
+	assert.Assert(t, marshalMatches(service.Spec, `
+ports:
+- name: postgres
+  port: 9876
+  protocol: TCP
+  targetPort: postgres
+selector:
+  postgres-operator.crunchydata.com/cluster: pg2
+  postgres-operator.crunchydata.com/instance-set: analytics
+  postgres-operator.crunchydata.com/role: replica
+type: LoadBalancer
+	`))
+}
+
+func TestSetServiceOptions(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		service := new(corev1.Service)
+		setServiceOptions(service, nil)
+		assert.DeepEqual(t, service, new(corev1.Service))
+	})
+
+	t.Run("Zero", func(t *testing.T) {
+		service := new(corev1.Service)
+		setServiceOptions(service, new(v1beta1.ServiceSpec))
+		assert.DeepEqual(t, service, new(corev1.Service))
+	})
+
+	t.Run("Everything", func(t *testing.T) {
+		local := corev1.ServiceExternalTrafficPolicyTypeLocal
+		dual := corev1.IPFamilyPolicyRequireDualStack
+		sticky := corev1.ServiceAffinityClientIP
+
+		service := new(corev1.Service)
+		service.Spec.Type = corev1.ServiceTypeLoadBalancer
+
+		setServiceOptions(service, &v1beta1.ServiceSpec{
+			ExternalTrafficPolicy:         &local,
+			IPFamilies:                    []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol},
+			IPFamilyPolicy:                &dual,
+			LoadBalancerClass:             initialize.String("example.com/internal"),
+			LoadBalancerSourceRanges:      []string{"10.0.0.0/8", "192.168.0.0/16"},
+			SessionAffinity:               &sticky,
+			SessionAffinityTimeoutSeconds: initialize.Int32(300),
+		})
+
+		assert.Assert(t, marshalMatches(service.Spec, `
+externalTrafficPolicy: Local
+ipFamilies:
+- IPv6
+- IPv4
+ipFamilyPolicy: RequireDualStack
+loadBalancerClass: example.com/internal
+loadBalancerSourceRanges:
+- 10.0.0.0/8
+- 192.168.0.0/16
+sessionAffinity: ClientIP
+sessionAffinityConfig:
+  clientIP:
+    timeoutSeconds: 300
+type: LoadBalancer
+		`))
+	})
+}
+
+func TestReconcileInstanceSetReplicaServices(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 1)
+
+	reconciler := &Reconciler{Client: cc, Owner: client.FieldOwner(t.Name())}
+
+	cluster := testCluster()
+	cluster.Namespace = setupNamespace(t, cc).Name
+	cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+		v1beta1.PostgresInstanceSetSpec{
+			Name:                "analytics",
+			DataVolumeClaimSpec: testVolumeClaimSpec(),
+			ReplicaService:      &v1beta1.ServiceSpec{Type: "ClusterIP"},
+		})
+	assert.NilError(t, cc.Create(ctx, cluster))
+
+	list := func() []string {
+		services := &corev1.ServiceList{}
+		assert.NilError(t, cc.List(ctx, services, client.InNamespace(cluster.Namespace)))
+
+		names := []string{}
+		for _, service := range services.Items {
+			names = append(names, service.Name)
+		}
+		return names
+	}
+
+	assert.NilError(t, reconciler.reconcileInstanceSetReplicaServices(ctx, cluster))
+	assert.DeepEqual(t, list(), []string{"hippo-set-analytics-replicas"})
+
+	// The Service is removed along with the spec.
+	cluster.Spec.InstanceSets[1].ReplicaService = nil
+
+	assert.NilError(t, reconciler.reconcileInstanceSetReplicaServices(ctx, cluster))
+	assert.DeepEqual(t, list(), []string{})
 }
@@ -21,6 +21,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"sync"
 
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/trace"
@@ -69,6 +70,10 @@ type Reconciler struct {
 		namespace, pod, container string,
 		stdin io.Reader, stdout, stderr io.Writer, command ...string,
 	) error
+
+	// verifiedPasswords remembers passwords that match their verifiers.
+	// See [Reconciler.verifyPostgresUserPassword].
+	verifiedPasswords sync.Map
 }
 
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
@@ -142,9 +147,8 @@ func (r *Reconciler) Reconcile(
 		return *result, nil
 	}
 
-	// Perform initial validation on a cluster
-	// TODO: Move this to a defaulting (mutating admission) webhook
-	// to leverage regular validation.
+	// Perform initial validation on a cluster. The validating admission webhook
+	// rejects this, too, but it is optional and clusters may predate it.
 	if cluster.Spec.Standby != nil &&
 		cluster.Spec.Standby.Enabled &&
 		cluster.Spec.Standby.Host == "" &&
@@ -210,12 +214,33 @@ func (r *Reconciler) Reconcile(
 	pgHBAs := postgres.NewHBAs()
 	pgmonitor.PostgreSQLHBAs(cluster, &pgHBAs)
 	pgbouncer.PostgreSQL(cluster, &pgHBAs)
+	postgres.UserHBAs(cluster, &pgHBAs)
+	postgres.AuthenticationHBAs(cluster, &pgHBAs)
 
 	pgParameters := postgres.NewParameters()
 	pgaudit.PostgreSQLParameters(&pgParameters)
 	pgbackrest.PostgreSQL(cluster, &pgParameters)
 	pgmonitor.PostgreSQLParameters(cluster, &pgParameters)
 
+	// Parameters that are not in the catalog and those with invalid values
+	// are skipped. Report both.
+	problems := postgres.ConfigParameters(cluster, &pgParameters)
+	for i := range cluster.Spec.InstanceSets {
+		_, errs := postgres.InstanceConfigParameters(
+			cluster, &cluster.Spec.InstanceSets[i], pgParameters)
+		problems = append(problems, errs...)
+	}
+	r.setParametersValidCondition(cluster, problems)
+
+	// Tuned parameters are defaults, so anything in the spec takes precedence.
+	cluster.Status.AutoTunedParameters = nil
+	if tuned := postgres.AutoTuneParameters(cluster, pgParameters).AsMap(); len(tuned) > 0 {
+		for name, value := range tuned {
+			pgParameters.Default.Add(name, value)
+		}
+		cluster.Status.AutoTunedParameters = tuned
+	}
+
 	if err == nil {
 		rootCA, err = r.reconcileRootCertificate(ctx, cluster)
 	}
@@ -242,6 +267,9 @@ func (r *Reconciler) Reconcile(
 	if err == nil {
 		instances, err = r.observeInstances(ctx, cluster)
 	}
+	if err == nil {
+		err = r.reconcilePatroniDCS(ctx, cluster, instances)
+	}
 	if err == nil {
 		err = updateResult(r.reconcilePatroniStatus(ctx, cluster, instances))
 	}
@@ -293,6 +321,9 @@ func (r *Reconciler) Reconcile(
 	if err == nil {
 		err = r.reconcileClusterReplicaService(ctx, cluster)
 	}
+	if err == nil {
+		err = r.reconcileInstanceSetReplicaServices(ctx, cluster)
+	}
 	if err == nil {
 		primaryCertificate, err = r.reconcileClusterCertificate(ctx, rootCA, cluster, primaryService)
 	}
@@ -312,14 +343,15 @@ func (r *Reconciler) Reconcile(
 		err = r.reconcileInstanceSets(
 			ctx, cluster, clusterConfigMap, clusterReplicationSecret,
 			rootCA, clusterPodService, instanceServiceAccount, instances,
-			patroniLeaderService, primaryCertificate, clusterVolumes, exporterWebConfig)
+			patroniLeaderService, primaryCertificate, clusterVolumes, exporterWebConfig,
+			pgParameters)
 	}
 
 	if err == nil {
 		err = r.reconcilePostgresDatabases(ctx, cluster, instances)
 	}
 	if err == nil {
-		err = r.reconcilePostgresUsers(ctx, cluster, instances)
+		err = updateResult(r.reconcilePostgresUsers(ctx, cluster, instances, rootCA))
 	}
 
 	if err == nil {
@@ -342,6 +374,12 @@ func (r *Reconciler) Reconcile(
 		// Pods takes precedence.
 		err = r.handlePatroniRestarts(ctx, cluster, instances)
 	}
+	if err == nil {
+		err = updateResult(r.reconcilePatroniMembers(ctx, cluster, instances))
+	}
+	if err == nil {
+		observeSynchronousStandbys(cluster)
+	}
 
 	// at this point everything reconciled successfully, and we can update the
 	// observedGeneration
@@ -416,6 +454,8 @@ func (r *Reconciler) setOwnerReference(
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch
 // +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch
 // +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=pgbackrestbackups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresrestores,verbs=get;list;watch
 
 // SetupWithManager adds the PostgresCluster controller to the provided runtime manager
 func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
@@ -442,6 +482,14 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 		opts.MaxConcurrentReconciles = 2
 	}
 
+	// Index clusters by the Secrets their users read passwords from so that
+	// watchSecrets does not have to look at every cluster in a namespace.
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1beta1.PostgresCluster{}, indexUserPasswordSecrets, postgresUserPasswordSecrets,
+	); err != nil {
+		return err
+	}
+
 	return builder.ControllerManagedBy(mgr).
 		For(&v1beta1.PostgresCluster{}).
 		WithOptions(opts).
@@ -459,6 +507,9 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 		Owns(&batchv1.CronJob{}).
 		Owns(&policyv1.PodDisruptionBudget{}).
 		Watches(&source.Kind{Type: &corev1.Pod{}}, r.watchPods()).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, r.watchSecrets()).
+		Watches(&source.Kind{Type: &v1beta1.PGBackRestBackup{}}, r.watchPGBackRestBackups()).
+		Watches(&source.Kind{Type: &v1beta1.PostgresRestore{}}, r.watchPostgresRestores()).
 		Watches(&source.Kind{Type: &appsv1.StatefulSet{}},
 			r.controllerRefHandlerFuncs()). // watch all StatefulSets
 		Complete(r)
@@ -96,6 +96,14 @@ func (r *Reconciler) handleDelete(
 		return nil, err
 	}
 
+	// Nothing can expire backups once the cluster is gone.
+	if err := r.releasePGBackRestBackups(ctx, cluster); err != nil {
+		return nil, err
+	}
+
+	// Nothing checks these passwords once the cluster is gone.
+	r.forgetPostgresUserPasswords(cluster)
+
 	// Our finalizer logic is finished; remove our finalizer.
 	// The Finalizers field is shared by multiple controllers, but the
 	// server-side merge strategy does not work on our custom resource due to a
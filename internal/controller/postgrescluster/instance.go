@@ -39,6 +39,7 @@ import (
 
 	"github.com/crunchydata/postgres-operator/internal/config"
 	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/kubeapi"
 	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/patroni"
@@ -320,10 +321,18 @@ func (r *Reconciler) observeInstances(
 
 	observed := newObservedInstances(cluster, runners.Items, pods.Items)
 
+	// Keep the members that Patroni last reported for each set.
+	// See [Reconciler.reconcilePatroniMembers].
+	previous := make(map[string]v1beta1.PostgresInstanceSetStatus)
+	for _, set := range cluster.Status.InstanceSets {
+		previous[set.Name] = set
+	}
+
 	// Fill out status sorted by set name.
 	cluster.Status.InstanceSets = cluster.Status.InstanceSets[:0]
 	for _, name := range observed.setNames.List() {
 		status := v1beta1.PostgresInstanceSetStatus{Name: name}
+		status.Members = previous[name].Members
 
 		for _, instance := range observed.bySet[name] {
 			status.Replicas += int32(len(instance.Pods))
@@ -503,16 +512,18 @@ func (r *Reconciler) reconcileInstanceSets(
 	primaryCertificate *corev1.SecretProjection,
 	clusterVolumes []corev1.PersistentVolumeClaim,
 	exporterWebConfig *corev1.ConfigMap,
+	pgParameters postgres.Parameters,
 ) error {
 
 	// Go through the observed instances and check if a primary has been determined.
 	// If the cluster is being shutdown and this instance is the primary, store
 	// the instance name as the startup instance. If the primary can be determined
 	// from the instance and the cluster is not being shutdown, clear any stored
-	// startup instance values.
+	// startup instance values. Instances also shutdown while Patroni changes DCS.
 	for _, instance := range instances.forCluster {
 		if primary, known := instance.IsPrimary(); primary && known {
-			if cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown {
+			if (cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown) ||
+				patroniChangingDCS(cluster) {
 				cluster.Status.StartupInstance = instance.Name
 				cluster.Status.StartupInstanceSet = instance.Spec.Name
 			} else {
@@ -539,7 +550,7 @@ func (r *Reconciler) reconcileInstanceSets(
 			rootCA, clusterPodService, instanceServiceAccount,
 			patroniLeaderService, primaryCertificate,
 			findAvailableInstanceNames(*set, instances, clusterVolumes),
-			numInstancePods, clusterVolumes, exporterWebConfig)
+			numInstancePods, clusterVolumes, exporterWebConfig, pgParameters)
 
 		if err == nil {
 			err = r.reconcileInstanceSetPodDisruptionBudget(ctx, cluster, set)
@@ -563,6 +574,12 @@ func (r *Reconciler) reconcileInstanceSets(
 		return err
 	}
 
+	// Label the Pods that receive traffic from the replica Service.
+	err = r.reconcileInstanceLoadBalance(ctx, cluster, instances)
+	if err != nil {
+		return err
+	}
+
 	// Rollout changes to instances by calling rolloutInstance.
 	err = r.rolloutInstances(ctx, cluster, instances,
 		func(ctx context.Context, instance *Instance) error {
@@ -572,6 +589,39 @@ func (r *Reconciler) reconcileInstanceSets(
 	return err
 }
 
+// +kubebuilder:rbac:groups="",resources="pods",verbs={patch}
+
+// reconcileInstanceLoadBalance labels the Pods that the replica Service of
+// cluster selects when some instance set is excluded from load balancing. See
+// [excludesLoadBalance]. Like the role label of Patroni, the label goes on each
+// Pod rather than its template so that changing it does not roll out instances.
+func (r *Reconciler) reconcileInstanceLoadBalance(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) error {
+	excludes := excludesLoadBalance(cluster)
+
+	var err error
+	for _, instance := range instances.forCluster {
+		want := excludes && instance.Spec != nil && loadBalanced(instance.Spec)
+
+		for _, pod := range instance.Pods {
+			value, has := pod.Labels[naming.LabelLoadBalance]
+
+			patch := kubeapi.NewMergePatch()
+			if want && value != "true" {
+				patch.Add("metadata", "labels", naming.LabelLoadBalance)("true")
+			}
+			if !want && has {
+				patch.Remove("metadata", "labels", naming.LabelLoadBalance)
+			}
+			if err == nil && !patch.IsEmpty() {
+				err = errors.WithStack(client.IgnoreNotFound(r.patch(ctx, pod, patch)))
+			}
+		}
+	}
+	return err
+}
+
 // +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=list
 
 // cleanupPodDisruptionBudgets removes pdbs that do not have an
@@ -714,7 +764,12 @@ func (r *Reconciler) rolloutInstance(
 		ctx, span = r.Tracer.Start(ctx, "patroni-change-primary")
 		defer span.End()
 
-		success, err := patroni.Executor(exec).ChangePrimaryAndWait(ctx, pod.Name, "")
+		api, err := r.patroniAPI(ctx, cluster, pod)
+
+		var success bool
+		if err == nil {
+			success, err = api.ChangePrimaryAndWait(ctx, pod.Name, "")
+		}
 		if err = errors.WithStack(err); err == nil && !success {
 			err = errors.New("unable to switchover")
 		}
@@ -978,6 +1033,7 @@ func (r *Reconciler) scaleUpInstances(
 	numInstancePods int,
 	clusterVolumes []corev1.PersistentVolumeClaim,
 	exporterWebConfig *corev1.ConfigMap,
+	pgParameters postgres.Parameters,
 ) ([]*appsv1.StatefulSet, error) {
 	log := logging.FromContext(ctx)
 
@@ -1014,6 +1070,14 @@ func (r *Reconciler) scaleUpInstances(
 		instances = append(instances, &appsv1.StatefulSet{ObjectMeta: next})
 	}
 
+	var replicateFrom string
+	if set.PatroniTags != nil && set.PatroniTags.ReplicateFrom != "" {
+		replicateFrom = replicateFromMember(observed, set.PatroniTags.ReplicateFrom)
+	}
+
+	// Problems with these parameters are reported by the main reconcile loop.
+	parameters, _ := postgres.InstanceConfigParameters(cluster, set, pgParameters)
+
 	var err error
 	for i := range instances {
 		err = r.reconcileInstance(
@@ -1022,6 +1086,7 @@ func (r *Reconciler) scaleUpInstances(
 			rootCA, clusterPodService, instanceServiceAccount,
 			patroniLeaderService, primaryCertificate, instances[i],
 			numInstancePods, clusterVolumes, exporterWebConfig,
+			replicateFrom, parameters,
 		)
 	}
 	if err == nil {
@@ -1031,6 +1096,22 @@ func (r *Reconciler) scaleUpInstances(
 	return instances, err
 }
 
+// replicateFromMember returns the name of the Patroni member that instances
+// replicating from set should stream from. It is the first Pod, by name, of
+// that set that is not terminating. It returns blank when there is none.
+func replicateFromMember(observed *observedInstances, set string) string {
+	var member string
+	for _, instance := range observed.bySet[set] {
+		if terminating, known := instance.IsTerminating(); !known || terminating {
+			continue
+		}
+		if name := instance.Pods[0].Name; member == "" || name < member {
+			member = name
+		}
+	}
+	return member
+}
+
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=create;patch
 
 // reconcileInstance writes instance according to spec of cluster.
@@ -1051,6 +1132,8 @@ func (r *Reconciler) reconcileInstance(
 	numInstancePods int,
 	clusterVolumes []corev1.PersistentVolumeClaim,
 	exporterWebConfig *corev1.ConfigMap,
+	replicateFrom string,
+	parameters *postgres.ParameterSet,
 ) error {
 	log := logging.FromContext(ctx).WithValues("instance", instance.Name)
 	ctx = logging.NewContext(ctx, log)
@@ -1074,7 +1157,8 @@ func (r *Reconciler) reconcileInstance(
 	)
 
 	if err == nil {
-		instanceConfigMap, err = r.reconcileInstanceConfigMap(ctx, cluster, spec, instance)
+		instanceConfigMap, err = r.reconcileInstanceConfigMap(
+			ctx, cluster, spec, instance, replicateFrom, parameters)
 	}
 	if err == nil {
 		instanceCertificates, err = r.reconcileInstanceCertificates(
@@ -1224,8 +1308,8 @@ func generateInstanceStatefulSetIntent(_ context.Context,
 	} else if cluster.Status.StartupInstance != sts.Name {
 		// there is a startup instance defined, but not this instance; do not run.
 		sts.Spec.Replicas = initialize.Int32(0)
-	} else if cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown &&
-		numInstancePods <= 1 {
+	} else if ((cluster.Spec.Shutdown != nil && *cluster.Spec.Shutdown) ||
+		patroniChangingDCS(cluster)) && numInstancePods <= 1 {
 		// this is the last instance of the shutdown sequence; do not run.
 		sts.Spec.Replicas = initialize.Int32(0)
 	} else {
@@ -1284,7 +1368,8 @@ func addPGBackRestToInstancePodSpec(cluster *v1beta1.PostgresCluster,
 // files (etc) that apply to instance of cluster.
 func (r *Reconciler) reconcileInstanceConfigMap(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, spec *v1beta1.PostgresInstanceSetSpec,
-	instance *appsv1.StatefulSet,
+	instance *appsv1.StatefulSet, replicateFrom string,
+	parameters *postgres.ParameterSet,
 ) (*corev1.ConfigMap, error) {
 	instanceConfigMap := &corev1.ConfigMap{ObjectMeta: naming.InstanceConfigMap(instance)}
 	instanceConfigMap.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
@@ -1305,7 +1390,7 @@ func (r *Reconciler) reconcileInstanceConfigMap(
 		})
 
 	if err == nil {
-		err = patroni.InstanceConfigMap(ctx, cluster, spec, instanceConfigMap)
+		err = patroni.InstanceConfigMap(ctx, cluster, spec, replicateFrom, parameters, instanceConfigMap)
 	}
 	if err == nil {
 		err = errors.WithStack(r.apply(ctx, instanceConfigMap))
@@ -36,12 +36,14 @@ import (
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/internal/util"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestReconcilerRolloutInstance(t *testing.T) {
 	ctx := context.Background()
 	cluster := new(v1beta1.PostgresCluster)
+	assert.NilError(t, util.AddAndSetFeatureGates(""))
 
 	t.Run("Singleton", func(t *testing.T) {
 		instances := []*Instance{
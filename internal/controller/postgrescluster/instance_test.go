@@ -44,6 +44,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
@@ -269,6 +270,43 @@ func TestNewObservedInstances(t *testing.T) {
 	})
 }
 
+func TestReplicateFromMember(t *testing.T) {
+	pod := func(name, set string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"postgres-operator.crunchydata.com/instance-set": set,
+				"postgres-operator.crunchydata.com/instance":     name[:len(name)-2],
+			},
+		}}
+	}
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "one"}, {Name: "two"}}
+
+	observed := newObservedInstances(cluster, nil, []corev1.Pod{
+		pod("hippo-one-wxyz-0", "one"),
+		pod("hippo-one-abcd-0", "one"),
+		pod("hippo-two-mnop-0", "two"),
+	})
+
+	assert.Equal(t, replicateFromMember(observed, "one"), "hippo-one-abcd-0")
+	assert.Equal(t, replicateFromMember(observed, "two"), "hippo-two-mnop-0")
+	assert.Equal(t, replicateFromMember(observed, "missing"), "")
+
+	t.Run("Terminating", func(t *testing.T) {
+		now := metav1.Now()
+		terminating := pod("hippo-one-abcd-0", "one")
+		terminating.DeletionTimestamp = &now
+
+		observed := newObservedInstances(cluster, nil, []corev1.Pod{
+			pod("hippo-one-wxyz-0", "one"), terminating,
+		})
+
+		assert.Equal(t, replicateFromMember(observed, "one"), "hippo-one-wxyz-0")
+	})
+}
+
 func TestWritablePod(t *testing.T) {
 	container := "container"
 
@@ -1270,6 +1308,24 @@ func TestGenerateInstanceStatefulSetIntent(t *testing.T) {
 		run: func(t *testing.T, ss *appsv1.StatefulSet) {
 			assert.Assert(t, ss.Spec.Template.Spec.TopologySpreadConstraints != nil)
 		},
+	}, {
+		name: "load balanced beside another set",
+		ip: intentParams{
+			cluster: func() *v1beta1.PostgresCluster {
+				cluster := testCluster()
+				cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+					v1beta1.PostgresInstanceSetSpec{
+						Name:        "reporting",
+						PatroniTags: &v1beta1.PatroniTags{NoLoadBalance: true},
+					})
+				return cluster
+			}(),
+		},
+		run: func(t *testing.T, ss *appsv1.StatefulSet) {
+			// Pods are labeled directly so their template does not change.
+			_, found := ss.Spec.Template.Labels[naming.LabelLoadBalance]
+			assert.Assert(t, !found)
+		},
 	}, {
 		name: "shutdown replica",
 		ip: intentParams{
@@ -1537,6 +1593,77 @@ func TestGenerateInstanceStatefulSetIntent(t *testing.T) {
 	}
 }
 
+func TestGenerateInstanceStatefulSetIntentLoadBalance(t *testing.T) {
+	generate := func(cluster *v1beta1.PostgresCluster) *appsv1.StatefulSet {
+		cluster.Default()
+		cluster.Namespace = "ns1"
+
+		sts := &appsv1.StatefulSet{}
+		generateInstanceStatefulSetIntent(context.Background(),
+			cluster, &cluster.Spec.InstanceSets[0], "svc", "sa", sts, 1)
+		return sts
+	}
+
+	cluster := testCluster()
+	cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+		v1beta1.PostgresInstanceSetSpec{Name: "reporting"})
+	before := generate(cluster.DeepCopy())
+
+	// Excluding set B from the replica Service does not roll the Pods of set A.
+	cluster.Spec.InstanceSets[1].PatroniTags = &v1beta1.PatroniTags{NoLoadBalance: true}
+	after := generate(cluster.DeepCopy())
+
+	assert.DeepEqual(t, before.Spec.Template, after.Spec.Template)
+}
+
+func TestReconcileInstanceLoadBalance(t *testing.T) {
+	ctx := context.Background()
+
+	pod := func(name string, labels map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Namespace, pod.Name = "ns1", name
+		pod.Labels = labels
+		return pod
+	}
+
+	cluster := testCluster()
+	cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+		v1beta1.PostgresInstanceSetSpec{Name: "reporting"})
+
+	balanced := pod("instance1-abcd-0", nil)
+	excluded := pod("reporting-wxyz-0", map[string]string{naming.LabelLoadBalance: "true"})
+	instances := &observedInstances{forCluster: []*Instance{
+		{Name: "instance1-abcd", Spec: &cluster.Spec.InstanceSets[0], Pods: []*corev1.Pod{balanced}},
+		{Name: "reporting-wxyz", Spec: &cluster.Spec.InstanceSets[1], Pods: []*corev1.Pod{excluded}},
+	}}
+
+	r := &Reconciler{Owner: client.FieldOwner(t.Name())}
+	r.Client = fake.NewClientBuilder().WithObjects(balanced, excluded).Build()
+
+	labeled := func(pod *corev1.Pod) bool {
+		var current corev1.Pod
+		assert.NilError(t, r.Client.Get(ctx, client.ObjectKeyFromObject(pod), &current))
+		_, found := current.Labels[naming.LabelLoadBalance]
+		return found
+	}
+
+	t.Run("Excluded", func(t *testing.T) {
+		cluster.Spec.InstanceSets[1].PatroniTags = &v1beta1.PatroniTags{NoLoadBalance: true}
+		assert.NilError(t, r.reconcileInstanceLoadBalance(ctx, cluster, instances))
+
+		assert.Assert(t, labeled(balanced), "expected label on load balanced Pod")
+		assert.Assert(t, !labeled(excluded), "expected no label on excluded Pod")
+	})
+
+	t.Run("Included", func(t *testing.T) {
+		cluster.Spec.InstanceSets[1].PatroniTags = nil
+		assert.NilError(t, r.reconcileInstanceLoadBalance(ctx, cluster, instances))
+
+		assert.Assert(t, !labeled(balanced), "expected no label when every set is load balanced")
+		assert.Assert(t, !labeled(excluded), "expected no label when every set is load balanced")
+	})
+}
+
 func TestFindAvailableInstanceNames(t *testing.T) {
 
 	testCases := []struct {
@@ -19,12 +19,16 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -34,11 +38,15 @@ import (
 	"github.com/crunchydata/postgres-operator/internal/patroni"
 	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/internal/util"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=deletecollection
 // +kubebuilder:rbac:groups="",resources=endpoints,verbs=deletecollection
 
+// deletePatroniArtifacts removes the Endpoints and ConfigMaps that Patroni
+// created for its DCS.
 func (r *Reconciler) deletePatroniArtifacts(
 	ctx context.Context, cluster *v1beta1.PostgresCluster,
 ) error {
@@ -54,10 +62,112 @@ func (r *Reconciler) deletePatroniArtifacts(
 				client.MatchingLabelsSelector{Selector: selector},
 			))
 	}
+	if err == nil {
+		err = errors.WithStack(
+			r.Client.DeleteAllOf(ctx, &corev1.ConfigMap{},
+				client.InNamespace(cluster.Namespace),
+				client.MatchingLabelsSelector{Selector: selector},
+			))
+	}
 
 	return err
 }
 
+// patroniChangingDCS returns true when spec.patroni.dcs differs from the DCS
+// that Patroni is configured to use. Every instance stops before that changes.
+func patroniChangingDCS(cluster *v1beta1.PostgresCluster) bool {
+	return cluster.Spec.Patroni != nil && cluster.Spec.Patroni.DCS != "" &&
+		cluster.Status.Patroni.DCS != "" &&
+		cluster.Status.Patroni.DCS != cluster.Spec.Patroni.DCS
+}
+
+// reconcilePatroniDCS sets cluster.Status.Patroni.DCS to the kind of DCS that
+// Patroni should use. It changes to match the spec only when no instances are
+// running so that instances never coordinate through different objects.
+func (r *Reconciler) reconcilePatroniDCS(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) error {
+	status := &cluster.Status.Patroni
+
+	// Clusters bootstrapped before this field existed are using Endpoints.
+	if status.DCS == "" && patroni.ClusterBootstrapped(cluster) {
+		status.DCS = v1beta1.PatroniDCSEndpoints
+	}
+
+	want := v1beta1.PatroniDCSEndpoints
+	if cluster.Spec.Patroni != nil && cluster.Spec.Patroni.DCS != "" {
+		want = cluster.Spec.Patroni.DCS
+	}
+	if status.DCS == want {
+		return nil
+	}
+
+	// Wait for the instances to stop. See [patroniChangingDCS].
+	for _, instance := range instances.forCluster {
+		if len(instance.Pods) > 0 {
+			return nil
+		}
+	}
+
+	// Remove everything Patroni stored in the previous DCS. The first instance
+	// to start takes over using its existing data directory.
+	// - https://patroni.readthedocs.io/en/latest/kubernetes.html
+	var err error
+	if status.DCS != "" {
+		err = r.deletePatroniArtifacts(ctx, cluster)
+
+		if err == nil {
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, "PatroniDCSChanged",
+				"Patroni is switching from %s to %s", status.DCS, want)
+		}
+	}
+	if err == nil {
+		status.DCS = want
+	}
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// patroniAPI returns a [patroni.API] for the Patroni member in pod. It calls
+// the Patroni REST API directly when the PatroniRESTAPI feature gate is enabled
+// and "patronictl" in the database container otherwise.
+func (r *Reconciler) patroniAPI(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, pod *corev1.Pod,
+) (patroni.API, error) {
+	if !util.DefaultMutableFeatureGate.Enabled(util.PatroniRESTAPI) {
+		return patroni.Executor(func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase,
+				stdin, stdout, stderr, command...)
+		}), nil
+	}
+
+	// Patroni serves its REST API using the instance certificates and accepts
+	// client certificates signed by the same authority. Use them both ways.
+	certificates := &corev1.Secret{ObjectMeta: naming.InstanceCertificates(
+		&metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Labels[naming.LabelInstance]},
+	)}
+	err := errors.WithStack(
+		r.Client.Get(ctx, client.ObjectKeyFromObject(certificates), certificates))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The instance certificate is valid for the stable DNS name of the Pod.
+	// See [naming.InstancePodDNSNames].
+	host := pod.Name + "." + pod.Spec.Subdomain + "." + pod.Namespace +
+		".svc." + naming.KubernetesClusterDomain(ctx)
+
+	api, err := patroni.NewClient(host, *cluster.Spec.Patroni.Port, certificates)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return api, nil
+}
+
 func (r *Reconciler) handlePatroniRestarts(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
 ) error {
@@ -99,14 +209,12 @@ func (r *Reconciler) handlePatroniRestarts(
 	// replicas here, replicas will typically restart first because we see them
 	// first.
 	if primaryNeedsRestart != nil {
-		exec := patroni.Executor(func(
-			ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
-		) error {
-			pod := primaryNeedsRestart.Pods[0]
-			return r.PodExec(pod.Namespace, pod.Name, container, stdin, stdout, stderr, command...)
-		})
-
-		return errors.WithStack(exec.RestartPendingMembers(ctx, "master", naming.PatroniScope(cluster)))
+		api, err := r.patroniAPI(ctx, cluster, primaryNeedsRestart.Pods[0])
+		if err == nil {
+			err = errors.WithStack(
+				api.RestartPendingMembers(ctx, "master", naming.PatroniScope(cluster)))
+		}
+		return err
 	}
 
 	// When the primary does not need to restart but a replica does, restart all
@@ -124,21 +232,19 @@ func (r *Reconciler) handlePatroniRestarts(
 	// how we decide when to restart.
 	// - https://www.postgresql.org/docs/current/runtime-config-replication.html
 	if replicaNeedsRestart != nil {
-		exec := patroni.Executor(func(
-			ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
-		) error {
-			pod := replicaNeedsRestart.Pods[0]
-			return r.PodExec(pod.Namespace, pod.Name, container, stdin, stdout, stderr, command...)
-		})
-
-		return errors.WithStack(exec.RestartPendingMembers(ctx, "replica", naming.PatroniScope(cluster)))
+		api, err := r.patroniAPI(ctx, cluster, replicaNeedsRestart.Pods[0])
+		if err == nil {
+			err = errors.WithStack(
+				api.RestartPendingMembers(ctx, "replica", naming.PatroniScope(cluster)))
+		}
+		return err
 	}
 
 	// Nothing needs to restart.
 	return nil
 }
 
-// +kubebuilder:rbac:groups="",resources=services,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=services,verbs=create;delete;get;patch
 
 // reconcilePatroniDistributedConfiguration sets labels and ownership on the
 // objects Patroni creates for its distributed configuration.
@@ -155,6 +261,16 @@ func (r *Reconciler) reconcilePatroniDistributedConfiguration(
 	dcsService := &corev1.Service{ObjectMeta: naming.PatroniDistributedConfiguration(cluster)}
 	dcsService.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
 
+	// When using ConfigMaps for DCS, there is nothing to protect.
+	if patroni.UsesConfigMaps(cluster) {
+		err := errors.WithStack(
+			r.Client.Get(ctx, client.ObjectKeyFromObject(dcsService), dcsService))
+		if err == nil {
+			err = errors.WithStack(r.deleteControlled(ctx, cluster, dcsService))
+		}
+		return client.IgnoreNotFound(err)
+	}
+
 	err := errors.WithStack(r.setControllerReference(cluster, dcsService))
 
 	dcsService.Annotations = naming.Merge(
@@ -212,8 +328,9 @@ func (r *Reconciler) reconcilePatroniDynamicConfiguration(
 	// NOTE(cbandy): Despite the guards above, calling PodExec may still fail
 	// due to a missing or stopped container.
 
-	exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
-		return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase, stdin, stdout, stderr, command...)
+	api, err := r.patroniAPI(ctx, cluster, pod)
+	if err != nil {
+		return err
 	}
 
 	var configuration map[string]interface{}
@@ -222,12 +339,69 @@ func (r *Reconciler) reconcilePatroniDynamicConfiguration(
 	}
 	configuration = patroni.DynamicConfiguration(cluster, configuration, pgHBAs, pgParameters)
 
-	return errors.WithStack(
-		patroni.Executor(exec).ReplaceConfiguration(ctx, configuration))
+	err = errors.WithStack(api.ReplaceConfiguration(ctx, configuration))
+	if err == nil {
+		r.observeRestartParameters(ctx, cluster, instances)
+	}
+	return err
+}
+
+// observeRestartParameters sets the "ParametersApplied" condition to list the
+// PostgreSQL parameters that take effect only after a restart and have yet to
+// do so. Patroni reports which instances have such parameters, and PostgreSQL
+// reports which parameters those are. Another reconcile happens when Patroni
+// reports a change; see [Reconciler.watchPods].
+func (r *Reconciler) observeRestartParameters(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) {
+	log := logging.FromContext(ctx)
+	condition := metav1.Condition{
+		Type:    v1beta1.PostgresParametersApplied,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Applied",
+		Message: "Every instance is using the current PostgreSQL parameters.",
+
+		ObservedGeneration: cluster.GetGeneration(),
+	}
+
+	pending := sets.NewString()
+	for _, instance := range instances.forCluster {
+		if len(instance.Pods) == 0 || !patroni.PodRequiresRestart(instance.Pods[0]) {
+			continue
+		}
+
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "RestartPending"
+
+		if running, known := instance.IsRunning(naming.ContainerDatabase); running && known {
+			pod := instance.Pods[0]
+			names, err := postgres.PendingRestartParameters(ctx, func(
+				ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+			) error {
+				return r.PodExec(pod.Namespace, pod.Name, naming.ContainerDatabase,
+					stdin, stdout, stderr, command...)
+			})
+			if err != nil {
+				log.Error(err, "unable to read pending restart parameters", "pod", pod.Name)
+			}
+			pending.Insert(names...)
+		}
+	}
+
+	if condition.Status == metav1.ConditionFalse {
+		condition.Message = "PostgreSQL must restart to apply changed parameters."
+	}
+	if pending.Len() > 0 {
+		condition.Message = fmt.Sprintf(
+			"PostgreSQL must restart to apply: %s", strings.Join(pending.List(), ", "))
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
 }
 
 // generatePatroniLeaderLeaseService returns a v1.Service that exposes the
-// Patroni leader when Patroni is using Endpoints for its leader elections.
+// Patroni leader. When Patroni is using Endpoints for its leader elections,
+// Patroni manages the Endpoints of this Service.
 func (r *Reconciler) generatePatroniLeaderLeaseService(
 	cluster *v1beta1.PostgresCluster) (*corev1.Service, error,
 ) {
@@ -258,6 +432,15 @@ func (r *Reconciler) generatePatroniLeaderLeaseService(
 	// - https://docs.k8s.io/concepts/services-networking/service/#services-without-selectors
 	service.Spec.Selector = nil
 
+	// When using ConfigMaps for DCS, Patroni writes no Endpoints. Let Kubernetes
+	// manage them by selecting the Pod that Patroni labels as leader.
+	if patroni.UsesConfigMaps(cluster) {
+		service.Spec.Selector = map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelRole:    naming.RolePatroniLeader,
+		}
+	}
+
 	// The TargetPort must be the name (not the number) of the PostgreSQL
 	// ContainerPort. This name allows the port number to differ between
 	// instances, which can happen during a rolling update.
@@ -288,6 +471,7 @@ func (r *Reconciler) generatePatroniLeaderLeaseService(
 		}
 	}
 	service.Spec.Ports = []corev1.ServicePort{servicePort}
+	setServiceOptions(service, cluster.Spec.Service)
 
 	err := errors.WithStack(r.setControllerReference(cluster, service))
 	return service, err
@@ -296,8 +480,8 @@ func (r *Reconciler) generatePatroniLeaderLeaseService(
 // +kubebuilder:rbac:groups="",resources="services",verbs={create,patch}
 
 // reconcilePatroniLeaderLease sets labels and ownership on the objects Patroni
-// creates for its leader elections. When Patroni is using Endpoints for this,
-// the returned Service resolves to the elected leader. Otherwise, it is nil.
+// creates for its leader elections. The returned Service resolves to the
+// elected leader.
 func (r *Reconciler) reconcilePatroniLeaderLease(
 	ctx context.Context, cluster *v1beta1.PostgresCluster,
 ) (*corev1.Service, error) {
@@ -312,6 +496,140 @@ func (r *Reconciler) reconcilePatroniLeaderLease(
 	return service, err
 }
 
+// patroniMembersInterval is how long PGO waits before asking Patroni about its
+// members again. Replication lag changes constantly, so this also limits how
+// often the status changes. Without the PatroniRESTAPI feature gate, every
+// request is a "patronictl list" command executed in an instance Pod.
+const patroniMembersInterval = 30 * time.Second
+
+// reconcilePatroniMembers populates the members of each instance set in
+// cluster.Status with what Patroni reports through its "GET /cluster" endpoint.
+// It asks Patroni at most once every [patroniMembersInterval] unless some Pod
+// has not been reported yet.
+func (r *Reconciler) reconcilePatroniMembers(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) (reconcile.Result, error) {
+	if !patroni.ClusterBootstrapped(cluster) {
+		// Patroni has not yet bootstrapped; there are no members to report.
+		return reconcile.Result{}, nil
+	}
+
+	now := metav1.Now()
+	reported := make(map[string]v1beta1.PatroniMemberStatus)
+	for _, set := range cluster.Status.InstanceSets {
+		for _, member := range set.Members {
+			reported[member.Name] = member
+		}
+	}
+
+	var pod *corev1.Pod
+	var stale bool
+	for _, instance := range instances.forCluster {
+		for _, p := range instance.Pods {
+			member, ok := reported[p.Name]
+			stale = stale || !ok || member.ObservedTime == nil ||
+				now.Sub(member.ObservedTime.Time) >= patroniMembersInterval
+		}
+
+		if terminating, known := instance.IsTerminating(); pod == nil && !terminating && known {
+			running, known := instance.IsRunning(naming.ContainerDatabase)
+
+			if running && known && len(instance.Pods) > 0 {
+				pod = instance.Pods[0]
+			}
+		}
+	}
+	if pod == nil {
+		// There are no running Patroni containers; nothing to ask.
+		return reconcile.Result{}, nil
+	}
+	if !stale {
+		return reconcile.Result{RequeueAfter: patroniMembersInterval}, nil
+	}
+
+	api, err := r.patroniAPI(ctx, cluster, pod)
+
+	var status *patroni.ClusterStatus
+	if err == nil {
+		status, err = api.GetCluster(ctx)
+		err = errors.WithStack(err)
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	members := make(map[string]patroni.ClusterMember, len(status.Members))
+	for _, member := range status.Members {
+		members[member.Name] = member
+	}
+
+	// Report every Pod that Patroni knows about. Keep the last report of those
+	// it does not so that their observed time shows how long they have been gone.
+	for i := range cluster.Status.InstanceSets {
+		set := &cluster.Status.InstanceSets[i]
+		set.Members = nil
+
+		for _, instance := range instances.bySet[set.Name] {
+			for _, p := range instance.Pods {
+				if member, ok := members[p.Name]; ok {
+					set.Members = append(set.Members, v1beta1.PatroniMemberStatus{
+						Name:                member.Name,
+						Role:                member.Role,
+						State:               member.State,
+						Timeline:            member.Timeline,
+						ReplicationLagBytes: member.Lag,
+						PendingRestart:      member.PendingRestart,
+						ObservedTime:        now.DeepCopy(),
+					})
+				} else if previous, ok := reported[p.Name]; ok {
+					set.Members = append(set.Members, previous)
+				}
+			}
+		}
+
+		sort.Slice(set.Members, func(i, j int) bool {
+			return set.Members[i].Name < set.Members[j].Name
+		})
+	}
+
+	return reconcile.Result{RequeueAfter: patroniMembersInterval}, nil
+}
+
+// observeSynchronousStandbys sets the "SynchronousStandbysAttached" condition
+// using the members that Patroni last reported. See [Reconciler.reconcilePatroniMembers].
+func observeSynchronousStandbys(cluster *v1beta1.PostgresCluster) {
+	sync := cluster.Spec.Patroni.SynchronousReplication
+	if sync == nil || sync.Mode == v1beta1.PatroniSynchronousModeDisabled {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, v1beta1.SynchronousStandbysAttached)
+		return
+	}
+
+	var attached int32
+	for _, set := range cluster.Status.InstanceSets {
+		for _, member := range set.Members {
+			// Patroni v4 reports the standbys of quorum mode differently.
+			if member.Role == "sync_standby" || member.Role == "quorum_standby" {
+				attached++
+			}
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:    v1beta1.SynchronousStandbysAttached,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Attached",
+		Message: fmt.Sprintf("%d of %d synchronous standbys are attached.", attached, *sync.NodeCount),
+
+		ObservedGeneration: cluster.GetGeneration(),
+	}
+	if attached < *sync.NodeCount {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Missing"
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
 // +kubebuilder:rbac:groups="",resources=endpoints,verbs=get
 
 // reconcilePatroniStatus populates cluster.Status.Patroni with observations.
@@ -329,14 +647,21 @@ func (r *Reconciler) reconcilePatroniStatus(
 		}
 	}
 
-	dcs := &corev1.Endpoints{ObjectMeta: naming.PatroniDistributedConfiguration(cluster)}
+	// Patroni writes the same annotations to either kind of DCS object.
+	var dcs client.Object = &corev1.Endpoints{}
+	if patroni.UsesConfigMaps(cluster) {
+		dcs = &corev1.ConfigMap{}
+	}
+	dcs.SetNamespace(naming.PatroniDistributedConfiguration(cluster).Namespace)
+	dcs.SetName(naming.PatroniDistributedConfiguration(cluster).Name)
+
 	err := errors.WithStack(client.IgnoreNotFound(
 		r.Client.Get(ctx, client.ObjectKeyFromObject(dcs), dcs)))
 
 	if err == nil {
-		if dcs.Annotations["initialize"] != "" {
+		if dcs.GetAnnotations()["initialize"] != "" {
 			// After bootstrap, Patroni writes the cluster system identifier to DCS.
-			cluster.Status.Patroni.SystemIdentifier = dcs.Annotations["initialize"]
+			cluster.Status.Patroni.SystemIdentifier = dcs.GetAnnotations()["initialize"]
 		} else if readyInstance {
 			// While we typically expect a value for the initialize key to be present in the
 			// Endpoints above by the time the StatefulSet for any instance indicates "ready"
@@ -533,10 +858,9 @@ func (r *Reconciler) reconcilePatroniSwitchover(ctx context.Context,
 	if runningPod == nil {
 		return errors.New("Could not find a running pod when attempting switchover.")
 	}
-	exec := func(_ context.Context, stdin io.Reader, stdout, stderr io.Writer,
-		command ...string) error {
-		return r.PodExec(runningPod.Namespace, runningPod.Name, naming.ContainerDatabase, stdin,
-			stdout, stderr, command...)
+	api, err := r.patroniAPI(ctx, cluster, runningPod)
+	if err != nil {
+		return err
 	}
 
 	// To ensure idempotency, the operator verifies that the timeline reported by Patroni
@@ -544,7 +868,7 @@ func (r *Reconciler) reconcilePatroniSwitchover(ctx context.Context,
 	// TODO(benjb): consider pulling the timeline from the pod annotation; manual experiments
 	// have shown that the annotation on the Leader pod is up to date during a switchover, but
 	// missing from the Replica pods.
-	timeline, err := patroni.Executor(exec).GetTimeline(ctx)
+	timeline, err := api.GetTimeline(ctx)
 
 	if err != nil {
 		return err
@@ -580,15 +904,15 @@ func (r *Reconciler) reconcilePatroniSwitchover(ctx context.Context,
 	// We have the pod executor, now we need to figure out which API call to use
 	// In the default case we will be using SwitchoverAndWait. This API call uses
 	// a Patronictl switchover to move to the target instance.
-	action := func(ctx context.Context, exec patroni.Executor, next string) (bool, error) {
-		success, err := exec.SwitchoverAndWait(ctx, next)
+	action := func(ctx context.Context, api patroni.API, next string) (bool, error) {
+		success, err := api.SwitchoverAndWait(ctx, next)
 		return success, errors.WithStack(err)
 	}
 
 	if spec.Type == v1beta1.PatroniSwitchoverTypeFailover {
 		// When a failover has been requested we use FailoverAndWait to change the primary.
-		action = func(ctx context.Context, exec patroni.Executor, next string) (bool, error) {
-			success, err := exec.FailoverAndWait(ctx, next)
+		action = func(ctx context.Context, api patroni.API, next string) (bool, error) {
+			success, err := api.FailoverAndWait(ctx, next)
 			return success, errors.WithStack(err)
 		}
 	}
@@ -599,7 +923,7 @@ func (r *Reconciler) reconcilePatroniSwitchover(ctx context.Context,
 		nextPrimary = targetInstance.Pods[0].Name
 	}
 
-	success, err := action(ctx, exec, nextPrimary)
+	success, err := action(ctx, api, nextPrimary)
 	if err = errors.WithStack(err); err == nil && !success {
 		err = errors.New("unable to switchover")
 	}
@@ -33,6 +33,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
@@ -41,7 +42,11 @@ import (
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/internal/pki"
+	"github.com/crunchydata/postgres-operator/internal/testing/events"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
+	"github.com/crunchydata/postgres-operator/internal/util"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
@@ -238,6 +243,21 @@ ownerReferences:
 			test.Expect(t, service, err)
 		})
 	}
+
+	t.Run("ConfigMaps", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.Patroni.DCS = v1beta1.PatroniDCSConfigMaps
+
+		service, err := reconciler.generatePatroniLeaderLeaseService(cluster)
+		assert.NilError(t, err)
+		assert.Equal(t, service.Spec.ClusterIP, "")
+
+		// Kubernetes manages the Endpoints using the leader label.
+		assert.Assert(t, marshalMatches(service.Spec.Selector, `
+postgres-operator.crunchydata.com/cluster: pg2
+postgres-operator.crunchydata.com/role: master
+		`))
+	})
 }
 
 func TestReconcilePatroniLeaderLease(t *testing.T) {
@@ -434,6 +454,200 @@ func TestPatroniReplicationSecret(t *testing.T) {
 
 }
 
+func TestReconcilePatroniMembers(t *testing.T) {
+	ctx := context.Background()
+	assert.NilError(t, util.AddAndSetFeatureGates(""))
+
+	var calls int
+	reconciler := &Reconciler{}
+	reconciler.PodExec = func(
+		namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		calls++
+		assert.Equal(t, pod, "one-a-0")
+		assert.Equal(t, container, naming.ContainerDatabase)
+		assert.DeepEqual(t, command, strings.Fields(`patronictl list --format json`))
+
+		_, err := stdout.Write([]byte(`[
+			{"Member": "one-a-0", "Role": "Leader", "State": "running", "TL": 2},
+			{"Member": "one-b-0", "Role": "Replica", "State": "streaming", "TL": 2, "Lag in MB": 1, "Pending restart": "*"}
+		]`))
+		return err
+	}
+
+	runningPod := func(name string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Name = name
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  naming.ContainerDatabase,
+			State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+		}}
+		return pod
+	}
+
+	instances := &observedInstances{bySet: map[string][]*Instance{}}
+	for _, name := range []string{"one-a", "one-b", "one-c"} {
+		instance := &Instance{
+			Name: name, Pods: []*corev1.Pod{runningPod(name + "-0")},
+			Runner: &appsv1.StatefulSet{},
+		}
+		instances.forCluster = append(instances.forCluster, instance)
+		instances.bySet["one"] = append(instances.bySet["one"], instance)
+	}
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Default()
+	cluster.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{{Name: "one"}}
+
+	t.Run("NotBootstrapped", func(t *testing.T) {
+		result, err := reconciler.reconcilePatroniMembers(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, result, reconcile.Result{})
+		assert.Equal(t, calls, 0)
+	})
+
+	cluster.Status.Patroni.SystemIdentifier = "12345"
+	earlier := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	cluster.Status.InstanceSets[0].Members = []v1beta1.PatroniMemberStatus{
+		{Name: "one-c-0", Role: "replica", State: "running", ObservedTime: &earlier},
+		{Name: "gone-0", Role: "replica", State: "running", ObservedTime: &earlier},
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		result, err := reconciler.reconcilePatroniMembers(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, result, reconcile.Result{RequeueAfter: patroniMembersInterval})
+		assert.Equal(t, calls, 1)
+
+		members := cluster.Status.InstanceSets[0].Members
+		assert.Equal(t, len(members), 3)
+
+		// Members are sorted by name. Those that Patroni reported have a recent
+		// heartbeat; the Pod it did not report keeps its previous status.
+		assert.Equal(t, members[0].Name, "one-a-0")
+		assert.Equal(t, members[0].Role, "leader")
+		assert.Equal(t, members[0].State, "running")
+		assert.Equal(t, members[0].Timeline, int64(2))
+		assert.Assert(t, members[0].ReplicationLagBytes == nil)
+		assert.Assert(t, !members[0].PendingRestart)
+		assert.Assert(t, time.Since(members[0].ObservedTime.Time) < time.Minute)
+
+		assert.Equal(t, members[1].Name, "one-b-0")
+		assert.Equal(t, members[1].Role, "replica")
+		assert.Equal(t, members[1].State, "streaming")
+		assert.Equal(t, *members[1].ReplicationLagBytes, int64(1<<20))
+		assert.Assert(t, members[1].PendingRestart)
+
+		assert.DeepEqual(t, members[2], v1beta1.PatroniMemberStatus{
+			Name: "one-c-0", Role: "replica", State: "running", ObservedTime: &earlier,
+		})
+	})
+
+	t.Run("Fresh", func(t *testing.T) {
+		// Every Pod has been reported recently, so Patroni is not asked again.
+		members := cluster.Status.InstanceSets[0].Members
+		recent := metav1.Now()
+		members[2].ObservedTime = &recent
+
+		result, err := reconciler.reconcilePatroniMembers(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, result, reconcile.Result{RequeueAfter: patroniMembersInterval})
+		assert.Equal(t, calls, 1, "expected no call to Patroni")
+	})
+
+	t.Run("NewPod", func(t *testing.T) {
+		instance := &Instance{
+			Name: "one-d", Pods: []*corev1.Pod{runningPod("one-d-0")},
+			Runner: &appsv1.StatefulSet{},
+		}
+		instances.forCluster = append(instances.forCluster, instance)
+		instances.bySet["one"] = append(instances.bySet["one"], instance)
+
+		_, err := reconciler.reconcilePatroniMembers(ctx, cluster, instances)
+		assert.NilError(t, err)
+		assert.Equal(t, calls, 2)
+	})
+
+	t.Run("NotRunning", func(t *testing.T) {
+		stopped := &observedInstances{forCluster: []*Instance{{
+			Name: "one-a", Pods: []*corev1.Pod{{}}, Runner: &appsv1.StatefulSet{},
+		}}}
+
+		result, err := reconciler.reconcilePatroniMembers(ctx, cluster, stopped)
+		assert.NilError(t, err)
+		assert.Equal(t, result, reconcile.Result{})
+		assert.Equal(t, calls, 2)
+	})
+}
+
+func TestObserveSynchronousStandbys(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Generation = 3
+	cluster.Default()
+
+	t.Run("Unspecified", func(t *testing.T) {
+		cluster.Status.Conditions = []metav1.Condition{{
+			Type: v1beta1.SynchronousStandbysAttached, Status: metav1.ConditionTrue,
+		}}
+
+		observeSynchronousStandbys(cluster)
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			v1beta1.SynchronousStandbysAttached) == nil)
+	})
+
+	cluster.Spec.Patroni.SynchronousReplication = &v1beta1.PatroniSynchronousReplication{
+		NodeCount: initialize.Int32(2),
+	}
+	cluster.Default()
+
+	t.Run("Missing", func(t *testing.T) {
+		cluster.Status.InstanceSets = []v1beta1.PostgresInstanceSetStatus{{
+			Name: "one",
+			Members: []v1beta1.PatroniMemberStatus{
+				{Name: "one-a-0", Role: "leader"},
+				{Name: "one-b-0", Role: "sync_standby"},
+				{Name: "one-c-0", Role: "replica"},
+			},
+		}}
+
+		observeSynchronousStandbys(cluster)
+		condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			v1beta1.SynchronousStandbysAttached)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "Missing")
+		assert.Equal(t, condition.Message, "1 of 2 synchronous standbys are attached.")
+		assert.Equal(t, condition.ObservedGeneration, int64(3))
+	})
+
+	t.Run("Attached", func(t *testing.T) {
+		cluster.Status.InstanceSets = append(cluster.Status.InstanceSets,
+			v1beta1.PostgresInstanceSetStatus{
+				Name: "two",
+				Members: []v1beta1.PatroniMemberStatus{
+					{Name: "two-a-0", Role: "quorum_standby"},
+				},
+			})
+
+		observeSynchronousStandbys(cluster)
+		condition := meta.FindStatusCondition(cluster.Status.Conditions,
+			v1beta1.SynchronousStandbysAttached)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, "Attached")
+		assert.Equal(t, condition.Message, "2 of 2 synchronous standbys are attached.")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		cluster.Spec.Patroni.SynchronousReplication.Mode = v1beta1.PatroniSynchronousModeDisabled
+
+		observeSynchronousStandbys(cluster)
+		assert.Assert(t, meta.FindStatusCondition(cluster.Status.Conditions,
+			v1beta1.SynchronousStandbysAttached) == nil)
+	})
+}
+
 func TestReconcilePatroniStatus(t *testing.T) {
 	ctx := context.Background()
 	_, tClient := setupKubernetes(t)
@@ -539,9 +753,145 @@ func TestReconcilePatroniStatus(t *testing.T) {
 	}
 }
 
+func TestReconcilePatroniDCS(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	ns := setupNamespace(t, cc)
+	recorder := events.NewRecorder(t, cc.Scheme())
+	reconciler := &Reconciler{Client: cc, Recorder: recorder}
+
+	newCluster := func(name string) *v1beta1.PostgresCluster {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Namespace, cluster.Name = ns.Name, name
+		cluster.Default()
+		return cluster
+	}
+
+	running := &observedInstances{forCluster: []*Instance{{Pods: []*corev1.Pod{{}}}}}
+	stopped := &observedInstances{forCluster: []*Instance{{}}}
+
+	t.Run("New", func(t *testing.T) {
+		cluster := newCluster("dcs-new")
+		cluster.Spec.Patroni.DCS = v1beta1.PatroniDCSConfigMaps
+
+		assert.NilError(t, reconciler.reconcilePatroniDCS(ctx, cluster, stopped))
+		assert.Equal(t, cluster.Status.Patroni.DCS, v1beta1.PatroniDCSConfigMaps)
+		assert.Assert(t, !patroniChangingDCS(cluster))
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Bootstrapped", func(t *testing.T) {
+		cluster := newCluster("dcs-bootstrapped")
+		cluster.Status.Patroni.SystemIdentifier = "6952526174828511264"
+
+		assert.NilError(t, reconciler.reconcilePatroniDCS(ctx, cluster, running))
+		assert.Equal(t, cluster.Status.Patroni.DCS, v1beta1.PatroniDCSEndpoints,
+			"expected Endpoints for clusters created before the field")
+		assert.Assert(t, !patroniChangingDCS(cluster))
+	})
+
+	t.Run("Change", func(t *testing.T) {
+		cluster := newCluster("dcs-change")
+		cluster.Status.Patroni.SystemIdentifier = "6952526174828511264"
+		cluster.Status.Patroni.DCS = v1beta1.PatroniDCSEndpoints
+		cluster.Spec.Patroni.DCS = v1beta1.PatroniDCSConfigMaps
+
+		labels := map[string]string{
+			naming.LabelCluster: cluster.Name,
+			naming.LabelPatroni: naming.PatroniScope(cluster),
+		}
+		endpoints := &corev1.Endpoints{ObjectMeta: naming.PatroniDistributedConfiguration(cluster)}
+		endpoints.Labels = labels
+		assert.NilError(t, cc.Create(ctx, endpoints))
+
+		other := &corev1.ConfigMap{ObjectMeta: naming.PatroniDistributedConfiguration(cluster)}
+		assert.NilError(t, cc.Create(ctx, other))
+
+		// Nothing changes while instances are running.
+		assert.NilError(t, reconciler.reconcilePatroniDCS(ctx, cluster, running))
+		assert.Equal(t, cluster.Status.Patroni.DCS, v1beta1.PatroniDCSEndpoints)
+		assert.Assert(t, patroniChangingDCS(cluster))
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(endpoints), endpoints))
+
+		// The previous DCS is removed once they stop.
+		assert.NilError(t, reconciler.reconcilePatroniDCS(ctx, cluster, stopped))
+		assert.Equal(t, cluster.Status.Patroni.DCS, v1beta1.PatroniDCSConfigMaps)
+		assert.Assert(t, !patroniChangingDCS(cluster))
+
+		err := cc.Get(ctx, client.ObjectKeyFromObject(endpoints), endpoints)
+		assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+		assert.NilError(t, cc.Get(ctx, client.ObjectKeyFromObject(other), other),
+			"expected unlabeled ConfigMap to remain")
+
+		assert.Equal(t, len(recorder.Events), 1)
+		assert.Equal(t, recorder.Events[0].Reason, "PatroniDCSChanged")
+		assert.Equal(t, recorder.Events[0].Note, "Patroni is switching from Endpoints to ConfigMaps")
+	})
+}
+
+func TestReconcilerPatroniAPI(t *testing.T) {
+	ctx := context.Background()
+	_, cc := setupKubernetes(t)
+	require.ParallelCapacity(t, 0)
+
+	ns := setupNamespace(t, cc)
+	reconciler := &Reconciler{Client: cc}
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Namespace, cluster.Name = ns.Name, "hippo"
+	cluster.Default()
+
+	pod := new(corev1.Pod)
+	pod.Namespace, pod.Name = ns.Name, "hippo-some-0"
+	pod.Labels = map[string]string{naming.LabelInstance: "hippo-some"}
+	pod.Spec.Subdomain = "hippo-pods"
+
+	t.Run("Executor", func(t *testing.T) {
+		assert.NilError(t, util.AddAndSetFeatureGates(""))
+
+		api, err := reconciler.patroniAPI(ctx, cluster, pod)
+		assert.NilError(t, err)
+
+		_, ok := api.(patroni.Executor)
+		assert.Assert(t, ok, "expected Executor, got %T", api)
+	})
+
+	t.Run("RESTAPI", func(t *testing.T) {
+		assert.NilError(t, util.AddAndSetFeatureGates(string(util.PatroniRESTAPI+"=true")))
+		t.Cleanup(func() {
+			assert.NilError(t, util.AddAndSetFeatureGates(string(util.PatroniRESTAPI+"=false")))
+		})
+
+		_, err := reconciler.patroniAPI(ctx, cluster, pod)
+		assert.Assert(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+
+		root, err := pki.NewRootCertificateAuthority()
+		assert.NilError(t, err)
+		leaf, err := root.GenerateLeafCertificate("any", nil)
+		assert.NilError(t, err)
+
+		certificates := &corev1.Secret{ObjectMeta: naming.InstanceCertificates(
+			&metav1.ObjectMeta{Namespace: ns.Name, Name: "hippo-some"})}
+		assert.NilError(t, patroni.InstanceCertificates(ctx,
+			root.Certificate, leaf.Certificate, leaf.PrivateKey, certificates))
+		assert.NilError(t, cc.Create(ctx, certificates))
+
+		api, err := reconciler.patroniAPI(ctx, cluster, pod)
+		assert.NilError(t, err)
+
+		client, ok := api.(*patroni.Client)
+		assert.Assert(t, ok, "expected Client, got %T", api)
+		assert.Equal(t, client.BaseURL, "https://hippo-some-0.hippo-pods."+ns.Name+
+			".svc."+naming.KubernetesClusterDomain(ctx)+":8008")
+	})
+}
+
 func TestReconcilePatroniSwitchover(t *testing.T) {
 	_, client := setupKubernetes(t)
 	require.ParallelCapacity(t, 0)
+	assert.NilError(t, util.AddAndSetFeatureGates(""))
 
 	var called, failover, callError, callFails bool
 	var timelineCallNoLeader, timelineCall bool
@@ -1017,3 +1367,74 @@ func TestReconcilePatroniSwitchover(t *testing.T) {
 		assert.Assert(t, cluster.Status.Patroni.SwitchoverTimeline == nil)
 	})
 }
+
+func TestObserveRestartParameters(t *testing.T) {
+	ctx := context.Background()
+
+	var calls []string
+	var stdout string
+	var failure error
+	reconciler := &Reconciler{
+		PodExec: func(
+			namespace, pod, container string, stdin io.Reader, out, _ io.Writer, command ...string,
+		) error {
+			calls = append(calls, pod)
+			_, _ = out.Write([]byte(stdout))
+			return failure
+		},
+	}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace = "ns1"
+
+	running := &corev1.Pod{}
+	running.Name = "running"
+	running.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  naming.ContainerDatabase,
+		State: corev1.ContainerState{Running: new(corev1.ContainerStateRunning)},
+	}}
+	stopped := &corev1.Pod{}
+	stopped.Name = "stopped"
+
+	instances := &observedInstances{forCluster: []*Instance{
+		{Pods: []*corev1.Pod{running}}, {Pods: []*corev1.Pod{stopped}},
+	}}
+
+	condition := func() *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.PostgresParametersApplied)
+	}
+
+	// Nothing is pending until Patroni says so.
+	reconciler.observeRestartParameters(ctx, cluster, instances)
+	assert.Assert(t, len(calls) == 0)
+	assert.Equal(t, condition().Status, metav1.ConditionTrue)
+	assert.Equal(t, condition().Reason, "Applied")
+
+	// PostgreSQL lists the parameters that are pending on running instances.
+	pending := map[string]string{"status": `{"role":"replica","pending_restart":true}`}
+	running.Annotations, stopped.Annotations = pending, pending
+	stdout = "max_connections\nshared_buffers\n"
+
+	reconciler.observeRestartParameters(ctx, cluster, instances)
+	assert.DeepEqual(t, calls, []string{"running"})
+	assert.Equal(t, condition().Status, metav1.ConditionFalse)
+	assert.Equal(t, condition().Reason, "RestartPending")
+	assert.Equal(t, condition().Message,
+		"PostgreSQL must restart to apply: max_connections, shared_buffers")
+
+	// Patroni is enough when PostgreSQL cannot say which parameters.
+	calls, failure = nil, errors.New("boom")
+
+	reconciler.observeRestartParameters(ctx, cluster, instances)
+	assert.DeepEqual(t, calls, []string{"running"})
+	assert.Equal(t, condition().Status, metav1.ConditionFalse)
+	assert.Equal(t, condition().Message,
+		"PostgreSQL must restart to apply changed parameters.")
+
+	// Parameters are applied after every instance restarts.
+	calls, running.Annotations, stopped.Annotations = nil, nil, nil
+
+	reconciler.observeRestartParameters(ctx, cluster, instances)
+	assert.Assert(t, len(calls) == 0)
+	assert.Equal(t, condition().Status, metav1.ConditionTrue)
+}
@@ -193,6 +193,7 @@ func (r *Reconciler) generatePGAdminService(
 		}
 	}
 	service.Spec.Ports = []corev1.ServicePort{servicePort}
+	setServiceOptions(service, cluster.Spec.UserInterface.PGAdmin.Service)
 
 	err := errors.WithStack(r.setControllerReference(cluster, service))
 
@@ -21,6 +21,7 @@ import (
 	"io"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -98,6 +99,10 @@ const (
 	// CronJob fails to create successfully
 	EventUnableToCreatePGBackRestCronJob = "UnableToCreatePGBackRestCronJob"
 
+	// EventUnableToExpireBackups is the event reason utilized when the pgBackRest expire command
+	// fails while applying the retention options of a repository
+	EventUnableToExpireBackups = "UnableToExpireBackups"
+
 	// ReasonReadyForRestore is the reason utilized within ConditionPGBackRestRestoreProgressing
 	// to indicate that the restore Job can proceed because the cluster is now ready to be
 	// restored (i.e. it has been properly prepared for a restore).
@@ -111,6 +116,9 @@ const (
 	incremental  = "incr"
 )
 
+// verify is the value of the CronJob label for scheduled restore drills
+const verify = "verify"
+
 // regexRepoIndex is the regex used to obtain the repo index from a pgBackRest repo name
 var regexRepoIndex = regexp.MustCompile(`\d+`)
 
@@ -119,7 +127,10 @@ var regexRepoIndex = regexp.MustCompile(`\d+`)
 type RepoResources struct {
 	cronjobs                []*batchv1.CronJob
 	manualBackupJobs        []*batchv1.Job
+	pgbackrestBackupJobs    []*batchv1.Job
 	replicaCreateBackupJobs []*batchv1.Job
+	scheduledBackupJobs     []*batchv1.Job
+	verifyJobs              []*batchv1.Job
 	hosts                   []*appsv1.StatefulSet
 	pvcs                    []*corev1.PersistentVolumeClaim
 }
@@ -342,6 +353,9 @@ func (r *Reconciler) cleanupRepoResources(ctx context.Context,
 // backupScheduleFound returns true if the CronJob in question should be created as
 // defined by the postgrescluster CRD, otherwise it returns false.
 func backupScheduleFound(repo v1beta1.PGBackRestRepo, backupType string) bool {
+	if backupType == verify {
+		return repo.Verify != nil
+	}
 	if repo.BackupSchedules != nil {
 		switch backupType {
 		case full:
@@ -369,8 +383,17 @@ func unstructuredToRepoResources(kind string, repoResources *RepoResources,
 			FromUnstructured(uList.UnstructuredContent(), &jobList); err != nil {
 			return errors.WithStack(err)
 		}
-		// we care about replica create backup jobs and manual backup jobs
+		// we care about replica create, manual, scheduled, and PGBackRestBackup backup jobs,
+		// and the restore drills of scheduled verification
 		for i, job := range jobList.Items {
+			switch job.GetLabels()[naming.LabelPGBackRestCronJob] {
+			case "":
+			case verify:
+				repoResources.verifyJobs = append(repoResources.verifyJobs, &jobList.Items[i])
+			default:
+				repoResources.scheduledBackupJobs =
+					append(repoResources.scheduledBackupJobs, &jobList.Items[i])
+			}
 			switch job.GetLabels()[naming.LabelPGBackRestBackup] {
 			case string(naming.BackupReplicaCreate):
 				repoResources.replicaCreateBackupJobs =
@@ -378,6 +401,9 @@ func unstructuredToRepoResources(kind string, repoResources *RepoResources,
 			case string(naming.BackupManual):
 				repoResources.manualBackupJobs =
 					append(repoResources.manualBackupJobs, &jobList.Items[i])
+			case string(naming.BackupPGBackRestBackup):
+				repoResources.pgbackrestBackupJobs =
+					append(repoResources.pgbackrestBackupJobs, &jobList.Items[i])
 			}
 		}
 	case "PersistentVolumeClaimList":
@@ -450,7 +476,8 @@ func (r *Reconciler) setScheduledJobStatus(ctx context.Context,
 		// we only care about the scheduled backup Jobs created by the
 		// associated CronJobs
 		sbs := v1beta1.PGBackRestScheduledBackupStatus{}
-		if job.GetLabels()[naming.LabelPGBackRestCronJob] != "" {
+		if job.GetLabels()[naming.LabelPGBackRestCronJob] != "" &&
+			job.GetLabels()[naming.LabelPGBackRestCronJob] != verify {
 			if len(job.OwnerReferences) > 0 {
 				sbs.CronJobName = job.OwnerReferences[0].Name
 			}
@@ -972,6 +999,11 @@ func (r *Reconciler) prepareForRestore(ctx context.Context,
 
 	// if everything is gone, proceed with re-bootstrapping the cluster via an in-place restore
 	if len(currentEndpoints) == 0 {
+		// Patroni may have used ConfigMaps rather than Endpoints for its DCS.
+		if err := r.deletePatroniArtifacts(ctx, cluster); err != nil {
+			return err
+		}
+
 		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionPostgresDataInitialized)
 		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
 			ObservedGeneration: cluster.GetGeneration(),
@@ -1052,7 +1084,7 @@ func (r *Reconciler) reconcileRestoreJob(ctx context.Context,
 	var deltaOptFound, foundTarget bool
 	for _, opt := range opts {
 		switch {
-		case strings.Contains(opt, "--target"):
+		case strings.Contains(opt, "--target"), opt == "--type=immediate":
 			foundTarget = true
 		case strings.Contains(opt, "--delta"):
 			deltaOptFound = true
@@ -1063,7 +1095,8 @@ func (r *Reconciler) reconcileRestoreJob(ctx context.Context,
 	}
 
 	// Note on the pgBackRest option `--target-action` in the restore job:
-	// (a) `--target-action` is only allowed if `--target` and `type` are set;
+	// (a) `--target-action` is only allowed if `--target` and `type` are set, or if `type` is
+	// `immediate`;
 	// TODO(benjaminjb): ensure that `type` is set as well before accepting `target-action`
 	// (b) our restore job assumes the `hot_standby: on` default, which is true of Postgres >= 10;
 	// (c) pgBackRest passes the `--target-action` setting as `recovery-target-action`
@@ -1344,6 +1377,9 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		result = updateReconcileResult(result, reconcile.Result{RequeueAfter: 10 * time.Second})
 	}
 
+	// Report the most recent restore drill of every repository
+	r.setVerifyStatus(postgresCluster, repoResources.verifyJobs)
+
 	// Reconcile the initial backup that is needed to enable replica creation using pgBackRest.
 	// This is done once stanza creation is successful
 	if err := r.reconcileReplicaCreateBackup(ctx, postgresCluster, instances,
@@ -1360,9 +1396,288 @@ func (r *Reconciler) reconcilePGBackRest(ctx context.Context,
 		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
 	}
 
+	// Reconcile the backups requested by PGBackRestBackup objects. These wait for manual
+	// and scheduled backups, since only one backup can run at a time.
+	if err := r.reconcilePGBackRestBackups(ctx, postgresCluster,
+		repoResources.pgbackrestBackupJobs,
+		append(append([]*batchv1.Job{}, repoResources.manualBackupJobs...),
+			repoResources.scheduledBackupJobs...),
+		sa, instances); err != nil {
+		log.Error(err, "unable to reconcile PGBackRestBackups")
+		result = updateReconcileResult(result, reconcile.Result{Requeue: true})
+	}
+
+	// Apply changes to the retention of every repository
+	if err := r.reconcileRetention(ctx, postgresCluster); err != nil {
+		log.Error(err, "unable to expire pgBackRest backups")
+		result = updateReconcileResult(result, reconcile.Result{RequeueAfter: time.Minute})
+	}
+
+	// Report the backups in every repository. Errors here should not prevent
+	// the rest of the cluster from reconciling, so requeue to try again later.
+	inventory, err := r.reconcileBackupInventory(ctx, postgresCluster, instances)
+	if err != nil {
+		log.Error(err, "unable to read pgBackRest backups")
+		inventory = reconcile.Result{RequeueAfter: pgbackrestInventoryInterval}
+	}
+	result = updateReconcileResult(result, inventory)
+
 	return result, nil
 }
 
+// +kubebuilder:rbac:groups="",resources="pods",verbs={list}
+// +kubebuilder:rbac:groups="",resources="pods/exec",verbs={create}
+
+// repoExecutor returns a pgbackrest.Executor that runs commands in the Pod where backups of
+// repo run. It returns nil when that Pod is not running.
+func (r *Reconciler) repoExecutor(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, repo v1beta1.PGBackRestRepo,
+) (pgbackrest.Executor, error) {
+	selector, containerName, err := getPGBackRestExecSelector(cluster, repo)
+	pods := &corev1.PodList{}
+	if err == nil {
+		err = errors.WithStack(r.Client.List(ctx, pods,
+			client.InNamespace(cluster.GetNamespace()),
+			client.MatchingLabelsSelector{Selector: selector}))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning {
+			podName := pods.Items[i].GetName()
+			return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+				command ...string) error {
+				return r.PodExec(cluster.GetNamespace(), podName, containerName,
+					stdin, stdout, stderr, command...)
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// reconcileRetention runs the pgBackRest expire command against every repository with a
+// stanza whose retention options have changed since they were last applied. The options are
+// given on the command line, so the command runs wherever backups of the repository run.
+func (r *Reconciler) reconcileRetention(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	var errs []error
+
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		var status *v1beta1.RepoStatus
+		for i := range cluster.Status.PGBackRest.Repos {
+			if cluster.Status.PGBackRest.Repos[i].Name == repo.Name {
+				status = &cluster.Status.PGBackRest.Repos[i]
+			}
+		}
+		if status == nil || !status.StanzaCreated {
+			continue
+		}
+
+		options := pgbackrest.RetentionOptions(cluster, repo)
+		hash, err := pgbackrest.RetentionHash(options)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Without any retention options, pgBackRest keeps every backup.
+		if hash == "" || hash == status.RetentionHash {
+			status.RetentionHash = hash
+			continue
+		}
+
+		// Try again when the Pod is running; changes to it trigger another reconcile.
+		exec, err := r.repoExecutor(ctx, cluster, repo)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if exec == nil {
+			continue
+		}
+
+		if err := exec.Expire(ctx, repo.Name, options); err != nil {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventUnableToExpireBackups,
+				"Unable to apply the retention of %s: %v", repo.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		// Expired backups are no longer in the repository; read it again.
+		status.RetentionHash = hash
+		cluster.Status.PGBackRest.InventoryTime = nil
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// pgbackrestInventoryInterval is how often the operator reads the backups in
+// every pgBackRest repository. See [Reconciler.reconcileBackupInventory].
+const pgbackrestInventoryInterval = 5 * time.Minute
+
+// backupInventoryStale returns true when the backups in status were read more
+// than pgbackrestInventoryInterval before now or before a backup Job completed.
+func backupInventoryStale(status *v1beta1.PGBackRestStatus, now time.Time) bool {
+	if status.InventoryTime == nil ||
+		now.Sub(status.InventoryTime.Time) >= pgbackrestInventoryInterval {
+		return true
+	}
+
+	jobs := []*v1beta1.PGBackRestJobStatus{status.ManualBackup}
+	for i := range status.ScheduledBackups {
+		scheduled := status.ScheduledBackups[i]
+		jobs = append(jobs, &v1beta1.PGBackRestJobStatus{CompletionTime: scheduled.CompletionTime})
+	}
+	for _, job := range jobs {
+		if job != nil && job.CompletionTime != nil &&
+			status.InventoryTime.Before(job.CompletionTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// +kubebuilder:rbac:groups="",resources="pods/exec",verbs={create}
+
+// reconcileBackupInventory reads the backups in every pgBackRest repository
+// and reports them in the status of cluster. It does so at most once every
+// pgbackrestInventoryInterval unless a backup Job has completed since.
+func (r *Reconciler) reconcileBackupInventory(
+	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
+) (reconcile.Result, error) {
+	status := cluster.Status.PGBackRest
+	now := time.Now()
+
+	stanzaCreated := false
+	for _, repo := range status.Repos {
+		stanzaCreated = stanzaCreated || repo.StanzaCreated
+	}
+
+	// Like stanza-create, run pgBackRest on the primary.
+	var primary string
+	for _, instance := range instances.forCluster {
+		if writable, known := instance.IsWritable(); writable && known {
+			primary = instance.Name + "-0"
+			break
+		}
+	}
+
+	if !stanzaCreated || primary == "" {
+		return reconcile.Result{}, nil
+	}
+	if !backupInventoryStale(status, now) {
+		return reconcile.Result{
+			RequeueAfter: status.InventoryTime.Add(pgbackrestInventoryInterval).Sub(now),
+		}, nil
+	}
+
+	exec := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
+		command ...string) error {
+		return r.PodExec(cluster.GetNamespace(), primary,
+			naming.ContainerDatabase, stdin, stdout, stderr, command...)
+	}
+
+	stanza, err := pgbackrest.Executor(exec).Info(ctx)
+
+	// Ask every repository when it stored the newest WAL of the current
+	// database. Repositories archive independently, so one may lag another.
+	archived := make(map[int]time.Time)
+	if err == nil {
+		newest := make(map[int]pgbackrest.InfoArchive)
+		for _, archive := range stanza.Archive {
+			if archive.Max != "" &&
+				archive.Database.ID >= newest[archive.Database.RepoKey].Database.ID {
+				newest[archive.Database.RepoKey] = archive
+			}
+		}
+		for key, archive := range newest {
+			if err == nil {
+				archived[key], err = pgbackrest.Executor(exec).ArchiveTime(
+					ctx, key, archive.ID, archive.Max)
+			}
+		}
+	}
+	if err == nil {
+		setBackupInventory(cluster, stanza, archived)
+		status.InventoryTime = &metav1.Time{Time: now}
+	}
+
+	return reconcile.Result{RequeueAfter: pgbackrestInventoryInterval}, err
+}
+
+// maxReportedBackups is the number of backups in each pgBackRest repository
+// that are reported in the status of a cluster. Retention policies can keep
+// hundreds of backups, and every one makes the status larger.
+const maxReportedBackups = 20
+
+// setBackupInventory replaces the backups of every repository in the status of
+// cluster with the newest of those in stanza. The latest time that a repository
+// can restore to is the later of its newest backup and when it stored its newest
+// WAL file, archived by repository number.
+func setBackupInventory(
+	cluster *v1beta1.PostgresCluster, stanza *pgbackrest.InfoStanza, archived map[int]time.Time,
+) {
+	unix := func(seconds int64) *metav1.Time {
+		if seconds <= 0 {
+			return nil
+		}
+		return &metav1.Time{Time: time.Unix(seconds, 0).UTC()}
+	}
+
+	for i := range cluster.Status.PGBackRest.Repos {
+		repo := &cluster.Status.PGBackRest.Repos[i]
+		repo.Backups = nil
+		repo.BackupCount = 0
+		repo.OldestRestorableTime = nil
+		repo.NewestRestorableTime = nil
+		repo.SizeBytes = nil
+
+		// Repositories are named "repo1" through "repo4" in the spec and
+		// numbered 1 through 4 by pgBackRest.
+		key, _ := strconv.Atoi(strings.TrimPrefix(repo.Name, "repo"))
+
+		var size int64
+		for _, backup := range stanza.Backup {
+			if backup.Database.RepoKey != key {
+				continue
+			}
+
+			repo.Backups = append(repo.Backups, v1beta1.PGBackRestBackupInfo{
+				Label:               backup.Label,
+				Type:                backup.Type,
+				StartTime:           unix(backup.Timestamp.Start),
+				StopTime:            unix(backup.Timestamp.Stop),
+				DatabaseSizeBytes:   backup.Info.Size,
+				RepositorySizeBytes: backup.Info.Repository.Delta,
+				WALStart:            backup.Archive.Start,
+				WALStop:             backup.Archive.Stop,
+				LSNStart:            backup.LSN.Start,
+				LSNStop:             backup.LSN.Stop,
+				Error:               backup.Error,
+			})
+			size += backup.Info.Repository.Delta
+		}
+
+		if n := len(repo.Backups); n > 0 {
+			repo.BackupCount = int32(n)
+			repo.SizeBytes = &size
+			repo.OldestRestorableTime = repo.Backups[0].StopTime
+			repo.NewestRestorableTime = repo.Backups[n-1].StopTime
+
+			if newest := repo.NewestRestorableTime; newest != nil && archived[key].After(newest.Time) {
+				repo.NewestRestorableTime = &metav1.Time{Time: archived[key]}
+			}
+
+			if n > maxReportedBackups {
+				repo.Backups = repo.Backups[n-maxReportedBackups:]
+			}
+		}
+	}
+}
+
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=create;patch
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;patch;delete
 
@@ -2782,6 +3097,13 @@ func (r *Reconciler) reconcileScheduledBackups(
 				}
 			}
 		}
+		if repo.Verify != nil {
+			if err := r.reconcilePGBackRestCronJob(ctx, cluster, repo,
+				verify, &repo.Verify.Schedule, sa, cronjobs); err != nil {
+				log.Error(err, "unable to reconcile restore verification for "+repo.Name)
+				requeue = true
+			}
+		}
 	}
 	return requeue
 }
@@ -2789,7 +3111,8 @@ func (r *Reconciler) reconcileScheduledBackups(
 // +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=create;patch
 
 // reconcilePGBackRestCronJob creates the CronJob for the given repo, pgBackRest
-// backup type and schedule
+// backup type and schedule. When backupType is "verify", the CronJob runs restore
+// drills rather than backups.
 func (r *Reconciler) reconcilePGBackRestCronJob(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, repo v1beta1.PGBackRestRepo,
 	backupType string, schedule *string, serviceAccount *corev1.ServiceAccount,
@@ -2866,11 +3189,17 @@ func (r *Reconciler) reconcilePGBackRestCronJob(
 		return nil
 	}
 
-	// set backup type (i.e. "full", "diff", "incr")
-	backupOpts := []string{"--type=" + backupType}
+	var jobSpec *batchv1.JobSpec
+	var err error
+	if backupType == verify {
+		jobSpec, err = generateVerifyJobSpecIntent(cluster, repo, labels, annotations)
+	} else {
+		// set backup type (i.e. "full", "diff", "incr")
+		backupOpts := []string{"--type=" + backupType}
 
-	jobSpec, err := generateBackupJobSpecIntent(cluster, repo,
-		serviceAccount.GetName(), labels, annotations, backupOpts...)
+		jobSpec, err = generateBackupJobSpecIntent(cluster, repo,
+			serviceAccount.GetName(), labels, annotations, backupOpts...)
+	}
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -0,0 +1,498 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionPGBackRestBackupProgressing is the type used in a condition to indicate
+	// whether or not the backup requested by a PGBackRestBackup is running.
+	ConditionPGBackRestBackupProgressing = "Progressing"
+
+	// ConditionPGBackRestBackupSucceeded is the type used in a condition to indicate
+	// whether or not the backup requested by a PGBackRestBackup completed successfully.
+	ConditionPGBackRestBackupSucceeded = "Succeeded"
+
+	// EventUnableToExpireBackupSet is the event reason utilized when pgBackRest is unable
+	// to expire the backup of a deleted PGBackRestBackup
+	EventUnableToExpireBackupSet = "UnableToExpireBackupSet"
+)
+
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=pgbackrestbackups,verbs={list,patch}
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=pgbackrestbackups/status,verbs={patch}
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs={create,patch,delete}
+
+// reconcilePGBackRestBackups takes the backups requested by the PGBackRestBackups that
+// reference cluster, one at a time in the order they were created, and reports their
+// progress in the status of each. Nothing starts while any of otherJobs, the manual and
+// scheduled backup Jobs of cluster, is running. It deletes the backup Jobs of
+// PGBackRestBackups that no longer exist.
+func (r *Reconciler) reconcilePGBackRestBackups(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, backupJobs, otherJobs []*batchv1.Job,
+	serviceAccount *corev1.ServiceAccount, instances *observedInstances) error {
+
+	list := &v1beta1.PGBackRestBackupList{}
+	if err := r.Client.List(ctx, list,
+		client.InNamespace(cluster.GetNamespace())); err != nil {
+		return errors.WithStack(err)
+	}
+
+	var backups []*v1beta1.PGBackRestBackup
+	for i := range list.Items {
+		if list.Items[i].Spec.PostgresClusterName == cluster.GetName() {
+			backups = append(backups, &list.Items[i])
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreationTimestamp.Before(&backups[j].CreationTimestamp)
+	})
+
+	exists := make(map[types.UID]bool, len(backups))
+	for _, backup := range backups {
+		exists[backup.GetUID()] = true
+	}
+
+	// Each Job is owned by both the cluster and its PGBackRestBackup, so the garbage
+	// collector does not delete it when only the PGBackRestBackup goes away.
+	var errs []error
+	var active bool
+	for _, job := range otherJobs {
+		if !jobCompleted(job) && !jobFailed(job) {
+			active = true
+		}
+	}
+
+	jobs := make(map[types.UID]*batchv1.Job, len(backupJobs))
+	for _, job := range backupJobs {
+		for _, ref := range job.GetOwnerReferences() {
+			if ref.Kind != "PGBackRestBackup" {
+				continue
+			}
+			if !exists[ref.UID] {
+				errs = append(errs, errors.WithStack(client.IgnoreNotFound(
+					r.Client.Delete(ctx, job,
+						client.PropagationPolicy(metav1.DeletePropagationBackground)))))
+				continue
+			}
+			jobs[ref.UID] = job
+			if !jobCompleted(job) && !jobFailed(job) {
+				active = true
+			}
+		}
+	}
+
+	// pgBackRest connects to a PostgreSQL instance that is not in recovery to
+	// initiate a backup. Similar to "writable" but not exactly.
+	clusterWritable := false
+	for _, instance := range instances.forCluster {
+		writable, known := instance.IsWritable()
+		if writable && known {
+			clusterWritable = true
+			break
+		}
+	}
+
+	for _, backup := range backups {
+		started, err := r.reconcilePGBackRestBackup(ctx, cluster, backup,
+			jobs[backup.GetUID()], serviceAccount, clusterWritable, active)
+		active = active || started
+		errs = append(errs, err)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// reconcilePGBackRestBackup reconciles the finalizer, the status, and the backup Job of
+// backup. It creates that Job when no other Job is active and the cluster is ready for
+// a backup. It returns true when it creates the Job.
+func (r *Reconciler) reconcilePGBackRestBackup(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, backup *v1beta1.PGBackRestBackup, job *batchv1.Job,
+	serviceAccount *corev1.ServiceAccount, clusterWritable, active bool,
+) (bool, error) {
+	var repo v1beta1.PGBackRestRepo
+	for _, spec := range cluster.Spec.Backups.PGBackRest.Repos {
+		if spec.Name == backup.Spec.RepoName {
+			repo = spec
+		}
+	}
+	var repoStatus *v1beta1.RepoStatus
+	for i := range cluster.Status.PGBackRest.Repos {
+		if cluster.Status.PGBackRest.Repos[i].Name == backup.Spec.RepoName {
+			repoStatus = &cluster.Status.PGBackRest.Repos[i]
+		}
+	}
+
+	if !backup.GetDeletionTimestamp().IsZero() {
+		return false, r.finalizePGBackRestBackup(ctx, cluster, backup, job, repo, repoStatus)
+	}
+
+	// Only PGBackRestBackups that expire their backup need a finalizer.
+	finalizers := sets.NewString(backup.GetFinalizers()...)
+	if backup.Spec.ExpireOnDelete != finalizers.Has(naming.Finalizer) {
+		if backup.Spec.ExpireOnDelete {
+			finalizers.Insert(naming.Finalizer)
+		} else {
+			finalizers.Delete(naming.Finalizer)
+		}
+		if err := r.patchPGBackRestBackupFinalizers(ctx, backup, finalizers); err != nil {
+			return false, err
+		}
+	}
+
+	before := backup.DeepCopy()
+	started, err := r.reconcilePGBackRestBackupJob(ctx, cluster, backup, job,
+		serviceAccount, repo, repoStatus, clusterWritable, active)
+
+	if err == nil && !equality.Semantic.DeepEqual(before.Status, backup.Status) {
+		err = errors.WithStack(r.Client.Status().Patch(ctx, backup,
+			client.MergeFrom(before), r.Owner))
+	}
+	return started, err
+}
+
+// reconcilePGBackRestBackupJob updates the status of backup according to its Job, if any.
+// Otherwise, it creates that Job when nothing else prevents the backup from starting.
+func (r *Reconciler) reconcilePGBackRestBackupJob(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, backup *v1beta1.PGBackRestBackup, job *batchv1.Job,
+	serviceAccount *corev1.ServiceAccount,
+	repo v1beta1.PGBackRestRepo, repoStatus *v1beta1.RepoStatus,
+	clusterWritable, active bool,
+) (bool, error) {
+	progressing := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+			ObservedGeneration: backup.GetGeneration(),
+			Type:               ConditionPGBackRestBackupProgressing,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+	succeeded := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+			ObservedGeneration: backup.GetGeneration(),
+			Type:               ConditionPGBackRestBackupSucceeded,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	if job != nil {
+		backup.Status.JobName = job.GetName()
+		backup.Status.StartTime = job.Status.StartTime
+		backup.Status.CompletionTime = job.Status.CompletionTime
+
+		switch {
+		case jobCompleted(job):
+			progressing(metav1.ConditionFalse, "BackupComplete", "Backup completed successfully")
+			succeeded(metav1.ConditionTrue, "BackupComplete", "Backup completed successfully")
+		case jobFailed(job):
+			progressing(metav1.ConditionFalse, "BackupFailed", "Backup did not complete successfully")
+			succeeded(metav1.ConditionFalse, "BackupFailed", "Backup did not complete successfully")
+		default:
+			progressing(metav1.ConditionTrue, "BackupRunning", "Backup Job is running")
+		}
+	}
+
+	// A PGBackRestBackup takes one backup. Once its Job finishes, it only waits
+	// for that backup to appear in the repository.
+	if condition := meta.FindStatusCondition(backup.Status.Conditions,
+		ConditionPGBackRestBackupSucceeded); condition != nil {
+		if condition.Status == metav1.ConditionTrue && backup.Status.Backup == nil {
+			setPGBackRestBackupInfo(cluster, backup, repoStatus)
+		}
+		return false, nil
+	}
+	if job != nil {
+		return false, nil
+	}
+
+	backup.Status.ObservedGeneration = backup.GetGeneration()
+
+	// Users should specify the repo and type of backup using the "repoName" and "type"
+	// fields. Reconciliation is reattempted when the PGBackRestBackup changes.
+	for _, opt := range backup.Spec.Options {
+		for _, option := range []string{"--repo", "--type"} {
+			if strings.HasPrefix(opt, option+"=") || strings.HasPrefix(opt, option+" ") {
+				progressing(metav1.ConditionFalse, "InvalidOptions", fmt.Sprintf(
+					"Option %q is not allowed: please use the %q field instead.",
+					option, strings.TrimPrefix(option, "--")))
+				return false, nil
+			}
+		}
+	}
+
+	// The remaining checks are reattempted when the cluster changes.
+	if repo.Name == "" {
+		progressing(metav1.ConditionFalse, "RepoNotFound", fmt.Sprintf(
+			"Repo %q is not defined for this cluster", backup.Spec.RepoName))
+		return false, nil
+	}
+	if active {
+		progressing(metav1.ConditionFalse, "BackupQueued",
+			"Waiting for another backup to complete")
+		return false, nil
+	}
+	if !clusterWritable {
+		progressing(metav1.ConditionFalse, "ClusterNotReady",
+			"Waiting for PostgreSQL to accept writes")
+		return false, nil
+	}
+	if pgbackrest.DedicatedRepoHostEnabled(cluster) {
+		condition := meta.FindStatusCondition(cluster.Status.Conditions, ConditionRepoHostReady)
+		if condition == nil || condition.Status != metav1.ConditionTrue {
+			progressing(metav1.ConditionFalse, "ClusterNotReady",
+				"Waiting for the pgBackRest repository host to be ready")
+			return false, nil
+		}
+	}
+	// Only one backup can run at a time, so wait for the replica create backup.
+	if condition := meta.FindStatusCondition(cluster.Status.Conditions,
+		ConditionReplicaCreate); condition == nil || condition.Status != metav1.ConditionTrue {
+		progressing(metav1.ConditionFalse, "ClusterNotReady",
+			"Waiting for the replica create backup to complete")
+		return false, nil
+	}
+	if repoStatus == nil || !repoStatus.StanzaCreated {
+		progressing(metav1.ConditionFalse, "ClusterNotReady", fmt.Sprintf(
+			"Waiting for the stanza of %q to be created", repo.Name))
+		return false, nil
+	}
+
+	backupType := backup.Spec.Type
+	if backupType == "" {
+		backupType = "full"
+	}
+
+	backupJob := &batchv1.Job{}
+	backupJob.ObjectMeta = naming.PGBackRestBackupRequestJob(cluster, backup)
+
+	labels := naming.Merge(cluster.Spec.Metadata.GetLabelsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetLabelsOrNil(),
+		naming.PGBackRestBackupJobLabels(cluster.GetName(), repo.Name,
+			naming.BackupPGBackRestBackup))
+	annotations := naming.Merge(cluster.Spec.Metadata.GetAnnotationsOrNil(),
+		cluster.Spec.Backups.PGBackRest.Metadata.GetAnnotationsOrNil())
+	backupJob.ObjectMeta.Labels = labels
+	backupJob.ObjectMeta.Annotations = annotations
+
+	spec, err := generateBackupJobSpecIntent(cluster, repo, serviceAccount.GetName(),
+		labels, annotations, append([]string{"--type=" + backupType},
+			backup.Spec.Options...)...)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	backupJob.Spec = *spec
+
+	// The cluster controls the Job so that changes to it trigger reconciliation.
+	// The PGBackRestBackup owns it so that the Job can be found again.
+	backupJob.SetGroupVersionKind(batchv1.SchemeGroupVersion.WithKind("Job"))
+	if err := r.setControllerReference(cluster, backupJob); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if err := controllerutil.SetOwnerReference(backup, backupJob,
+		r.Client.Scheme()); err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	if err := r.apply(ctx, backupJob); err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	backup.Status.JobName = backupJob.GetName()
+	progressing(metav1.ConditionTrue, "BackupStarted", "Backup Job was created")
+	return true, nil
+}
+
+// setPGBackRestBackupInfo copies the backup taken by the Job of backup from the
+// repository status into the status of backup. When the repository was last read
+// before that Job completed, it arranges for the repository to be read again.
+func setPGBackRestBackupInfo(cluster *v1beta1.PostgresCluster,
+	backup *v1beta1.PGBackRestBackup, repoStatus *v1beta1.RepoStatus) {
+
+	start, stop := backup.Status.StartTime, backup.Status.CompletionTime
+	if repoStatus == nil || start == nil || stop == nil {
+		return
+	}
+
+	for i := range repoStatus.Backups {
+		info := repoStatus.Backups[i]
+		if info.StartTime != nil && info.StopTime != nil &&
+			!info.StartTime.Before(start) && !stop.Before(info.StopTime) {
+			backup.Status.Backup = info.DeepCopy()
+		}
+	}
+
+	inventory := cluster.Status.PGBackRest.InventoryTime
+	if backup.Status.Backup == nil && inventory != nil && inventory.Before(stop) {
+		cluster.Status.PGBackRest.InventoryTime = nil
+	}
+}
+
+// finalizePGBackRestBackup expires the backup of backup from its repository, then
+// removes the finalizer of backup. It waits for a running backup Job to finish and
+// for the backup of a completed Job to be read from the repository.
+func (r *Reconciler) finalizePGBackRestBackup(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, backup *v1beta1.PGBackRestBackup, job *batchv1.Job,
+	repo v1beta1.PGBackRestRepo, repoStatus *v1beta1.RepoStatus,
+) error {
+	finalizers := sets.NewString(backup.GetFinalizers()...)
+	if !finalizers.Has(naming.Finalizer) {
+		return nil
+	}
+	if job != nil && !jobCompleted(job) && !jobFailed(job) {
+		return nil
+	}
+
+	// A backup that completed moments ago may not be in the status of its
+	// repository yet. Look for it there, and wait while the repository has
+	// not been read since the Job completed. This clears the inventory time
+	// so the repository is read again.
+	if backup.Spec.ExpireOnDelete && backup.Status.Backup == nil &&
+		job != nil && jobCompleted(job) {
+		backup.Status.StartTime = job.Status.StartTime
+		backup.Status.CompletionTime = job.Status.CompletionTime
+		setPGBackRestBackupInfo(cluster, backup, repoStatus)
+
+		if backup.Status.Backup == nil && repoStatus != nil &&
+			cluster.Status.PGBackRest.InventoryTime == nil {
+			return nil
+		}
+	}
+
+	// Expire the backup only when it is still in the repository. It may have
+	// been expired already according to the retention of the repository. Only
+	// the newest backups are in status, so look in the repository for others.
+	var label string
+	var reported bool
+	if backup.Spec.ExpireOnDelete && backup.Status.Backup != nil &&
+		repo.Name != "" && repoStatus != nil {
+		for _, info := range repoStatus.Backups {
+			if info.Label == backup.Status.Backup.Label {
+				label, reported = info.Label, true
+			}
+		}
+		if label == "" && int(repoStatus.BackupCount) > len(repoStatus.Backups) {
+			label = backup.Status.Backup.Label
+		}
+	}
+
+	if label != "" {
+		// Try again when the Pod is running; changes to it trigger another reconcile.
+		exec, err := r.repoExecutor(ctx, cluster, repo)
+		if err != nil || exec == nil {
+			return err
+		}
+
+		if !reported {
+			stanza, err := exec.Info(ctx)
+			if err != nil {
+				return err
+			}
+			key := strings.TrimPrefix(repo.Name, "repo")
+			present := false
+			for _, info := range stanza.Backup {
+				present = present ||
+					(info.Label == label && fmt.Sprint(info.Database.RepoKey) == key)
+			}
+			if !present {
+				return r.patchPGBackRestBackupFinalizers(ctx, backup,
+					finalizers.Delete(naming.Finalizer))
+			}
+		}
+
+		if err := exec.ExpireSet(ctx, repo.Name, label); err != nil {
+			r.Recorder.Eventf(backup, corev1.EventTypeWarning, EventUnableToExpireBackupSet,
+				"Unable to expire backup %s from %s; set expireOnDelete to false to "+
+					"delete without expiring: %v", label, repo.Name, err)
+			return err
+		}
+
+		// The backup is no longer in the repository; read it again.
+		cluster.Status.PGBackRest.InventoryTime = nil
+	}
+
+	return r.patchPGBackRestBackupFinalizers(ctx, backup, finalizers.Delete(naming.Finalizer))
+}
+
+// releasePGBackRestBackups removes our finalizer from every PGBackRestBackup that
+// references cluster. Their backups are left in place when the cluster is deleted.
+func (r *Reconciler) releasePGBackRestBackups(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) error {
+	list := &v1beta1.PGBackRestBackupList{}
+	if err := r.Client.List(ctx, list,
+		client.InNamespace(cluster.GetNamespace())); err != nil {
+		return errors.WithStack(err)
+	}
+
+	var errs []error
+	for i := range list.Items {
+		backup := &list.Items[i]
+		finalizers := sets.NewString(backup.GetFinalizers()...)
+		if backup.Spec.PostgresClusterName == cluster.GetName() &&
+			finalizers.Has(naming.Finalizer) {
+			errs = append(errs, r.patchPGBackRestBackupFinalizers(ctx, backup,
+				finalizers.Delete(naming.Finalizer)))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// patchPGBackRestBackupFinalizers sets the finalizers of backup to finalizers.
+func (r *Reconciler) patchPGBackRestBackupFinalizers(ctx context.Context,
+	backup *v1beta1.PGBackRestBackup, finalizers sets.String) error {
+
+	// The Finalizers field is shared by multiple controllers, but the
+	// server-side merge strategy does not work on our custom resource due to a
+	// bug in Kubernetes. Build a merge-patch that includes the full list of
+	// Finalizers plus ResourceVersion to detect conflicts with other potential
+	// writers.
+	// - https://issue.k8s.io/99730
+	before := backup.DeepCopy()
+	// Make another copy so that Patch doesn't write back to backup.
+	intent := before.DeepCopy()
+	intent.Finalizers = finalizers.List()
+	if err := r.patch(ctx, intent,
+		client.MergeFromWithOptions(before, client.MergeFromWithOptimisticLock{})); err != nil {
+		return errors.WithStack(err)
+	}
+
+	backup.Finalizers = intent.Finalizers
+	backup.ResourceVersion = intent.ResourceVersion
+	return nil
+}
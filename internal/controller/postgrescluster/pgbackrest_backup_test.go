@@ -0,0 +1,498 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestReconcilePGBackRestBackups(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	started := time.Date(2023, time.January, 2, 3, 4, 5, 0, time.UTC)
+	completed := started.Add(10 * time.Minute)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+		Name: "repo2",
+		GCS:  &v1beta1.RepoGCS{Bucket: "bucket"},
+	}}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		InventoryTime: &metav1.Time{Time: completed.Add(time.Minute)},
+		Repos: []v1beta1.RepoStatus{{
+			Name: "repo2", StanzaCreated: true,
+			Backups: []v1beta1.PGBackRestBackupInfo{
+				{
+					Label:     "20230101-000000F",
+					StartTime: &metav1.Time{Time: started.Add(-24 * time.Hour)},
+					StopTime:  &metav1.Time{Time: completed.Add(-24 * time.Hour)},
+				},
+				{
+					Label:     "20230102-030510F",
+					Type:      "full",
+					StartTime: &metav1.Time{Time: started.Add(5 * time.Second)},
+					StopTime:  &metav1.Time{Time: completed.Add(-5 * time.Second)},
+					LSNStart:  "0/2000028",
+					LSNStop:   "0/2000100",
+				},
+			},
+		}},
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type: ConditionReplicaCreate, Status: metav1.ConditionTrue, Reason: "test",
+	})
+
+	backup := func(name, uid string) *v1beta1.PGBackRestBackup {
+		backup := &v1beta1.PGBackRestBackup{}
+		backup.Namespace, backup.Name = cluster.Namespace, name
+		backup.UID = types.UID(uid)
+		backup.Spec.PostgresClusterName = cluster.Name
+		backup.Spec.RepoName = "repo2"
+		return backup
+	}
+	job := func(owner *v1beta1.PGBackRestBackup, condition batchv1.JobConditionType) *batchv1.Job {
+		job := &batchv1.Job{}
+		job.ObjectMeta = naming.PGBackRestBackupRequestJob(cluster, owner)
+		job.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: v1beta1.GroupVersion.String(), Kind: "PGBackRestBackup",
+			Name: owner.Name, UID: owner.UID,
+		}}
+		job.Status.StartTime = &metav1.Time{Time: started}
+		if condition != "" {
+			job.Status.CompletionTime = &metav1.Time{Time: completed}
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type: condition, Status: corev1.ConditionTrue,
+			}}
+		}
+		return job
+	}
+
+	condition := func(
+		t testing.TB, r *Reconciler, backup *v1beta1.PGBackRestBackup, conditionType string,
+	) *metav1.Condition {
+		t.Helper()
+		assert.NilError(t, r.Client.Get(ctx, client.ObjectKeyFromObject(backup), backup))
+		return meta.FindStatusCondition(backup.Status.Conditions, conditionType)
+	}
+
+	t.Run("Completed", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		done := backup("done", "uid-done")
+		waiting := backup("waiting", "uid-waiting")
+		orphan := job(backup("gone", "uid-gone"), "")
+
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(done, waiting, orphan).Build()
+
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			[]*batchv1.Job{job(done, batchv1.JobComplete), orphan},
+			nil, &corev1.ServiceAccount{}, &observedInstances{}))
+
+		progressing := condition(t, r, done, ConditionPGBackRestBackupProgressing)
+		assert.Assert(t, progressing != nil)
+		assert.Equal(t, progressing.Status, metav1.ConditionFalse)
+
+		succeeded := condition(t, r, done, ConditionPGBackRestBackupSucceeded)
+		assert.Assert(t, succeeded != nil)
+		assert.Equal(t, succeeded.Status, metav1.ConditionTrue)
+		assert.Equal(t, done.Status.JobName, naming.PGBackRestBackupRequestJob(cluster, done).Name)
+		assert.Assert(t, done.Status.CompletionTime != nil)
+
+		assert.Assert(t, done.Status.Backup != nil)
+		assert.Equal(t, done.Status.Backup.Label, "20230102-030510F")
+		assert.Equal(t, done.Status.Backup.LSNStart, "0/2000028")
+		assert.Equal(t, done.Status.Backup.LSNStop, "0/2000100")
+
+		// The other backup waits for PostgreSQL.
+		progressing = condition(t, r, waiting, ConditionPGBackRestBackupProgressing)
+		assert.Assert(t, progressing != nil)
+		assert.Equal(t, progressing.Status, metav1.ConditionFalse)
+		assert.Equal(t, progressing.Reason, "ClusterNotReady")
+		assert.Assert(t, condition(t, r, waiting, ConditionPGBackRestBackupSucceeded) == nil)
+
+		// The Job of the deleted PGBackRestBackup is gone.
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(orphan), orphan)
+		assert.Assert(t, apierrors.IsNotFound(err), "got %#v", err)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		failed := backup("failed", "uid-failed")
+
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(failed).Build()
+
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			[]*batchv1.Job{job(failed, batchv1.JobFailed)},
+			nil, &corev1.ServiceAccount{}, &observedInstances{}))
+
+		succeeded := condition(t, r, failed, ConditionPGBackRestBackupSucceeded)
+		assert.Assert(t, succeeded != nil)
+		assert.Equal(t, succeeded.Status, metav1.ConditionFalse)
+		assert.Assert(t, failed.Status.Backup == nil)
+
+		// Nothing starts again after the Job is gone.
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			nil, nil, &corev1.ServiceAccount{}, &observedInstances{}))
+
+		progressing := condition(t, r, failed, ConditionPGBackRestBackupProgressing)
+		assert.Assert(t, progressing != nil)
+		assert.Equal(t, progressing.Reason, "BackupFailed")
+	})
+
+	t.Run("Queued", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		running := backup("running", "uid-running")
+		queued := backup("queued", "uid-queued")
+		invalid := backup("invalid", "uid-invalid")
+		invalid.Spec.Options = []string{"--type=incr"}
+		unknown := backup("unknown", "uid-unknown")
+		unknown.Spec.RepoName = "repo4"
+
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(running, queued, invalid, unknown).Build()
+
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			[]*batchv1.Job{job(running, "")},
+			nil, &corev1.ServiceAccount{}, &observedInstances{}))
+
+		for _, tt := range []struct {
+			backup *v1beta1.PGBackRestBackup
+			status metav1.ConditionStatus
+			reason string
+		}{
+			{running, metav1.ConditionTrue, "BackupRunning"},
+			{queued, metav1.ConditionFalse, "BackupQueued"},
+			{invalid, metav1.ConditionFalse, "InvalidOptions"},
+			{unknown, metav1.ConditionFalse, "RepoNotFound"},
+		} {
+			progressing := condition(t, r, tt.backup, ConditionPGBackRestBackupProgressing)
+			assert.Assert(t, progressing != nil, "%s", tt.backup.Name)
+			assert.Equal(t, progressing.Status, tt.status, "%s", tt.backup.Name)
+			assert.Equal(t, progressing.Reason, tt.reason, "%s", tt.backup.Name)
+		}
+	})
+
+	t.Run("OtherBackups", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		queued := backup("queued", "uid-queued")
+
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(queued).Build()
+
+		other := func(labels map[string]string, condition batchv1.JobConditionType) *batchv1.Job {
+			job := &batchv1.Job{}
+			job.Namespace, job.Name = cluster.Namespace, "other"
+			job.Labels = labels
+			if condition != "" {
+				job.Status.Conditions = []batchv1.JobCondition{{
+					Type: condition, Status: corev1.ConditionTrue,
+				}}
+			}
+			return job
+		}
+
+		// Manual and scheduled backups that are running hold up the queue.
+		for _, labels := range []map[string]string{
+			naming.PGBackRestBackupJobLabels(cluster.Name, "repo2", naming.BackupManual),
+			naming.PGBackRestCronJobLabels(cluster.Name, "repo2", full),
+		} {
+			assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+				nil, []*batchv1.Job{other(labels, "")},
+				&corev1.ServiceAccount{}, &observedInstances{}))
+
+			progressing := condition(t, r, queued, ConditionPGBackRestBackupProgressing)
+			assert.Assert(t, progressing != nil)
+			assert.Equal(t, progressing.Reason, "BackupQueued")
+		}
+
+		// Finished ones do not.
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			nil, []*batchv1.Job{other(
+				naming.PGBackRestBackupJobLabels(cluster.Name, "repo2", naming.BackupManual),
+				batchv1.JobComplete)},
+			&corev1.ServiceAccount{}, &observedInstances{}))
+
+		progressing := condition(t, r, queued, ConditionPGBackRestBackupProgressing)
+		assert.Assert(t, progressing != nil)
+		assert.Equal(t, progressing.Reason, "ClusterNotReady")
+	})
+
+	t.Run("Finalizer", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		expiring := backup("expiring", "uid-expiring")
+		expiring.Spec.ExpireOnDelete = true
+
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(expiring).Build()
+
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			nil, nil, &corev1.ServiceAccount{}, &observedInstances{}))
+		assert.NilError(t, r.Client.Get(ctx, client.ObjectKeyFromObject(expiring), expiring))
+		assert.DeepEqual(t, expiring.Finalizers, []string{naming.Finalizer})
+
+		// The finalizer goes away with the option.
+		expiring.Spec.ExpireOnDelete = false
+		assert.NilError(t, r.Client.Update(ctx, expiring))
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			nil, nil, &corev1.ServiceAccount{}, &observedInstances{}))
+		assert.NilError(t, r.Client.Get(ctx, client.ObjectKeyFromObject(expiring), expiring))
+		assert.Assert(t, len(expiring.Finalizers) == 0)
+	})
+}
+
+func TestFinalizePGBackRestBackup(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+		Name: "repo2",
+		GCS:  &v1beta1.RepoGCS{Bucket: "bucket"},
+	}}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		InventoryTime: &metav1.Time{Time: time.Now()},
+		Repos: []v1beta1.RepoStatus{{
+			Name: "repo2", StanzaCreated: true,
+			Backups: []v1beta1.PGBackRestBackupInfo{{Label: "20230102-030510F"}},
+		}},
+	}
+
+	pod := &corev1.Pod{}
+	pod.Namespace = cluster.Namespace
+	pod.Name = "hippo-instance1-abcd-0"
+	pod.Labels = map[string]string{
+		naming.LabelCluster:     cluster.Name,
+		naming.LabelInstance:    "hippo-instance1-abcd",
+		naming.LabelInstanceSet: "instance1",
+		naming.LabelRole:        naming.RolePatroniLeader,
+	}
+	pod.Status.Phase = corev1.PodRunning
+
+	backup := &v1beta1.PGBackRestBackup{}
+	backup.Namespace, backup.Name = cluster.Namespace, "expiring"
+	backup.Finalizers = []string{naming.Finalizer}
+	backup.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	backup.Spec.PostgresClusterName = cluster.Name
+	backup.Spec.RepoName = "repo2"
+	backup.Spec.ExpireOnDelete = true
+	backup.Status.Backup = &v1beta1.PGBackRestBackupInfo{Label: "20230102-030510F"}
+
+	t.Run("Error", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		backup := backup.DeepCopy()
+		recorder := record.NewFakeRecorder(1)
+
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, backup).Build()
+		r.Recorder = recorder
+		r.PodExec = func(string, string, string, io.Reader, io.Writer, io.Writer, ...string) error {
+			return errors.New("boom")
+		}
+
+		assert.ErrorContains(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			nil, nil, &corev1.ServiceAccount{}, &observedInstances{}), "boom")
+		assert.Assert(t, strings.Contains(<-recorder.Events, EventUnableToExpireBackupSet))
+
+		assert.NilError(t, r.Client.Get(ctx, client.ObjectKeyFromObject(backup), backup))
+		assert.DeepEqual(t, backup.Finalizers, []string{naming.Finalizer})
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		backup := backup.DeepCopy()
+
+		var calls int
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, backup).Build()
+		r.PodExec = func(namespace, name, container string,
+			_ io.Reader, _, _ io.Writer, command ...string) error {
+			calls++
+			assert.Equal(t, name, "hippo-instance1-abcd-0")
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "expire", "--stanza=db", "--repo=2", "--set=20230102-030510F",
+			})
+			return nil
+		}
+
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			nil, nil, &corev1.ServiceAccount{}, &observedInstances{}))
+		assert.Equal(t, calls, 1)
+		assert.Assert(t, cluster.Status.PGBackRest.InventoryTime == nil,
+			"expected the inventory to be read again")
+
+		// The object goes away with its last finalizer.
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(backup), backup)
+		assert.Assert(t, apierrors.IsNotFound(err), "got %#v", err)
+	})
+
+	t.Run("JustCompleted", func(t *testing.T) {
+		started := time.Date(2023, time.January, 2, 3, 5, 0, 0, time.UTC)
+
+		cluster := cluster.DeepCopy()
+		cluster.Status.PGBackRest.InventoryTime = &metav1.Time{Time: started}
+		cluster.Status.PGBackRest.Repos[0].Backups = nil
+
+		backup := backup.DeepCopy()
+		backup.UID = "backup-uid"
+		backup.Status = v1beta1.PGBackRestBackupStatus{}
+
+		job := &batchv1.Job{}
+		job.Namespace, job.Name = cluster.Namespace, "hippo-backup-expiring"
+		job.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: v1beta1.GroupVersion.String(), Kind: "PGBackRestBackup",
+			Name: backup.Name, UID: backup.UID,
+		}}
+		job.Status.StartTime = &metav1.Time{Time: started.Add(time.Second)}
+		job.Status.CompletionTime = &metav1.Time{Time: started.Add(time.Minute)}
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+		}}
+
+		var calls int
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, backup).Build()
+		r.PodExec = func(namespace, name, container string,
+			_ io.Reader, _, _ io.Writer, command ...string) error {
+			calls++
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "expire", "--stanza=db", "--repo=2", "--set=20230102-030510F",
+			})
+			return nil
+		}
+
+		// The repository was read before the Job completed, so the backup is not
+		// there yet. Wait for it to be read again.
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			[]*batchv1.Job{job}, nil, &corev1.ServiceAccount{}, &observedInstances{}))
+		assert.Equal(t, calls, 0)
+		assert.Assert(t, cluster.Status.PGBackRest.InventoryTime == nil,
+			"expected the inventory to be read again")
+
+		assert.NilError(t, r.Client.Get(ctx, client.ObjectKeyFromObject(backup), backup))
+		assert.DeepEqual(t, backup.Finalizers, []string{naming.Finalizer})
+
+		// Once read, the backup is expired.
+		cluster.Status.PGBackRest.InventoryTime = &metav1.Time{Time: started.Add(2 * time.Minute)}
+		cluster.Status.PGBackRest.Repos[0].Backups = []v1beta1.PGBackRestBackupInfo{{
+			Label:     "20230102-030510F",
+			StartTime: &metav1.Time{Time: started.Add(10 * time.Second)},
+			StopTime:  &metav1.Time{Time: started.Add(50 * time.Second)},
+		}}
+
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			[]*batchv1.Job{job}, nil, &corev1.ServiceAccount{}, &observedInstances{}))
+		assert.Equal(t, calls, 1)
+
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(backup), backup)
+		assert.Assert(t, apierrors.IsNotFound(err), "got %#v", err)
+	})
+
+	t.Run("NotReported", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.PGBackRest.Repos[0].Backups = []v1beta1.PGBackRestBackupInfo{
+			{Label: "20230110-030510F"},
+		}
+		cluster.Status.PGBackRest.Repos[0].BackupCount = 30
+
+		for _, tt := range []struct {
+			name    string
+			info    string
+			expires bool
+		}{
+			{name: "Present", expires: true, info: `[{"name": "db", "backup": [
+				{"label": "20230102-030510F", "database": {"repo-key": 2}}
+			]}]`},
+			{name: "Absent", expires: false, info: `[{"name": "db", "backup": [
+				{"label": "20230102-030510F", "database": {"repo-key": 1}}
+			]}]`},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				cluster := cluster.DeepCopy()
+				backup := backup.DeepCopy()
+
+				var expired bool
+				r := new(Reconciler)
+				r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, backup).Build()
+				r.PodExec = func(namespace, name, container string,
+					_ io.Reader, stdout, _ io.Writer, command ...string) error {
+					switch command[1] {
+					case "info":
+						_, err := stdout.Write([]byte(tt.info))
+						return err
+					case "expire":
+						expired = true
+						return nil
+					}
+					panic("unexpected command")
+				}
+
+				assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+					nil, nil, &corev1.ServiceAccount{}, &observedInstances{}))
+				assert.Equal(t, expired, tt.expires)
+
+				err := r.Client.Get(ctx, client.ObjectKeyFromObject(backup), backup)
+				assert.Assert(t, apierrors.IsNotFound(err), "got %#v", err)
+			})
+		}
+	})
+
+	t.Run("AlreadyExpired", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Status.PGBackRest.Repos[0].Backups = nil
+		backup := backup.DeepCopy()
+
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod, backup).Build()
+		r.PodExec = func(string, string, string, io.Reader, io.Writer, io.Writer, ...string) error {
+			panic("expected no exec")
+		}
+
+		assert.NilError(t, r.reconcilePGBackRestBackups(ctx, cluster,
+			nil, nil, &corev1.ServiceAccount{}, &observedInstances{}))
+
+		// The object goes away with its last finalizer.
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(backup), backup)
+		assert.Assert(t, apierrors.IsNotFound(err), "got %#v", err)
+	})
+}
@@ -46,7 +46,9 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -3787,3 +3789,215 @@ func TestSetScheduledJobStatus(t *testing.T) {
 		assert.Assert(t, len(postgresCluster.Status.PGBackRest.ScheduledBackups) == 0)
 	})
 }
+
+func TestBackupInventoryStale(t *testing.T) {
+	now := time.Now()
+	status := new(v1beta1.PGBackRestStatus)
+	assert.Assert(t, backupInventoryStale(status, now), "expected never read to be stale")
+
+	status.InventoryTime = &metav1.Time{Time: now.Add(-time.Minute)}
+	assert.Assert(t, !backupInventoryStale(status, now))
+	assert.Assert(t, backupInventoryStale(status, now.Add(pgbackrestInventoryInterval)))
+
+	status.ManualBackup = &v1beta1.PGBackRestJobStatus{
+		CompletionTime: &metav1.Time{Time: now.Add(-2 * time.Minute)},
+	}
+	assert.Assert(t, !backupInventoryStale(status, now))
+
+	status.ScheduledBackups = []v1beta1.PGBackRestScheduledBackupStatus{{
+		CompletionTime: &metav1.Time{Time: now.Add(-30 * time.Second)},
+	}}
+	assert.Assert(t, backupInventoryStale(status, now), "expected a completed backup to be stale")
+}
+
+func TestSetBackupInventory(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{Name: "repo1"}, {Name: "repo2"}},
+	}
+
+	stanza := &pgbackrest.InfoStanza{Name: "db"}
+	for _, backup := range []struct {
+		label       string
+		repo        int
+		start, stop int64
+		delta       int64
+	}{
+		{"20230101-000000F", 1, 1672531200, 1672531500, 100},
+		{"20230102-000000F_20230102-000000I", 1, 1672617600, 1672617660, 20},
+		{"20230101-000000F", 2, 1672531200, 1672531800, 90},
+	} {
+		var info pgbackrest.InfoBackup
+		info.Label = backup.label
+		info.Type = "full"
+		info.Database.RepoKey = backup.repo
+		info.Timestamp.Start = backup.start
+		info.Timestamp.Stop = backup.stop
+		info.Info.Size = 1000
+		info.Info.Repository.Delta = backup.delta
+		info.Archive.Start = "000000010000000000000002"
+		info.Archive.Stop = "000000010000000000000003"
+		stanza.Backup = append(stanza.Backup, info)
+	}
+
+	// Only the second repository has stored WAL since its newest backup.
+	archived := map[int]time.Time{
+		1: time.Unix(1672617700, 0).UTC(),
+		2: time.Unix(1672531700, 0).UTC(),
+	}
+	setBackupInventory(cluster, stanza, archived)
+
+	repo1 := cluster.Status.PGBackRest.Repos[0]
+	assert.Equal(t, len(repo1.Backups), 2)
+	assert.Equal(t, repo1.Backups[0].Label, "20230101-000000F")
+	assert.Equal(t, repo1.Backups[0].Type, "full")
+	assert.Equal(t, repo1.Backups[0].DatabaseSizeBytes, int64(1000))
+	assert.Equal(t, repo1.Backups[0].WALStart, "000000010000000000000002")
+	assert.Equal(t, *repo1.SizeBytes, int64(120))
+	assert.Equal(t, repo1.OldestRestorableTime.Unix(), int64(1672531500))
+	assert.Equal(t, repo1.BackupCount, int32(2))
+	assert.Equal(t, repo1.NewestRestorableTime.Unix(), int64(1672617700),
+		"expected archived WAL to extend the restorable window")
+
+	repo2 := cluster.Status.PGBackRest.Repos[1]
+	assert.Equal(t, len(repo2.Backups), 1)
+	assert.Equal(t, *repo2.SizeBytes, int64(90))
+	assert.Equal(t, repo2.OldestRestorableTime.Unix(), int64(1672531800))
+	assert.Equal(t, repo2.NewestRestorableTime.Unix(), int64(1672531800),
+		"expected WAL of another repository to be ignored")
+
+	t.Run("Many", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		stanza := &pgbackrest.InfoStanza{Name: "db"}
+		for i := 0; i < maxReportedBackups+5; i++ {
+			var info pgbackrest.InfoBackup
+			info.Label = fmt.Sprintf("backup-%02d", i)
+			info.Database.RepoKey = 1
+			info.Timestamp.Stop = 1672531200 + int64(i)*3600
+			info.Info.Repository.Delta = 10
+			stanza.Backup = append(stanza.Backup, info)
+		}
+
+		setBackupInventory(cluster, stanza, nil)
+
+		repo1 := cluster.Status.PGBackRest.Repos[0]
+		assert.Equal(t, len(repo1.Backups), maxReportedBackups)
+		assert.Equal(t, repo1.BackupCount, int32(maxReportedBackups+5))
+		assert.Equal(t, repo1.Backups[0].Label, "backup-05", "expected the newest backups")
+		assert.Equal(t, *repo1.SizeBytes, int64(10*(maxReportedBackups+5)))
+		assert.Equal(t, repo1.OldestRestorableTime.Unix(), int64(1672531200),
+			"expected the oldest backup, even when it is not reported")
+	})
+
+	// Repositories without backups are cleared.
+	setBackupInventory(cluster, &pgbackrest.InfoStanza{}, nil)
+	assert.Assert(t, cluster.Status.PGBackRest.Repos[0].Backups == nil)
+	assert.Equal(t, cluster.Status.PGBackRest.Repos[0].BackupCount, int32(0))
+	assert.Assert(t, cluster.Status.PGBackRest.Repos[0].SizeBytes == nil)
+	assert.Assert(t, cluster.Status.PGBackRest.Repos[0].NewestRestorableTime == nil)
+}
+
+func TestReconcileRetention(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace = "ns1"
+	cluster.Name = "hippo"
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{
+		Name: "repo2",
+		GCS:  &v1beta1.RepoGCS{Bucket: "bucket"},
+		Retention: &v1beta1.PGBackRestRetention{
+			Full: initialize.Int32(3),
+		},
+	}}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		InventoryTime: &metav1.Time{Time: time.Now()},
+		Repos:         []v1beta1.RepoStatus{{Name: "repo2", StanzaCreated: true}},
+	}
+
+	pod := &corev1.Pod{}
+	pod.Namespace = cluster.Namespace
+	pod.Name = "hippo-instance1-abcd-0"
+	pod.Labels = map[string]string{
+		naming.LabelCluster:     cluster.Name,
+		naming.LabelInstance:    "hippo-instance1-abcd",
+		naming.LabelInstanceSet: "instance1",
+		naming.LabelRole:        naming.RolePatroniLeader,
+	}
+
+	t.Run("PodNotRunning", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithObjects(pod).Build()
+		r.PodExec = func(string, string, string, io.Reader, io.Writer, io.Writer, ...string) error {
+			panic("expected no exec")
+		}
+
+		assert.NilError(t, r.reconcileRetention(ctx, cluster))
+		assert.Equal(t, cluster.Status.PGBackRest.Repos[0].RetentionHash, "")
+	})
+
+	pod = pod.DeepCopy()
+	pod.Status.Phase = corev1.PodRunning
+
+	t.Run("Error", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		recorder := record.NewFakeRecorder(1)
+
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithObjects(pod).Build()
+		r.Recorder = recorder
+		r.PodExec = func(string, string, string, io.Reader, io.Writer, io.Writer, ...string) error {
+			return errors.New("boom")
+		}
+
+		assert.ErrorContains(t, r.reconcileRetention(ctx, cluster), "boom")
+		assert.Equal(t, cluster.Status.PGBackRest.Repos[0].RetentionHash, "")
+		assert.Assert(t, strings.Contains(<-recorder.Events, EventUnableToExpireBackups))
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+
+		var calls int
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithObjects(pod).Build()
+		r.PodExec = func(namespace, name, container string,
+			_ io.Reader, _, _ io.Writer, command ...string) error {
+			calls++
+			assert.Equal(t, namespace, "ns1")
+			assert.Equal(t, name, "hippo-instance1-abcd-0")
+			assert.Equal(t, container, naming.ContainerDatabase)
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "expire", "--stanza=db", "--repo=2", "--repo2-retention-full=3",
+			})
+			return nil
+		}
+
+		assert.NilError(t, r.reconcileRetention(ctx, cluster))
+		assert.Equal(t, calls, 1)
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].RetentionHash != "")
+		assert.Assert(t, cluster.Status.PGBackRest.InventoryTime == nil,
+			"expected the inventory to be read again")
+
+		// Nothing happens until the retention changes again.
+		assert.NilError(t, r.reconcileRetention(ctx, cluster))
+		assert.Equal(t, calls, 1)
+
+		cluster.Spec.Backups.PGBackRest.Repos[0].Retention.Full = initialize.Int32(4)
+		r.PodExec = func(_, _, _ string, _ io.Reader, _, _ io.Writer, command ...string) error {
+			calls++
+			assert.Equal(t, command[len(command)-1], "--repo2-retention-full=4")
+			return nil
+		}
+		assert.NilError(t, r.reconcileRetention(ctx, cluster))
+		assert.Equal(t, calls, 2)
+
+		// Removing the retention expires nothing.
+		cluster.Spec.Backups.PGBackRest.Repos[0].Retention = nil
+		assert.NilError(t, r.reconcileRetention(ctx, cluster))
+		assert.Equal(t, calls, 2)
+		assert.Equal(t, cluster.Status.PGBackRest.Repos[0].RetentionHash, "")
+	})
+}
@@ -0,0 +1,212 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// EventRestoreVerified is the event reason utilized when a restore drill restores
+	// the latest backup of a repository and the restored database passes its checks
+	EventRestoreVerified = "RestoreVerified"
+
+	// EventRestoreVerificationFailed is the event reason utilized when a restore drill
+	// is unable to restore the latest backup of a repository or the restored database
+	// fails its checks
+	EventRestoreVerificationFailed = "RestoreVerificationFailed"
+)
+
+// generateVerifyJobSpecIntent returns the spec of a Job that restores the latest backup in
+// repo to an ephemeral volume and checks the restored database. The Job uses the same
+// pgBackRest configuration as a restore in-place, but nothing it does affects the volumes
+// or Pods of the cluster.
+func generateVerifyJobSpecIntent(cluster *v1beta1.PostgresCluster,
+	repo v1beta1.PGBackRestRepo, labels, annotations map[string]string,
+) (*batchv1.JobSpec, error) {
+	claimSpec := repo.Verify.VolumeClaimSpec
+	if claimSpec == nil && len(cluster.Spec.InstanceSets) > 0 {
+		claimSpec = &cluster.Spec.InstanceSets[0].DataVolumeClaimSpec
+	}
+	if claimSpec == nil {
+		return nil, errors.Errorf("no volume claim spec to verify %q", repo.Name)
+	}
+
+	// Recover only until the restored database is consistent, then promote it so the
+	// restore script is able to finish. Keep WAL on the same temporary volume.
+	pgdata := postgres.DataDirectory(cluster)
+	opts := []string{
+		"--stanza=" + pgbackrest.DefaultStanzaName,
+		"--pg1-path=" + pgdata,
+		"--repo=" + regexRepoIndex.FindString(repo.Name),
+		"--type=immediate",
+		"--target-action=promote",
+		"--link-map=pg_wal=" + postgres.WALDirectory(cluster,
+			&v1beta1.PostgresInstanceSetSpec{}),
+	}
+
+	dataVolumeMount := postgres.DataVolumeMount()
+	dataVolume := corev1.Volume{
+		Name: dataVolumeMount.Name,
+		VolumeSource: corev1.VolumeSource{
+			Ephemeral: &corev1.EphemeralVolumeSource{
+				VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+					Spec: *claimSpec.DeepCopy(),
+				},
+			},
+		},
+	}
+
+	jobSpec := &batchv1.JobSpec{
+		// A failed drill is reported rather than retried.
+		BackoffLimit: initialize.Int32(0),
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: annotations},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Command: pgbackrest.VerifyCommand(pgdata, repo.Verify.Query,
+						strings.Join(opts, " ")),
+					Env:             []corev1.EnvVar{{Name: "PGHOST", Value: "/tmp"}},
+					Image:           config.PostgresContainerImage(cluster),
+					ImagePullPolicy: cluster.Spec.ImagePullPolicy,
+					Name:            naming.PGBackRestRestoreContainerName,
+					Resources:       repo.Verify.Resources,
+					SecurityContext: initialize.RestrictedSecurityContext(),
+					VolumeMounts:    []corev1.VolumeMount{dataVolumeMount},
+				}},
+
+				// Do not add environment variables describing services in this namespace.
+				EnableServiceLinks: initialize.Bool(false),
+
+				// pgBackRest may interact with a cloud storage provider. Use the instance
+				// ServiceAccount for its possible cloud identity without mounting its
+				// Kubernetes API credentials.
+				AutomountServiceAccountToken: initialize.Bool(false),
+				ServiceAccountName:           naming.ClusterInstanceRBAC(cluster).Name,
+
+				RestartPolicy:   corev1.RestartPolicyNever,
+				SecurityContext: postgres.PodSecurityContext(cluster),
+				Volumes:         []corev1.Volume{dataVolume},
+			},
+		},
+	}
+
+	// set the TTL, priority class name, tolerations, and affinity, if they exist
+	if jobs := cluster.Spec.Backups.PGBackRest.Jobs; jobs != nil {
+		jobSpec.TTLSecondsAfterFinished = jobs.TTLSecondsAfterFinished
+		if jobs.PriorityClassName != nil {
+			jobSpec.Template.Spec.PriorityClassName = *jobs.PriorityClassName
+		}
+		jobSpec.Template.Spec.Tolerations = jobs.Tolerations
+		jobSpec.Template.Spec.Affinity = jobs.Affinity
+	}
+
+	// Set the image pull secrets, if any exist.
+	// This is set here rather than using the service account due to the lack
+	// of propagation to existing pods when the CRD is updated:
+	// https://github.com/kubernetes/kubernetes/issues/88456
+	jobSpec.Template.Spec.ImagePullSecrets = cluster.Spec.ImagePullSecrets
+
+	pgbackrest.AddConfigToRestorePod(cluster, nil, &jobSpec.Template.Spec)
+	addNSSWrapper(
+		config.PGBackRestContainerImage(cluster),
+		cluster.Spec.ImagePullPolicy,
+		&jobSpec.Template)
+	addTMPEmptyDir(&jobSpec.Template)
+
+	return jobSpec, nil
+}
+
+// setVerifyStatus reports the most recent restore drill of every repository in the status
+// of cluster. It records an event the first time it sees that a drill has finished.
+func (r *Reconciler) setVerifyStatus(cluster *v1beta1.PostgresCluster, jobs []*batchv1.Job) {
+	if cluster.Status.PGBackRest == nil {
+		return
+	}
+
+	verifying := map[string]bool{}
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		verifying[repo.Name] = repo.Verify != nil
+	}
+
+	for i := range cluster.Status.PGBackRest.Repos {
+		status := &cluster.Status.PGBackRest.Repos[i]
+		if !verifying[status.Name] {
+			status.Verify = nil
+			continue
+		}
+
+		var latest *batchv1.Job
+		for _, job := range jobs {
+			if job.GetLabels()[naming.LabelPGBackRestRepo] == status.Name &&
+				(latest == nil || latest.CreationTimestamp.Before(&job.CreationTimestamp)) {
+				latest = job
+			}
+		}
+		if latest == nil {
+			continue
+		}
+
+		previous := status.Verify
+		status.Verify = &v1beta1.PGBackRestVerifyStatus{
+			JobName:   latest.GetName(),
+			StartTime: latest.Status.StartTime,
+		}
+		switch {
+		case jobCompleted(latest):
+			status.Verify.Passed = initialize.Bool(true)
+			status.Verify.CompletionTime = latest.Status.CompletionTime
+		case jobFailed(latest):
+			status.Verify.Passed = initialize.Bool(false)
+			for _, condition := range latest.Status.Conditions {
+				if condition.Type == batchv1.JobFailed {
+					status.Verify.CompletionTime = condition.LastTransitionTime.DeepCopy()
+				}
+			}
+		default:
+			continue
+		}
+
+		if previous != nil && previous.JobName == latest.GetName() && previous.Passed != nil {
+			continue
+		}
+
+		var elapsed time.Duration
+		if status.Verify.StartTime != nil && status.Verify.CompletionTime != nil {
+			elapsed = status.Verify.CompletionTime.Sub(status.Verify.StartTime.Time).Round(time.Second)
+		}
+		if *status.Verify.Passed {
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, EventRestoreVerified,
+				"Restore drill of %q passed in %s: Job %q", status.Name, elapsed, latest.GetName())
+		} else {
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, EventRestoreVerificationFailed,
+				"Restore drill of %q failed after %s: Job %q", status.Name, elapsed, latest.GetName())
+		}
+	}
+}
@@ -0,0 +1,207 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestGenerateVerifyJobSpecIntent(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.PostgresVersion = 14
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{
+		Name: "instance1",
+		DataVolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("1Gi"),
+				},
+			},
+		},
+	}}
+
+	repo := v1beta1.PGBackRestRepo{
+		Name:   "repo2",
+		Verify: &v1beta1.PGBackRestVerify{Schedule: "@daily", Query: "SELECT 1"},
+	}
+	labels := naming.PGBackRestCronJobLabels(cluster.Name, repo.Name, verify)
+
+	spec, err := generateVerifyJobSpecIntent(cluster, repo, labels, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, *spec.BackoffLimit, int32(0))
+	assert.DeepEqual(t, spec.Template.Labels, map[string]string(labels))
+
+	pod := spec.Template.Spec
+	assert.Equal(t, pod.RestartPolicy, corev1.RestartPolicyNever)
+	assert.Equal(t, pod.ServiceAccountName, "hippo-instance")
+	assert.Equal(t, *pod.AutomountServiceAccountToken, false)
+
+	assert.Equal(t, len(pod.Containers), 1)
+	container := pod.Containers[0]
+	assert.Equal(t, container.Name, naming.PGBackRestRestoreContainerName)
+	assert.Equal(t, container.Command[5], "/pgdata/pg14")
+	assert.Equal(t, container.Command[6], "SELECT 1")
+	assert.Equal(t, container.Command[len(container.Command)-1], strings.Join([]string{
+		"--stanza=db", "--pg1-path=/pgdata/pg14", "--repo=2",
+		"--type=immediate", "--target-action=promote",
+		"--link-map=pg_wal=/pgdata/pg14_wal",
+	}, " "))
+
+	var mounts []string
+	for _, mount := range container.VolumeMounts {
+		mounts = append(mounts, mount.Name)
+	}
+	assert.DeepEqual(t, mounts, []string{"postgres-data", "pgbackrest-config", "tmp"})
+
+	// The restored database is written to an ephemeral volume like the first instance set.
+	assert.Equal(t, pod.Volumes[0].Name, "postgres-data")
+	assert.Assert(t, pod.Volumes[0].Ephemeral != nil)
+	assert.DeepEqual(t, pod.Volumes[0].Ephemeral.VolumeClaimTemplate.Spec,
+		cluster.Spec.InstanceSets[0].DataVolumeClaimSpec)
+
+	t.Run("VolumeClaimSpec", func(t *testing.T) {
+		repo := *repo.DeepCopy()
+		repo.Verify.VolumeClaimSpec = &corev1.PersistentVolumeClaimSpec{
+			StorageClassName: new(string),
+		}
+
+		spec, err := generateVerifyJobSpecIntent(cluster, repo, labels, nil)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, spec.Template.Spec.Volumes[0].Ephemeral.VolumeClaimTemplate.Spec,
+			*repo.Verify.VolumeClaimSpec)
+	})
+}
+
+func TestSetVerifyStatus(t *testing.T) {
+	started := time.Date(2023, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+		{Name: "repo1"},
+		{Name: "repo2", Verify: &v1beta1.PGBackRestVerify{Schedule: "@daily"}},
+	}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{
+			{Name: "repo1", Verify: &v1beta1.PGBackRestVerifyStatus{JobName: "old"}},
+			{Name: "repo2"},
+		},
+	}
+
+	job := func(name string, created time.Time, conditions ...batchv1.JobCondition) *batchv1.Job {
+		job := &batchv1.Job{}
+		job.Name = name
+		job.CreationTimestamp = metav1.NewTime(created)
+		job.Labels = naming.PGBackRestCronJobLabels(cluster.Name, "repo2", verify)
+		job.Status.StartTime = &metav1.Time{Time: created}
+		job.Status.Conditions = conditions
+		for _, c := range conditions {
+			if c.Type == batchv1.JobComplete {
+				job.Status.CompletionTime = c.LastTransitionTime.DeepCopy()
+			}
+		}
+		return job
+	}
+	failed := job("failed", started, batchv1.JobCondition{
+		Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(started.Add(90 * time.Second)),
+	})
+	passed := job("passed", started.Add(time.Hour), batchv1.JobCondition{
+		Type: batchv1.JobComplete, Status: corev1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(started.Add(time.Hour + 3*time.Minute)),
+	})
+	running := job("running", started.Add(2*time.Hour))
+
+	t.Run("Failed", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		recorder := record.NewFakeRecorder(10)
+		r := &Reconciler{Recorder: recorder}
+
+		r.setVerifyStatus(cluster, []*batchv1.Job{failed})
+
+		// Status is removed from repos that are not verified.
+		assert.Assert(t, cluster.Status.PGBackRest.Repos[0].Verify == nil)
+
+		status := cluster.Status.PGBackRest.Repos[1].Verify
+		assert.Assert(t, status != nil)
+		assert.Equal(t, status.JobName, "failed")
+		assert.Equal(t, *status.Passed, false)
+		assert.Equal(t, status.CompletionTime.Time, started.Add(90*time.Second))
+
+		assert.Equal(t, len(recorder.Events), 1)
+		event := <-recorder.Events
+		assert.Assert(t, strings.Contains(event, "Warning "+EventRestoreVerificationFailed), event)
+		assert.Assert(t, strings.Contains(event, `"repo2" failed after 1m30s`), event)
+
+		// Events are recorded once per Job.
+		r.setVerifyStatus(cluster, []*batchv1.Job{failed})
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+
+	t.Run("Passed", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		recorder := record.NewFakeRecorder(10)
+		r := &Reconciler{Recorder: recorder}
+
+		r.setVerifyStatus(cluster, []*batchv1.Job{passed, failed})
+
+		status := cluster.Status.PGBackRest.Repos[1].Verify
+		assert.Equal(t, status.JobName, "passed")
+		assert.Equal(t, *status.Passed, true)
+
+		assert.Equal(t, len(recorder.Events), 1)
+		event := <-recorder.Events
+		assert.Assert(t, strings.Contains(event, "Normal "+EventRestoreVerified), event)
+		assert.Assert(t, strings.Contains(event, `"repo2" passed in 3m0s`), event)
+	})
+
+	t.Run("Running", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		recorder := record.NewFakeRecorder(10)
+		r := &Reconciler{Recorder: recorder}
+
+		r.setVerifyStatus(cluster, []*batchv1.Job{failed, running, passed})
+
+		status := cluster.Status.PGBackRest.Repos[1].Verify
+		assert.Equal(t, status.JobName, "running")
+		assert.Assert(t, status.Passed == nil)
+		assert.Assert(t, status.CompletionTime == nil)
+		assert.Equal(t, len(recorder.Events), 0)
+	})
+}
+
+func TestBackupScheduleFoundVerify(t *testing.T) {
+	repo := v1beta1.PGBackRestRepo{Name: "repo1"}
+	assert.Assert(t, !backupScheduleFound(repo, verify))
+
+	repo.Verify = &v1beta1.PGBackRestVerify{Schedule: "@daily"}
+	assert.Assert(t, backupScheduleFound(repo, verify))
+	assert.Assert(t, !backupScheduleFound(repo, full))
+}
@@ -317,6 +317,7 @@ func (r *Reconciler) generatePGBouncerService(
 		}
 	}
 	service.Spec.Ports = []corev1.ServicePort{servicePort}
+	setServiceOptions(service, cluster.Spec.Proxy.PGBouncer.Service)
 
 	err := errors.WithStack(r.setControllerReference(cluster, service))
 
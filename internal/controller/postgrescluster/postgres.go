@@ -18,25 +18,32 @@ package postgrescluster
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"net"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/pgaudit"
+	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/postgis"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	pgpassword "github.com/crunchydata/postgres-operator/internal/postgres/password"
@@ -64,7 +71,6 @@ func (r *Reconciler) generatePostgresUserSecret(
 
 	intent.Data["host"] = []byte(hostname)
 	intent.Data["port"] = []byte(port)
-	intent.Data["user"] = []byte(username)
 
 	// Use the existing password and verifier.
 	if existing != nil {
@@ -72,8 +78,25 @@ func (r *Reconciler) generatePostgresUserSecret(
 		intent.Data["verifier"] = existing.Data["verifier"]
 	}
 
-	// When password is unset, generate a new one according to the specified policy.
-	if len(intent.Data["password"]) == 0 {
+	// A user with a password grace period alternates with another PostgreSQL
+	// user. Keep the verifier of the one that is not active so its password
+	// continues to work. The Secret has the user with the newest password.
+	if existing != nil && len(existing.Data["previous-verifier"]) > 0 &&
+		spec.Password != nil && spec.Password.Rotation != nil &&
+		spec.Password.Rotation.GracePeriodSeconds != nil {
+		intent.Data["previous-verifier"] = existing.Data["previous-verifier"]
+	}
+	login := username
+	if status := postgresUserPasswordStatus(cluster, username); status != nil &&
+		status.ActiveUser != "" {
+		login = status.ActiveUser
+	}
+	intent.Data["user"] = []byte(login)
+
+	// When password is unset, generate a new one according to the specified
+	// policy. A password from another Secret is never generated.
+	if len(intent.Data["password"]) == 0 &&
+		(spec.Password == nil || spec.Password.SecretKeyRef == nil) {
 		// NOTE: The tests around ASCII passwords are lacking. When changing
 		// this, make sure that ASCII is the default.
 		generate := util.GenerateASCIIPassword
@@ -92,11 +115,19 @@ func (r *Reconciler) generatePostgresUserSecret(
 		intent.Data["verifier"] = nil
 	}
 
+	// When the existing verifier does not match the password, discard it.
+	// This happens when someone changes the password but not the verifier.
+	if len(intent.Data["verifier"]) > 0 && len(intent.Data["password"]) > 0 {
+		if !r.verifyPostgresUserPassword(cluster, login,
+			string(intent.Data["password"]), string(intent.Data["verifier"]),
+		) {
+			intent.Data["verifier"] = nil
+		}
+	}
+
 	// When a password has been generated or the verifier is empty,
 	// generate a verifier based on the current password.
-	// NOTE(cbandy): We don't have a function to compare a plaintext
-	// password to a SCRAM verifier.
-	if len(intent.Data["verifier"]) == 0 {
+	if len(intent.Data["verifier"]) == 0 && len(intent.Data["password"]) > 0 {
 		verifier, err := pgpassword.NewSCRAMPassword(string(intent.Data["password"])).Build()
 		if err != nil {
 			return nil, errors.WithStack(err)
@@ -104,6 +135,26 @@ func (r *Reconciler) generatePostgresUserSecret(
 		intent.Data["verifier"] = []byte(verifier)
 	}
 
+	// Another Secret may provide only a verifier. Leave the password out of
+	// connection URIs when there is none.
+	userinfo := url.User(login)
+	if len(intent.Data["password"]) > 0 {
+		userinfo = url.UserPassword(login, string(intent.Data["password"]))
+	} else {
+		delete(intent.Data, "password")
+	}
+	if len(intent.Data["verifier"]) == 0 {
+		delete(intent.Data, "verifier")
+	}
+
+	// A user with a client certificate must connect using TLS. Verify the
+	// server certificate, too.
+	// - https://www.postgresql.org/docs/current/libpq-ssl.html
+	options := url.Values{}
+	if spec.ClientCertificate {
+		options.Set("sslmode", "verify-full")
+	}
+
 	// When a database has been specified, include it and a connection URI.
 	// - https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
 	if len(spec.Databases) > 0 {
@@ -111,17 +162,23 @@ func (r *Reconciler) generatePostgresUserSecret(
 
 		intent.Data["dbname"] = []byte(database)
 		intent.Data["uri"] = []byte((&url.URL{
-			Scheme: "postgresql",
-			User:   url.UserPassword(username, string(intent.Data["password"])),
-			Host:   net.JoinHostPort(hostname, port),
-			Path:   database,
+			Scheme:   "postgresql",
+			User:     userinfo,
+			Host:     net.JoinHostPort(hostname, port),
+			Path:     database,
+			RawQuery: options.Encode(),
 		}).String())
 
 		// The JDBC driver requires a different URI scheme and query component.
 		// - https://jdbc.postgresql.org/documentation/use/#connection-parameters
 		query := url.Values{}
-		query.Set("user", username)
-		query.Set("password", string(intent.Data["password"]))
+		for k := range options {
+			query.Set(k, options.Get(k))
+		}
+		query.Set("user", login)
+		if len(intent.Data["password"]) > 0 {
+			query.Set("password", string(intent.Data["password"]))
+		}
 		intent.Data["jdbc-uri"] = []byte((&url.URL{
 			Scheme:   "jdbc:postgresql",
 			Host:     net.JoinHostPort(hostname, port),
@@ -131,7 +188,10 @@ func (r *Reconciler) generatePostgresUserSecret(
 	}
 
 	// When PgBouncer is enabled, include values for connecting through it.
-	if cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil {
+	// PgBouncer logs into PostgreSQL without a client certificate, so users
+	// that must present one cannot connect through it.
+	if cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil &&
+		!spec.ClientCertificate {
 		pgBouncer := naming.ClusterPGBouncer(cluster)
 		hostname := pgBouncer.Name + "." + pgBouncer.Namespace + ".svc"
 		port := fmt.Sprint(*cluster.Spec.Proxy.PGBouncer.Port)
@@ -143,10 +203,11 @@ func (r *Reconciler) generatePostgresUserSecret(
 			database := string(spec.Databases[0])
 
 			intent.Data["pgbouncer-uri"] = []byte((&url.URL{
-				Scheme: "postgresql",
-				User:   url.UserPassword(username, string(intent.Data["password"])),
-				Host:   net.JoinHostPort(hostname, port),
-				Path:   database,
+				Scheme:   "postgresql",
+				User:     userinfo,
+				Host:     net.JoinHostPort(hostname, port),
+				Path:     database,
+				RawQuery: options.Encode(),
 			}).String())
 
 			// The JDBC driver requires a different URI scheme and query component.
@@ -155,8 +216,13 @@ func (r *Reconciler) generatePostgresUserSecret(
 			// - https://jdbc.postgresql.org/documentation/use/#connection-parameters
 			// - https://www.pgbouncer.org/faq.html#how-to-use-prepared-statements-with-transaction-pooling
 			query := url.Values{}
-			query.Set("user", username)
-			query.Set("password", string(intent.Data["password"]))
+			for k := range options {
+				query.Set(k, options.Get(k))
+			}
+			query.Set("user", login)
+			if len(intent.Data["password"]) > 0 {
+				query.Set("password", string(intent.Data["password"]))
+			}
 			query.Set("prepareThreshold", "0")
 			intent.Data["pgbouncer-jdbc-uri"] = []byte((&url.URL{
 				Scheme:   "jdbc:postgresql",
@@ -181,7 +247,81 @@ func (r *Reconciler) generatePostgresUserSecret(
 	return intent, err
 }
 
-// reconcilePostgresDatabases creates databases inside of PostgreSQL.
+// verifiedPasswordKey identifies one PostgreSQL user of one cluster in
+// [Reconciler.verifiedPasswords].
+type verifiedPasswordKey struct {
+	cluster types.UID
+	login   string
+}
+
+// verifyPostgresUserPassword returns true when verifier was built from login
+// and password. Checking a SCRAM verifier is deliberately slow, so the latest
+// match for each user of cluster is remembered and not checked again.
+func (r *Reconciler) verifyPostgresUserPassword(
+	cluster *v1beta1.PostgresCluster, login, password, verifier string,
+) bool {
+	key := verifiedPasswordKey{cluster: cluster.UID, login: login}
+	sum := sha256.Sum256([]byte(password + "\x00" + verifier))
+	if value, ok := r.verifiedPasswords.Load(key); ok && value == sum {
+		return true
+	}
+
+	ok, _ := pgpassword.Verify(login, password, verifier)
+	if ok {
+		r.verifiedPasswords.Store(key, sum)
+	}
+	return ok
+}
+
+// forgetPostgresUserPasswords discards the passwords remembered for cluster
+// by [Reconciler.verifyPostgresUserPassword].
+func (r *Reconciler) forgetPostgresUserPasswords(cluster *v1beta1.PostgresCluster) {
+	r.verifiedPasswords.Range(func(key, _ any) bool {
+		if key.(verifiedPasswordKey).cluster == cluster.UID {
+			r.verifiedPasswords.Delete(key)
+		}
+		return true
+	})
+}
+
+// defaultPostgresUsers returns the users to create when cluster does not
+// specify any: one user that can access one database, both matching the
+// PostgresCluster name. It returns errors instead when that name is not
+// a valid PostgreSQL user name.
+func defaultPostgresUsers(
+	cluster *v1beta1.PostgresCluster,
+) ([]v1beta1.PostgresUserSpec, field.ErrorList) {
+	path := field.NewPath("spec", "users").Index(0).Child("name")
+	reUser := regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	allErrors := field.ErrorList{}
+
+	// User names cannot be too long. PostgresCluster.Name is a DNS
+	// subdomain, so use len() to count characters.
+	if n := len(cluster.Name); n > 63 {
+		allErrors = append(allErrors,
+			field.Invalid(path, cluster.Name,
+				fmt.Sprintf("should be at most %d chars long", 63)))
+	}
+	// See v1beta1.PostgresRoleSpec validation markers.
+	if !reUser.MatchString(cluster.Name) {
+		allErrors = append(allErrors,
+			field.Invalid(path, cluster.Name,
+				fmt.Sprintf("should match '%s'", reUser)))
+	}
+
+	if len(allErrors) > 0 {
+		return nil, allErrors
+	}
+
+	identifier := v1beta1.PostgresIdentifier(cluster.Name)
+	return []v1beta1.PostgresUserSpec{{
+		Name:      identifier,
+		Databases: []v1beta1.PostgresIdentifier{identifier},
+	}}, nil
+}
+
+// reconcilePostgresDatabases creates databases inside of PostgreSQL and installs
+// their extensions. Database owners are written with users.
 func (r *Reconciler) reconcilePostgresDatabases(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
 ) error {
@@ -207,9 +347,9 @@ func (r *Reconciler) reconcilePostgresDatabases(
 	databases := sets.String{}
 	if cluster.Spec.Users == nil {
 		// Users are unspecified; create one database matching the cluster name
-		// if it is also a valid database name.
-		// TODO(cbandy): Move this to a defaulting (mutating admission) webhook
-		// to leverage regular validation.
+		// if it is also a valid database name. The mutating admission webhook
+		// fills in spec.users, so this only matters for clusters that were not
+		// defaulted by it.
 		path := field.NewPath("spec", "users").Index(0).Child("databases").Index(0)
 
 		// Database names cannot be too long. PostgresCluster.Name is a DNS
@@ -229,9 +369,18 @@ func (r *Reconciler) reconcilePostgresDatabases(
 		}
 	}
 
+	// Databases with a specification are created with their properties.
+	// Leave them out of the list of names so they are created only once.
+	var extensions bool
+	for _, spec := range cluster.Spec.Databases {
+		databases.Delete(string(spec.Name))
+		extensions = extensions || len(spec.Extensions) > 0
+	}
+
 	// Calculate a hash of the SQL that should be executed in PostgreSQL.
 
 	var pgAuditOK, postgisInstallOK bool
+	extensionsOK := true
 	create := func(ctx context.Context, exec postgres.Executor) error {
 		if pgAuditOK = pgaudit.EnableInPostgreSQL(ctx, exec) == nil; !pgAuditOK {
 			// pgAudit can only be enabled after its shared library is loaded,
@@ -254,7 +403,24 @@ func (r *Reconciler) reconcilePostgresDatabases(
 				"Unable to install PostGIS")
 		}
 
-		return postgres.CreateDatabasesInPostgreSQL(ctx, exec, databases.List())
+		err := postgres.CreateDatabasesInPostgreSQL(ctx, exec, databases.List())
+
+		if err == nil && len(cluster.Spec.Databases) > 0 {
+			err = postgres.WriteDatabasesInPostgreSQL(ctx, exec, cluster.Spec.Databases)
+		}
+
+		// An extension that is missing from the image should not prevent the
+		// rest of the cluster from being reconciled. Report it and try again
+		// on the next reconcile.
+		if err == nil && extensions {
+			if extensionsOK = postgres.WriteExtensionsInPostgreSQL(
+				ctx, exec, cluster.Spec.Databases) == nil; !extensionsOK {
+				r.Recorder.Event(cluster, corev1.EventTypeWarning, "ExtensionsFailed",
+					"Unable to install or update database extensions")
+			}
+		}
+
+		return err
 	}
 
 	revision, err := safeHash32(func(hasher io.Writer) error {
@@ -285,7 +451,7 @@ func (r *Reconciler) reconcilePostgresDatabases(
 		log := logging.FromContext(ctx).WithValues("revision", revision)
 		err = errors.WithStack(create(logging.NewContext(ctx, log), podExecutor))
 	}
-	if err == nil && pgAuditOK && postgisInstallOK {
+	if err == nil && pgAuditOK && postgisInstallOK && extensionsOK {
 		cluster.Status.DatabaseRevision = revision
 	}
 
@@ -296,8 +462,9 @@ func (r *Reconciler) reconcilePostgresDatabases(
 // passwords in PostgreSQL.
 func (r *Reconciler) reconcilePostgresUsers(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
-) error {
-	users, secrets, err := r.reconcilePostgresUserSecrets(ctx, cluster)
+	root *pki.RootCertificateAuthority,
+) (reconcile.Result, error) {
+	users, secrets, err := r.reconcilePostgresUserSecrets(ctx, cluster, root)
 	if err == nil {
 		err = r.reconcilePostgresUsersInPostgreSQL(ctx, cluster, instances, users, secrets)
 	}
@@ -308,7 +475,21 @@ func (r *Reconciler) reconcilePostgresUsers(
 		// are available here, too.
 		err = r.reconcilePGAdminUsers(ctx, cluster, users, secrets)
 	}
-	return err
+
+	// Reconcile again when the next password is due.
+	result := reconcile.Result{}
+	for _, status := range cluster.Status.UserPasswords {
+		if status.NextRotationTime != nil {
+			next := time.Until(status.NextRotationTime.Time)
+			if next < time.Second {
+				next = time.Second
+			}
+			if result.RequeueAfter == 0 || next < result.RequeueAfter {
+				result.RequeueAfter = next
+			}
+		}
+	}
+	return result, err
 }
 
 // +kubebuilder:rbac:groups="",resources="secrets",verbs={list}
@@ -320,42 +501,19 @@ func (r *Reconciler) reconcilePostgresUsers(
 // Secrets it wrote.
 func (r *Reconciler) reconcilePostgresUserSecrets(
 	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	root *pki.RootCertificateAuthority,
 ) (
 	[]v1beta1.PostgresUserSpec, map[string]*corev1.Secret, error,
 ) {
 	// When users are unspecified, create one user matching the cluster name if
-	// it is also a valid user name.
-	// TODO(cbandy): Move this to a defaulting (mutating admission) webhook to
-	// leverage regular validation.
+	// it is also a valid user name. The mutating admission webhook does the
+	// same, so this only matters for clusters that were not defaulted by it.
 	specUsers := cluster.Spec.Users
 	if specUsers == nil {
-		path := field.NewPath("spec", "users").Index(0).Child("name")
-		reUser := regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
-		allErrors := field.ErrorList{}
-
-		// User names cannot be too long. PostgresCluster.Name is a DNS
-		// subdomain, so use len() to count characters.
-		if n := len(cluster.Name); n > 63 {
-			allErrors = append(allErrors,
-				field.Invalid(path, cluster.Name,
-					fmt.Sprintf("should be at most %d chars long", 63)))
-		}
-		// See v1beta1.PostgresRoleSpec validation markers.
-		if !reUser.MatchString(cluster.Name) {
-			allErrors = append(allErrors,
-				field.Invalid(path, cluster.Name,
-					fmt.Sprintf("should match '%s'", reUser)))
-		}
-
-		if len(allErrors) > 0 {
+		var allErrors field.ErrorList
+		if specUsers, allErrors = defaultPostgresUsers(cluster); len(allErrors) > 0 {
 			r.Recorder.Event(cluster, corev1.EventTypeWarning, "InvalidUser",
 				allErrors.ToAggregate().Error())
-		} else {
-			identifier := v1beta1.PostgresIdentifier(cluster.Name)
-			specUsers = []v1beta1.PostgresUserSpec{{
-				Name:      identifier,
-				Databases: []v1beta1.PostgresIdentifier{identifier},
-			}}
 		}
 	}
 
@@ -396,13 +554,14 @@ func (r *Reconciler) reconcilePostgresUserSecrets(
 				} else {
 					userSecrets[secretUserName] = secret
 				}
-			} else if err == nil {
+			} else if err == nil && metav1.IsControlledBy(secret, cluster) {
 				err = errors.WithStack(r.deleteControlled(ctx, cluster, secret))
 			}
 		}
 	}
 
 	// Reconcile each PostgreSQL user in the cluster spec.
+	now := metav1.Now().Rfc3339Copy()
 	for userName, user := range userSpecs {
 		secret := userSecrets[userName]
 
@@ -412,9 +571,22 @@ func (r *Reconciler) reconcilePostgresUserSecrets(
 			secret = defaultSecret
 		}
 
+		// Read the password or verifier from another Secret, when specified.
+		if err == nil && user.Password != nil && user.Password.SecretKeyRef != nil {
+			secret, err = r.readPostgresUserPassword(ctx, cluster, user, secret)
+		}
+
+		// Discard the existing password when it is time for a new one.
+		if err == nil {
+			secret = rotatePostgresUserPassword(cluster, user, secret, now)
+		}
+
 		if err == nil {
 			userSecrets[userName], err = r.generatePostgresUserSecret(cluster, user, secret)
 		}
+		if err == nil && user.ClientCertificate {
+			err = postgresUserCertificate(root, user, secret, userSecrets[userName])
+		}
 		if err == nil {
 			err = errors.WithStack(r.apply(ctx, userSecrets[userName]))
 		}
@@ -423,8 +595,144 @@ func (r *Reconciler) reconcilePostgresUserSecrets(
 	return specUsers, userSecrets, err
 }
 
+// postgresUserCertificate populates intent with a client certificate for the
+// user in spec, its private key, and the certificate authority. The existing
+// certificate is kept until it is due for renewal.
+func postgresUserCertificate(
+	root *pki.RootCertificateAuthority, spec *v1beta1.PostgresUserSpec,
+	existing, intent *corev1.Secret,
+) error {
+	// PostgreSQL matches the common name to the user. A user name is not a
+	// host name, so the certificate has no DNS names.
+	// - https://www.postgresql.org/docs/current/auth-cert.html
+	leaf := &pki.LeafCertificate{}
+	commonName := string(spec.Name)
+	var dnsNames []string
+
+	if existing != nil {
+		// Unmarshal and validate the stored leaf. These first errors can
+		// be ignored because they result in an invalid leaf which is then
+		// correctly regenerated.
+		_ = leaf.Certificate.UnmarshalText(existing.Data[clusterCertFile])
+		_ = leaf.PrivateKey.UnmarshalText(existing.Data[clusterKeyFile])
+	}
+
+	leaf, err := root.RegenerateLeafWhenNecessary(leaf, commonName, dnsNames)
+	err = errors.WithStack(err)
+
+	if err == nil {
+		intent.Data[clusterCertFile], err = leaf.Certificate.MarshalText()
+		err = errors.WithStack(err)
+	}
+	if err == nil {
+		intent.Data[clusterKeyFile], err = leaf.PrivateKey.MarshalText()
+		err = errors.WithStack(err)
+	}
+	if err == nil {
+		intent.Data[rootCertFile], err = root.Certificate.MarshalText()
+		err = errors.WithStack(err)
+	}
+
+	return err
+}
+
+// +kubebuilder:rbac:groups="",resources="secrets",verbs={get}
+
+// readPostgresUserPassword returns a copy of existing with the password or
+// verifier stored in the Secret referenced by spec. When that Secret or its
+// key is missing, it emits a warning event and returns existing.
+func (r *Reconciler) readPostgresUserPassword(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	spec *v1beta1.PostgresUserSpec, existing *corev1.Secret,
+) (*corev1.Secret, error) {
+	ref := spec.Password.SecretKeyRef
+	source := &corev1.Secret{}
+	err := errors.WithStack(r.Client.Get(ctx,
+		client.ObjectKey{Namespace: cluster.Namespace, Name: ref.Name}, source))
+
+	if client.IgnoreNotFound(err) != nil {
+		return existing, err
+	}
+	if err != nil || len(source.Data[ref.Key]) == 0 {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "MissingPasswordSecret",
+			"Secret %q has no %q key for user %q", ref.Name, ref.Key, spec.Name)
+		return existing, nil
+	}
+
+	return postgresUserPasswordFromSecret(existing, source.Data[ref.Key]), nil
+}
+
+// postgresUserPasswordFromSecret returns a copy of existing with value as its
+// verifier or password. A password that differs from the existing one
+// discards the existing verifier so that another is generated.
+func postgresUserPasswordFromSecret(existing *corev1.Secret, value []byte) *corev1.Secret {
+	result := new(corev1.Secret)
+	if existing != nil {
+		result = existing.DeepCopy()
+	}
+	initialize.ByteMap(&result.Data)
+
+	switch {
+	case isPostgresPasswordVerifier(value):
+		delete(result.Data, "password")
+		result.Data["verifier"] = value
+
+	case !bytes.Equal(result.Data["password"], value):
+		result.Data["password"] = value
+		delete(result.Data, "verifier")
+	}
+
+	return result
+}
+
+// isPostgresPasswordVerifier returns true when value looks like a password
+// that PostgreSQL has already hashed: SCRAM-SHA-256 or MD5.
+// - https://www.postgresql.org/docs/current/catalog-pg-authid.html
+func isPostgresPasswordVerifier(value []byte) bool {
+	return bytes.HasPrefix(value, []byte("SCRAM-SHA-256$")) ||
+		(len(value) == 35 && bytes.HasPrefix(value, []byte("md5")) &&
+			strings.Trim(string(value[3:]), "0123456789abcdef") == "")
+}
+
+// postgresPasswordCheckInterval is how often passwords in PostgreSQL are
+// compared to their Secrets when nothing else has changed.
+const postgresPasswordCheckInterval = time.Hour
+
+// comparePostgresUserPasswords returns the users whose password in PostgreSQL
+// differs from their verifier and records them in the status of cluster. It
+// does nothing until the interval since the last comparison has elapsed.
+func (r *Reconciler) comparePostgresUserPasswords(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	exec postgres.Executor, verifiers map[string]string,
+) ([]string, error) {
+	now := metav1.Now().Rfc3339Copy()
+	if checked := cluster.Status.UserPasswordsCheckedTime; checked != nil &&
+		now.Before(&metav1.Time{Time: checked.Add(postgresPasswordCheckInterval)}) {
+		return nil, nil
+	}
+
+	// Users without a verifier have nothing to compare.
+	compare := make(map[string]string, len(verifiers))
+	for name, verifier := range verifiers {
+		if verifier != "" {
+			compare[name] = verifier
+		}
+	}
+
+	different, err := postgres.ComparePasswordsInPostgreSQL(ctx, exec, compare)
+	if err == nil {
+		cluster.Status.UserPasswordsCheckedTime = &now
+		cluster.Status.UserPasswordsDrifted = nil
+		if len(different) > 0 {
+			cluster.Status.UserPasswordsDrifted = different
+		}
+	}
+	return different, errors.WithStack(err)
+}
+
 // reconcilePostgresUsersInPostgreSQL creates users inside of PostgreSQL and
-// sets their options and database access as specified.
+// sets their options and database access as specified. It also changes the
+// owner of any databases that specify one.
 func (r *Reconciler) reconcilePostgresUsersInPostgreSQL(
 	ctx context.Context, cluster *v1beta1.PostgresCluster, instances *observedInstances,
 	specUsers []v1beta1.PostgresUserSpec, userSecrets map[string]*corev1.Secret,
@@ -459,15 +767,86 @@ func (r *Reconciler) reconcilePostgresUsersInPostgreSQL(
 		return nil
 	}
 
+	// Users that were disabled after being removed from the spec can login
+	// again when they return to the spec, unless their options say otherwise.
+	removed := make(map[string]*v1beta1.PostgresUserRemovalStatus)
+	for i := range cluster.Status.RemovedUsers {
+		removed[cluster.Status.RemovedUsers[i].Name] = &cluster.Status.RemovedUsers[i]
+	}
+	var privileges bool
+	writeUsers := make([]v1beta1.PostgresUserSpec, len(specUsers))
+	for i := range specUsers {
+		specUsers[i].DeepCopyInto(&writeUsers[i])
+
+		// Users with a privileges specification are not granted ALL PRIVILEGES
+		// on their databases. Their privileges are written separately.
+		if specUsers[i].Privileges != nil && specUsers[i].Name != "postgres" {
+			privileges = true
+			writeUsers[i].Databases = nil
+		}
+
+		if status := removed[string(specUsers[i].Name)]; status != nil &&
+			status.Applied && status.Policy == v1beta1.PostgresUserRemovalPolicyNoLogin &&
+			!strings.Contains(strings.ToUpper(specUsers[i].Options), "LOGIN") {
+			writeUsers[i].Options = strings.TrimSpace("LOGIN " + specUsers[i].Options)
+		}
+	}
+
+	// Passwords can expire, and rotating users can have an alternate.
+	writeUsers, verifiers, alternates := postgresUsersWithRotation(
+		cluster, writeUsers, userSecrets)
+
+	// Users that were installed earlier but are no longer in the spec are
+	// removed. Compare with the status rather than with Secrets so that the
+	// removal is attempted again when the status fails to write.
+	installed := make([]string, len(writeUsers))
+	for i := range writeUsers {
+		installed[i] = string(writeUsers[i].Name)
+	}
+	sort.Strings(installed)
+	for _, name := range cluster.Status.Users {
+		if !containsPostgresUser(writeUsers, name) && removed[name] == nil {
+			recordRemovedPostgresUser(cluster, name)
+		}
+	}
+
+	// Apply the removal policy of users that are no longer in the spec.
+	if err := removePostgresUsersInPostgreSQL(
+		ctx, cluster, podExecutor, writeUsers,
+	); err != nil {
+		return err
+	}
+
 	// Calculate a hash of the SQL that should be executed in PostgreSQL.
 
-	verifiers := make(map[string]string, len(userSecrets))
-	for userName := range userSecrets {
-		verifiers[userName] = string(userSecrets[userName].Data["verifier"])
+	// Databases can be owned by users, so change their owners after users
+	// are written.
+	var owners bool
+	for _, spec := range cluster.Spec.Databases {
+		owners = owners || spec.Owner != ""
 	}
 
+	privilegesOK := true
 	write := func(ctx context.Context, exec postgres.Executor) error {
-		return postgres.WriteUsersInPostgreSQL(ctx, exec, specUsers, verifiers)
+		err := postgres.WriteUsersInPostgreSQL(ctx, exec, writeUsers, verifiers)
+		if err == nil && len(alternates) > 0 {
+			err = postgres.WriteAlternateUsersInPostgreSQL(ctx, exec, alternates)
+		}
+		if err == nil && owners {
+			err = postgres.WriteDatabaseOwnersInPostgreSQL(ctx, exec, cluster.Spec.Databases)
+		}
+
+		// Privileges can refer to schemas and roles that are created by
+		// applications. Report any failure and try again on the next reconcile
+		// rather than stopping the rest of the cluster from being reconciled.
+		if err == nil && privileges {
+			if privilegesOK = postgres.WriteUserPrivilegesInPostgreSQL(
+				ctx, exec, specUsers) == nil; !privilegesOK {
+				r.Recorder.Event(cluster, corev1.EventTypeWarning, "PrivilegesFailed",
+					"Unable to grant the privileges specified for users")
+			}
+		}
+		return err
 	}
 
 	revision, err := safeHash32(func(hasher io.Writer) error {
@@ -484,11 +863,19 @@ func (r *Reconciler) reconcilePostgresUsersInPostgreSQL(
 	})
 
 	if err == nil && revision == cluster.Status.UsersRevision {
-		// The necessary SQL has already been applied; there's nothing more to do.
+		// The necessary SQL has already been applied. Periodically check that
+		// passwords have not been changed some other way. Report any that have
+		// and write them again.
+		cluster.Status.Users = installed
 
 		// TODO(cbandy): Give the user a way to trigger execution regardless.
 		// The value of an annotation could influence the hash, for example.
-		return nil
+		different, err := r.comparePostgresUserPasswords(ctx, cluster, podExecutor, verifiers)
+		if err != nil || len(different) == 0 {
+			return err
+		}
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, "PasswordDrift",
+			"Passwords of users %q were changed outside of their Secrets", different)
 	}
 
 	// Apply the necessary SQL and record its hash in cluster.Status. Include
@@ -498,9 +885,338 @@ func (r *Reconciler) reconcilePostgresUsersInPostgreSQL(
 		log := logging.FromContext(ctx).WithValues("revision", revision)
 		err = errors.WithStack(write(logging.NewContext(ctx, log), podExecutor))
 	}
-	if err == nil {
+	if err == nil && privilegesOK {
 		cluster.Status.UsersRevision = revision
 	}
+	if err == nil {
+		cluster.Status.Users = installed
+
+		// Users in the spec are no longer removed.
+		kept := cluster.Status.RemovedUsers[:0]
+		for _, status := range cluster.Status.RemovedUsers {
+			if !containsPostgresUser(writeUsers, status.Name) {
+				kept = append(kept, status)
+			}
+		}
+		cluster.Status.RemovedUsers = kept
+		if len(kept) == 0 {
+			cluster.Status.RemovedUsers = nil
+		}
+
+		// Passwords that no longer rotate have no expiration now.
+		rotating := cluster.Status.UserPasswords[:0]
+		for _, status := range cluster.Status.UserPasswords {
+			for i := range specUsers {
+				if string(specUsers[i].Name) == status.Name &&
+					specUsers[i].Password != nil && specUsers[i].Password.Rotation != nil {
+					rotating = append(rotating, status)
+				}
+			}
+		}
+		cluster.Status.UserPasswords = rotating
+		if len(rotating) == 0 {
+			cluster.Status.UserPasswords = nil
+		}
+	}
+
+	return err
+}
+
+// alternatePostgresUserName returns the name of the PostgreSQL user that
+// alternates with the user named name during password rotation.
+func alternatePostgresUserName(name string) string { return name + "-alternate" }
+
+// postgresUserPasswordStatus returns the password rotation status of the
+// PostgreSQL user named name, if any.
+func postgresUserPasswordStatus(
+	cluster *v1beta1.PostgresCluster, name string,
+) *v1beta1.PostgresUserPasswordStatus {
+	for i := range cluster.Status.UserPasswords {
+		if cluster.Status.UserPasswords[i].Name == name {
+			return &cluster.Status.UserPasswords[i]
+		}
+	}
+	return nil
+}
+
+// rotatePostgresUserPassword records the password rotation of spec in the
+// status of cluster. When it is time for a new password, it returns a copy of
+// existing without a password so that one is generated. Users with a grace
+// period switch to their alternate, keeping the verifier of the other.
+func rotatePostgresUserPassword(
+	cluster *v1beta1.PostgresCluster, spec *v1beta1.PostgresUserSpec,
+	existing *corev1.Secret, now metav1.Time,
+) *corev1.Secret {
+	if spec.Password == nil || spec.Password.Rotation == nil ||
+		spec.Password.SecretKeyRef != nil {
+		return existing
+	}
+
+	name := string(spec.Name)
+	rotation := spec.Password.Rotation
+	interval := time.Duration(rotation.IntervalSeconds) * time.Second
+	status := postgresUserPasswordStatus(cluster, name)
+
+	switch {
+	case status == nil:
+		// Start counting from now.
+		cluster.Status.UserPasswords = append(cluster.Status.UserPasswords,
+			v1beta1.PostgresUserPasswordStatus{Name: name, RotatedTime: now})
+		status = &cluster.Status.UserPasswords[len(cluster.Status.UserPasswords)-1]
+
+	case existing == nil || len(existing.Data["password"]) == 0:
+		// A new password is going to be generated regardless.
+		status.RotatedTime = now
+
+	case !now.Before(&metav1.Time{Time: status.RotatedTime.Add(interval)}):
+		existing = existing.DeepCopy()
+		delete(existing.Data, "previous-verifier")
+
+		// The "postgres" user has no alternate.
+		if rotation.GracePeriodSeconds != nil && name != "postgres" {
+			existing.Data["previous-verifier"] = existing.Data["verifier"]
+
+			if status.ActiveUser == "" || status.ActiveUser == name {
+				status.ActiveUser = alternatePostgresUserName(name)
+			} else {
+				status.ActiveUser = name
+			}
+		} else {
+			status.ActiveUser = ""
+		}
+
+		delete(existing.Data, "password")
+		delete(existing.Data, "verifier")
+		status.RotatedTime = now
+	}
+
+	next := metav1.NewTime(status.RotatedTime.Add(interval))
+	status.NextRotationTime = &next
+
+	return existing
+}
+
+// postgresUsersWithRotation returns users and their verifiers accounting for
+// password rotation: passwords expire as specified, and users that have been
+// replaced by their alternate keep their previous password until the grace
+// period ends. It also returns each alternate user and the user it acts as.
+func postgresUsersWithRotation(
+	cluster *v1beta1.PostgresCluster, users []v1beta1.PostgresUserSpec,
+	secrets map[string]*corev1.Secret,
+) ([]v1beta1.PostgresUserSpec, map[string]string, map[string]string) {
+	alternates := make(map[string]string)
+	result := make([]v1beta1.PostgresUserSpec, 0, len(users))
+	verifiers := make(map[string]string, len(secrets))
+
+	validUntil := func(options, when string) string {
+		if strings.Contains(strings.ToUpper(options), "VALID UNTIL") {
+			return options
+		}
+		return strings.TrimSpace(options + " VALID UNTIL '" + when + "'")
+	}
+
+	for i := range users {
+		user := users[i]
+		name := string(user.Name)
+		status := postgresUserPasswordStatus(cluster, name)
+
+		var verifier, previous string
+		if secret := secrets[name]; secret != nil {
+			verifier = string(secret.Data["verifier"])
+			previous = string(secret.Data["previous-verifier"])
+		}
+
+		if user.Password == nil || user.Password.Rotation == nil || status == nil {
+			// Clear any expiration from when the password was rotating. Any
+			// alternate is no longer written, so it is dropped as a removed user.
+			if status != nil {
+				user.Options = validUntil(user.Options, "infinity")
+			}
+			result = append(result, user)
+			verifiers[name] = verifier
+			continue
+		}
+
+		rotation := user.Password.Rotation
+		interval := time.Duration(rotation.IntervalSeconds) * time.Second
+		var grace time.Duration
+		if rotation.GracePeriodSeconds != nil {
+			grace = time.Duration(*rotation.GracePeriodSeconds) * time.Second
+		}
+
+		expires := "infinity"
+		if rotation.Expire {
+			expires = status.RotatedTime.Add(interval + grace).UTC().Format(time.RFC3339)
+		}
+		graceEnds := status.RotatedTime.Add(grace).UTC().Format(time.RFC3339)
+
+		active := status.ActiveUser
+		if active == "" {
+			active = name
+		}
+
+		// The alternate exists once it has been active.
+		if active == name && previous == "" {
+			user.Options = validUntil(user.Options, expires)
+			result = append(result, user)
+			verifiers[name] = verifier
+			continue
+		}
+
+		// The alternate acts as the user, so it needs no databases of its own.
+		alternate := v1beta1.PostgresUserSpec{
+			Name:    v1beta1.PostgresIdentifier(alternatePostgresUserName(name)),
+			Options: user.Options,
+		}
+		alternates[string(alternate.Name)] = name
+
+		if previous == "" {
+			previous = verifier
+		}
+		if active == name {
+			user.Options = validUntil(user.Options, expires)
+			verifiers[name] = verifier
+			alternate.Options = validUntil(alternate.Options, graceEnds)
+			verifiers[string(alternate.Name)] = previous
+		} else {
+			user.Options = validUntil(user.Options, graceEnds)
+			verifiers[name] = previous
+			alternate.Options = validUntil(alternate.Options, expires)
+			verifiers[string(alternate.Name)] = verifier
+		}
+		result = append(result, user, alternate)
+	}
+
+	return result, verifiers, alternates
+}
+
+// containsPostgresUser returns whether or not users has a user named name.
+func containsPostgresUser(users []v1beta1.PostgresUserSpec, name string) bool {
+	for i := range users {
+		if string(users[i].Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// postgresUserRemovalPolicy returns the policy that applies to the PostgreSQL
+// user named name after it is removed from the spec of cluster.
+func postgresUserRemovalPolicy(cluster *v1beta1.PostgresCluster, name string) string {
+	// The alternate of a user exists only while its password rotates. It is
+	// always dropped, whether rotation ended or the user was removed.
+	if base := strings.TrimSuffix(name, alternatePostgresUserName("")); base != name &&
+		(containsPostgresUser(cluster.Spec.Users, base) ||
+			sets.NewString(cluster.Status.Users...).Has(base)) {
+		return v1beta1.PostgresUserRemovalPolicyDrop
+	}
+
+	// The "postgres" user is used by the operator and always retained.
+	if name == "postgres" ||
+		cluster.Spec.UserRemoval == nil || cluster.Spec.UserRemoval.Policy == "" {
+		return v1beta1.PostgresUserRemovalPolicyRetain
+	}
+	return cluster.Spec.UserRemoval.Policy
+}
+
+// recordRemovedPostgresUser adds the PostgreSQL user named name to the removed
+// users in the status of cluster. A retained user needs nothing more, so its
+// policy is applied immediately.
+func recordRemovedPostgresUser(cluster *v1beta1.PostgresCluster, name string) {
+	var status *v1beta1.PostgresUserRemovalStatus
+	for i := range cluster.Status.RemovedUsers {
+		if cluster.Status.RemovedUsers[i].Name == name {
+			status = &cluster.Status.RemovedUsers[i]
+		}
+	}
+	if status == nil {
+		cluster.Status.RemovedUsers = append(cluster.Status.RemovedUsers,
+			v1beta1.PostgresUserRemovalStatus{Name: name})
+		status = &cluster.Status.RemovedUsers[len(cluster.Status.RemovedUsers)-1]
+	}
+
+	status.Policy = postgresUserRemovalPolicy(cluster, name)
+	status.Applied = false
+	status.AppliedTime = nil
+	status.Message = ""
+
+	if status.Policy == v1beta1.PostgresUserRemovalPolicyRetain {
+		now := metav1.Now()
+		status.Applied, status.AppliedTime = true, &now
+	}
+}
+
+// removePostgresUsersInPostgreSQL applies the removal policy of PostgreSQL
+// users that have been removed from the spec of cluster but not yet disabled
+// or dropped. Pending users follow the current policy. The result for each
+// user is recorded in the status of cluster.
+func removePostgresUsersInPostgreSQL(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+	exec postgres.Executor, specUsers []v1beta1.PostgresUserSpec,
+) error {
+	var disable, drop []*v1beta1.PostgresUserRemovalStatus
+
+	for i := range cluster.Status.RemovedUsers {
+		status := &cluster.Status.RemovedUsers[i]
+		if status.Applied || containsPostgresUser(specUsers, status.Name) {
+			continue
+		}
+
+		status.Policy = postgresUserRemovalPolicy(cluster, status.Name)
+		switch status.Policy {
+		case v1beta1.PostgresUserRemovalPolicyNoLogin:
+			disable = append(disable, status)
+		case v1beta1.PostgresUserRemovalPolicyDrop:
+			drop = append(drop, status)
+		default:
+			now := metav1.Now()
+			status.Applied, status.AppliedTime, status.Message = true, &now, ""
+		}
+	}
+
+	names := func(statuses []*v1beta1.PostgresUserRemovalStatus) []string {
+		result := make([]string, len(statuses))
+		for i := range statuses {
+			result[i] = statuses[i].Name
+		}
+		return result
+	}
+	record := func(statuses []*v1beta1.PostgresUserRemovalStatus, err error) {
+		now := metav1.Now()
+		for _, status := range statuses {
+			if err != nil {
+				status.Message = err.Error()
+			} else {
+				status.Applied, status.AppliedTime, status.Message = true, &now, ""
+			}
+		}
+	}
+
+	// The user that receives the objects of dropped users cannot itself be
+	// dropped. Leave it pending and say why.
+	owner := "postgres"
+	if spec := cluster.Spec.UserRemoval; spec != nil && spec.ReassignOwnedTo != "" {
+		owner = string(spec.ReassignOwnedTo)
+	}
+	for i := len(drop) - 1; i >= 0; i-- {
+		if drop[i].Name == owner {
+			drop[i].Message = fmt.Sprintf(
+				"Unable to drop %q because objects are reassigned to it", owner)
+			drop = append(drop[:i], drop[i+1:]...)
+		}
+	}
+
+	var err error
+	if len(disable) > 0 {
+		err = errors.WithStack(
+			postgres.DisableUsersInPostgreSQL(ctx, exec, names(disable)))
+		record(disable, err)
+	}
+	if err == nil && len(drop) > 0 {
+		err = errors.WithStack(
+			postgres.DropUsersInPostgreSQL(ctx, exec, names(drop), owner))
+		record(drop, err)
+	}
 
 	return err
 }
@@ -561,6 +1277,49 @@ func (r *Reconciler) reconcilePostgresDataVolume(
 	return pvc, err
 }
 
+// setParametersValidCondition sets the "ParametersValid" condition to list
+// problems with the PostgreSQL parameters in the spec of cluster. It records an
+// event for each problem that was not already listed.
+func (r *Reconciler) setParametersValidCondition(
+	cluster *v1beta1.PostgresCluster, problems []error,
+) {
+	condition := metav1.Condition{
+		Type:    v1beta1.PostgresParametersValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Valid",
+		Message: "Every PostgreSQL parameter in the spec is in use.",
+
+		ObservedGeneration: cluster.GetGeneration(),
+	}
+
+	var previous string
+	if c := meta.FindStatusCondition(cluster.Status.Conditions, condition.Type); c != nil &&
+		c.Status == metav1.ConditionFalse {
+		previous = c.Message
+	}
+
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		messages = append(messages, problem.Error())
+
+		if !strings.Contains(previous, problem.Error()) {
+			reason := "InvalidParameter"
+			if errors.Is(problem, postgres.ErrParameterUnknown) {
+				reason = "UnknownParameter"
+			}
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, reason, problem.Error())
+		}
+	}
+
+	if len(messages) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "InvalidParameters"
+		condition.Message = "Skipped PostgreSQL parameters: " + strings.Join(messages, "; ")
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
+
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=create;delete;patch
 
@@ -0,0 +1,369 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+const (
+	// ConditionPostgresRestoreProgressing is the type used in a condition to indicate
+	// whether or not the restore requested by a PostgresRestore is running.
+	ConditionPostgresRestoreProgressing = "Progressing"
+
+	// ConditionPostgresRestoreSucceeded is the type used in a condition to indicate
+	// whether or not the restore requested by a PostgresRestore completed successfully.
+	ConditionPostgresRestoreSucceeded = "Succeeded"
+
+	// ConditionPostgresRestoreTargetChecked is the type used in a condition to indicate
+	// whether or not the target of a PostgresRestore was found to be within the recovery
+	// window of its repository before the restore started.
+	ConditionPostgresRestoreTargetChecked = "TargetChecked"
+)
+
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresrestores,verbs={list}
+
+// observePostgresRestore returns the PostgresRestore that cluster should restore next:
+// the oldest one that references cluster and has not succeeded. It returns nil when
+// there is none.
+func (r *Reconciler) observePostgresRestore(
+	ctx context.Context, cluster *v1beta1.PostgresCluster,
+) (*v1beta1.PostgresRestore, error) {
+	list := &v1beta1.PostgresRestoreList{}
+	if err := r.Client.List(ctx, list,
+		client.InNamespace(cluster.GetNamespace())); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var restores []*v1beta1.PostgresRestore
+	for i := range list.Items {
+		restore := &list.Items[i]
+		if restore.Spec.PostgresClusterName == cluster.GetName() &&
+			restore.GetDeletionTimestamp().IsZero() &&
+			!meta.IsStatusConditionTrue(restore.Status.Conditions,
+				ConditionPostgresRestoreSucceeded) {
+			restores = append(restores, restore)
+		}
+	}
+	sort.SliceStable(restores, func(i, j int) bool {
+		return restores[i].CreationTimestamp.Before(&restores[j].CreationTimestamp)
+	})
+
+	if len(restores) > 0 {
+		return restores[0], nil
+	}
+	return nil, nil
+}
+
+// postgresRestoreID returns the value that identifies restore in the pgBackRest restore
+// status of its cluster.
+func postgresRestoreID(restore *v1beta1.PostgresRestore) string {
+	return "~pgo-restore-" + string(restore.GetUID())
+}
+
+// postgresRestoreDataSource returns an in-place data source that restores the target
+// of restore.
+// - https://pgbackrest.org/command.html#command-restore
+func postgresRestoreDataSource(restore *v1beta1.PostgresRestore) *v1beta1.PostgresClusterDataSource {
+	var options []string
+
+	if target := restore.Spec.Target; target != nil {
+		switch {
+		case target.Time != nil:
+			// The restore command is interpreted by a shell, so quote the space.
+			options = append(options, "--type=time", fmt.Sprintf(`--target="%s"`,
+				target.Time.UTC().Format("2006-01-02 15:04:05-07")))
+		case target.LSN != "":
+			options = append(options, "--type=lsn", "--target="+target.LSN)
+		case target.XID != "":
+			options = append(options, "--type=xid", "--target="+target.XID)
+		case target.Backup != "":
+			options = append(options, "--type=immediate")
+		}
+		if target.Backup != "" {
+			options = append(options, "--set="+target.Backup)
+		}
+		if target.Exclusive && (target.Time != nil || target.LSN != "" || target.XID != "") {
+			options = append(options, "--target-exclusive")
+		}
+	}
+
+	return &v1beta1.PostgresClusterDataSource{
+		RepoName:          restore.Spec.RepoName,
+		Options:           append(options, restore.Spec.Options...),
+		Resources:         restore.Spec.Resources,
+		Affinity:          restore.Spec.Affinity,
+		PriorityClassName: restore.Spec.PriorityClassName,
+		Tolerations:       restore.Spec.Tolerations,
+	}
+}
+
+// parseLSN returns the position of a PostgreSQL WAL location like "16/B374D848".
+func parseLSN(lsn string) (uint64, bool) {
+	high, low, found := strings.Cut(lsn, "/")
+	if !found {
+		return 0, false
+	}
+	h, err1 := strconv.ParseUint(high, 16, 32)
+	l, err2 := strconv.ParseUint(low, 16, 32)
+	return h<<32 | l, err1 == nil && err2 == nil
+}
+
+// validatePostgresRestore checks restore against cluster and the recovery window of
+// its repository. It returns the reason and message of a condition when restore
+// cannot start, or empty strings when it can.
+func validatePostgresRestore(
+	cluster *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore,
+) (string, string) {
+	// Users should specify the type and target of the restore using the "target" field.
+	for _, opt := range restore.Spec.Options {
+		for _, option := range []string{"--type", "--target", "--set"} {
+			if opt == option || strings.HasPrefix(opt, option+"=") ||
+				strings.HasPrefix(opt, option+" ") {
+				return "InvalidOptions", fmt.Sprintf(
+					"Option %q is not allowed: please use the \"target\" field instead.", option)
+			}
+		}
+	}
+
+	// Restore a cluster in-place only after it has bootstrapped from its own data source.
+	if !patroni.ClusterBootstrapped(cluster) {
+		return "ClusterNotReady", "Waiting for the cluster to bootstrap"
+	}
+
+	target := restore.Spec.Target
+	if target == nil {
+		target = &v1beta1.PostgresRestoreTarget{}
+	}
+	var targets int
+	for _, set := range []bool{target.Time != nil, target.LSN != "", target.XID != ""} {
+		if set {
+			targets++
+		}
+	}
+	if targets > 1 {
+		return "InvalidTarget", "Only one of time, lsn, and xid can be set"
+	}
+
+	var repoFound bool
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		repoFound = repoFound || repo.Name == restore.Spec.RepoName
+	}
+	if !repoFound {
+		return "RepoNotFound", fmt.Sprintf(
+			"Repo %q is not defined for this cluster", restore.Spec.RepoName)
+	}
+
+	var status *v1beta1.RepoStatus
+	if cluster.Status.PGBackRest != nil && cluster.Status.PGBackRest.InventoryTime != nil {
+		for i := range cluster.Status.PGBackRest.Repos {
+			if cluster.Status.PGBackRest.Repos[i].Name == restore.Spec.RepoName {
+				status = &cluster.Status.PGBackRest.Repos[i]
+			}
+		}
+	}
+	if status == nil {
+		return "RecoveryWindowUnknown", fmt.Sprintf(
+			"Waiting for the backups in %q to be reported", restore.Spec.RepoName)
+	}
+	if len(status.Backups) == 0 {
+		return "NoBackups", fmt.Sprintf(
+			"There are no backups in %q to restore", restore.Spec.RepoName)
+	}
+
+	// The target must come after the end of the chosen backup, or the oldest one.
+	// Only the newest backups are reported, so either may be missing from status.
+	reported := int(status.BackupCount) <= len(status.Backups)
+	var earliest *v1beta1.PGBackRestBackupInfo
+	if target.Backup == "" && reported {
+		earliest = &status.Backups[0]
+	}
+	if target.Backup != "" {
+		for i := range status.Backups {
+			if status.Backups[i].Label == target.Backup {
+				earliest = &status.Backups[i]
+			}
+		}
+		if earliest == nil && reported {
+			return "InvalidTarget", fmt.Sprintf(
+				"Backup %q is not in %q", target.Backup, restore.Spec.RepoName)
+		}
+	}
+
+	if target.Time != nil {
+		if earliest != nil && earliest.StopTime != nil && target.Time.Before(earliest.StopTime) {
+			return "InvalidTarget", fmt.Sprintf(
+				"Time %s is before the end of backup %q at %s",
+				target.Time.UTC().Format(metav1.RFC3339Micro), earliest.Label,
+				earliest.StopTime.UTC().Format(metav1.RFC3339Micro))
+		}
+		if oldest := status.OldestRestorableTime; earliest == nil && target.Backup == "" &&
+			oldest != nil && target.Time.Before(oldest) {
+			return "InvalidTarget", fmt.Sprintf(
+				"Time %s is before the recovery window of %q, which starts at %s",
+				target.Time.UTC().Format(metav1.RFC3339Micro), restore.Spec.RepoName,
+				oldest.UTC().Format(metav1.RFC3339Micro))
+		}
+		if newest := status.NewestRestorableTime; newest != nil && newest.Before(target.Time) {
+			return "InvalidTarget", fmt.Sprintf(
+				"Time %s is after the recovery window of %q, which ends at %s",
+				target.Time.UTC().Format(metav1.RFC3339Micro), restore.Spec.RepoName,
+				newest.UTC().Format(metav1.RFC3339Micro))
+		}
+	}
+
+	if target.LSN != "" && earliest != nil {
+		lsn, ok := parseLSN(target.LSN)
+		if stop, known := parseLSN(earliest.LSNStop); !ok || (known && lsn < stop) {
+			return "InvalidTarget", fmt.Sprintf(
+				"LSN %s is before the end of backup %q at %s",
+				target.LSN, earliest.Label, earliest.LSNStop)
+		}
+	}
+
+	return "", ""
+}
+
+// uncheckedPostgresRestoreTarget returns a message when validatePostgresRestore cannot
+// tell whether the target of restore is within the recovery window of its repository.
+// pgBackRest does not report the transaction IDs or the end location of the WAL in a
+// repository, and status reports only its newest backups.
+func uncheckedPostgresRestoreTarget(
+	cluster *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore,
+) string {
+	target := restore.Spec.Target
+	if target == nil {
+		return ""
+	}
+
+	if target.Backup != "" && cluster.Status.PGBackRest != nil {
+		for _, status := range cluster.Status.PGBackRest.Repos {
+			if status.Name != restore.Spec.RepoName {
+				continue
+			}
+			found := false
+			for _, info := range status.Backups {
+				found = found || info.Label == target.Backup
+			}
+			if !found {
+				return fmt.Sprintf("Backup %q is not among the backups reported for %q",
+					target.Backup, restore.Spec.RepoName)
+			}
+		}
+	}
+
+	switch {
+	case target.XID != "":
+		return fmt.Sprintf("Transaction %s cannot be compared to the WAL in %q",
+			target.XID, restore.Spec.RepoName)
+	case target.LSN != "":
+		return fmt.Sprintf("LSN %s cannot be compared to the end of the WAL in %q",
+			target.LSN, restore.Spec.RepoName)
+	}
+	return ""
+}
+
+// +kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresrestores/status,verbs={patch}
+
+// reconcilePostgresRestore reports the progress of restore in its status. Before restore
+// has started, it checks that restore can start and returns false when it cannot.
+func (r *Reconciler) reconcilePostgresRestore(ctx context.Context,
+	cluster *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore,
+) (bool, error) {
+	var status *v1beta1.PGBackRestJobStatus
+	if cluster.Status.PGBackRest != nil && cluster.Status.PGBackRest.Restore != nil &&
+		cluster.Status.PGBackRest.Restore.ID == postgresRestoreID(restore) {
+		status = cluster.Status.PGBackRest.Restore
+	}
+
+	before := restore.DeepCopy()
+	condition := func(conditionType string, status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{
+			ObservedGeneration: restore.GetGeneration(),
+			Type:               conditionType,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	proceed := true
+	if status == nil {
+		restore.Status.ObservedGeneration = restore.GetGeneration()
+
+		if reason, message := validatePostgresRestore(cluster, restore); reason != "" {
+			proceed = false
+			condition(ConditionPostgresRestoreProgressing, metav1.ConditionFalse, reason, message)
+			meta.RemoveStatusCondition(&restore.Status.Conditions,
+				ConditionPostgresRestoreTargetChecked)
+		} else {
+			condition(ConditionPostgresRestoreProgressing, metav1.ConditionTrue,
+				"RestoreInPlaceRequested", "Preparing cluster to restore in-place")
+
+			// Some targets can be checked only by restoring them.
+			if message := uncheckedPostgresRestoreTarget(cluster, restore); message != "" {
+				condition(ConditionPostgresRestoreTargetChecked, metav1.ConditionFalse,
+					"TargetNotChecked", message)
+			} else {
+				condition(ConditionPostgresRestoreTargetChecked, metav1.ConditionTrue,
+					"TargetInRecoveryWindow", fmt.Sprintf(
+						"The target is within the recovery window of %q", restore.Spec.RepoName))
+			}
+		}
+	} else {
+		restore.Status.StartTime = status.StartTime
+		restore.Status.CompletionTime = status.CompletionTime
+
+		switch {
+		case status.Finished && meta.IsStatusConditionTrue(cluster.Status.Conditions,
+			ConditionPostgresDataInitialized):
+			condition(ConditionPostgresRestoreProgressing, metav1.ConditionFalse,
+				"RestoreComplete", "Restore completed successfully")
+			condition(ConditionPostgresRestoreSucceeded, metav1.ConditionTrue,
+				"RestoreComplete", "Restore completed successfully")
+		case status.Finished:
+			condition(ConditionPostgresRestoreProgressing, metav1.ConditionFalse,
+				"RestoreFailed", "Restore did not complete successfully")
+			condition(ConditionPostgresRestoreSucceeded, metav1.ConditionFalse,
+				"RestoreFailed", "Restore did not complete successfully")
+		default:
+			if c := meta.FindStatusCondition(cluster.Status.Conditions,
+				ConditionPGBackRestRestoreProgressing); c != nil {
+				condition(ConditionPostgresRestoreProgressing, c.Status, c.Reason, c.Message)
+			}
+		}
+	}
+
+	var err error
+	if !equality.Semantic.DeepEqual(before.Status, restore.Status) {
+		err = errors.WithStack(r.Client.Status().Patch(ctx, restore,
+			client.MergeFrom(before), r.Owner))
+	}
+	return proceed, err
+}
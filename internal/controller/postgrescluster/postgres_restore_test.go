@@ -0,0 +1,486 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestObservePostgresRestore(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+
+	restore := func(name, clusterName string, created time.Time) *v1beta1.PostgresRestore {
+		restore := &v1beta1.PostgresRestore{}
+		restore.Namespace, restore.Name = "ns1", name
+		restore.CreationTimestamp = metav1.NewTime(created)
+		restore.Spec.PostgresClusterName = clusterName
+		return restore
+	}
+
+	now := time.Now().Truncate(time.Second)
+	done := restore("done", "hippo", now.Add(-3*time.Hour))
+	meta.SetStatusCondition(&done.Status.Conditions, metav1.Condition{
+		Type: ConditionPostgresRestoreSucceeded, Status: metav1.ConditionTrue, Reason: "test",
+	})
+
+	r := new(Reconciler)
+	r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		done,
+		restore("other", "rhino", now.Add(-2*time.Hour)),
+		restore("newer", "hippo", now),
+		restore("older", "hippo", now.Add(-time.Hour)),
+	).Build()
+
+	// The oldest that has not succeeded.
+	next, err := r.observePostgresRestore(ctx, cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, next != nil)
+	assert.Equal(t, next.Name, "older")
+
+	// Nothing for other clusters.
+	cluster.Name = "elephant"
+	next, err = r.observePostgresRestore(ctx, cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, next == nil)
+}
+
+func TestPostgresRestoreDataSource(t *testing.T) {
+	restore := &v1beta1.PostgresRestore{}
+	restore.Spec.RepoName = "repo2"
+	restore.Spec.PriorityClassName = initialize.String("important")
+
+	t.Run("Latest", func(t *testing.T) {
+		restore := restore.DeepCopy()
+		restore.Spec.Options = []string{"--process-max=2"}
+
+		source := postgresRestoreDataSource(restore)
+		assert.Equal(t, source.ClusterName, "", "expected in-place")
+		assert.Equal(t, source.RepoName, "repo2")
+		assert.Equal(t, *source.PriorityClassName, "important")
+		assert.DeepEqual(t, source.Options, []string{"--process-max=2"})
+	})
+
+	for _, tt := range []struct {
+		name    string
+		target  v1beta1.PostgresRestoreTarget
+		options []string
+	}{
+		{
+			name: "Time",
+			target: v1beta1.PostgresRestoreTarget{Time: &metav1.Time{
+				Time: time.Date(2023, time.January, 2, 3, 4, 5, 0, time.FixedZone("", -5*3600)),
+			}},
+			options: []string{"--type=time", `--target="2023-01-02 08:04:05+00"`},
+		},
+		{
+			name:    "LSN",
+			target:  v1beta1.PostgresRestoreTarget{LSN: "0/3000060", Exclusive: true},
+			options: []string{"--type=lsn", "--target=0/3000060", "--target-exclusive"},
+		},
+		{
+			name:    "XID",
+			target:  v1beta1.PostgresRestoreTarget{XID: "1234", Backup: "20230101-000000F"},
+			options: []string{"--type=xid", "--target=1234", "--set=20230101-000000F"},
+		},
+		{
+			name:    "Backup",
+			target:  v1beta1.PostgresRestoreTarget{Backup: "20230101-000000F", Exclusive: true},
+			options: []string{"--type=immediate", "--set=20230101-000000F"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := restore.DeepCopy()
+			restore.Spec.Target = &tt.target
+			assert.DeepEqual(t, postgresRestoreDataSource(restore).Options, tt.options)
+		})
+	}
+}
+
+func TestParseLSN(t *testing.T) {
+	for _, tt := range []struct {
+		lsn   string
+		value uint64
+		ok    bool
+	}{
+		{"0/0", 0, true},
+		{"0/3000060", 0x3000060, true},
+		{"16/B374D848", 0x16_B374D848, true},
+		{"", 0, false},
+		{"16", 0, false},
+		{"x/1", 0, false},
+	} {
+		value, ok := parseLSN(tt.lsn)
+		assert.Equal(t, ok, tt.ok, "%q", tt.lsn)
+		if ok {
+			assert.Equal(t, value, tt.value, "%q", tt.lsn)
+		}
+	}
+}
+
+func TestValidatePostgresRestore(t *testing.T) {
+	stop := time.Date(2023, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{Name: "repo1"}}
+	cluster.Status.Patroni.SystemIdentifier = "12345"
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		InventoryTime: &metav1.Time{Time: stop.Add(48 * time.Hour)},
+		Repos: []v1beta1.RepoStatus{{
+			Name: "repo1",
+			Backups: []v1beta1.PGBackRestBackupInfo{
+				{Label: "20230102-030000F", StopTime: &metav1.Time{Time: stop}, LSNStop: "0/3000100"},
+				{Label: "20230103-030000F", StopTime: &metav1.Time{Time: stop.Add(24 * time.Hour)}, LSNStop: "0/5000100"},
+			},
+			NewestRestorableTime: &metav1.Time{Time: stop.Add(36 * time.Hour)},
+		}},
+	}
+
+	restore := &v1beta1.PostgresRestore{}
+	restore.Spec.RepoName = "repo1"
+
+	t.Run("Valid", func(t *testing.T) {
+		for _, target := range []*v1beta1.PostgresRestoreTarget{
+			nil,
+			{Time: &metav1.Time{Time: stop.Add(time.Hour)}},
+			{Time: &metav1.Time{Time: stop.Add(25 * time.Hour)}, Backup: "20230103-030000F"},
+			{LSN: "0/4000000"},
+			{XID: "100", Backup: "20230102-030000F"},
+		} {
+			restore := restore.DeepCopy()
+			restore.Spec.Target = target
+			reason, message := validatePostgresRestore(cluster, restore)
+			assert.Equal(t, reason, "", "%v", message)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		// Older backups are left out of status.
+		cluster := cluster.DeepCopy()
+		cluster.Status.PGBackRest.Repos[0].BackupCount = 30
+		cluster.Status.PGBackRest.Repos[0].OldestRestorableTime = &metav1.Time{
+			Time: stop.Add(-240 * time.Hour),
+		}
+
+		for _, target := range []*v1beta1.PostgresRestoreTarget{
+			{Backup: "20221225-030000F"},
+			{Time: &metav1.Time{Time: stop.Add(-time.Hour)}},
+			{LSN: "0/1000000"},
+		} {
+			restore := restore.DeepCopy()
+			restore.Spec.Target = target
+			reason, message := validatePostgresRestore(cluster, restore)
+			assert.Equal(t, reason, "", "%v", message)
+		}
+
+		restore := restore.DeepCopy()
+		restore.Spec.Target = &v1beta1.PostgresRestoreTarget{
+			Time: &metav1.Time{Time: stop.Add(-241 * time.Hour)},
+		}
+		reason, message := validatePostgresRestore(cluster, restore)
+		assert.Equal(t, reason, "InvalidTarget")
+		assert.Assert(t, cmp.Contains(message, "before the recovery window"))
+	})
+
+	for _, tt := range []struct {
+		name    string
+		mutate  func(*v1beta1.PostgresCluster, *v1beta1.PostgresRestore)
+		reason  string
+		message string
+	}{
+		{
+			name: "Options",
+			mutate: func(_ *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore) {
+				restore.Spec.Options = []string{"--delta", "--type=time"}
+			},
+			reason: "InvalidOptions", message: `"--type"`,
+		},
+		{
+			name: "NotBootstrapped",
+			mutate: func(cluster *v1beta1.PostgresCluster, _ *v1beta1.PostgresRestore) {
+				cluster.Status.Patroni.SystemIdentifier = ""
+			},
+			reason: "ClusterNotReady", message: "bootstrap",
+		},
+		{
+			name: "TooManyTargets",
+			mutate: func(_ *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore) {
+				restore.Spec.Target = &v1beta1.PostgresRestoreTarget{LSN: "0/4000000", XID: "100"}
+			},
+			reason: "InvalidTarget", message: "Only one",
+		},
+		{
+			name: "RepoNotFound",
+			mutate: func(_ *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore) {
+				restore.Spec.RepoName = "repo3"
+			},
+			reason: "RepoNotFound", message: `"repo3"`,
+		},
+		{
+			name: "NoInventory",
+			mutate: func(cluster *v1beta1.PostgresCluster, _ *v1beta1.PostgresRestore) {
+				cluster.Status.PGBackRest.InventoryTime = nil
+			},
+			reason: "RecoveryWindowUnknown", message: `"repo1"`,
+		},
+		{
+			name: "NoBackups",
+			mutate: func(cluster *v1beta1.PostgresCluster, _ *v1beta1.PostgresRestore) {
+				cluster.Status.PGBackRest.Repos[0].Backups = nil
+			},
+			reason: "NoBackups", message: `"repo1"`,
+		},
+		{
+			name: "BackupNotFound",
+			mutate: func(_ *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore) {
+				restore.Spec.Target = &v1beta1.PostgresRestoreTarget{Backup: "20230101-000000F"}
+			},
+			reason: "InvalidTarget", message: `"20230101-000000F"`,
+		},
+		{
+			name: "TimeTooEarly",
+			mutate: func(_ *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore) {
+				restore.Spec.Target = &v1beta1.PostgresRestoreTarget{
+					Time: &metav1.Time{Time: stop.Add(-time.Hour)},
+				}
+			},
+			reason: "InvalidTarget", message: "before the end of backup",
+		},
+		{
+			name: "TimeBeforeBackup",
+			mutate: func(_ *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore) {
+				restore.Spec.Target = &v1beta1.PostgresRestoreTarget{
+					Time: &metav1.Time{Time: stop.Add(time.Hour)}, Backup: "20230103-030000F",
+				}
+			},
+			reason: "InvalidTarget", message: `"20230103-030000F"`,
+		},
+		{
+			name: "TimeTooLate",
+			mutate: func(_ *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore) {
+				restore.Spec.Target = &v1beta1.PostgresRestoreTarget{
+					Time: &metav1.Time{Time: stop.Add(37 * time.Hour)},
+				}
+			},
+			reason: "InvalidTarget", message: "after the recovery window",
+		},
+		{
+			name: "LSNTooEarly",
+			mutate: func(_ *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore) {
+				restore.Spec.Target = &v1beta1.PostgresRestoreTarget{LSN: "0/3000000"}
+			},
+			reason: "InvalidTarget", message: "0/3000100",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, restore := cluster.DeepCopy(), restore.DeepCopy()
+			tt.mutate(cluster, restore)
+
+			reason, message := validatePostgresRestore(cluster, restore)
+			assert.Equal(t, reason, tt.reason)
+			assert.Assert(t, cmp.Contains(message, tt.message))
+		})
+	}
+}
+
+func TestUncheckedPostgresRestoreTarget(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		Repos: []v1beta1.RepoStatus{{
+			Name:        "repo1",
+			Backups:     []v1beta1.PGBackRestBackupInfo{{Label: "20230102-030000F"}},
+			BackupCount: 30,
+		}},
+	}
+
+	restore := &v1beta1.PostgresRestore{}
+	restore.Spec.RepoName = "repo1"
+
+	for _, tt := range []struct {
+		target  *v1beta1.PostgresRestoreTarget
+		message string
+	}{
+		{target: nil},
+		{target: &v1beta1.PostgresRestoreTarget{Time: &metav1.Time{}}},
+		{target: &v1beta1.PostgresRestoreTarget{Backup: "20230102-030000F"}},
+		{
+			target:  &v1beta1.PostgresRestoreTarget{Backup: "20221225-030000F"},
+			message: `Backup "20221225-030000F" is not among the backups reported for "repo1"`,
+		},
+		{
+			target:  &v1beta1.PostgresRestoreTarget{XID: "100"},
+			message: `Transaction 100 cannot be compared to the WAL in "repo1"`,
+		},
+		{
+			target:  &v1beta1.PostgresRestoreTarget{LSN: "0/4000000"},
+			message: `LSN 0/4000000 cannot be compared to the end of the WAL in "repo1"`,
+		},
+	} {
+		restore := restore.DeepCopy()
+		restore.Spec.Target = tt.target
+		assert.Equal(t, uncheckedPostgresRestoreTarget(cluster, restore), tt.message)
+	}
+}
+
+func TestReconcilePostgresRestore(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Namespace, cluster.Name = "ns1", "hippo"
+	cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{{Name: "repo1"}}
+	cluster.Status.Patroni.SystemIdentifier = "12345"
+	cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+		InventoryTime: &metav1.Time{Time: time.Now()},
+		Repos: []v1beta1.RepoStatus{{
+			Name:    "repo1",
+			Backups: []v1beta1.PGBackRestBackupInfo{{Label: "20230102-030000F"}},
+		}},
+	}
+
+	restore := &v1beta1.PostgresRestore{}
+	restore.Namespace, restore.Name = "ns1", "yesterday"
+	restore.UID = "some-uid"
+	restore.Generation = 2
+	restore.Spec.PostgresClusterName = "hippo"
+	restore.Spec.RepoName = "repo1"
+
+	reconcile := func(
+		t testing.TB, cluster *v1beta1.PostgresCluster, restore *v1beta1.PostgresRestore,
+	) bool {
+		t.Helper()
+		r := new(Reconciler)
+		r.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(restore).Build()
+
+		proceed, err := r.reconcilePostgresRestore(ctx, cluster, restore)
+		assert.NilError(t, err)
+		assert.NilError(t, r.Client.Get(ctx, client.ObjectKeyFromObject(restore), restore))
+		return proceed
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		cluster, restore := cluster.DeepCopy(), restore.DeepCopy()
+		restore.Spec.RepoName = "repo4"
+
+		assert.Assert(t, !reconcile(t, cluster, restore))
+		assert.Equal(t, restore.Status.ObservedGeneration, int64(2))
+
+		condition := meta.FindStatusCondition(restore.Status.Conditions,
+			ConditionPostgresRestoreProgressing)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionFalse)
+		assert.Equal(t, condition.Reason, "RepoNotFound")
+	})
+
+	t.Run("Start", func(t *testing.T) {
+		cluster, restore := cluster.DeepCopy(), restore.DeepCopy()
+
+		assert.Assert(t, reconcile(t, cluster, restore))
+
+		condition := meta.FindStatusCondition(restore.Status.Conditions,
+			ConditionPostgresRestoreProgressing)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+
+		checked := meta.FindStatusCondition(restore.Status.Conditions,
+			ConditionPostgresRestoreTargetChecked)
+		assert.Assert(t, checked != nil)
+		assert.Equal(t, checked.Status, metav1.ConditionTrue)
+	})
+
+	t.Run("StartUnchecked", func(t *testing.T) {
+		cluster, restore := cluster.DeepCopy(), restore.DeepCopy()
+		restore.Spec.Target = &v1beta1.PostgresRestoreTarget{XID: "1234"}
+
+		assert.Assert(t, reconcile(t, cluster, restore))
+
+		checked := meta.FindStatusCondition(restore.Status.Conditions,
+			ConditionPostgresRestoreTargetChecked)
+		assert.Assert(t, checked != nil)
+		assert.Equal(t, checked.Status, metav1.ConditionFalse)
+		assert.Equal(t, checked.Reason, "TargetNotChecked")
+		assert.Assert(t, cmp.Contains(checked.Message, "Transaction 1234"))
+	})
+
+	t.Run("Running", func(t *testing.T) {
+		cluster, restore := cluster.DeepCopy(), restore.DeepCopy()
+		cluster.Status.Patroni.SystemIdentifier = ""
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Restore: &v1beta1.PGBackRestJobStatus{
+				ID: postgresRestoreID(restore), StartTime: &metav1.Time{Time: time.Now()},
+			},
+		}
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type:   ConditionPGBackRestRestoreProgressing,
+			Status: metav1.ConditionTrue,
+			Reason: ReasonReadyForRestore, Message: "Restoring cluster in-place",
+		})
+
+		// Nothing is checked once the restore has started.
+		assert.Assert(t, reconcile(t, cluster, restore))
+		assert.Assert(t, restore.Status.StartTime != nil)
+
+		condition := meta.FindStatusCondition(restore.Status.Conditions,
+			ConditionPostgresRestoreProgressing)
+		assert.Assert(t, condition != nil)
+		assert.Equal(t, condition.Status, metav1.ConditionTrue)
+		assert.Equal(t, condition.Reason, ReasonReadyForRestore)
+	})
+
+	t.Run("Finished", func(t *testing.T) {
+		cluster, restore := cluster.DeepCopy(), restore.DeepCopy()
+		cluster.Status.PGBackRest = &v1beta1.PGBackRestStatus{
+			Restore: &v1beta1.PGBackRestJobStatus{
+				ID: postgresRestoreID(restore), Finished: true,
+			},
+		}
+
+		// The restore Job failed.
+		assert.Assert(t, reconcile(t, cluster, restore))
+		succeeded := meta.FindStatusCondition(restore.Status.Conditions,
+			ConditionPostgresRestoreSucceeded)
+		assert.Assert(t, succeeded != nil)
+		assert.Equal(t, succeeded.Status, metav1.ConditionFalse)
+
+		// The restore Job completed.
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type: ConditionPostgresDataInitialized, Status: metav1.ConditionTrue, Reason: "test",
+		})
+		assert.Assert(t, reconcile(t, cluster, restore))
+		succeeded = meta.FindStatusCondition(restore.Status.Conditions,
+			ConditionPostgresRestoreSucceeded)
+		assert.Assert(t, succeeded != nil)
+		assert.Equal(t, succeeded.Status, metav1.ConditionTrue)
+	})
+}
@@ -20,8 +20,11 @@ package postgrescluster
 
 import (
 	"context"
+	"crypto/sha256"
 	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/pkg/errors"
@@ -29,13 +32,17 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 
 	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/naming"
+	"github.com/crunchydata/postgres-operator/internal/pki"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
+	pgpassword "github.com/crunchydata/postgres-operator/internal/postgres/password"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
 	"github.com/crunchydata/postgres-operator/internal/testing/require"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
@@ -140,18 +147,41 @@ func TestGeneratePostgresUserSecret(t *testing.T) {
 			assert.Assert(t, len(secret.Data["verifier"]) > 90, "got %v", len(secret.Data["verifier"]))
 		}
 
-		// Copied when existing Secret is full.
+		// Copied when existing Secret is full and matching.
+		verifier, err := pgpassword.NewSCRAMPassword("asdf").Build()
+		assert.NilError(t, err)
+
 		secret, err = reconciler.generatePostgresUserSecret(cluster, spec, &corev1.Secret{
 			Data: map[string][]byte{
 				"password": []byte(`asdf`),
-				"verifier": []byte(`some$thing`),
+				"verifier": []byte(verifier),
 			},
 		})
 		assert.NilError(t, err)
 
 		if assert.Check(t, secret != nil) {
 			assert.Equal(t, string(secret.Data["password"]), "asdf")
-			assert.Equal(t, string(secret.Data["verifier"]), "some$thing")
+			assert.Equal(t, string(secret.Data["verifier"]), verifier)
+		}
+
+		// Regenerated when the verifier is for another password or is invalid.
+		for _, mismatch := range []string{verifier, `some$thing`} {
+			secret, err = reconciler.generatePostgresUserSecret(cluster, spec, &corev1.Secret{
+				Data: map[string][]byte{
+					"password": []byte(`changed`),
+					"verifier": []byte(mismatch),
+				},
+			})
+			assert.NilError(t, err)
+
+			if assert.Check(t, secret != nil) {
+				assert.Equal(t, string(secret.Data["password"]), "changed")
+				assert.Assert(t, string(secret.Data["verifier"]) != mismatch)
+
+				ok, err := pgpassword.Verify("", "changed", string(secret.Data["verifier"]))
+				assert.NilError(t, err)
+				assert.Assert(t, ok)
+			}
 		}
 	})
 
@@ -203,6 +233,58 @@ func TestGeneratePostgresUserSecret(t *testing.T) {
 		}
 	})
 
+	t.Run("SecretKeyRef", func(t *testing.T) {
+		spec := *spec
+		spec.Databases = []v1beta1.PostgresIdentifier{"db1"}
+		spec.Password = &v1beta1.PostgresPasswordSpec{
+			SecretKeyRef: &corev1.SecretKeySelector{Key: "some-key"},
+		}
+
+		// Nothing is generated.
+		secret, err := reconciler.generatePostgresUserSecret(cluster, &spec, nil)
+		assert.NilError(t, err)
+
+		if assert.Check(t, secret != nil) {
+			assert.Assert(t, secret.Data["password"] == nil)
+			assert.Assert(t, secret.Data["verifier"] == nil)
+		}
+
+		// Only a verifier; no password in the URIs.
+		secret, err = reconciler.generatePostgresUserSecret(cluster, &spec, &corev1.Secret{
+			Data: map[string][]byte{"verifier": []byte("SCRAM-SHA-256$some")},
+		})
+		assert.NilError(t, err)
+
+		if assert.Check(t, secret != nil) {
+			_, ok := secret.Data["password"]
+			assert.Assert(t, !ok)
+			assert.Equal(t, string(secret.Data["verifier"]), "SCRAM-SHA-256$some")
+			assert.Equal(t, string(secret.Data["uri"]),
+				"postgresql://some-user-name@hippo2-primary.ns1.svc:9999/db1")
+			assert.Equal(t, string(secret.Data["jdbc-uri"]),
+				"jdbc:postgresql://hippo2-primary.ns1.svc:9999/db1?user=some-user-name")
+		}
+	})
+
+	t.Run("ClientCertificate", func(t *testing.T) {
+		spec := *spec
+		spec.ClientCertificate = true
+		spec.Databases = []v1beta1.PostgresIdentifier{"db1"}
+
+		secret, err := reconciler.generatePostgresUserSecret(cluster, &spec, nil)
+		assert.NilError(t, err)
+
+		if assert.Check(t, secret != nil) {
+			assert.Assert(t, cmp.Regexp(
+				`^postgresql://some-user-name:[^@]+@hippo2-primary.ns1.svc:9999/db1[?]sslmode=verify-full$`,
+				string(secret.Data["uri"])))
+			assert.Assert(t, cmp.Regexp(
+				`^jdbc:postgresql://hippo2-primary.ns1.svc:9999/db1`+
+					`[?]password=[^&]+&sslmode=verify-full&user=some-user-name$`,
+				string(secret.Data["jdbc-uri"])))
+		}
+	})
+
 	t.Run("PgBouncer", func(t *testing.T) {
 		assert.NilError(t, yaml.Unmarshal([]byte(`{
 			proxy: { pgBouncer: { port: 10220 } },
@@ -234,6 +316,19 @@ func TestGeneratePostgresUserSecret(t *testing.T) {
 					`[?]password=[^&]+&prepareThreshold=0&user=some-user-name$`,
 				string(secret.Data["pgbouncer-jdbc-uri"])))
 		}
+
+		// PgBouncer cannot log in as a user with a client certificate.
+		spec.ClientCertificate = true
+
+		secret, err = reconciler.generatePostgresUserSecret(cluster, &spec, nil)
+		assert.NilError(t, err)
+
+		if assert.Check(t, secret != nil) {
+			assert.Assert(t, cmp.Regexp(`[?]sslmode=verify-full$`, string(secret.Data["uri"])))
+			for key := range secret.Data {
+				assert.Assert(t, !strings.HasPrefix(key, "pgbouncer-"), "unexpected %q", key)
+			}
+		}
 	})
 }
 
@@ -661,3 +756,624 @@ func TestReconcileDatabaseInitSQLConfigMap(t *testing.T) {
 		assert.Assert(t, called)
 	})
 }
+
+func TestRemovePostgresUsersInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Record", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+
+		recordRemovedPostgresUser(cluster, "retained")
+		assert.Equal(t, len(cluster.Status.RemovedUsers), 1)
+		assert.Equal(t, cluster.Status.RemovedUsers[0].Policy, "Retain")
+		assert.Assert(t, cluster.Status.RemovedUsers[0].Applied)
+		assert.Assert(t, cluster.Status.RemovedUsers[0].AppliedTime != nil)
+
+		cluster.Spec.UserRemoval = &v1beta1.PostgresUserRemovalSpec{Policy: "Drop"}
+		recordRemovedPostgresUser(cluster, "dropped")
+		recordRemovedPostgresUser(cluster, "postgres")
+		assert.Equal(t, len(cluster.Status.RemovedUsers), 3)
+		assert.Equal(t, cluster.Status.RemovedUsers[1].Policy, "Drop")
+		assert.Assert(t, !cluster.Status.RemovedUsers[1].Applied)
+		assert.Equal(t, cluster.Status.RemovedUsers[2].Policy, "Retain",
+			"expected the postgres user to be retained")
+
+		// Recording a user again resets its status.
+		recordRemovedPostgresUser(cluster, "retained")
+		assert.Equal(t, len(cluster.Status.RemovedUsers), 3)
+		assert.Equal(t, cluster.Status.RemovedUsers[0].Policy, "Drop")
+		assert.Assert(t, !cluster.Status.RemovedUsers[0].Applied)
+	})
+
+	t.Run("Alternate", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.Users = []v1beta1.PostgresUserSpec{{Name: "app"}}
+		cluster.Status.Users = []string{"app", "app-alternate", "gone", "gone-alternate"}
+
+		// Alternates are dropped regardless of the policy.
+		recordRemovedPostgresUser(cluster, "app-alternate")
+		recordRemovedPostgresUser(cluster, "gone")
+		recordRemovedPostgresUser(cluster, "gone-alternate")
+		recordRemovedPostgresUser(cluster, "other-alternate")
+		assert.Equal(t, cluster.Status.RemovedUsers[0].Policy, "Drop")
+		assert.Equal(t, cluster.Status.RemovedUsers[1].Policy, "Retain")
+		assert.Equal(t, cluster.Status.RemovedUsers[2].Policy, "Drop")
+		assert.Equal(t, cluster.Status.RemovedUsers[3].Policy, "Retain",
+			"expected a user that is not an alternate to follow the policy")
+	})
+
+	t.Run("Nothing", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Status.RemovedUsers = []v1beta1.PostgresUserRemovalStatus{
+			{Name: "applied", Policy: "NoLogin", Applied: true},
+			{Name: "returned", Policy: "Drop"},
+		}
+
+		exec := func(context.Context, io.Reader, io.Writer, io.Writer, ...string) error {
+			panic("should not be called")
+		}
+
+		assert.NilError(t, removePostgresUsersInPostgreSQL(ctx, cluster, exec,
+			[]v1beta1.PostgresUserSpec{{Name: "returned"}}))
+		assert.Assert(t, !cluster.Status.RemovedUsers[1].Applied)
+	})
+
+	t.Run("Policies", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.UserRemoval = &v1beta1.PostgresUserRemovalSpec{
+			Policy: "NoLogin",
+		}
+		cluster.Status.RemovedUsers = []v1beta1.PostgresUserRemovalStatus{
+			{Name: "one", Policy: "Drop"},
+			{Name: "two", Policy: "Retain"},
+		}
+
+		var commands [][]string
+		exec := func(
+			_ context.Context, _ io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			commands = append(commands, command)
+			return nil
+		}
+
+		// Pending users follow the current policy.
+		assert.NilError(t, removePostgresUsersInPostgreSQL(ctx, cluster, exec, nil))
+		assert.Equal(t, len(commands), 1)
+		assert.Assert(t, cmp.Contains(commands[0], `--set=roles=["one","two"]`))
+
+		for _, status := range cluster.Status.RemovedUsers {
+			assert.Equal(t, status.Policy, "NoLogin")
+			assert.Assert(t, status.Applied)
+			assert.Assert(t, status.AppliedTime != nil)
+		}
+
+		// Applied users are left alone.
+		commands = nil
+		cluster.Spec.UserRemoval.Policy = "Drop"
+		assert.NilError(t, removePostgresUsersInPostgreSQL(ctx, cluster, exec, nil))
+		assert.Equal(t, len(commands), 0)
+		assert.Equal(t, cluster.Status.RemovedUsers[0].Policy, "NoLogin")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.UserRemoval = &v1beta1.PostgresUserRemovalSpec{
+			Policy: "Drop", ReassignOwnedTo: "app",
+		}
+		cluster.Status.RemovedUsers = []v1beta1.PostgresUserRemovalStatus{
+			{Name: "one"},
+		}
+
+		expected := errors.New("boom")
+		exec := func(
+			_ context.Context, _ io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			assert.Assert(t, cmp.Contains(command, `--set=owner=app`))
+			return expected
+		}
+
+		err := removePostgresUsersInPostgreSQL(ctx, cluster, exec, nil)
+		assert.Assert(t, errors.Is(err, expected))
+		assert.Equal(t, cluster.Status.RemovedUsers[0].Policy, "Drop")
+		assert.Assert(t, !cluster.Status.RemovedUsers[0].Applied)
+		assert.Equal(t, cluster.Status.RemovedUsers[0].Message, "boom")
+	})
+
+	t.Run("ReassignOwner", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.UserRemoval = &v1beta1.PostgresUserRemovalSpec{
+			Policy: "Drop", ReassignOwnedTo: "app",
+		}
+		cluster.Status.RemovedUsers = []v1beta1.PostgresUserRemovalStatus{
+			{Name: "app"}, {Name: "one"},
+		}
+
+		exec := func(
+			_ context.Context, _ io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			assert.Assert(t, cmp.Contains(command, `--set=roles=["one"]`))
+			return nil
+		}
+
+		// The owner is not dropped and remains pending with a reason.
+		assert.NilError(t, removePostgresUsersInPostgreSQL(ctx, cluster, exec, nil))
+		assert.Assert(t, !cluster.Status.RemovedUsers[0].Applied)
+		assert.Assert(t, cmp.Contains(cluster.Status.RemovedUsers[0].Message, "reassigned"))
+		assert.Assert(t, cluster.Status.RemovedUsers[1].Applied)
+	})
+}
+
+func TestReconcilePostgresUsersInPostgreSQLRemoved(t *testing.T) {
+	ctx := context.Background()
+	observed := &observedInstances{forCluster: []*Instance{{
+		Name: "instance",
+		Pods: []*corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pod",
+				Annotations: map[string]string{"status": `{"role":"master"}`},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: naming.ContainerDatabase,
+					State: corev1.ContainerState{
+						Running: new(corev1.ContainerStateRunning),
+					},
+				}},
+			},
+		}},
+		Runner: &appsv1.StatefulSet{},
+	}}}
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.UserRemoval = &v1beta1.PostgresUserRemovalSpec{Policy: "Drop"}
+	cluster.Status.Users = []string{"app", "gone"}
+	specUsers := []v1beta1.PostgresUserSpec{{Name: "app"}}
+
+	var drops int
+	var failure error
+	r := &Reconciler{
+		Recorder: new(record.FakeRecorder),
+		PodExec: func(_, _, _ string, stdin io.Reader, _, _ io.Writer, command ...string) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			if strings.Contains(string(b), "DROP ROLE") {
+				drops++
+				assert.Assert(t, cmp.Contains(command, `--set=roles=["gone"]`))
+				return failure
+			}
+			return nil
+		},
+	}
+
+	t.Run("Failure", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		failure = errors.New("boom")
+
+		assert.Assert(t, r.reconcilePostgresUsersInPostgreSQL(
+			ctx, cluster, observed, specUsers, nil) != nil)
+		assert.Equal(t, drops, 1)
+		assert.DeepEqual(t, cluster.Status.Users, []string{"app", "gone"})
+		assert.Equal(t, len(cluster.Status.RemovedUsers), 1)
+		assert.Assert(t, !cluster.Status.RemovedUsers[0].Applied)
+		assert.Equal(t, cluster.Status.RemovedUsers[0].Message, "boom")
+	})
+
+	// The removal comes from the status, so it happens even when nothing
+	// about it was recorded before.
+	t.Run("Success", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		failure, drops = nil, 0
+
+		assert.NilError(t, r.reconcilePostgresUsersInPostgreSQL(
+			ctx, cluster, observed, specUsers, nil))
+		assert.Equal(t, drops, 1)
+		assert.DeepEqual(t, cluster.Status.Users, []string{"app"})
+		assert.Equal(t, len(cluster.Status.RemovedUsers), 1)
+		assert.Equal(t, cluster.Status.RemovedUsers[0].Name, "gone")
+		assert.Assert(t, cluster.Status.RemovedUsers[0].Applied)
+
+		// Nothing is removed again.
+		drops = 0
+		cluster.Status.UsersRevision = ""
+		assert.NilError(t, r.reconcilePostgresUsersInPostgreSQL(
+			ctx, cluster, observed, specUsers, nil))
+		assert.Equal(t, drops, 0)
+	})
+
+	// The alternate of a user is dropped when its password stops rotating.
+	t.Run("RotationEnded", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Spec.Users = []v1beta1.PostgresUserSpec{{Name: "gone"}}
+		cluster.Status.Users = []string{"gone", "gone-alternate"}
+		cluster.Status.UserPasswords = []v1beta1.PostgresUserPasswordStatus{
+			{Name: "gone", ActiveUser: "gone-alternate"},
+		}
+
+		var dropped []string
+		r := &Reconciler{
+			Recorder: new(record.FakeRecorder),
+			PodExec: func(_, _, _ string, stdin io.Reader, _, _ io.Writer, command ...string) error {
+				b, err := io.ReadAll(stdin)
+				assert.NilError(t, err)
+				if strings.Contains(string(b), "DROP ROLE") {
+					dropped = append(dropped, command...)
+				}
+				return nil
+			},
+		}
+
+		assert.NilError(t, r.reconcilePostgresUsersInPostgreSQL(
+			ctx, cluster, observed, cluster.Spec.Users, nil))
+		assert.Assert(t, cmp.Contains(dropped, `--set=roles=["gone-alternate"]`))
+		assert.DeepEqual(t, cluster.Status.Users, []string{"gone"})
+		assert.Equal(t, cluster.Status.RemovedUsers[0].Policy, "Drop")
+		assert.Assert(t, cluster.Status.UserPasswords == nil)
+	})
+}
+
+func TestRotatePostgresUserPassword(t *testing.T) {
+	start := metav1.Now().Rfc3339Copy()
+	later := metav1.NewTime(start.Add(2 * time.Hour))
+
+	secret := &corev1.Secret{Data: map[string][]byte{
+		"password": []byte("first"),
+		"verifier": []byte("first-verifier"),
+	}}
+
+	t.Run("NoRotation", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		spec := &v1beta1.PostgresUserSpec{Name: "app"}
+
+		assert.Equal(t, rotatePostgresUserPassword(cluster, spec, secret, start), secret)
+		assert.Assert(t, cluster.Status.UserPasswords == nil)
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		spec := &v1beta1.PostgresUserSpec{
+			Name: "app",
+			Password: &v1beta1.PostgresPasswordSpec{
+				Rotation: &v1beta1.PostgresPasswordRotationSpec{IntervalSeconds: 3600},
+			},
+		}
+
+		// The first call starts counting.
+		assert.Equal(t, rotatePostgresUserPassword(cluster, spec, secret, start), secret)
+		assert.Equal(t, len(cluster.Status.UserPasswords), 1)
+		status := cluster.Status.UserPasswords[0]
+		assert.Equal(t, status.Name, "app")
+		assert.Equal(t, status.RotatedTime, start)
+		assert.Equal(t, status.NextRotationTime.Time, start.Add(time.Hour))
+
+		// The password is discarded once the interval passes.
+		result := rotatePostgresUserPassword(cluster, spec, secret, later)
+		assert.Assert(t, result != secret)
+		assert.Equal(t, len(result.Data["password"]), 0)
+		assert.Equal(t, len(result.Data["verifier"]), 0)
+		assert.Equal(t, len(result.Data["previous-verifier"]), 0)
+		assert.Equal(t, string(secret.Data["password"]), "first", "expected no change")
+
+		status = cluster.Status.UserPasswords[0]
+		assert.Equal(t, status.ActiveUser, "")
+		assert.Equal(t, status.RotatedTime, later)
+		assert.Equal(t, status.NextRotationTime.Time, later.Add(time.Hour))
+	})
+
+	t.Run("GracePeriod", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Status.UserPasswords = []v1beta1.PostgresUserPasswordStatus{
+			{Name: "app", RotatedTime: start},
+		}
+		spec := &v1beta1.PostgresUserSpec{
+			Name: "app",
+			Password: &v1beta1.PostgresPasswordSpec{
+				Rotation: &v1beta1.PostgresPasswordRotationSpec{
+					IntervalSeconds:    3600,
+					GracePeriodSeconds: initialize.Int32(600),
+				},
+			},
+		}
+
+		result := rotatePostgresUserPassword(cluster, spec, secret, later)
+		assert.Equal(t, len(result.Data["password"]), 0)
+		assert.Equal(t, string(result.Data["previous-verifier"]), "first-verifier")
+		assert.Equal(t, cluster.Status.UserPasswords[0].ActiveUser, "app-alternate")
+
+		// The next rotation switches back.
+		result.Data["password"] = []byte("second")
+		result.Data["verifier"] = []byte("second-verifier")
+		result = rotatePostgresUserPassword(cluster, spec, result,
+			metav1.NewTime(later.Add(time.Hour)))
+		assert.Equal(t, string(result.Data["previous-verifier"]), "second-verifier")
+		assert.Equal(t, cluster.Status.UserPasswords[0].ActiveUser, "app")
+	})
+}
+
+func TestPostgresUsersWithRotation(t *testing.T) {
+	rotated := metav1.Date(2022, time.October, 1, 12, 0, 0, 0, time.UTC)
+	rotation := &v1beta1.PostgresPasswordRotationSpec{
+		IntervalSeconds:    3600,
+		GracePeriodSeconds: initialize.Int32(600),
+		Expire:             true,
+	}
+	secrets := map[string]*corev1.Secret{
+		"app": {Data: map[string][]byte{
+			"verifier":          []byte("new"),
+			"previous-verifier": []byte("old"),
+		}},
+		"other": {Data: map[string][]byte{"verifier": []byte("other")}},
+	}
+
+	t.Run("NoRotation", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		users := []v1beta1.PostgresUserSpec{{Name: "other", Options: "CREATEDB"}}
+
+		result, verifiers, alternates := postgresUsersWithRotation(cluster, users, secrets)
+		assert.DeepEqual(t, result, users)
+		assert.DeepEqual(t, verifiers, map[string]string{"other": "other"})
+		assert.Equal(t, len(alternates), 0)
+
+		// Expiration is cleared after rotation is removed.
+		cluster.Status.UserPasswords = []v1beta1.PostgresUserPasswordStatus{
+			{Name: "other", RotatedTime: rotated},
+		}
+		result, _, _ = postgresUsersWithRotation(cluster, users, secrets)
+		assert.Equal(t, result[0].Options, `CREATEDB VALID UNTIL 'infinity'`)
+	})
+
+	t.Run("Alternate", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Status.UserPasswords = []v1beta1.PostgresUserPasswordStatus{
+			{Name: "app", RotatedTime: rotated, ActiveUser: "app-alternate"},
+		}
+		users := []v1beta1.PostgresUserSpec{{
+			Name:      "app",
+			Databases: []v1beta1.PostgresIdentifier{"db"},
+			Password:  &v1beta1.PostgresPasswordSpec{Rotation: rotation},
+		}}
+
+		result, verifiers, alternates := postgresUsersWithRotation(cluster, users, secrets)
+		assert.Equal(t, len(result), 2)
+		assert.Equal(t, result[0].Name, v1beta1.PostgresIdentifier("app"))
+		assert.Equal(t, result[0].Options, `VALID UNTIL '2022-10-01T12:10:00Z'`)
+		assert.Equal(t, result[1].Name, v1beta1.PostgresIdentifier("app-alternate"))
+		assert.Equal(t, result[1].Options, `VALID UNTIL '2022-10-01T13:10:00Z'`)
+		assert.Assert(t, result[1].Databases == nil)
+
+		assert.DeepEqual(t, verifiers, map[string]string{
+			"app": "old", "app-alternate": "new",
+		})
+		assert.DeepEqual(t, alternates, map[string]string{"app-alternate": "app"})
+	})
+}
+
+func TestPostgresUserPasswordFromSecret(t *testing.T) {
+	const verifier = "SCRAM-SHA-256$4096:c2FsdA==$c3RvcmVk:c2VydmVy"
+
+	t.Run("Verifier", func(t *testing.T) {
+		for _, value := range []string{
+			verifier, "md5" + strings.Repeat("0a", 16),
+		} {
+			assert.Assert(t, isPostgresPasswordVerifier([]byte(value)), "%q", value)
+		}
+		for _, value := range []string{
+			"", "password", "md5", "md5" + strings.Repeat("zz", 16), "scram-sha-256$",
+		} {
+			assert.Assert(t, !isPostgresPasswordVerifier([]byte(value)), "%q", value)
+		}
+
+		existing := &corev1.Secret{Data: map[string][]byte{
+			"password": []byte("old"), "verifier": []byte("old$verifier"),
+		}}
+
+		result := postgresUserPasswordFromSecret(existing, []byte(verifier))
+		assert.DeepEqual(t, result.Data, map[string][]byte{
+			"verifier": []byte(verifier),
+		})
+
+		// The existing Secret is not changed.
+		assert.Equal(t, string(existing.Data["password"]), "old")
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		// Nothing existing.
+		result := postgresUserPasswordFromSecret(nil, []byte("secret"))
+		assert.DeepEqual(t, result.Data, map[string][]byte{
+			"password": []byte("secret"),
+		})
+
+		existing := &corev1.Secret{Data: map[string][]byte{
+			"password": []byte("secret"), "verifier": []byte("some$verifier"),
+		}}
+
+		// Same password; keep the verifier.
+		result = postgresUserPasswordFromSecret(existing, []byte("secret"))
+		assert.DeepEqual(t, result.Data, existing.Data)
+
+		// Different password; discard the verifier.
+		result = postgresUserPasswordFromSecret(existing, []byte("changed"))
+		assert.DeepEqual(t, result.Data, map[string][]byte{
+			"password": []byte("changed"),
+		})
+	})
+}
+
+func TestComparePostgresUserPasswords(t *testing.T) {
+	ctx := context.Background()
+	reconciler := &Reconciler{}
+	cluster := &v1beta1.PostgresCluster{}
+
+	calls := 0
+	exec := func(
+		_ context.Context, stdin io.Reader, stdout, _ io.Writer, command ...string,
+	) error {
+		calls++
+
+		// Users without a verifier are not compared.
+		assert.Assert(t, cmp.Contains(command, `--set=verifiers={"app":"SCRAM-SHA-256$some"}`))
+
+		_, err := stdout.Write([]byte(`["app"]`))
+		return err
+	}
+
+	verifiers := map[string]string{"app": "SCRAM-SHA-256$some", "empty": ""}
+
+	different, err := reconciler.comparePostgresUserPasswords(ctx, cluster, exec, verifiers)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, different, []string{"app"})
+	assert.Equal(t, calls, 1)
+	assert.Assert(t, cluster.Status.UserPasswordsCheckedTime != nil)
+	assert.DeepEqual(t, cluster.Status.UserPasswordsDrifted, []string{"app"})
+
+	// Not again until the interval elapses.
+	different, err = reconciler.comparePostgresUserPasswords(ctx, cluster, exec, verifiers)
+	assert.NilError(t, err)
+	assert.Assert(t, different == nil)
+	assert.Equal(t, calls, 1)
+
+	cluster.Status.UserPasswordsCheckedTime = &metav1.Time{
+		Time: time.Now().Add(-postgresPasswordCheckInterval - time.Second),
+	}
+	_, err = reconciler.comparePostgresUserPasswords(ctx, cluster, exec, verifiers)
+	assert.NilError(t, err)
+	assert.Equal(t, calls, 2)
+
+	// Users are no longer listed once their passwords match.
+	cluster.Status.UserPasswordsCheckedTime = nil
+	different, err = reconciler.comparePostgresUserPasswords(ctx, cluster,
+		func(_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string) error {
+			_, err := stdout.Write([]byte(`[]`))
+			return err
+		}, verifiers)
+	assert.NilError(t, err)
+	assert.Equal(t, len(different), 0)
+	assert.Assert(t, cluster.Status.UserPasswordsDrifted == nil)
+}
+
+func TestVerifyPostgresUserPassword(t *testing.T) {
+	reconciler := &Reconciler{}
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.UID = "some-uid"
+
+	verifier, err := pgpassword.NewSCRAMPassword("secret").Build()
+	assert.NilError(t, err)
+
+	assert.Assert(t, reconciler.verifyPostgresUserPassword(cluster, "app", "secret", verifier))
+	assert.Assert(t, !reconciler.verifyPostgresUserPassword(cluster, "app", "other", verifier))
+
+	remembered := func() (count int) {
+		reconciler.verifiedPasswords.Range(func(_, _ any) bool { count++; return true })
+		return
+	}
+
+	// Only matches are remembered.
+	assert.Equal(t, remembered(), 1)
+
+	// A remembered match is not checked again.
+	key := verifiedPasswordKey{cluster: "some-uid", login: "app"}
+	reconciler.verifiedPasswords.Store(key, sha256.Sum256([]byte("secret\x00bogus")))
+	assert.Assert(t, reconciler.verifyPostgresUserPassword(cluster, "app", "secret", "bogus"))
+
+	// A new match for the same user replaces the old one.
+	verifier, err = pgpassword.NewSCRAMPassword("changed").Build()
+	assert.NilError(t, err)
+	assert.Assert(t, reconciler.verifyPostgresUserPassword(cluster, "app", "changed", verifier))
+	assert.Assert(t, !reconciler.verifyPostgresUserPassword(cluster, "app", "secret", "bogus"))
+	assert.Equal(t, remembered(), 1)
+
+	// Matches of other clusters are kept when a cluster is forgotten.
+	other := cluster.DeepCopy()
+	other.UID = "other-uid"
+	assert.Assert(t, reconciler.verifyPostgresUserPassword(other, "app", "changed", verifier))
+	assert.Equal(t, remembered(), 2)
+
+	reconciler.forgetPostgresUserPasswords(cluster)
+	assert.Equal(t, remembered(), 1)
+	_, ok := reconciler.verifiedPasswords.Load(verifiedPasswordKey{cluster: "other-uid", login: "app"})
+	assert.Assert(t, ok)
+}
+
+func TestPostgresUserCertificate(t *testing.T) {
+	root, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	spec := &v1beta1.PostgresUserSpec{Name: "app"}
+	intent := &corev1.Secret{Data: map[string][]byte{}}
+
+	// Generated when nothing exists.
+	assert.NilError(t, postgresUserCertificate(root, spec, nil, intent))
+
+	leaf := &pki.LeafCertificate{}
+	assert.NilError(t, leaf.Certificate.UnmarshalText(intent.Data["tls.crt"]))
+	assert.NilError(t, leaf.PrivateKey.UnmarshalText(intent.Data["tls.key"]))
+	assert.Equal(t, leaf.Certificate.CommonName(), "app")
+	assert.Assert(t, leaf.Certificate.DNSNames() == nil, "expected no DNS names")
+
+	authority, err := root.Certificate.MarshalText()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, intent.Data["ca.crt"], authority)
+
+	// Kept when it is still good.
+	existing := intent.DeepCopy()
+	intent = &corev1.Secret{Data: map[string][]byte{}}
+	assert.NilError(t, postgresUserCertificate(root, spec, existing, intent))
+	assert.DeepEqual(t, intent.Data, existing.Data)
+
+	// Replaced when it is for another user.
+	spec.Name = "other"
+	intent = &corev1.Secret{Data: map[string][]byte{}}
+	assert.NilError(t, postgresUserCertificate(root, spec, existing, intent))
+	assert.Assert(t, string(intent.Data["tls.crt"]) != string(existing.Data["tls.crt"]))
+
+	assert.NilError(t, leaf.Certificate.UnmarshalText(intent.Data["tls.crt"]))
+	assert.Equal(t, leaf.Certificate.CommonName(), "other")
+}
+
+func TestSetParametersValidCondition(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{Recorder: recorder}
+
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.PostgresVersion = 14
+	cluster.Spec.Config.Parameters = map[string]v1beta1.PostgresParameterValue{
+		"jit": "sometimes", "not_in_the_catalog": "x",
+	}
+
+	condition := func() *metav1.Condition {
+		return meta.FindStatusCondition(cluster.Status.Conditions, v1beta1.PostgresParametersValid)
+	}
+	problems := func() []error {
+		parameters := postgres.NewParameters()
+		return postgres.ConfigParameters(cluster, &parameters)
+	}
+
+	reconciler.setParametersValidCondition(cluster, problems())
+	assert.Equal(t, condition().Status, metav1.ConditionFalse)
+	assert.Equal(t, condition().Reason, "InvalidParameters")
+	assert.Assert(t, strings.Contains(condition().Message, `"jit"`), condition().Message)
+	assert.Assert(t, strings.Contains(condition().Message, `"not_in_the_catalog"`), condition().Message)
+
+	assert.Equal(t, len(recorder.Events), 2)
+	assert.Assert(t, strings.HasPrefix(<-recorder.Events, "Warning InvalidParameter "))
+	assert.Assert(t, strings.HasPrefix(<-recorder.Events, "Warning UnknownParameter "))
+
+	// Events are recorded only when a problem first appears.
+	reconciler.setParametersValidCondition(cluster, problems())
+	assert.Equal(t, len(recorder.Events), 0)
+
+	cluster.Spec.Config.Parameters["work_mem"] = "lots"
+	reconciler.setParametersValidCondition(cluster, problems())
+	assert.Equal(t, len(recorder.Events), 1)
+	assert.Assert(t, strings.Contains(<-recorder.Events, `"work_mem"`))
+
+	// The condition is true when there are no problems.
+	cluster.Spec.Config.Parameters = nil
+	reconciler.setParametersValidCondition(cluster, problems())
+	assert.Equal(t, condition().Status, metav1.ConditionTrue)
+	assert.Equal(t, len(recorder.Events), 0)
+
+	// Problems that return are recorded again.
+	cluster.Spec.Config.Parameters = map[string]v1beta1.PostgresParameterValue{"jit": "sometimes"}
+	reconciler.setParametersValidCondition(cluster, problems())
+	assert.Equal(t, len(recorder.Events), 1)
+}
@@ -16,14 +16,19 @@
 package postgrescluster
 
 import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/internal/naming"
 	"github.com/crunchydata/postgres-operator/internal/patroni"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // watchPods returns a handler.EventHandler for Pods.
@@ -60,3 +65,94 @@ func (*Reconciler) watchPods() handler.Funcs {
 		},
 	}
 }
+
+// indexUserPasswordSecrets is the name of a field index of PostgresClusters
+// by the Secrets that their users read passwords from.
+const indexUserPasswordSecrets = "spec.users.password.secretKeyRef.name"
+
+// postgresUserPasswordSecrets returns the names of Secrets that users of
+// cluster read their passwords from. It is a client.IndexerFunc.
+func postgresUserPasswordSecrets(object client.Object) []string {
+	cluster, ok := object.(*v1beta1.PostgresCluster)
+	if !ok {
+		return nil
+	}
+
+	names := sets.NewString()
+	for _, user := range cluster.Spec.Users {
+		if user.Password != nil && user.Password.SecretKeyRef != nil {
+			names.Insert(user.Password.SecretKeyRef.Name)
+		}
+	}
+	return names.List()
+}
+
+// watchSecrets returns a handler.EventHandler for Secrets. It queues every
+// PostgresCluster with a user that reads its password from the Secret.
+func (r *Reconciler) watchSecrets() handler.Funcs {
+	handle := func(secret client.Object, q workqueue.RateLimitingInterface) {
+		ctx := context.Background()
+		log := logging.FromContext(ctx).WithValues(
+			"namespace", secret.GetNamespace(), "secret", secret.GetName())
+
+		// Find clusters using the index registered in SetupWithManager.
+		clusters := &v1beta1.PostgresClusterList{}
+		if err := r.Client.List(ctx, clusters,
+			client.InNamespace(secret.GetNamespace()),
+			client.MatchingFields{indexUserPasswordSecrets: secret.GetName()},
+		); err != nil {
+			log.Error(err, "unable to list PostgresClusters")
+			return
+		}
+
+		for i := range clusters.Items {
+			if sets.NewString(postgresUserPasswordSecrets(&clusters.Items[i])...).Has(secret.GetName()) {
+				q.Add(reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(&clusters.Items[i]),
+				})
+			}
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			handle(e.Object, q)
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			handle(e.ObjectNew, q)
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			handle(e.Object, q)
+		},
+	}
+}
+
+// watchPGBackRestBackups returns a handler.EventHandler for PGBackRestBackups.
+// It queues the PostgresCluster that a PGBackRestBackup references.
+func (*Reconciler) watchPGBackRestBackups() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
+		backup, ok := object.(*v1beta1.PGBackRestBackup)
+		if !ok || backup.Spec.PostgresClusterName == "" {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{
+			Namespace: backup.GetNamespace(),
+			Name:      backup.Spec.PostgresClusterName,
+		}}}
+	})
+}
+
+// watchPostgresRestores returns a handler.EventHandler for PostgresRestores.
+// It queues the PostgresCluster that a PostgresRestore references.
+func (*Reconciler) watchPostgresRestores() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
+		restore, ok := object.(*v1beta1.PostgresRestore)
+		if !ok || restore.Spec.PostgresClusterName == "" {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{
+			Namespace: restore.GetNamespace(),
+			Name:      restore.Spec.PostgresClusterName,
+		}}}
+	})
+}
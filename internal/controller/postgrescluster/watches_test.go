@@ -22,9 +22,13 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crunchydata/postgres-operator/internal/controller/runtime"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestWatchPodsUpdate(t *testing.T) {
@@ -141,3 +145,127 @@ func TestWatchPodsUpdate(t *testing.T) {
 		queue.Done(item)
 	})
 }
+
+func TestWatchSecrets(t *testing.T) {
+	scheme, err := runtime.CreatePostgresOperatorScheme()
+	assert.NilError(t, err)
+
+	cluster := func(namespace, name string, users ...v1beta1.PostgresUserSpec) *v1beta1.PostgresCluster {
+		cluster := &v1beta1.PostgresCluster{}
+		cluster.Namespace, cluster.Name = namespace, name
+		cluster.Spec.Users = users
+		return cluster
+	}
+	reference := func(user, secret string) v1beta1.PostgresUserSpec {
+		return v1beta1.PostgresUserSpec{
+			Name: v1beta1.PostgresIdentifier(user),
+			Password: &v1beta1.PostgresPasswordSpec{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+					Key:                  "password",
+				},
+			},
+		}
+	}
+
+	reconciler := &Reconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			cluster("ns1", "no-refs", v1beta1.PostgresUserSpec{Name: "app"}),
+			cluster("ns1", "one-ref", reference("app", "vault"), reference("other", "vault")),
+			cluster("ns1", "other-ref", reference("app", "elsewhere")),
+			cluster("ns2", "same-ref", reference("app", "vault")),
+		).Build(),
+	}
+
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	secret := &corev1.Secret{}
+	secret.Namespace, secret.Name = "ns1", "vault"
+
+	// Only clusters in the same namespace that reference the Secret.
+	reconciler.watchSecrets().Update(event.UpdateEvent{
+		ObjectOld: secret, ObjectNew: secret,
+	}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
+	item, _ := queue.Get()
+	expected := reconcile.Request{}
+	expected.Namespace = "ns1"
+	expected.Name = "one-ref"
+	assert.Equal(t, item, expected)
+	queue.Done(item)
+
+	// Nothing references this Secret.
+	secret.Name = "unknown"
+	reconciler.watchSecrets().Create(event.CreateEvent{Object: secret}, queue)
+	assert.Equal(t, queue.Len(), 0)
+}
+
+func TestPostgresUserPasswordSecrets(t *testing.T) {
+	assert.Assert(t, postgresUserPasswordSecrets(&corev1.Secret{}) == nil)
+
+	cluster := &v1beta1.PostgresCluster{}
+	assert.DeepEqual(t, postgresUserPasswordSecrets(cluster), []string{})
+
+	for _, name := range []string{"vault", "", "elsewhere", "vault"} {
+		user := v1beta1.PostgresUserSpec{Name: "app"}
+		if name != "" {
+			user.Password = &v1beta1.PostgresPasswordSpec{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				},
+			}
+		}
+		cluster.Spec.Users = append(cluster.Spec.Users, user)
+	}
+	assert.DeepEqual(t, postgresUserPasswordSecrets(cluster), []string{"elsewhere", "vault"})
+}
+
+func TestWatchPGBackRestBackups(t *testing.T) {
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	reconciler := &Reconciler{}
+
+	backup := &v1beta1.PGBackRestBackup{}
+	backup.Namespace, backup.Name = "ns1", "nightly"
+
+	// Nothing happens without a cluster name.
+	reconciler.watchPGBackRestBackups().Create(event.CreateEvent{Object: backup}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// The referenced cluster is queued.
+	backup.Spec.PostgresClusterName = "hippo"
+	reconciler.watchPGBackRestBackups().Delete(event.DeleteEvent{Object: backup}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
+	item, _ := queue.Get()
+	expected := reconcile.Request{}
+	expected.Namespace = "ns1"
+	expected.Name = "hippo"
+	assert.Equal(t, item, expected)
+	queue.Done(item)
+}
+
+func TestWatchPostgresRestores(t *testing.T) {
+	queue := controllertest.Queue{Interface: workqueue.New()}
+	reconciler := &Reconciler{}
+
+	restore := &v1beta1.PostgresRestore{}
+	restore.Namespace, restore.Name = "ns1", "yesterday"
+
+	// Nothing happens without a cluster name.
+	reconciler.watchPostgresRestores().Create(event.CreateEvent{Object: restore}, queue)
+	assert.Equal(t, queue.Len(), 0)
+
+	// The referenced cluster is queued.
+	restore.Spec.PostgresClusterName = "hippo"
+	reconciler.watchPostgresRestores().Update(event.UpdateEvent{
+		ObjectOld: restore, ObjectNew: restore,
+	}, queue)
+	assert.Equal(t, queue.Len(), 1)
+
+	item, _ := queue.Get()
+	expected := reconcile.Request{}
+	expected.Namespace = "ns1"
+	expected.Name = "hippo"
+	assert.Equal(t, item, expected)
+	queue.Done(item)
+}
@@ -0,0 +1,652 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crunchydata/postgres-operator/internal/config"
+	"github.com/crunchydata/postgres-operator/internal/kubeapi"
+	"github.com/crunchydata/postgres-operator/internal/pgbackrest"
+	"github.com/crunchydata/postgres-operator/internal/postgres"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// +kubebuilder:webhook:path=/mutate-postgres-operator-crunchydata-com-v1beta1-postgrescluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=create;update,versions=v1beta1,name=mpostgrescluster.postgres-operator.crunchydata.com,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-postgres-operator-crunchydata-com-v1beta1-postgrescluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=create;update,versions=v1beta1,name=vpostgrescluster.postgres-operator.crunchydata.com,admissionReviewVersions=v1
+
+// SetupWebhookWithManager registers the PostgresCluster mutating and
+// validating admission webhooks with the webhook server of mgr.
+func (r *Reconciler) SetupWebhookWithManager(mgr manager.Manager) error {
+	return builder.WebhookManagedBy(mgr).
+		For(&v1beta1.PostgresCluster{}).
+		WithDefaulter(webhook{}).
+		WithValidator(webhook{}).
+		Complete()
+}
+
+// webhook implements admission.CustomDefaulter and admission.CustomValidator
+// for PostgresCluster.
+type webhook struct{}
+
+var _ admission.CustomDefaulter = webhook{}
+var _ admission.CustomValidator = webhook{}
+
+// Default sets any defaults that would otherwise be computed by the
+// Reconciler, including the default user and database, so they are stored
+// in the API.
+func (webhook) Default(_ context.Context, obj runtime.Object) error {
+	cluster, ok := obj.(*v1beta1.PostgresCluster)
+	if !ok {
+		return apierrors.NewBadRequest(
+			fmt.Sprintf("expected a PostgresCluster but got a %T", obj))
+	}
+
+	cluster.Default()
+
+	// Leave users unspecified when the cluster name is not a valid user name.
+	// The validating webhook does not reject this because an explicit list
+	// of users is a valid way to fix it.
+	if cluster.Spec.Users == nil {
+		if users, errs := defaultPostgresUsers(cluster); len(errs) == 0 {
+			cluster.Spec.Users = users
+		}
+	}
+
+	return nil
+}
+
+// ValidateCreate rejects a PostgresCluster with an invalid spec.
+func (webhook) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	cluster, ok := obj.(*v1beta1.PostgresCluster)
+	if !ok {
+		return apierrors.NewBadRequest(
+			fmt.Sprintf("expected a PostgresCluster but got a %T", obj))
+	}
+	return invalidPostgresCluster(cluster, validatePostgresCluster(cluster))
+}
+
+// ValidateUpdate rejects changes that would make a PostgresCluster spec invalid.
+// Problems that were already present in the old spec are allowed so that the
+// operator and users can continue to change a cluster that was created before
+// a check existed or while the environment of the operator was different.
+func (webhook) ValidateUpdate(_ context.Context, oldObj, obj runtime.Object) error {
+	cluster, ok := obj.(*v1beta1.PostgresCluster)
+	if !ok {
+		return apierrors.NewBadRequest(
+			fmt.Sprintf("expected a PostgresCluster but got a %T", obj))
+	}
+	old, ok := oldObj.(*v1beta1.PostgresCluster)
+	if !ok {
+		return apierrors.NewBadRequest(
+			fmt.Sprintf("expected a PostgresCluster but got a %T", oldObj))
+	}
+
+	// Allow the finalizer to be removed from a cluster that is being deleted,
+	// regardless of its spec.
+	if cluster.DeletionTimestamp != nil {
+		return nil
+	}
+
+	return invalidPostgresCluster(cluster, kubeapi.IntroducedErrors(
+		validatePostgresCluster(old), validatePostgresCluster(cluster)))
+}
+
+// ValidateDelete allows every PostgresCluster to be deleted.
+func (webhook) ValidateDelete(context.Context, runtime.Object) error { return nil }
+
+// invalidPostgresCluster returns an Invalid API error for cluster when there
+// are any errors in allErrors.
+func invalidPostgresCluster(cluster *v1beta1.PostgresCluster, allErrors field.ErrorList) error {
+	if len(allErrors) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		v1beta1.GroupVersion.WithKind("PostgresCluster").GroupKind(),
+		cluster.Name, allErrors)
+}
+
+// validatePostgresCluster checks the parts of a PostgresCluster spec that
+// cannot be expressed in its OpenAPI schema. Defaults are applied to a copy
+// of cluster before checking it.
+func validatePostgresCluster(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	cluster = cluster.DeepCopy()
+	cluster.Default()
+
+	spec := field.NewPath("spec")
+	allErrors := field.ErrorList{}
+
+	// Instance set names default to their position in the list, so an explicit
+	// name can collide with a default one. See also the pattern and comment on
+	// v1beta1.PostgresInstanceSetSpec.Name.
+	setNames := sets.NewString()
+	for i := range cluster.Spec.InstanceSets {
+		name := cluster.Spec.InstanceSets[i].Name
+		path := spec.Child("instances").Index(i).Child("name")
+
+		if setNames.Has(name) {
+			allErrors = append(allErrors, field.Duplicate(path, name))
+		}
+		if n := len(cluster.Name) + len(name); n > 46 {
+			allErrors = append(allErrors, field.Invalid(path, name, fmt.Sprintf(
+				"combined length of cluster name and instance set name must be "+
+					"no more than 46 characters, got %d", n)))
+		}
+		setNames.Insert(name)
+	}
+
+	// Cascading replication must end at the primary, so instance sets cannot
+	// replicate from one another in a loop.
+	replicateFrom := map[string]string{}
+	for _, set := range cluster.Spec.InstanceSets {
+		if set.PatroniTags != nil && set.PatroniTags.ReplicateFrom != "" {
+			replicateFrom[set.Name] = set.PatroniTags.ReplicateFrom
+		}
+	}
+	for i, set := range cluster.Spec.InstanceSets {
+		source, ok := replicateFrom[set.Name]
+		if !ok {
+			continue
+		}
+		path := spec.Child("instances").Index(i).Child("patroniTags", "replicateFrom")
+
+		if !setNames.Has(source) {
+			allErrors = append(allErrors, field.NotFound(path, source))
+			continue
+		}
+		for next, steps := source, 0; next != "" && steps < len(replicateFrom); steps++ {
+			if next == set.Name {
+				allErrors = append(allErrors, field.Invalid(path, source,
+					"instance sets cannot replicate from one another in a loop"))
+				break
+			}
+			next = replicateFrom[next]
+		}
+	}
+
+	// Every image comes from the spec or an environment variable of the
+	// operator. Only PostgreSQL depends on the spec to pick that variable.
+	if config.PostgresContainerImage(cluster) == "" {
+		key := fmt.Sprintf("RELATED_IMAGE_POSTGRES_%d", cluster.Spec.PostgresVersion)
+		path := spec.Child("postgresVersion")
+		value := interface{}(cluster.Spec.PostgresVersion)
+
+		if version := cluster.Spec.PostGISVersion; version != "" {
+			key += "_GIS_" + version
+			path, value = spec.Child("postGISVersion"), version
+		}
+
+		allErrors = append(allErrors, field.Invalid(path, value, fmt.Sprintf(
+			"no image specified and the operator has no %s environment variable", key)))
+	}
+
+	allErrors = append(allErrors, validateBackups(cluster)...)
+	allErrors = append(allErrors, validateUsers(cluster)...)
+	allErrors = append(allErrors, validateAuthentication(cluster)...)
+	allErrors = append(allErrors, validateParameters(cluster)...)
+	allErrors = append(allErrors, validateServices(cluster)...)
+
+	if cluster.Spec.DataSource != nil &&
+		cluster.Spec.DataSource.PostgresCluster != nil &&
+		cluster.Spec.DataSource.PGBackRest != nil {
+		allErrors = append(allErrors, field.Forbidden(
+			spec.Child("dataSource", "pgbackrest"),
+			"cannot be combined with spec.dataSource.postgresCluster"))
+	}
+
+	if standby := cluster.Spec.Standby; standby != nil && standby.Enabled &&
+		standby.Host == "" && standby.RepoName == "" {
+		allErrors = append(allErrors, field.Required(
+			spec.Child("standby"), "a standby requires a host or repoName"))
+	}
+
+	if patroni := cluster.Spec.Patroni; patroni != nil {
+		path := spec.Child("patroni")
+
+		if *patroni.SyncPeriodSeconds >= *patroni.LeaderLeaseDurationSeconds {
+			allErrors = append(allErrors, field.Invalid(
+				path.Child("syncPeriodSeconds"), *patroni.SyncPeriodSeconds,
+				"must be less than leaderLeaseDurationSeconds"))
+		}
+
+		if switchover := patroni.Switchover; switchover != nil &&
+			switchover.Type == v1beta1.PatroniSwitchoverTypeFailover &&
+			(switchover.TargetInstance == nil || *switchover.TargetInstance == "") {
+			allErrors = append(allErrors, field.Required(
+				path.Child("switchover", "targetInstance"),
+				"a targetInstance is required to failover"))
+		}
+
+		if sync := patroni.SynchronousReplication; sync != nil {
+			for i, name := range sync.Candidates {
+				if !setNames.Has(name) {
+					allErrors = append(allErrors, field.NotFound(
+						path.Child("synchronousReplication", "candidates").Index(i), name))
+				}
+			}
+		}
+	}
+
+	return allErrors
+}
+
+// validateBackups checks that every reference to a pgBackRest repository in
+// cluster names a repository defined in spec.backups.pgbackrest.repos.
+func validateBackups(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	pgbackrest := field.NewPath("spec", "backups", "pgbackrest")
+	allErrors := field.ErrorList{}
+
+	repoNames := sets.NewString()
+	for _, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		repoNames.Insert(repo.Name)
+	}
+
+	checkRepoName := func(path *field.Path, name string) {
+		if !repoNames.Has(name) {
+			allErrors = append(allErrors,
+				field.NotSupported(path, name, repoNames.List()))
+		}
+	}
+
+	// Retention depends on the backups that are taken in each repository.
+	for i, repo := range cluster.Spec.Backups.PGBackRest.Repos {
+		retention := repo.Retention
+		if retention == nil {
+			continue
+		}
+
+		path := pgbackrest.Child("repos").Index(i).Child("retention")
+		schedules := repo.BackupSchedules
+		if schedules == nil {
+			schedules = new(v1beta1.PGBackRestBackupSchedules)
+		}
+
+		if retention.FullType == "time" && retention.Full == nil {
+			allErrors = append(allErrors, field.Required(path.Child("full"),
+				"the number of days to keep full backups is required when fullType is \"time\""))
+		}
+		if retention.Differential != nil && schedules.Differential == nil {
+			allErrors = append(allErrors, field.Invalid(path.Child("differential"),
+				*retention.Differential, "the repository has no differential backup schedule"))
+		}
+		if (retention.ArchiveType == "diff" && schedules.Differential == nil) ||
+			(retention.ArchiveType == "incr" && schedules.Incremental == nil) {
+			allErrors = append(allErrors, field.Invalid(path.Child("archiveType"),
+				retention.ArchiveType, "the repository has no backup schedule of this type"))
+		}
+
+		// Options in global would silently replace those in retention.
+		for _, option := range sets.StringKeySet(cluster.Spec.Backups.PGBackRest.Global).List() {
+			if strings.HasPrefix(option, repo.Name+"-retention-") {
+				allErrors = append(allErrors, field.Forbidden(
+					pgbackrest.Child("global").Key(option),
+					fmt.Sprintf("this option conflicts with %s", path)))
+			}
+		}
+	}
+
+	if manual := cluster.Spec.Backups.PGBackRest.Manual; manual != nil {
+		checkRepoName(pgbackrest.Child("manual", "repoName"), manual.RepoName)
+	}
+
+	// An in-place restore can read the repositories of another cluster.
+	if restore := cluster.Spec.Backups.PGBackRest.Restore; restore != nil &&
+		restore.PostgresClusterDataSource != nil &&
+		(restore.ClusterName == "" || restore.ClusterName == cluster.Name) &&
+		(restore.ClusterNamespace == "" || restore.ClusterNamespace == cluster.Namespace) {
+		checkRepoName(pgbackrest.Child("restore", "repoName"), restore.RepoName)
+	}
+
+	if standby := cluster.Spec.Standby; standby != nil && standby.RepoName != "" {
+		checkRepoName(field.NewPath("spec", "standby", "repoName"), standby.RepoName)
+	}
+
+	return allErrors
+}
+
+// hbaMethodOptions are the options accepted by each authentication method.
+// Any method accepts "clientcert" on TLS connections.
+// - https://www.postgresql.org/docs/current/auth-methods.html
+var hbaMethodOptions = map[string][]string{
+	"bsd":   nil,
+	"cert":  {"clientname", "map"},
+	"gss":   {"include_realm", "krb_realm", "map"},
+	"ident": {"map"},
+	"ldap": {
+		"ldapbasedn", "ldapbinddn", "ldapbindpasswd", "ldapport", "ldapprefix",
+		"ldapscheme", "ldapsearchattribute", "ldapsearchfilter", "ldapserver",
+		"ldapsuffix", "ldaptls", "ldapurl",
+	},
+	"md5":           nil,
+	"pam":           {"pam_use_hostname", "pamservice"},
+	"password":      nil,
+	"peer":          {"map"},
+	"radius":        {"radiusidentifiers", "radiusports", "radiussecrets", "radiusservers"},
+	"reject":        nil,
+	"scram-sha-256": nil,
+	"sspi":          {"compat_realm", "include_realm", "krb_realm", "map", "upn_username"},
+	"trust":         nil,
+}
+
+// validateAuthentication checks that each rule in spec.authentication uses
+// options and addresses that are valid for its connection and method.
+func validateAuthentication(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	allErrors := field.ErrorList{}
+	if cluster.Spec.Authentication == nil {
+		return allErrors
+	}
+
+	for i, rule := range cluster.Spec.Authentication.Rules {
+		path := field.NewPath("spec", "authentication", "rules").Index(i)
+		if rule.Connection == "" {
+			rule.Connection = "hostssl"
+		}
+
+		// Only some methods work with some kinds of connection.
+		// - https://www.postgresql.org/docs/current/auth-pg-hba-conf.html
+		switch {
+		case rule.Method == "cert" && rule.Connection != "hostssl":
+			allErrors = append(allErrors, field.Invalid(path.Child("method"), rule.Method,
+				`requires a "hostssl" connection`))
+		case rule.Method == "peer" && rule.Connection != "local":
+			allErrors = append(allErrors, field.Invalid(path.Child("method"), rule.Method,
+				`requires a "local" connection`))
+		case (rule.Method == "gss" || rule.Method == "sspi") && rule.Connection == "local":
+			allErrors = append(allErrors, field.Invalid(path.Child("method"), rule.Method,
+				`cannot be used with a "local" connection`))
+		}
+
+		if rule.Network != "" {
+			if rule.Connection == "local" {
+				allErrors = append(allErrors, field.Forbidden(path.Child("network"),
+					`cannot be used with a "local" connection`))
+			} else if _, _, err := net.ParseCIDR(rule.Network); err != nil &&
+				rule.Network != "samenet" {
+				allErrors = append(allErrors, field.Invalid(path.Child("network"),
+					rule.Network, `must be "samenet" or an address range in CIDR notation`))
+			}
+		}
+
+		allowed := sets.NewString(hbaMethodOptions[rule.Method]...)
+		if rule.Connection == "hostssl" {
+			allowed.Insert("clientcert")
+		}
+		for key := range rule.Options {
+			if !allowed.Has(key) {
+				allErrors = append(allErrors, field.NotSupported(
+					path.Child("options").Key(key), key, allowed.List()))
+			}
+		}
+	}
+
+	return allErrors
+}
+
+// validateParameters checks the names and values in spec.config.parameters
+// against the parameters of spec.postgresVersion. The Reconciler skips those
+// that are not valid and reports them in a condition.
+func validateParameters(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	allErrors := field.ErrorList{}
+
+	// The operator overrides these values. Others, like shared_preload_libraries,
+	// are appended to and can be specified.
+	managed := postgres.NewParameters()
+	pgbackrest.PostgreSQL(cluster, &managed)
+
+	names := make([]string, 0, len(cluster.Spec.Config.Parameters))
+	for name := range cluster.Spec.Config.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := field.NewPath("spec", "config", "parameters").Key(name)
+		value := cluster.Spec.Config.Parameters[name]
+
+		if managed.Mandatory.Has(name) {
+			allErrors = append(allErrors, field.Forbidden(path,
+				"this parameter is managed by the operator"))
+			continue
+		}
+
+		if err := validateParameter(path,
+			cluster.Spec.PostgresVersion, name, value.String()); err != nil {
+			allErrors = append(allErrors, err)
+		}
+	}
+
+	// Instance sets cannot change parameters that must be the same on every
+	// instance, either.
+	for i := range cluster.Spec.InstanceSets {
+		set := &cluster.Spec.InstanceSets[i]
+		if set.Config == nil {
+			continue
+		}
+
+		names := make([]string, 0, len(set.Config.Parameters))
+		for name := range set.Config.Parameters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			path := field.NewPath("spec", "instances").Index(i).
+				Child("config", "parameters").Key(name)
+			value := set.Config.Parameters[name]
+
+			if managed.Mandatory.Has(name) {
+				allErrors = append(allErrors, field.Forbidden(path,
+					"this parameter is managed by the operator"))
+				continue
+			}
+			if postgres.ParameterClusterWide(name) {
+				allErrors = append(allErrors, field.Forbidden(path,
+					"this parameter must be the same on every instance; set it in spec.config.parameters"))
+				continue
+			}
+
+			if err := validateParameter(path,
+				cluster.Spec.PostgresVersion, name, value.String()); err != nil {
+				allErrors = append(allErrors, err)
+			}
+		}
+	}
+
+	return allErrors
+}
+
+// validateParameter checks name and value against the parameters of version.
+// It returns an error about path when either is not valid.
+func validateParameter(path *field.Path, version int, name, value string) *field.Error {
+	err := postgres.ValidateParameter(version, name, value)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, postgres.ErrParameterUnknown):
+		return field.Invalid(path, name, err.Error())
+	case errors.Is(err, postgres.ErrParameterReadOnly):
+		return field.Forbidden(path, err.Error())
+	default:
+		return field.Invalid(path, value, err.Error())
+	}
+}
+
+// internalLoadBalancerAnnotations are the annotations that some cloud providers
+// read to put a load balancer on a private network.
+var internalLoadBalancerAnnotations = []string{
+	"cloud.google.com/load-balancer-type",
+	"networking.gke.io/load-balancer-type",
+	"service.beta.kubernetes.io/aws-load-balancer-internal",
+	"service.beta.kubernetes.io/azure-load-balancer-internal",
+	"service.beta.kubernetes.io/oci-load-balancer-internal",
+}
+
+// validateServices checks that the fields of each Service in cluster are
+// allowed with the type of that Service.
+func validateServices(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	spec := field.NewPath("spec")
+	allErrors := field.ErrorList{}
+
+	allErrors = append(allErrors, validateService(spec.Child("service"), cluster.Spec.Service)...)
+	allErrors = append(allErrors, validateService(spec.Child("replicaService"), cluster.Spec.ReplicaService)...)
+
+	for i := range cluster.Spec.InstanceSets {
+		allErrors = append(allErrors, validateService(
+			spec.Child("instances").Index(i).Child("replicaService"),
+			cluster.Spec.InstanceSets[i].ReplicaService)...)
+	}
+	if cluster.Spec.Proxy != nil && cluster.Spec.Proxy.PGBouncer != nil {
+		allErrors = append(allErrors, validateService(
+			spec.Child("proxy", "pgBouncer", "service"),
+			cluster.Spec.Proxy.PGBouncer.Service)...)
+	}
+	if cluster.Spec.UserInterface != nil && cluster.Spec.UserInterface.PGAdmin != nil {
+		allErrors = append(allErrors, validateService(
+			spec.Child("userInterface", "pgAdmin", "service"),
+			cluster.Spec.UserInterface.PGAdmin.Service)...)
+	}
+
+	return allErrors
+}
+
+// validateService checks one ServiceSpec at path.
+func validateService(path *field.Path, service *v1beta1.ServiceSpec) field.ErrorList {
+	allErrors := field.ErrorList{}
+	if service == nil {
+		return allErrors
+	}
+
+	loadBalancer := service.Type == string(corev1.ServiceTypeLoadBalancer)
+	external := loadBalancer || service.Type == string(corev1.ServiceTypeNodePort)
+
+	if service.ExternalTrafficPolicy != nil && !external {
+		allErrors = append(allErrors, field.Forbidden(path.Child("externalTrafficPolicy"),
+			"may only be set when type is NodePort or LoadBalancer"))
+	}
+
+	families := sets.NewString()
+	for i, family := range service.IPFamilies {
+		if family != corev1.IPv4Protocol && family != corev1.IPv6Protocol {
+			allErrors = append(allErrors, field.NotSupported(
+				path.Child("ipFamilies").Index(i), family,
+				[]string{string(corev1.IPv4Protocol), string(corev1.IPv6Protocol)}))
+		} else if families.Has(string(family)) {
+			allErrors = append(allErrors, field.Duplicate(
+				path.Child("ipFamilies").Index(i), family))
+		}
+		families.Insert(string(family))
+	}
+	if service.IPFamilyPolicy != nil &&
+		*service.IPFamilyPolicy == corev1.IPFamilyPolicySingleStack &&
+		len(service.IPFamilies) > 1 {
+		allErrors = append(allErrors, field.Invalid(path.Child("ipFamilies"),
+			service.IPFamilies, "must have at most one item when ipFamilyPolicy is SingleStack"))
+	}
+
+	if service.LoadBalancerClass != nil && !loadBalancer {
+		allErrors = append(allErrors, field.Forbidden(path.Child("loadBalancerClass"),
+			"may only be set when type is LoadBalancer"))
+	}
+	if len(service.LoadBalancerSourceRanges) > 0 && !loadBalancer {
+		allErrors = append(allErrors, field.Forbidden(path.Child("loadBalancerSourceRanges"),
+			"may only be set when type is LoadBalancer"))
+	}
+	for i, cidr := range service.LoadBalancerSourceRanges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			allErrors = append(allErrors, field.Invalid(
+				path.Child("loadBalancerSourceRanges").Index(i), cidr,
+				"must be an address range in CIDR notation"))
+		}
+	}
+
+	if service.SessionAffinityTimeoutSeconds != nil && (service.SessionAffinity == nil ||
+		*service.SessionAffinity != corev1.ServiceAffinityClientIP) {
+		allErrors = append(allErrors, field.Forbidden(path.Child("sessionAffinityTimeoutSeconds"),
+			"may only be set when sessionAffinity is ClientIP"))
+	}
+
+	// An internal load balancer is still a load balancer.
+	for _, key := range internalLoadBalancerAnnotations {
+		if _, ok := service.Metadata.GetAnnotationsOrNil()[key]; ok && !loadBalancer {
+			allErrors = append(allErrors, field.Forbidden(
+				path.Child("metadata", "annotations").Key(key),
+				"may only be set when type is LoadBalancer"))
+		}
+	}
+
+	return allErrors
+}
+
+// validateUsers checks the password rotation of each user in cluster.
+func validateUsers(cluster *v1beta1.PostgresCluster) field.ErrorList {
+	allErrors := field.ErrorList{}
+
+	for i := range cluster.Spec.Users {
+		user := cluster.Spec.Users[i]
+		if user.Password == nil || user.Password.Rotation == nil {
+			continue
+		}
+
+		// A password from another Secret is rotated by whatever manages it.
+		if user.Password.SecretKeyRef != nil {
+			allErrors = append(allErrors, field.Forbidden(
+				field.NewPath("spec", "users").Index(i).Child("password", "secretKeyRef"),
+				"cannot be used with rotation"))
+		}
+
+		rotation := user.Password.Rotation
+		if rotation.GracePeriodSeconds == nil {
+			continue
+		}
+
+		path := field.NewPath("spec", "users").Index(i).Child("password", "rotation")
+
+		if *rotation.GracePeriodSeconds >= rotation.IntervalSeconds {
+			allErrors = append(allErrors, field.Invalid(
+				path.Child("gracePeriodSeconds"), *rotation.GracePeriodSeconds,
+				"must be less than intervalSeconds"))
+		}
+
+		// The alternate user must also be a valid PostgreSQL identifier.
+		name := string(user.Name)
+		if user.Name == "postgres" {
+			allErrors = append(allErrors, field.Forbidden(
+				path.Child("gracePeriodSeconds"),
+				`the "postgres" user cannot have an alternate`))
+		} else if n := len(alternatePostgresUserName(name)); n > 63 {
+			allErrors = append(allErrors, field.Invalid(
+				path.Child("gracePeriodSeconds"), *rotation.GracePeriodSeconds,
+				fmt.Sprintf("user name is too long for an alternate: %q is %d characters",
+					alternatePostgresUserName(name), n)))
+		}
+	}
+
+	return allErrors
+}
@@ -0,0 +1,581 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgrescluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/initialize"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestWebhookDefault(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("WrongType", func(t *testing.T) {
+		err := webhook{}.Default(ctx, &corev1.Pod{})
+		assert.Assert(t, apierrors.IsBadRequest(err), "got %#v", err)
+	})
+
+	t.Run("Spec", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+			v1beta1.PostgresInstanceSetSpec{})
+
+		assert.NilError(t, webhook{}.Default(ctx, cluster))
+		assert.Equal(t, cluster.Kind, "PostgresCluster")
+		assert.Equal(t, cluster.Spec.InstanceSets[1].Name, "01")
+		assert.Equal(t, *cluster.Spec.InstanceSets[1].Replicas, int32(1))
+		assert.Equal(t, *cluster.Spec.Port, int32(5432))
+		assert.Equal(t, *cluster.Spec.Patroni.Port, int32(8008))
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Name = "some-name"
+
+		assert.NilError(t, webhook{}.Default(ctx, cluster))
+		assert.Assert(t, marshalMatches(cluster.Spec.Users, `
+- databases:
+  - some-name
+  name: some-name
+		`))
+
+		t.Run("Empty", func(t *testing.T) {
+			cluster := testCluster()
+			cluster.Spec.Users = []v1beta1.PostgresUserSpec{}
+
+			assert.NilError(t, webhook{}.Default(ctx, cluster))
+			assert.Assert(t, cluster.Spec.Users != nil)
+			assert.Equal(t, len(cluster.Spec.Users), 0)
+		})
+
+		t.Run("InvalidName", func(t *testing.T) {
+			cluster := testCluster()
+			cluster.Name = "some.name"
+
+			assert.NilError(t, webhook{}.Default(ctx, cluster))
+			assert.Assert(t, cluster.Spec.Users == nil)
+		})
+	})
+}
+
+func TestWebhookValidate(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("RELATED_IMAGE_POSTGRES_13", "")
+	t.Setenv("RELATED_IMAGE_POSTGRES_14", "some-image")
+
+	t.Run("WrongType", func(t *testing.T) {
+		err := webhook{}.ValidateCreate(ctx, &corev1.Pod{})
+		assert.Assert(t, apierrors.IsBadRequest(err), "got %#v", err)
+
+		err = webhook{}.ValidateUpdate(ctx, &corev1.Pod{}, &corev1.Pod{})
+		assert.Assert(t, apierrors.IsBadRequest(err), "got %#v", err)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		cluster := testCluster()
+
+		assert.NilError(t, webhook{}.ValidateCreate(ctx, cluster))
+		assert.NilError(t, webhook{}.ValidateUpdate(ctx, cluster, cluster))
+		assert.NilError(t, webhook{}.ValidateDelete(ctx, cluster))
+
+		cluster.Spec.Backups.PGBackRest.Repos[0].BackupSchedules =
+			&v1beta1.PGBackRestBackupSchedules{
+				Full:         initialize.String("@weekly"),
+				Differential: initialize.String("@daily"),
+				Incremental:  initialize.String("@hourly"),
+			}
+		cluster.Spec.Backups.PGBackRest.Repos[0].Retention = &v1beta1.PGBackRestRetention{
+			Full: initialize.Int32(14), FullType: "time",
+			Differential: initialize.Int32(2),
+			Archive:      initialize.Int32(24), ArchiveType: "incr",
+		}
+		assert.NilError(t, webhook{}.ValidateCreate(ctx, cluster), "expected retention to be valid")
+	})
+
+	for _, tt := range []struct {
+		name   string
+		mutate func(*v1beta1.PostgresCluster)
+		errors []string
+	}{
+		{
+			name: "DuplicateInstanceSet",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{
+					{Name: "01"}, {},
+				}
+			},
+			errors: []string{`spec.instances[1].name: Duplicate value: "01"`},
+		},
+		{
+			name: "LongInstanceSet",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.InstanceSets[0].Name = strings.Repeat("x", 42)
+			},
+			errors: []string{`spec.instances[0].name: Invalid value: "xxxx`, `got 47`},
+		},
+		{
+			name: "NoImage",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Image = ""
+			},
+			errors: []string{
+				`spec.postgresVersion: Invalid value: 13: no image specified`,
+				`no RELATED_IMAGE_POSTGRES_13 environment variable`,
+			},
+		},
+		{
+			name: "NoPostGISImage",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Image = ""
+				cluster.Spec.PostgresVersion = 14
+				cluster.Spec.PostGISVersion = "3.3"
+			},
+			errors: []string{
+				`spec.postGISVersion: Invalid value: "3.3": no image specified`,
+				`no RELATED_IMAGE_POSTGRES_14_GIS_3.3 environment variable`,
+			},
+		},
+		{
+			name: "ManualRepo",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Backups.PGBackRest.Manual = &v1beta1.PGBackRestManualBackup{
+					RepoName: "repo2",
+				}
+			},
+			errors: []string{
+				`spec.backups.pgbackrest.manual.repoName: Unsupported value: "repo2": supported values: "repo1"`,
+			},
+		},
+		{
+			name: "Retention",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Backups.PGBackRest.Global = map[string]string{
+					"repo1-retention-full": "2",
+					"repo2-retention-full": "2",
+				}
+				cluster.Spec.Backups.PGBackRest.Repos[0].BackupSchedules =
+					&v1beta1.PGBackRestBackupSchedules{Incremental: initialize.String("@hourly")}
+				cluster.Spec.Backups.PGBackRest.Repos[0].Retention = &v1beta1.PGBackRestRetention{
+					FullType:     "time",
+					Differential: initialize.Int32(2),
+					ArchiveType:  "diff",
+				}
+			},
+			errors: []string{
+				`spec.backups.pgbackrest.repos[0].retention.full: Required value`,
+				`spec.backups.pgbackrest.repos[0].retention.differential: Invalid value: 2: the repository has no differential backup schedule`,
+				`spec.backups.pgbackrest.repos[0].retention.archiveType: Invalid value: "diff"`,
+				`spec.backups.pgbackrest.global[repo1-retention-full]: Forbidden: this option conflicts with spec.backups.pgbackrest.repos[0].retention`,
+			},
+		},
+		{
+			name: "RestoreRepo",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Backups.PGBackRest.Restore = &v1beta1.PGBackRestRestore{
+					Enabled: initialize.Bool(true),
+					PostgresClusterDataSource: &v1beta1.PostgresClusterDataSource{
+						RepoName: "repo3",
+					},
+				}
+			},
+			errors: []string{
+				`spec.backups.pgbackrest.restore.repoName: Unsupported value: "repo3"`,
+			},
+		},
+		{
+			name: "StandbyRepo",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{
+					Enabled: true, RepoName: "repo4",
+				}
+			},
+			errors: []string{
+				`spec.standby.repoName: Unsupported value: "repo4"`,
+			},
+		},
+		{
+			name: "StandbyNothing",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Standby = &v1beta1.PostgresStandbySpec{Enabled: true}
+			},
+			errors: []string{
+				`spec.standby: Required value: a standby requires a host or repoName`,
+			},
+		},
+		{
+			name: "DataSources",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.DataSource = &v1beta1.DataSource{
+					PGBackRest:      &v1beta1.PGBackRestDataSource{},
+					PostgresCluster: &v1beta1.PostgresClusterDataSource{},
+				}
+			},
+			errors: []string{`spec.dataSource.pgbackrest: Forbidden`},
+		},
+		{
+			name: "PatroniSyncPeriod",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+					LeaderLeaseDurationSeconds: initialize.Int32(10),
+					SyncPeriodSeconds:          initialize.Int32(10),
+				}
+			},
+			errors: []string{`spec.patroni.syncPeriodSeconds: Invalid value: 10`},
+		},
+		{
+			name: "FailoverTarget",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+					Switchover: &v1beta1.PatroniSwitchover{
+						Enabled: true, Type: v1beta1.PatroniSwitchoverTypeFailover,
+					},
+				}
+			},
+			errors: []string{`spec.patroni.switchover.targetInstance: Required value`},
+		},
+		{
+			name: "SynchronousCandidates",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+					SynchronousReplication: &v1beta1.PatroniSynchronousReplication{
+						Candidates: []string{"instance1", "missing"},
+					},
+				}
+			},
+			errors: []string{
+				`spec.patroni.synchronousReplication.candidates[1]: Not found: "missing"`,
+			},
+		},
+		{
+			name: "ReplicateFrom",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+					v1beta1.PostgresInstanceSetSpec{
+						Name:        "cascade",
+						PatroniTags: &v1beta1.PatroniTags{ReplicateFrom: "instance1"},
+					},
+					v1beta1.PostgresInstanceSetSpec{
+						Name:        "elsewhere",
+						PatroniTags: &v1beta1.PatroniTags{ReplicateFrom: "missing"},
+					})
+			},
+			errors: []string{
+				`spec.instances[2].patroniTags.replicateFrom: Not found: "missing"`,
+			},
+		},
+		{
+			name: "ReplicateFromLoop",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.InstanceSets[0].PatroniTags = &v1beta1.PatroniTags{ReplicateFrom: "instance1"}
+				cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+					v1beta1.PostgresInstanceSetSpec{
+						Name:        "one",
+						PatroniTags: &v1beta1.PatroniTags{ReplicateFrom: "two"},
+					},
+					v1beta1.PostgresInstanceSetSpec{
+						Name:        "two",
+						PatroniTags: &v1beta1.PatroniTags{ReplicateFrom: "one"},
+					})
+			},
+			errors: []string{
+				`spec.instances[0].patroniTags.replicateFrom: Invalid value: "instance1": instance sets cannot replicate from one another in a loop`,
+				`spec.instances[1].patroniTags.replicateFrom: Invalid value: "two": instance sets cannot replicate from one another in a loop`,
+				`spec.instances[2].patroniTags.replicateFrom: Invalid value: "one": instance sets cannot replicate from one another in a loop`,
+			},
+		},
+		{
+			name: "RotationGracePeriod",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Users = []v1beta1.PostgresUserSpec{{
+					Name: "app",
+					Password: &v1beta1.PostgresPasswordSpec{
+						Rotation: &v1beta1.PostgresPasswordRotationSpec{
+							IntervalSeconds:    3600,
+							GracePeriodSeconds: initialize.Int32(3600),
+						},
+					},
+				}}
+			},
+			errors: []string{
+				`spec.users[0].password.rotation.gracePeriodSeconds: Invalid value: 3600: must be less than intervalSeconds`,
+			},
+		},
+		{
+			name: "RotationAlternatePostgres",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Users = []v1beta1.PostgresUserSpec{{
+					Name: "postgres",
+					Password: &v1beta1.PostgresPasswordSpec{
+						Rotation: &v1beta1.PostgresPasswordRotationSpec{
+							IntervalSeconds:    7200,
+							GracePeriodSeconds: initialize.Int32(60),
+						},
+					},
+				}}
+			},
+			errors: []string{`spec.users[0].password.rotation.gracePeriodSeconds: Forbidden`},
+		},
+		{
+			name: "RotationAlternateLongName",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Users = []v1beta1.PostgresUserSpec{{
+					Name: v1beta1.PostgresIdentifier(strings.Repeat("x", 60)),
+					Password: &v1beta1.PostgresPasswordSpec{
+						Rotation: &v1beta1.PostgresPasswordRotationSpec{
+							IntervalSeconds:    7200,
+							GracePeriodSeconds: initialize.Int32(60),
+						},
+					},
+				}}
+			},
+			errors: []string{`is 70 characters`},
+		},
+		{
+			name: "RotationSecretKeyRef",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Users = []v1beta1.PostgresUserSpec{{
+					Name: "app",
+					Password: &v1beta1.PostgresPasswordSpec{
+						Rotation: &v1beta1.PostgresPasswordRotationSpec{
+							IntervalSeconds: 7200,
+						},
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "vault"},
+							Key:                  "app-password",
+						},
+					},
+				}}
+			},
+			errors: []string{`spec.users[0].password.secretKeyRef: Forbidden`},
+		},
+		{
+			name: "AuthenticationMethodConnection",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+					Rules: []v1beta1.PostgresHBARuleSpec{
+						{Method: "md5"},
+						{Connection: "host", Method: "cert"},
+						{Connection: "hostssl", Method: "peer"},
+						{Connection: "local", Method: "gss"},
+					},
+				}
+			},
+			errors: []string{
+				`spec.authentication.rules[1].method: Invalid value: "cert": requires a "hostssl" connection`,
+				`spec.authentication.rules[2].method: Invalid value: "peer": requires a "local" connection`,
+				`spec.authentication.rules[3].method: Invalid value: "gss"`,
+			},
+		},
+		{
+			name: "AuthenticationNetwork",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+					Rules: []v1beta1.PostgresHBARuleSpec{
+						{Network: "10.0.0.0/8", Method: "md5"},
+						{Network: "samenet", Method: "md5"},
+						{Network: "10.0.0.1", Method: "md5"},
+						{Connection: "local", Network: "samenet", Method: "trust"},
+					},
+				}
+			},
+			errors: []string{
+				`spec.authentication.rules[2].network: Invalid value: "10.0.0.1"`,
+				`spec.authentication.rules[3].network: Forbidden`,
+			},
+		},
+		{
+			name: "AuthenticationOptions",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+					Rules: []v1beta1.PostgresHBARuleSpec{
+						{Method: "ldap", Options: map[string]string{
+							"ldapserver": "ldap.example.com", "clientcert": "verify-full",
+						}},
+						{Connection: "host", Method: "ldap", Options: map[string]string{
+							"clientcert": "verify-ca",
+						}},
+						{Method: "md5", Options: map[string]string{"map": "x"}},
+					},
+				}
+			},
+			errors: []string{
+				`spec.authentication.rules[1].options[clientcert]: Unsupported value: "clientcert"`,
+				`spec.authentication.rules[2].options[map]: Unsupported value: "map"`,
+			},
+		},
+		{
+			name: "ParameterValues",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Config.Parameters = map[string]v1beta1.PostgresParameterValue{
+					"log_min_duration_statement": "5s",
+					"max_connections":            "0",
+					"shared_buffers":             "1GB",
+					"work_mem":                   "4 mb",
+					"pgaudit.log":                "all",
+					"not_in_the_catalog":         "1",
+				}
+			},
+			errors: []string{
+				`spec.config.parameters[max_connections]: Invalid value: "0": 0 is outside the valid range`,
+				`spec.config.parameters[not_in_the_catalog]: Invalid value: "not_in_the_catalog": unrecognized configuration parameter`,
+				`spec.config.parameters[work_mem]: Invalid value: "4 mb"`,
+			},
+		},
+		{
+			name: "ParameterManaged",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Config.Parameters = map[string]v1beta1.PostgresParameterValue{
+					"archive_command": "true",
+					"block_size":      "16384",
+					"wal_level":       "replica",
+
+					"shared_preload_libraries": "pg_stat_statements",
+				}
+			},
+			errors: []string{
+				`spec.config.parameters[archive_command]: Forbidden: this parameter is managed by the operator`,
+				`spec.config.parameters[block_size]: Forbidden: parameter cannot be changed`,
+				`spec.config.parameters[wal_level]: Forbidden: this parameter is managed by the operator`,
+			},
+		},
+		{
+			name: "InstanceSetParameters",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.InstanceSets[0].Config = &v1beta1.PostgresInstanceConfig{
+					Parameters: map[string]v1beta1.PostgresParameterValue{
+						"archive_command": "true",
+						"max_connections": "500",
+						"shared_buffers":  "8GB",
+						"work_mem":        "4 mb",
+
+						"shared_preload_libraries": "pg_stat_statements",
+					},
+				}
+			},
+			errors: []string{
+				`spec.instances[0].config.parameters[archive_command]: Forbidden: this parameter is managed by the operator`,
+				`spec.instances[0].config.parameters[max_connections]: Forbidden: this parameter must be the same on every instance`,
+				`spec.instances[0].config.parameters[shared_preload_libraries]: Forbidden: this parameter must be the same on every instance`,
+				`spec.instances[0].config.parameters[work_mem]: Invalid value: "4 mb"`,
+			},
+		},
+		{
+			name: "ServiceLoadBalancerFields",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				local := corev1.ServiceExternalTrafficPolicyTypeLocal
+				cluster.Spec.Service = &v1beta1.ServiceSpec{
+					Type:                     "ClusterIP",
+					ExternalTrafficPolicy:    &local,
+					LoadBalancerClass:        initialize.String("example.com/lb"),
+					LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+					Metadata: &v1beta1.Metadata{Annotations: map[string]string{
+						"service.beta.kubernetes.io/aws-load-balancer-internal": "true",
+					}},
+				}
+				cluster.Spec.UserInterface = &v1beta1.UserInterfaceSpec{
+					PGAdmin: &v1beta1.PGAdminPodSpec{Service: &v1beta1.ServiceSpec{
+						Type:                     "LoadBalancer",
+						LoadBalancerSourceRanges: []string{"10.0.0.0/8", "192.168.1.1"},
+					}},
+				}
+			},
+			errors: []string{
+				`spec.service.externalTrafficPolicy: Forbidden: may only be set when type is NodePort or LoadBalancer`,
+				`spec.service.loadBalancerClass: Forbidden: may only be set when type is LoadBalancer`,
+				`spec.service.loadBalancerSourceRanges: Forbidden: may only be set when type is LoadBalancer`,
+				`spec.service.metadata.annotations[service.beta.kubernetes.io/aws-load-balancer-internal]: Forbidden`,
+				`spec.userInterface.pgAdmin.service.loadBalancerSourceRanges[1]: Invalid value: "192.168.1.1"`,
+			},
+		},
+		{
+			name: "ServiceIPFamilies",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				single := corev1.IPFamilyPolicySingleStack
+				cluster.Spec.Service = &v1beta1.ServiceSpec{
+					Type:           "ClusterIP",
+					IPFamilies:     []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv4Protocol},
+					IPFamilyPolicy: &single,
+				}
+				cluster.Spec.InstanceSets[0].ReplicaService = &v1beta1.ServiceSpec{
+					Type:       "ClusterIP",
+					IPFamilies: []corev1.IPFamily{"IPv5"},
+				}
+			},
+			errors: []string{
+				`spec.service.ipFamilies[1]: Duplicate value: "IPv4"`,
+				`spec.service.ipFamilies: Invalid value`,
+				`spec.instances[0].replicaService.ipFamilies[0]: Unsupported value: "IPv5"`,
+			},
+		},
+		{
+			name: "ServiceSessionAffinity",
+			mutate: func(cluster *v1beta1.PostgresCluster) {
+				cluster.Spec.Proxy.PGBouncer.Service = &v1beta1.ServiceSpec{
+					Type:                          "ClusterIP",
+					SessionAffinityTimeoutSeconds: initialize.Int32(60),
+				}
+			},
+			errors: []string{
+				`spec.proxy.pgBouncer.service.sessionAffinityTimeoutSeconds: Forbidden: may only be set when sessionAffinity is ClientIP`,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := testCluster()
+			tt.mutate(cluster)
+
+			err := webhook{}.ValidateCreate(ctx, cluster)
+			assert.Assert(t, apierrors.IsInvalid(err), "got %#v", err)
+			for _, expected := range tt.errors {
+				assert.ErrorContains(t, err, expected)
+			}
+
+			assert.ErrorContains(t, webhook{}.ValidateUpdate(ctx, testCluster(), cluster), tt.errors[0])
+
+			// Problems that were already present are allowed, so that finalizers,
+			// metadata, and other fields can change.
+			before := cluster.DeepCopy()
+			cluster.Finalizers = append(cluster.Finalizers, "some.example.com/finalizer")
+			assert.NilError(t, webhook{}.ValidateUpdate(ctx, before, cluster))
+
+			// Deletion is allowed to proceed.
+			cluster.DeletionTimestamp = &metav1.Time{}
+			assert.NilError(t, webhook{}.ValidateUpdate(ctx, cluster, cluster))
+			assert.NilError(t, webhook{}.ValidateDelete(ctx, cluster))
+		})
+	}
+
+	t.Run("RestoreOtherCluster", func(t *testing.T) {
+		cluster := testCluster()
+		cluster.Spec.Backups.PGBackRest.Restore = &v1beta1.PGBackRestRestore{
+			Enabled: initialize.Bool(true),
+			PostgresClusterDataSource: &v1beta1.PostgresClusterDataSource{
+				ClusterName: "rhino", RepoName: "repo3",
+			},
+		}
+
+		assert.NilError(t, webhook{}.ValidateCreate(ctx, cluster))
+	})
+}
@@ -0,0 +1,45 @@
+package kubeapi
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// IntroducedErrors returns the errors in current that are not in previous.
+// Errors are the same when they are of the same type about the same value of
+// the same field. Validating webhooks use this to allow updates to objects
+// that were already invalid.
+func IntroducedErrors(previous, current field.ErrorList) field.ErrorList {
+	key := func(err *field.Error) string {
+		return fmt.Sprintf("%s\x00%s\x00%v", err.Type, err.Field, err.BadValue)
+	}
+
+	existing := make(map[string]bool, len(previous))
+	for _, err := range previous {
+		existing[key(err)] = true
+	}
+
+	introduced := field.ErrorList{}
+	for _, err := range current {
+		if !existing[key(err)] {
+			introduced = append(introduced, err)
+		}
+	}
+	return introduced
+}
@@ -0,0 +1,42 @@
+package kubeapi
+
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestIntroducedErrors(t *testing.T) {
+	spec := field.NewPath("spec")
+
+	previous := field.ErrorList{
+		field.Invalid(spec.Child("a"), 1, "first"),
+		field.Required(spec.Child("b"), "second"),
+	}
+	current := field.ErrorList{
+		field.Invalid(spec.Child("a"), 1, "message may change"),
+		field.Invalid(spec.Child("a"), 2, "different value"),
+		field.Invalid(spec.Child("b"), nil, "different type"),
+		field.Required(spec.Child("c"), "different field"),
+	}
+
+	assert.DeepEqual(t, IntroducedErrors(previous, current), current[1:])
+	assert.DeepEqual(t, IntroducedErrors(nil, current), current)
+	assert.DeepEqual(t, IntroducedErrors(previous, nil), field.ErrorList{})
+}
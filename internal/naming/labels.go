@@ -33,6 +33,12 @@ const (
 	LabelPatroni = labelPrefix + "patroni"
 	LabelRole    = labelPrefix + "role"
 
+	// LabelLoadBalance is applied to instance Pods that receive traffic from
+	// the replica Service of their cluster. It is present only when some
+	// instance set of the cluster is excluded from load balancing. PGO labels
+	// the Pods directly so that their StatefulSet templates do not change.
+	LabelLoadBalance = labelPrefix + "load-balance"
+
 	// LabelClusterCertificate is used to identify a secret containing a cluster certificate
 	LabelClusterCertificate = labelPrefix + "cluster-certificate"
 
@@ -143,6 +149,9 @@ const (
 	// BackupReplicaCreate is the backup type for the backup taken to enable pgBackRest replica
 	// creation
 	BackupReplicaCreate BackupJobType = "replica-create"
+
+	// BackupPGBackRestBackup is the backup type for backups requested by a PGBackRestBackup
+	BackupPGBackRestBackup BackupJobType = "pgbackrestbackup"
 )
 
 // Merge takes sets of labels and merges them. The last set
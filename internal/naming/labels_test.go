@@ -61,6 +61,7 @@ func TestLabelValuesValid(t *testing.T) {
 	assert.Assert(t, nil == validation.IsValidLabelValue(RolePrimary))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RoleReplica))
 	assert.Assert(t, nil == validation.IsValidLabelValue(string(BackupReplicaCreate)))
+	assert.Assert(t, nil == validation.IsValidLabelValue(string(BackupPGBackRestBackup)))
 	assert.Assert(t, nil == validation.IsValidLabelValue(RoleMonitoring))
 }
 
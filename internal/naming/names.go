@@ -316,6 +316,16 @@ func InstanceSet(cluster *v1beta1.PostgresCluster,
 	}
 }
 
+// InstanceSetReplicaService returns the ObjectMeta necessary to lookup the
+// Service that exposes the PostgreSQL replica instances of a single set.
+func InstanceSetReplicaService(cluster *v1beta1.PostgresCluster,
+	set *v1beta1.PostgresInstanceSetSpec) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      cluster.Name + "-set-" + set.Name + "-replicas",
+		Namespace: cluster.Namespace,
+	}
+}
+
 // InstancePostgresDataVolume returns the ObjectMeta for the PostgreSQL data
 // volume for instance.
 func InstancePostgresDataVolume(instance *appsv1.StatefulSet) metav1.ObjectMeta {
@@ -433,6 +443,23 @@ func PGBackRestBackupJob(cluster *v1beta1.PostgresCluster) metav1.ObjectMeta {
 	}
 }
 
+// PGBackRestBackupRequestJob returns the ObjectMeta for the pgBackRest backup
+// Job that takes the backup requested by backup. The name is based on an eight
+// character hash of the UID of backup, so it is the same every time.
+func PGBackRestBackupRequestJob(
+	cluster *v1beta1.PostgresCluster, backup *v1beta1.PGBackRestBackup,
+) metav1.ObjectMeta {
+	// hash.Hash.Write never returns an error: https://pkg.go.dev/hash#Hash.
+	hash := fnv.New32()
+	_, _ = hash.Write([]byte(backup.GetUID()))
+	suffix := rand.SafeEncodeString(fmt.Sprint(hash.Sum32()))[:8]
+
+	return metav1.ObjectMeta{
+		Namespace: cluster.GetNamespace(),
+		Name:      cluster.GetName() + "-backup-" + suffix,
+	}
+}
+
 // PGBackRestCronJob returns the ObjectMeta for a pgBackRest CronJob
 func PGBackRestCronJob(cluster *v1beta1.PostgresCluster, backuptype, repoName string) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
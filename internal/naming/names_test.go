@@ -70,6 +70,11 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 	instanceSet := &v1beta1.PostgresInstanceSetSpec{
 		Name: "set-1",
 	}
+	backup := &v1beta1.PGBackRestBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1", Name: "b1", UID: "3b5b34ea-8fa8-4a43-8e8e-b0e5a3f6b1c4",
+		},
+	}
 
 	type test struct {
 		name  string
@@ -121,6 +126,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 	t.Run("Jobs", func(t *testing.T) {
 		testUniqueAndValid(t, []test{
 			{"PGBackRestBackupJob", PGBackRestBackupJob(cluster)},
+			{"PGBackRestBackupRequestJob", PGBackRestBackupRequestJob(cluster, backup)},
 			{"PGBackRestRestoreJob", PGBackRestRestoreJob(cluster)},
 		})
 	})
@@ -190,6 +196,7 @@ func TestClusterNamesUniqueAndValid(t *testing.T) {
 			{"ClusterPodService", ClusterPodService(cluster)},
 			{"ClusterPrimaryService", ClusterPrimaryService(cluster)},
 			{"ClusterReplicaService", ClusterReplicaService(cluster)},
+			{"InstanceSetReplicaService", InstanceSetReplicaService(cluster, instanceSet)},
 			// Patroni can use Endpoints which relate directly to a Service.
 			{"PatroniDistributedConfiguration", PatroniDistributedConfiguration(cluster)},
 			{"PatroniLeaderEndpoints", PatroniLeaderEndpoints(cluster)},
@@ -33,8 +33,27 @@ type API interface {
 	// paused, next cannot be blank.
 	ChangePrimaryAndWait(ctx context.Context, current, next string) (bool, error)
 
+	// SwitchoverAndWait tries to change the current Patroni leader to target.
+	// It returns true when an election completes successfully.
+	SwitchoverAndWait(ctx context.Context, target string) (bool, error)
+
+	// FailoverAndWait tries to change the current Patroni leader to target.
+	// It returns true when an election completes successfully.
+	FailoverAndWait(ctx context.Context, target string) (bool, error)
+
 	// ReplaceConfiguration replaces Patroni's entire dynamic configuration.
 	ReplaceConfiguration(ctx context.Context, configuration map[string]interface{}) error
+
+	// RestartPendingMembers restarts the members with role in scope that have
+	// a pending restart.
+	RestartPendingMembers(ctx context.Context, role, scope string) error
+
+	// GetTimeline returns the timeline of the running leader, or zero when
+	// there is none.
+	GetTimeline(ctx context.Context) (int64, error)
+
+	// GetCluster returns the status of every member in the Patroni cluster.
+	GetCluster(ctx context.Context) (*ClusterStatus, error)
 }
 
 // Executor implements API by calling "patronictl".
@@ -217,3 +236,56 @@ func (exec Executor) GetTimeline(ctx context.Context) (int64, error) {
 
 	return 0, err
 }
+
+// GetCluster gets the status of every member by calling "patronictl". It
+// reports replication lag in megabytes, so the lag here is a multiple of 1MiB.
+// Similar to the "GET /cluster" REST endpoint.
+func (exec Executor) GetCluster(ctx context.Context) (*ClusterStatus, error) {
+	var stdout, stderr bytes.Buffer
+
+	// The following exits zero when it is able to read the DCS. It prints one
+	// JSON object per member with the same keys as its table format.
+	// - https://github.com/zalando/patroni/blob/v2.1.1/patroni/ctl.py#L849
+	err := exec(ctx, nil, &stdout, &stderr,
+		"patronictl", "list", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	if stderr.String() != "" {
+		return nil, errors.New(stderr.String())
+	}
+
+	var members []struct {
+		Member         string
+		Role           string
+		State          string
+		Timeline       int64       `json:"TL"`
+		Lag            interface{} `json:"Lag in MB"`
+		PendingRestart string      `json:"Pending restart"`
+	}
+	err = json.Unmarshal(stdout.Bytes(), &members)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ClusterStatus{Members: make([]ClusterMember, 0, len(members))}
+	for _, m := range members {
+		// The table shows roles with spaces and capital letters, e.g.
+		// "Sync Standby" rather than "sync_standby".
+		member := ClusterMember{
+			Name:           m.Member,
+			Role:           strings.ReplaceAll(strings.ToLower(m.Role), " ", "_"),
+			State:          m.State,
+			Timeline:       m.Timeline,
+			PendingRestart: m.PendingRestart == "*",
+		}
+		if megabytes, ok := m.Lag.(float64); ok {
+			lag := int64(megabytes * (1 << 20))
+			member.Lag = &lag
+		}
+		status.Members = append(status.Members, member)
+	}
+
+	return status, nil
+}
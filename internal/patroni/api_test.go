@@ -298,3 +298,65 @@ func TestExecutorGetTimeline(t *testing.T) {
 		assert.Equal(t, tl, int64(4))
 	})
 }
+
+func TestExecutorGetCluster(t *testing.T) {
+	t.Run("Arguments", func(t *testing.T) {
+		called := false
+		_, _ = Executor(func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			called = true
+			assert.DeepEqual(t, command, strings.Fields(`patronictl list --format json`))
+			assert.Assert(t, stdin == nil, "expected no stdin, got %T", stdin)
+			return nil
+		}).GetCluster(context.Background())
+
+		assert.Assert(t, called)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		expected := errors.New("bang")
+		_, actual := Executor(func(
+			context.Context, io.Reader, io.Writer, io.Writer, ...string,
+		) error {
+			return expected
+		}).GetCluster(context.Background())
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("Stderr", func(t *testing.T) {
+		_, actual := Executor(func(
+			_ context.Context, _ io.Reader, _, stderr io.Writer, _ ...string,
+		) error {
+			_, _ = stderr.Write([]byte(`no luck`))
+			return nil
+		}).GetCluster(context.Background())
+
+		assert.Error(t, actual, "no luck")
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		status, err := Executor(func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, _ = stdout.Write([]byte(`[
+				{"Cluster": "hippo-ha", "Member": "hippo-instance1-67mc-0", "Host": "hippo-instance1-67mc-0.hippo-pods", "Role": "Leader", "State": "running", "TL": 4, "Lag in MB": ""},
+				{"Cluster": "hippo-ha", "Member": "hippo-instance1-ltcf-0", "Host": "hippo-instance1-ltcf-0.hippo-pods", "Role": "Sync Standby", "State": "streaming", "TL": 4, "Lag in MB": 2, "Pending restart": "*"}
+			]`))
+			return nil
+		}).GetCluster(context.Background())
+		assert.NilError(t, err)
+
+		lag := int64(2 << 20)
+		assert.DeepEqual(t, status, &ClusterStatus{
+			Members: []ClusterMember{
+				{Name: "hippo-instance1-67mc-0", Role: "leader", State: "running", Timeline: 4},
+				{
+					Name: "hippo-instance1-ltcf-0", Role: "sync_standby", State: "streaming", Timeline: 4,
+					Lag: &lag, PendingRestart: true,
+				},
+			},
+		})
+	})
+}
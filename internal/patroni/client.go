@@ -0,0 +1,394 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package patroni
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+)
+
+// Client implements API by calling the Patroni REST API of one member.
+// - https://patroni.readthedocs.io/en/latest/rest_api.html
+type Client struct {
+	// BaseURL is the scheme, host, and port of the member's REST API,
+	// e.g. "https://hippo-instance1-abcd-0.hippo-pods.ns.svc:8008".
+	BaseURL string
+
+	// HTTP sends every request. When nil, [http.DefaultClient] is used.
+	HTTP *http.Client
+}
+
+// Client implements API.
+var _ API = (*Client)(nil)
+
+// NewClient returns a Client for the Patroni member listening at host and port.
+// It authenticates with the certificates in instanceCertificates, the same
+// ones Patroni uses to serve its REST API. See [InstanceCertificates].
+func NewClient(host string, port int32, instanceCertificates *corev1.Secret) (*Client, error) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(instanceCertificates.Data[certAuthorityFileKey]) {
+		return nil, errors.New("patroni: missing certificate authority")
+	}
+
+	// The combined file holds both the private key and the certificate.
+	// Each argument below is searched for the kind of block it expects.
+	combined := instanceCertificates.Data[certServerFileKey]
+	certificate, err := tls.X509KeyPair(combined, combined)
+	if err != nil {
+		return nil, fmt.Errorf("patroni: %w", err)
+	}
+
+	return &Client{
+		BaseURL: "https://" + net.JoinHostPort(host, strconv.Itoa(int(port))),
+		HTTP: &http.Client{
+			// Patroni waits up to two "loop_wait" when changing the leader,
+			// so this is longer than any one response should take.
+			Timeout: 2 * time.Minute,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					Certificates: []tls.Certificate{certificate},
+					MinVersion:   tls.VersionTLS12,
+					RootCAs:      roots,
+				},
+			},
+		},
+	}, nil
+}
+
+// Error is returned when the Patroni REST API responds with an unexpected
+// HTTP status.
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+
+	// Body is the text of the response. Patroni usually explains itself here.
+	Body string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("patroni: %s %s: %d %s",
+		e.Method, e.URL, e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// ClusterMember is one member of the Patroni cluster as reported by the
+// "GET /cluster" endpoint.
+type ClusterMember struct {
+	Name           string                 `json:"name"`
+	Role           string                 `json:"role"`
+	State          string                 `json:"state"`
+	APIURL         string                 `json:"api_url"`
+	Host           string                 `json:"host"`
+	Port           int                    `json:"port"`
+	Timeline       int64                  `json:"timeline"`
+	PendingRestart bool                   `json:"pending_restart"`
+	Tags           map[string]interface{} `json:"tags,omitempty"`
+
+	// Lag is the number of bytes this member is behind the leader. It is nil
+	// for the leader and when Patroni reports "unknown".
+	Lag *int64 `json:"-"`
+}
+
+// UnmarshalJSON decodes member and its "lag", which Patroni reports as either
+// a number or some text.
+func (member *ClusterMember) UnmarshalJSON(data []byte) error {
+	type plain ClusterMember
+	fields := struct {
+		*plain
+		Lag json.RawMessage `json:"lag"`
+	}{plain: (*plain)(member)}
+
+	err := json.Unmarshal(data, &fields)
+	if err == nil {
+		var lag int64
+		if json.Unmarshal(fields.Lag, &lag) == nil && len(fields.Lag) > 0 {
+			member.Lag = &lag
+		}
+	}
+	return err
+}
+
+// IsLeader returns true when member is the leader of its cluster, including
+// the leader of a standby cluster.
+func (member ClusterMember) IsLeader() bool {
+	return member.Role == "leader" || member.Role == "standby_leader"
+}
+
+// ClusterStatus is the response of the "GET /cluster" endpoint.
+type ClusterStatus struct {
+	Members []ClusterMember `json:"members"`
+	Pause   bool            `json:"pause"`
+}
+
+// Leader returns the member that is the leader of the cluster, if any.
+func (status ClusterStatus) Leader() *ClusterMember {
+	for i := range status.Members {
+		if status.Members[i].IsLeader() {
+			return &status.Members[i]
+		}
+	}
+	return nil
+}
+
+// do sends a request with a JSON body, when body is not nil, to the member at
+// baseURL. It returns the response body when the response status is expected.
+func (c *Client) do(
+	ctx context.Context, baseURL, method, path string, body interface{}, expected ...int,
+) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	log := logging.FromContext(ctx)
+	log.V(1).Info("called Patroni",
+		"method", method, "url", request.URL.String(),
+		"status", response.StatusCode, "body", string(content),
+	)
+
+	for _, code := range expected {
+		if response.StatusCode == code {
+			return content, nil
+		}
+	}
+
+	return content, &Error{
+		Method:     method,
+		URL:        request.URL.String(),
+		StatusCode: response.StatusCode,
+		Body:       string(content),
+	}
+}
+
+// memberURL returns the base URL of member. Every member of a PostgresCluster
+// shares the same Pod subdomain, so the hostname of member is the hostname of
+// this Client with its first label replaced by the name of member.
+func (c *Client) memberURL(member ClusterMember) (string, error) {
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return "", err
+	}
+
+	api, err := url.Parse(member.APIURL)
+	if err != nil {
+		return "", err
+	}
+
+	host := member.Name
+	if _, domain, found := strings.Cut(base.Hostname(), "."); found {
+		host += "." + domain
+	}
+
+	port := api.Port()
+	if port == "" {
+		port = base.Port()
+	}
+
+	return base.Scheme + "://" + net.JoinHostPort(host, port), nil
+}
+
+// GetCluster returns the status of every member in the Patroni cluster by
+// calling the "GET /cluster" endpoint.
+func (c *Client) GetCluster(ctx context.Context) (*ClusterStatus, error) {
+	content, err := c.do(ctx, c.BaseURL, http.MethodGet, "/cluster", nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var status ClusterStatus
+	err = json.Unmarshal(content, &status)
+	return &status, err
+}
+
+// changeLeader calls the "POST /switchover" or "POST /failover" endpoint, then
+// checks that the new leader is next. When next is blank, any leader other
+// than current is a success. Patroni responds once the election completes or
+// after two "loop_wait".
+// - https://github.com/zalando/patroni/blob/v2.1.1/patroni/api.py#L461-L477
+func (c *Client) changeLeader(
+	ctx context.Context, path, current, next string,
+) (bool, error) {
+	body := map[string]string{}
+	if current != "" {
+		body["leader"] = current
+	}
+	if next != "" {
+		body["candidate"] = next
+	}
+
+	_, err := c.do(ctx, c.BaseURL, http.MethodPost, path, body, http.StatusOK)
+	if err != nil {
+		return false, err
+	}
+
+	// Patroni responds OK even when a member other than the candidate becomes
+	// the leader. Look at the cluster rather than at the text of the response.
+	status, err := c.GetCluster(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	leader := status.Leader()
+	if leader == nil {
+		return false, nil
+	}
+	if next != "" {
+		return leader.Name == next, nil
+	}
+	return leader.Name != current, nil
+}
+
+// ChangePrimaryAndWait tries to demote the current Patroni leader by calling
+// the "POST /switchover" endpoint. It returns true when an election completes
+// successfully. When Patroni is paused, next cannot be blank.
+func (c *Client) ChangePrimaryAndWait(
+	ctx context.Context, current, next string,
+) (bool, error) {
+	return c.changeLeader(ctx, "/switchover", current, next)
+}
+
+// SwitchoverAndWait tries to change the current Patroni leader to target by
+// calling the "POST /switchover" endpoint. It returns true when an election
+// completes successfully. When Patroni is paused, target cannot be blank.
+func (c *Client) SwitchoverAndWait(ctx context.Context, target string) (bool, error) {
+	// The switchover endpoint requires the name of the current leader.
+	status, err := c.GetCluster(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	leader := status.Leader()
+	if leader == nil {
+		return false, errors.New("patroni: cluster has no leader")
+	}
+
+	return c.changeLeader(ctx, "/switchover", leader.Name, target)
+}
+
+// FailoverAndWait tries to change the current Patroni leader to target by
+// calling the "POST /failover" endpoint. It returns true when an election
+// completes successfully. Unlike switchover, failover works when the cluster
+// has no healthy leader.
+func (c *Client) FailoverAndWait(ctx context.Context, target string) (bool, error) {
+	return c.changeLeader(ctx, "/failover", "", target)
+}
+
+// ReplaceConfiguration replaces Patroni's entire dynamic configuration by
+// calling the "PUT /config" endpoint.
+func (c *Client) ReplaceConfiguration(
+	ctx context.Context, configuration map[string]interface{},
+) error {
+	_, err := c.do(ctx, c.BaseURL, http.MethodPut, "/config", configuration, http.StatusOK)
+	return err
+}
+
+// RestartPendingMembers looks up Patroni members with role and restarts those
+// that have a pending restart by calling the "POST /restart" endpoint of each.
+// The role "master" matches the leader and "replica" matches every other
+// member. The scope is always the cluster of this Client's member.
+func (c *Client) RestartPendingMembers(ctx context.Context, role, _ string) error {
+	status, err := c.GetCluster(ctx)
+	if err != nil {
+		return err
+	}
+
+	leader := role == "master" || role == "leader"
+
+	for _, member := range status.Members {
+		if member.IsLeader() != leader || !member.PendingRestart {
+			continue
+		}
+
+		var baseURL string
+		baseURL, err = c.memberURL(member)
+
+		// A "503 Service Unavailable" response means the restart conditions
+		// are not satisfied, usually because the member has already restarted.
+		// - https://github.com/zalando/patroni/blob/v2.1.1/patroni/api.py#L383-L392
+		if err == nil {
+			_, err = c.do(ctx, baseURL, http.MethodPost, "/restart",
+				map[string]interface{}{"restart_pending": true},
+				http.StatusOK, http.StatusServiceUnavailable)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetTimeline returns the timeline of the running leader as reported by the
+// "GET /cluster" endpoint. It returns zero when there is no running leader.
+func (c *Client) GetTimeline(ctx context.Context) (int64, error) {
+	status, err := c.GetCluster(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, member := range status.Members {
+		if member.Role == "leader" && member.State == "running" {
+			return member.Timeline, nil
+		}
+	}
+
+	return 0, nil
+}
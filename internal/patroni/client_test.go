@@ -0,0 +1,403 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package patroni
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crunchydata/postgres-operator/internal/pki"
+)
+
+// patroniServer starts a plain HTTP server that answers with handlers by
+// method and path. It returns a Client that sends every request to it.
+func patroniServer(t *testing.T, handlers map[string]http.HandlerFunc) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := handlers[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	// Send requests for any host to the server above.
+	address := server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		},
+	}
+	t.Cleanup(transport.CloseIdleConnections)
+
+	return &Client{
+		BaseURL: "http://some-pod.some-service.some-ns.svc:8008",
+		HTTP:    &http.Client{Transport: transport},
+	}
+}
+
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	root, err := pki.NewRootCertificateAuthority()
+	assert.NilError(t, err)
+
+	leaf, err := root.GenerateLeafCertificate("localhost", []string{"localhost"})
+	assert.NilError(t, err)
+
+	certificates := new(corev1.Secret)
+	assert.NilError(t, InstanceCertificates(context.Background(),
+		root.Certificate, leaf.Certificate, leaf.PrivateKey, certificates))
+
+	t.Run("MissingAuthority", func(t *testing.T) {
+		_, err := NewClient("localhost", 8008, new(corev1.Secret))
+		assert.ErrorContains(t, err, "certificate authority")
+	})
+
+	t.Run("MissingCertificate", func(t *testing.T) {
+		secret := certificates.DeepCopy()
+		delete(secret.Data, certServerFileKey)
+
+		_, err := NewClient("localhost", 8008, secret)
+		assert.ErrorContains(t, err, "patroni:")
+	})
+
+	t.Run("MutualTLS", func(t *testing.T) {
+		// Serve and verify clients the same way Patroni does.
+		combined := certificates.Data[certServerFileKey]
+		certificate, err := tls.X509KeyPair(combined, combined)
+		assert.NilError(t, err)
+
+		authorities := x509.NewCertPool()
+		assert.Assert(t, authorities.AppendCertsFromPEM(certificates.Data[certAuthorityFileKey]))
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, r.URL.Path, "/cluster")
+				assert.Equal(t, len(r.TLS.PeerCertificates), 1, "expected a client certificate")
+				_, _ = w.Write([]byte(`{"members":[]}`))
+			}))
+		server.TLS = &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    authorities,
+		}
+		server.StartTLS()
+		t.Cleanup(server.Close)
+
+		u, err := url.Parse(server.URL)
+		assert.NilError(t, err)
+		port, err := strconv.Atoi(u.Port())
+		assert.NilError(t, err)
+
+		client, err := NewClient("localhost", int32(port), certificates)
+		assert.NilError(t, err)
+		assert.Equal(t, client.BaseURL, "https://localhost:"+u.Port())
+
+		status, err := client.GetCluster(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, len(status.Members), 0)
+	})
+}
+
+func TestClientGetCluster(t *testing.T) {
+	client := patroniServer(t, map[string]http.HandlerFunc{
+		"GET /cluster": respond(200, `{
+			"members": [
+				{"name": "one", "role": "leader", "state": "running", "timeline": 3,
+				 "api_url": "https://one.pods:8008/patroni", "host": "one.pods", "port": 5432},
+				{"name": "two", "role": "replica", "state": "streaming", "timeline": 3,
+				 "pending_restart": true, "tags": {"nofailover": true}, "lag": 1024},
+				{"name": "three", "role": "replica", "state": "starting", "lag": "unknown"}
+			],
+			"pause": true
+		}`),
+	})
+
+	status, err := client.GetCluster(context.Background())
+	assert.NilError(t, err)
+
+	lag := int64(1024)
+	assert.DeepEqual(t, status, &ClusterStatus{
+		Members: []ClusterMember{
+			{
+				Name: "one", Role: "leader", State: "running", Timeline: 3,
+				APIURL: "https://one.pods:8008/patroni", Host: "one.pods", Port: 5432,
+			},
+			{
+				Name: "two", Role: "replica", State: "streaming", Timeline: 3,
+				PendingRestart: true, Tags: map[string]interface{}{"nofailover": true},
+				Lag: &lag,
+			},
+			{Name: "three", Role: "replica", State: "starting"},
+		},
+		Pause: true,
+	})
+	assert.Equal(t, status.Leader().Name, "one")
+
+	t.Run("Error", func(t *testing.T) {
+		client := patroniServer(t, map[string]http.HandlerFunc{
+			"GET /cluster": respond(500, "nope\n"),
+		})
+
+		_, err := client.GetCluster(context.Background())
+
+		var apiError *Error
+		assert.Assert(t, errors.As(err, &apiError))
+		assert.Equal(t, apiError.StatusCode, 500)
+		assert.Equal(t, apiError.Method, "GET")
+		assert.Equal(t, apiError.Body, "nope\n")
+		assert.ErrorContains(t, err, "500 nope")
+	})
+}
+
+func TestClientChangePrimaryAndWait(t *testing.T) {
+	t.Run("Arguments", func(t *testing.T) {
+		called := false
+		client := patroniServer(t, map[string]http.HandlerFunc{
+			"POST /switchover": func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				var body map[string]string
+				assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+				assert.DeepEqual(t, body, map[string]string{"leader": "old", "candidate": "new"})
+				assert.Equal(t, r.Header.Get("Content-Type"), "application/json")
+			},
+			"GET /cluster": respond(200, `{"members":[{"name":"new","role":"leader"}]}`),
+		})
+
+		success, err := client.ChangePrimaryAndWait(context.Background(), "old", "new")
+		assert.NilError(t, err)
+		assert.Assert(t, success)
+		assert.Assert(t, called)
+	})
+
+	t.Run("AnyCandidate", func(t *testing.T) {
+		client := patroniServer(t, map[string]http.HandlerFunc{
+			"POST /switchover": func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]string
+				assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+				assert.DeepEqual(t, body, map[string]string{"leader": "old"})
+			},
+			"GET /cluster": respond(200, `{"members":[{"name":"other","role":"leader"}]}`),
+		})
+
+		success, err := client.ChangePrimaryAndWait(context.Background(), "old", "")
+		assert.NilError(t, err)
+		assert.Assert(t, success)
+	})
+
+	t.Run("OtherCandidate", func(t *testing.T) {
+		// Patroni responds OK when some other member becomes the leader.
+		client := patroniServer(t, map[string]http.HandlerFunc{
+			"POST /switchover": respond(200, `Switched over to "other" instead of "new"`),
+			"GET /cluster":     respond(200, `{"members":[{"name":"other","role":"leader"}]}`),
+		})
+
+		success, err := client.ChangePrimaryAndWait(context.Background(), "old", "new")
+		assert.NilError(t, err)
+		assert.Assert(t, !success)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		client := patroniServer(t, map[string]http.HandlerFunc{
+			"POST /switchover": respond(503, `Switchover failed`),
+		})
+
+		success, err := client.ChangePrimaryAndWait(context.Background(), "old", "new")
+		assert.Assert(t, !success)
+
+		var apiError *Error
+		assert.Assert(t, errors.As(err, &apiError))
+		assert.Equal(t, apiError.StatusCode, 503)
+		assert.Equal(t, apiError.Body, "Switchover failed")
+	})
+}
+
+func TestClientSwitchoverAndWait(t *testing.T) {
+	client := patroniServer(t, map[string]http.HandlerFunc{
+		"POST /switchover": func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]string
+			assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.DeepEqual(t, body, map[string]string{"leader": "old", "candidate": "new"})
+		},
+		"GET /cluster": func() http.HandlerFunc {
+			leader := "old"
+			return func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"members":[{"name":"` + leader + `","role":"leader"}]}`))
+				leader = "new"
+			}
+		}(),
+	})
+
+	success, err := client.SwitchoverAndWait(context.Background(), "new")
+	assert.NilError(t, err)
+	assert.Assert(t, success)
+
+	t.Run("NoLeader", func(t *testing.T) {
+		client := patroniServer(t, map[string]http.HandlerFunc{
+			"GET /cluster": respond(200, `{"members":[{"name":"one","role":"replica"}]}`),
+		})
+
+		success, err := client.SwitchoverAndWait(context.Background(), "one")
+		assert.ErrorContains(t, err, "no leader")
+		assert.Assert(t, !success)
+	})
+}
+
+func TestClientFailoverAndWait(t *testing.T) {
+	client := patroniServer(t, map[string]http.HandlerFunc{
+		"POST /failover": func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]string
+			assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.DeepEqual(t, body, map[string]string{"candidate": "new"})
+		},
+		"GET /cluster": respond(200, `{"members":[{"name":"new","role":"leader"}]}`),
+	})
+
+	success, err := client.FailoverAndWait(context.Background(), "new")
+	assert.NilError(t, err)
+	assert.Assert(t, success)
+}
+
+func TestClientReplaceConfiguration(t *testing.T) {
+	called := false
+	client := patroniServer(t, map[string]http.HandlerFunc{
+		"PUT /config": func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			body, err := io.ReadAll(r.Body)
+			assert.NilError(t, err)
+			assert.Equal(t, string(body), `{"some":"values"}`)
+		},
+	})
+
+	assert.NilError(t, client.ReplaceConfiguration(context.Background(),
+		map[string]interface{}{"some": "values"}))
+	assert.Assert(t, called)
+
+	t.Run("Error", func(t *testing.T) {
+		client := patroniServer(t, map[string]http.HandlerFunc{
+			"PUT /config": respond(400, "bad"),
+		})
+
+		err := client.ReplaceConfiguration(context.Background(), nil)
+		assert.ErrorContains(t, err, "400 bad")
+	})
+}
+
+func TestClientRestartPendingMembers(t *testing.T) {
+	cluster := `{"members":[
+		{"name":"leader","role":"leader","pending_restart":true,
+		 "api_url":"https://leader.some-service:8008/patroni"},
+		{"name":"sync","role":"sync_standby","pending_restart":true,
+		 "api_url":"https://sync.some-service:8008/patroni"},
+		{"name":"replica","role":"replica","pending_restart":false,
+		 "api_url":"https://replica.some-service:8008/patroni"}
+	]}`
+
+	for _, tt := range []struct {
+		role      string
+		restarted []string
+	}{
+		{role: "master", restarted: []string{"leader.some-service.some-ns.svc:8008"}},
+		{role: "replica", restarted: []string{"sync.some-service.some-ns.svc:8008"}},
+	} {
+		t.Run(tt.role, func(t *testing.T) {
+			var restarted []string
+			client := patroniServer(t, map[string]http.HandlerFunc{
+				"GET /cluster": respond(200, cluster),
+				"POST /restart": func(w http.ResponseWriter, r *http.Request) {
+					restarted = append(restarted, r.Host)
+
+					var body map[string]interface{}
+					assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+					assert.DeepEqual(t, body, map[string]interface{}{"restart_pending": true})
+
+					// Patroni responds this way when the member already restarted.
+					w.WriteHeader(503)
+				},
+			})
+
+			assert.NilError(t, client.RestartPendingMembers(context.Background(), tt.role, "scope"))
+			assert.DeepEqual(t, restarted, tt.restarted)
+		})
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		client := patroniServer(t, map[string]http.HandlerFunc{
+			"GET /cluster":  respond(200, cluster),
+			"POST /restart": respond(500, "oops"),
+		})
+
+		err := client.RestartPendingMembers(context.Background(), "master", "scope")
+		assert.ErrorContains(t, err, "500 oops")
+	})
+}
+
+func TestClientGetTimeline(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		members  string
+		timeline int64
+	}{
+		{
+			name:     "Running",
+			members:  `[{"role":"replica","state":"streaming","timeline":4},{"role":"leader","state":"running","timeline":4}]`,
+			timeline: 4,
+		},
+		{
+			name:     "Stopped",
+			members:  `[{"role":"leader","state":"stopped","timeline":4}]`,
+			timeline: 0,
+		},
+		{
+			name:     "Empty",
+			members:  `[]`,
+			timeline: 0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := patroniServer(t, map[string]http.HandlerFunc{
+				"GET /cluster": respond(200, `{"members":`+tt.members+`}`),
+			})
+
+			timeline, err := client.GetTimeline(context.Background())
+			assert.NilError(t, err)
+			assert.Equal(t, timeline, tt.timeline)
+		})
+	}
+}
@@ -60,8 +60,8 @@ func clusterYAML(
 		// lifetime.
 		"scope": naming.PatroniScope(cluster),
 
-		// Use Kubernetes Endpoints for the distributed configuration store (DCS).
-		// These values cannot change during the cluster's lifetime.
+		// Use Kubernetes Endpoints or ConfigMaps for the distributed configuration
+		// store (DCS). These values cannot change while any instance is running.
 		//
 		// NOTE(cbandy): It *might* be possible to *carefully* change the role and
 		// scope labels, but there is no way to reconfigure all instances at once.
@@ -69,7 +69,7 @@ func clusterYAML(
 			"namespace":     cluster.Namespace,
 			"role_label":    naming.LabelRole,
 			"scope_label":   naming.LabelPatroni,
-			"use_endpoints": true,
+			"use_endpoints": !UsesConfigMaps(cluster),
 
 			// In addition to "scope_label" above, Patroni will add the following to
 			// every object it creates. It will also use these as filters when doing
@@ -200,6 +200,21 @@ func DynamicConfiguration(
 	root["ttl"] = *cluster.Spec.Patroni.LeaderLeaseDurationSeconds
 	root["loop_wait"] = *cluster.Spec.Patroni.SyncPeriodSeconds
 
+	// Override any synchronous replication settings with those in the spec.
+	// - https://patroni.readthedocs.io/en/latest/replication_modes.html
+	if sync := cluster.Spec.Patroni.SynchronousReplication; sync != nil {
+		switch sync.Mode {
+		case v1beta1.PatroniSynchronousModeEnabled:
+			root["synchronous_mode"] = true
+		case v1beta1.PatroniSynchronousModeQuorum:
+			root["synchronous_mode"] = "quorum"
+		default:
+			root["synchronous_mode"] = false
+		}
+		root["synchronous_mode_strict"] = sync.Strict
+		root["synchronous_node_count"] = *sync.NodeCount
+	}
+
 	// Copy the "postgresql" section before making any changes.
 	postgresql := map[string]interface{}{
 		// TODO(cbandy): explain this. requires an archive, perhaps.
@@ -224,6 +239,12 @@ func DynamicConfiguration(
 			parameters[k] = v
 		}
 	}
+	// Override the above with any specified parameters.
+	if pgParameters.Specified != nil {
+		for k, v := range pgParameters.Specified.AsMap() {
+			parameters[k] = v
+		}
+	}
 	// Override the above with mandatory parameters.
 	if pgParameters.Mandatory != nil {
 		for k, v := range pgParameters.Mandatory.AsMap() {
@@ -242,11 +263,15 @@ func DynamicConfiguration(
 	}
 	postgresql["parameters"] = parameters
 
-	// Copy the "postgresql.pg_hba" section after any mandatory values.
-	hba := make([]string, 0, len(pgHBAs.Mandatory))
+	// Copy the "postgresql.pg_hba" section after any mandatory and specified
+	// values.
+	hba := make([]string, 0, len(pgHBAs.Mandatory)+len(pgHBAs.Specified))
 	for i := range pgHBAs.Mandatory {
 		hba = append(hba, pgHBAs.Mandatory[i].String())
 	}
+	for i := range pgHBAs.Specified {
+		hba = append(hba, pgHBAs.Specified[i].String())
+	}
 	if section, ok := postgresql["pg_hba"].([]interface{}); ok {
 		for i := range section {
 			// any pg_hba values that are not strings will be skipped
@@ -256,7 +281,12 @@ func DynamicConfiguration(
 		}
 	}
 	// When the section is missing or empty, include the recommended defaults.
-	if len(hba) == len(pgHBAs.Mandatory) {
+	// The authentication section of the spec can decide explicitly.
+	defaults := len(hba) == len(pgHBAs.Mandatory)
+	if auth := cluster.Spec.Authentication; auth != nil && auth.Defaults != nil {
+		defaults = *auth.Defaults
+	}
+	if defaults {
 		for i := range pgHBAs.Default {
 			hba = append(hba, pgHBAs.Default[i].String())
 		}
@@ -466,9 +496,13 @@ func instanceConfigFiles(cluster, instance *corev1.ConfigMap) []corev1.VolumePro
 	}
 }
 
-// instanceYAML returns Patroni settings that apply to instance.
+// instanceYAML returns Patroni settings that apply to instance. When instance
+// replicates from another instance set, replicateFrom is the name of a Patroni
+// member in that set. PostgreSQL parameters in instanceParameters take
+// precedence over those in the dynamic configuration.
 func instanceYAML(
 	cluster *v1beta1.PostgresCluster, instance *v1beta1.PostgresInstanceSetSpec,
+	replicateFrom string, instanceParameters *postgres.ParameterSet,
 	pgbackrestReplicaCreateCommand []string,
 ) (string, error) {
 	root := map[string]interface{}{
@@ -494,10 +528,46 @@ func instanceYAML(
 			// See the PATRONI_RESTAPI_LISTEN environment variable.
 		},
 
-		"tags": map[string]interface{}{
-			// TODO(cbandy): "nofailover"
-			// TODO(cbandy): "nosync"
-		},
+		"tags": map[string]interface{}{},
+	}
+
+	tags := root["tags"].(map[string]interface{})
+
+	// A delayed replica lags the primary on purpose, so it should neither win
+	// an election nor serve load balanced reads.
+	if instance.ReplicationDelaySeconds != nil {
+		tags["nofailover"] = true
+		tags["noloadbalance"] = true
+	}
+	if spec := instance.PatroniTags; spec != nil {
+		if spec.NoFailover {
+			tags["nofailover"] = true
+		}
+		if spec.NoLoadBalance {
+			tags["noloadbalance"] = true
+		}
+		if spec.NoSync {
+			tags["nosync"] = true
+		}
+		if spec.CloneFrom {
+			tags["clonefrom"] = true
+		}
+		if spec.ReplicateFrom != "" && replicateFrom != "" {
+			tags["replicatefrom"] = replicateFrom
+		}
+	}
+
+	// Instances outside the synchronous candidates never become synchronous
+	// standbys. Every instance is a candidate when none are listed.
+	if spec := cluster.Spec.Patroni; spec != nil && spec.SynchronousReplication != nil &&
+		len(spec.SynchronousReplication.Candidates) > 0 {
+		candidate := false
+		for _, name := range spec.SynchronousReplication.Candidates {
+			candidate = candidate || name == instance.Name
+		}
+		if !candidate {
+			tags["nosync"] = true
+		}
 	}
 
 	postgresql := map[string]interface{}{
@@ -521,6 +591,23 @@ func instanceYAML(
 	}
 	root["postgresql"] = postgresql
 
+	// Patroni merges these over the parameters in its dynamic configuration.
+	// - https://patroni.readthedocs.io/en/latest/dynamic_configuration.html
+	if instanceParameters != nil {
+		if parameters := instanceParameters.AsMap(); len(parameters) > 0 {
+			postgresql["parameters"] = parameters
+		}
+	}
+
+	// Patroni writes "recovery_conf" settings only when PostgreSQL is a
+	// standby, so a delayed replica stops waiting should it ever be promoted.
+	// - https://patroni.readthedocs.io/en/latest/yaml_configuration.html#postgresql
+	if delay := instance.ReplicationDelaySeconds; delay != nil {
+		postgresql["recovery_conf"] = map[string]interface{}{
+			"recovery_min_apply_delay": fmt.Sprintf("%ds", *delay),
+		}
+	}
+
 	// The "basebackup" replica method is configured differently from others.
 	// Patroni prepends "--" before it calls `pg_basebackup`.
 	// - https://github.com/zalando/patroni/blob/v2.0.2/patroni/postgresql/bootstrap.py#L45
@@ -151,6 +151,16 @@ watchdog:
   mode: "off"
 	`)+"\n")
 	})
+
+	t.Run("ConfigMaps", func(t *testing.T) {
+		cluster := new(v1beta1.PostgresCluster)
+		cluster.Default()
+		cluster.Status.Patroni.DCS = v1beta1.PatroniDCSConfigMaps
+
+		data, err := clusterYAML(cluster, postgres.HBAs{}, postgres.Parameters{})
+		assert.NilError(t, err)
+		assert.Assert(t, strings.Contains(data, "\n  use_endpoints: false\n"), "got:\n%s", data)
+	})
 }
 
 func TestDynamicConfiguration(t *testing.T) {
@@ -228,6 +238,62 @@ func TestDynamicConfiguration(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "top-level: synchronous replication overrides input",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Patroni: &v1beta1.PatroniSpec{
+						SynchronousReplication: &v1beta1.PatroniSynchronousReplication{
+							Strict:    true,
+							NodeCount: newInt32(2),
+						},
+					},
+				},
+			},
+			input: map[string]interface{}{
+				"synchronous_mode":        false,
+				"synchronous_node_count":  5,
+				"synchronous_mode_strict": "nope",
+			},
+			expected: map[string]interface{}{
+				"loop_wait":               int32(10),
+				"ttl":                     int32(30),
+				"synchronous_mode":        true,
+				"synchronous_mode_strict": true,
+				"synchronous_node_count":  int32(2),
+				"postgresql": map[string]interface{}{
+					"parameters":    map[string]interface{}{},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
+		{
+			name: "top-level: synchronous replication modes",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Patroni: &v1beta1.PatroniSpec{
+						SynchronousReplication: &v1beta1.PatroniSynchronousReplication{
+							Mode: "Quorum",
+						},
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"loop_wait":               int32(10),
+				"ttl":                     int32(30),
+				"synchronous_mode":        "quorum",
+				"synchronous_mode_strict": false,
+				"synchronous_node_count":  int32(1),
+				"postgresql": map[string]interface{}{
+					"parameters":    map[string]interface{}{},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
 		{
 			name: "postgresql: wrong-type is ignored",
 			input: map[string]interface{}{
@@ -367,6 +433,70 @@ func TestDynamicConfiguration(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "postgresql.parameters: specified overrides input",
+			input: map[string]interface{}{
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"something": "str",
+						"another":   5,
+					},
+				},
+			},
+			params: postgres.Parameters{
+				Specified: parameters(map[string]string{
+					"something": "specified",
+					"unrelated": "setting",
+				}),
+				Mandatory: parameters(map[string]string{
+					"unrelated": "mandatory",
+				}),
+			},
+			expected: map[string]interface{}{
+				"loop_wait": int32(10),
+				"ttl":       int32(30),
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"something": "specified",
+						"another":   5,
+						"unrelated": "mandatory",
+					},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
+		{
+			name: "postgresql.parameters: specified shared_preload_libraries",
+			input: map[string]interface{}{
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"shared_preload_libraries": "given",
+					},
+				},
+			},
+			params: postgres.Parameters{
+				Specified: parameters(map[string]string{
+					"shared_preload_libraries": "specified",
+				}),
+				Mandatory: parameters(map[string]string{
+					"shared_preload_libraries": "mandatory",
+				}),
+			},
+			expected: map[string]interface{}{
+				"loop_wait": int32(10),
+				"ttl":       int32(30),
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"shared_preload_libraries": "mandatory,specified",
+					},
+					"pg_hba":        []string{},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
 		{
 			name: "postgresql.parameters: mandatory shared_preload_libraries",
 			input: map[string]interface{}{
@@ -541,6 +671,100 @@ func TestDynamicConfiguration(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "postgresql.pg_hba: specified after mandatory, no default",
+			input: map[string]interface{}{
+				"postgresql": map[string]interface{}{
+					"pg_hba": []interface{}{"custom"},
+				},
+			},
+			hbas: postgres.HBAs{
+				Mandatory: []postgres.HostBasedAuthentication{
+					*postgres.NewHBA().Local().Method("peer"),
+				},
+				Specified: []postgres.HostBasedAuthentication{
+					*postgres.NewHBA().TLS().Method("cert"),
+				},
+				Default: []postgres.HostBasedAuthentication{
+					*postgres.NewHBA().TLS().Method("md5"),
+				},
+			},
+			expected: map[string]interface{}{
+				"loop_wait": int32(10),
+				"ttl":       int32(30),
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{},
+					"pg_hba": []string{
+						"local all all peer",
+						"hostssl all all all cert",
+						"custom",
+					},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
+		{
+			name: "postgresql.pg_hba: defaults kept explicitly",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Authentication: &v1beta1.PostgresAuthenticationSpec{
+						Defaults: initialize.Bool(true),
+					},
+				},
+			},
+			hbas: postgres.HBAs{
+				Specified: []postgres.HostBasedAuthentication{
+					*postgres.NewHBA().TLS().Method("cert"),
+				},
+				Default: []postgres.HostBasedAuthentication{
+					*postgres.NewHBA().TLS().Method("md5"),
+				},
+			},
+			expected: map[string]interface{}{
+				"loop_wait": int32(10),
+				"ttl":       int32(30),
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{},
+					"pg_hba": []string{
+						"hostssl all all all cert",
+						"hostssl all all all md5",
+					},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
+		{
+			name: "postgresql.pg_hba: defaults removed explicitly",
+			cluster: &v1beta1.PostgresCluster{
+				Spec: v1beta1.PostgresClusterSpec{
+					Authentication: &v1beta1.PostgresAuthenticationSpec{
+						Defaults: initialize.Bool(false),
+					},
+				},
+			},
+			hbas: postgres.HBAs{
+				Mandatory: []postgres.HostBasedAuthentication{
+					*postgres.NewHBA().Local().Method("peer"),
+				},
+				Default: []postgres.HostBasedAuthentication{
+					*postgres.NewHBA().TLS().Method("md5"),
+				},
+			},
+			expected: map[string]interface{}{
+				"loop_wait": int32(10),
+				"ttl":       int32(30),
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{},
+					"pg_hba": []string{
+						"local all all peer",
+					},
+					"use_pg_rewind": true,
+					"use_slots":     false,
+				},
+			},
+		},
 		{
 			name: "standby_cluster: input passes through",
 			input: map[string]interface{}{
@@ -837,7 +1061,7 @@ func TestInstanceYAML(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{Spec: v1beta1.PostgresClusterSpec{PostgresVersion: 12}}
 	instance := new(v1beta1.PostgresInstanceSetSpec)
 
-	data, err := instanceYAML(cluster, instance, nil)
+	data, err := instanceYAML(cluster, instance, "", nil, nil)
 	assert.NilError(t, err)
 	assert.Equal(t, data, strings.Trim(`
 # Generated by postgres-operator. DO NOT EDIT.
@@ -860,7 +1084,7 @@ restapi: {}
 tags: {}
 	`, "\t\n")+"\n")
 
-	dataWithReplicaCreate, err := instanceYAML(cluster, instance, []string{"some", "backrest", "cmd"})
+	dataWithReplicaCreate, err := instanceYAML(cluster, instance, "", nil, []string{"some", "backrest", "cmd"})
 	assert.NilError(t, err)
 	assert.Equal(t, dataWithReplicaCreate, strings.Trim(`
 # Generated by postgres-operator. DO NOT EDIT.
@@ -891,6 +1115,159 @@ tags: {}
 	`, "\t\n")+"\n")
 }
 
+func TestInstanceYAMLSynchronousCandidates(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 12
+	cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+		SynchronousReplication: &v1beta1.PatroniSynchronousReplication{},
+	}
+	cluster.Default()
+
+	tags := func(t *testing.T, name string) map[string]interface{} {
+		instance := &v1beta1.PostgresInstanceSetSpec{Name: name}
+		data, err := instanceYAML(cluster, instance, "", nil, nil)
+		assert.NilError(t, err)
+
+		var parsed struct{ Tags map[string]interface{} }
+		assert.NilError(t, yaml.Unmarshal([]byte(data), &parsed))
+		return parsed.Tags
+	}
+
+	t.Run("NoCandidates", func(t *testing.T) {
+		assert.DeepEqual(t, tags(t, "one"), map[string]interface{}{})
+	})
+
+	cluster.Spec.Patroni.SynchronousReplication.Candidates = []string{"one"}
+
+	t.Run("Candidate", func(t *testing.T) {
+		assert.DeepEqual(t, tags(t, "one"), map[string]interface{}{})
+	})
+
+	t.Run("NotCandidate", func(t *testing.T) {
+		assert.DeepEqual(t, tags(t, "two"), map[string]interface{}{"nosync": true})
+	})
+}
+
+func TestInstanceYAMLTags(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 12
+	cluster.Default()
+
+	tags := func(t *testing.T, instance *v1beta1.PostgresInstanceSetSpec, replicateFrom string) map[string]interface{} {
+		data, err := instanceYAML(cluster, instance, replicateFrom, nil, nil)
+		assert.NilError(t, err)
+
+		var parsed struct{ Tags map[string]interface{} }
+		assert.NilError(t, yaml.Unmarshal([]byte(data), &parsed))
+		return parsed.Tags
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		instance := &v1beta1.PostgresInstanceSetSpec{Name: "one"}
+		assert.DeepEqual(t, tags(t, instance, ""), map[string]interface{}{})
+
+		instance.PatroniTags = &v1beta1.PatroniTags{}
+		assert.DeepEqual(t, tags(t, instance, ""), map[string]interface{}{})
+	})
+
+	t.Run("All", func(t *testing.T) {
+		instance := &v1beta1.PostgresInstanceSetSpec{Name: "one"}
+		instance.PatroniTags = &v1beta1.PatroniTags{
+			NoFailover:    true,
+			NoLoadBalance: true,
+			NoSync:        true,
+			CloneFrom:     true,
+			ReplicateFrom: "two",
+		}
+
+		assert.DeepEqual(t, tags(t, instance, "hippo-two-abcd-0"), map[string]interface{}{
+			"clonefrom":     true,
+			"nofailover":    true,
+			"noloadbalance": true,
+			"nosync":        true,
+			"replicatefrom": "hippo-two-abcd-0",
+		})
+	})
+
+	t.Run("ReplicateFromNoMember", func(t *testing.T) {
+		instance := &v1beta1.PostgresInstanceSetSpec{Name: "one"}
+		instance.PatroniTags = &v1beta1.PatroniTags{ReplicateFrom: "two"}
+
+		assert.DeepEqual(t, tags(t, instance, ""), map[string]interface{}{})
+	})
+}
+
+func TestInstanceYAMLReplicationDelay(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 12
+	cluster.Default()
+
+	instance := &v1beta1.PostgresInstanceSetSpec{Name: "delayed"}
+	instance.ReplicationDelaySeconds = initialize.Int32(3600)
+
+	data, err := instanceYAML(cluster, instance, "", nil, nil)
+	assert.NilError(t, err)
+
+	var parsed struct {
+		PostgreSQL struct {
+			RecoveryConf map[string]interface{} `json:"recovery_conf"`
+		}
+		Tags map[string]interface{}
+	}
+	assert.NilError(t, yaml.Unmarshal([]byte(data), &parsed))
+
+	assert.DeepEqual(t, parsed.PostgreSQL.RecoveryConf, map[string]interface{}{
+		"recovery_min_apply_delay": "3600s",
+	})
+	assert.DeepEqual(t, parsed.Tags, map[string]interface{}{
+		"nofailover":    true,
+		"noloadbalance": true,
+	})
+}
+
+func TestInstanceYAMLParameters(t *testing.T) {
+	t.Parallel()
+
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 12
+	cluster.Default()
+
+	instance := &v1beta1.PostgresInstanceSetSpec{Name: "analytics"}
+
+	parse := func(t *testing.T, parameters *postgres.ParameterSet) map[string]interface{} {
+		data, err := instanceYAML(cluster, instance, "", parameters, nil)
+		assert.NilError(t, err)
+
+		var parsed struct {
+			PostgreSQL map[string]interface{}
+		}
+		assert.NilError(t, yaml.Unmarshal([]byte(data), &parsed))
+		return parsed.PostgreSQL
+	}
+
+	t.Run("None", func(t *testing.T) {
+		assert.Assert(t, parse(t, nil)["parameters"] == nil)
+		assert.Assert(t, parse(t, postgres.NewParameterSet())["parameters"] == nil)
+	})
+
+	t.Run("Some", func(t *testing.T) {
+		parameters := postgres.NewParameterSet()
+		parameters.Add("shared_buffers", "8GB")
+		parameters.Add("work_mem", "64MB")
+
+		assert.DeepEqual(t, parse(t, parameters)["parameters"], map[string]interface{}{
+			"shared_buffers": "8GB",
+			"work_mem":       "64MB",
+		})
+	})
+}
+
 func TestPGBackRestCreateReplicaCommand(t *testing.T) {
 	t.Parallel()
 
@@ -898,7 +1275,7 @@ func TestPGBackRestCreateReplicaCommand(t *testing.T) {
 	cluster := new(v1beta1.PostgresCluster)
 	instance := new(v1beta1.PostgresInstanceSetSpec)
 
-	data, err := instanceYAML(cluster, instance, []string{"some", "backrest", "cmd"})
+	data, err := instanceYAML(cluster, instance, "", nil, []string{"some", "backrest", "cmd"})
 	assert.NilError(t, err)
 
 	var parsed struct {
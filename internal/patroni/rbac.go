@@ -27,7 +27,13 @@ import (
 // +kubebuilder:rbac:namespace=patroni,groups="",resources=pods,verbs=list;watch
 // +kubebuilder:rbac:namespace=patroni,groups="",resources=pods,verbs=patch
 
-// TODO(cbandy): Separate these so that one can choose ConfigMap over Endpoints.
+// When using ConfigMaps for DCS, "create", "list", "patch", and "watch" are
+// required. Include "get" for good measure. The `patronictl scaffold` and
+// `patronictl remove` commands require "deletecollection".
+// +kubebuilder:rbac:namespace=patroni,groups="",resources=configmaps,verbs=get
+// +kubebuilder:rbac:namespace=patroni,groups="",resources=configmaps,verbs=create;deletecollection
+// +kubebuilder:rbac:namespace=patroni,groups="",resources=configmaps,verbs=list;watch
+// +kubebuilder:rbac:namespace=patroni,groups="",resources=configmaps,verbs=patch
 
 // When using Endpoints for DCS, "create", "list", "patch", and "watch" are
 // required. Include "get" for good measure. The `patronictl scaffold` and
@@ -45,22 +51,28 @@ import (
 
 // Permissions returns the RBAC rules Patroni needs for cluster.
 func Permissions(cluster *v1beta1.PostgresCluster) []rbacv1.PolicyRule {
-	// TODO(cbandy): This must change when using ConfigMaps for DCS.
-
 	rules := make([]rbacv1.PolicyRule, 0, 4)
 
-	rules = append(rules, rbacv1.PolicyRule{
-		APIGroups: []string{corev1.SchemeGroupVersion.Group},
-		Resources: []string{"endpoints"},
-		Verbs:     []string{"create", "deletecollection", "get", "list", "patch", "watch"},
-	})
-
-	if cluster.Spec.OpenShift != nil && *cluster.Spec.OpenShift {
+	if UsesConfigMaps(cluster) {
 		rules = append(rules, rbacv1.PolicyRule{
 			APIGroups: []string{corev1.SchemeGroupVersion.Group},
-			Resources: []string{"endpoints/restricted"},
-			Verbs:     []string{"create"},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"create", "deletecollection", "get", "list", "patch", "watch"},
+		})
+	} else {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{corev1.SchemeGroupVersion.Group},
+			Resources: []string{"endpoints"},
+			Verbs:     []string{"create", "deletecollection", "get", "list", "patch", "watch"},
 		})
+
+		if cluster.Spec.OpenShift != nil && *cluster.Spec.OpenShift {
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups: []string{corev1.SchemeGroupVersion.Group},
+				Resources: []string{"endpoints/restricted"},
+				Verbs:     []string{"create"},
+			})
+		}
 	}
 
 	rules = append(rules, rbacv1.PolicyRule{
@@ -73,11 +85,13 @@ func Permissions(cluster *v1beta1.PostgresCluster) []rbacv1.PolicyRule {
 	// NOTE(cbandy): The PostgresCluster controller already creates this Service;
 	// it might be possible to eliminate this permission if it also created the
 	// Endpoints.
-	rules = append(rules, rbacv1.PolicyRule{
-		APIGroups: []string{corev1.SchemeGroupVersion.Group},
-		Resources: []string{"services"},
-		Verbs:     []string{"create"},
-	})
+	if !UsesConfigMaps(cluster) {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{corev1.SchemeGroupVersion.Group},
+			Resources: []string{"services"},
+			Verbs:     []string{"create"},
+		})
+	}
 
 	return rules
 }
@@ -37,6 +37,12 @@ func ClusterBootstrapped(postgresCluster *v1beta1.PostgresCluster) bool {
 	return postgresCluster.Status.Patroni.SystemIdentifier != ""
 }
 
+// UsesConfigMaps returns true when Patroni is configured to use ConfigMaps
+// rather than Endpoints for its DCS. See v1beta1.PatroniStatus.DCS.
+func UsesConfigMaps(cluster *v1beta1.PostgresCluster) bool {
+	return cluster.Status.Patroni.DCS == v1beta1.PatroniDCSConfigMaps
+}
+
 // ClusterConfigMap populates the shared ConfigMap with fields needed to run Patroni.
 func ClusterConfigMap(ctx context.Context,
 	inCluster *v1beta1.PostgresCluster,
@@ -55,9 +61,14 @@ func ClusterConfigMap(ctx context.Context,
 }
 
 // InstanceConfigMap populates the shared ConfigMap with fields needed to run Patroni.
+// When inInstanceSpec replicates from another instance set, inReplicateFrom is
+// the name of a Patroni member in that set. See [postgres.InstanceConfigParameters]
+// for inInstanceParameters.
 func InstanceConfigMap(ctx context.Context,
 	inCluster *v1beta1.PostgresCluster,
 	inInstanceSpec *v1beta1.PostgresInstanceSetSpec,
+	inReplicateFrom string,
+	inInstanceParameters *postgres.ParameterSet,
 	outInstanceConfigMap *corev1.ConfigMap,
 ) error {
 	var err error
@@ -67,7 +78,7 @@ func InstanceConfigMap(ctx context.Context,
 	command := pgbackrest.ReplicaCreateCommand(inCluster, inInstanceSpec)
 
 	outInstanceConfigMap.Data[configMapFileKey], err = instanceYAML(
-		inCluster, inInstanceSpec, command)
+		inCluster, inInstanceSpec, inReplicateFrom, inInstanceParameters, command)
 
 	return err
 }
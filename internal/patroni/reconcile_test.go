@@ -109,15 +109,15 @@ func TestInstanceConfigMap(t *testing.T) {
 	cluster := new(v1beta1.PostgresCluster)
 	instance := new(v1beta1.PostgresInstanceSetSpec)
 	config := new(corev1.ConfigMap)
-	data, _ := instanceYAML(cluster, instance, nil)
+	data, _ := instanceYAML(cluster, instance, "", nil, nil)
 
-	assert.NilError(t, InstanceConfigMap(ctx, cluster, instance, config))
+	assert.NilError(t, InstanceConfigMap(ctx, cluster, instance, "", nil, config))
 
 	assert.DeepEqual(t, config.Data["patroni.yaml"], data)
 
 	// No change when called again.
 	before := config.DeepCopy()
-	assert.NilError(t, InstanceConfigMap(ctx, cluster, instance, config))
+	assert.NilError(t, InstanceConfigMap(ctx, cluster, instance, "", nil, config))
 	assert.DeepEqual(t, config, before)
 }
 
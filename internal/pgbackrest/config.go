@@ -251,6 +251,42 @@ mv "${pgdata}" "${pgdata}_bootstrap"`
 	return append([]string{"bash", "-ceu", "--", restoreScript, "-", pgdata}, args...)
 }
 
+// VerifyCommand returns the command for a restore drill. It runs [RestoreCommand] with pgdata
+// and any pgBackRest options provided, then starts the restored database and checks it:
+//   - When query is not empty, it must succeed against the "postgres" database.
+//   - Otherwise, pg_amcheck checks every database on PostgreSQL 14 and later.
+//
+// The database is stopped before the command exits.
+func VerifyCommand(pgdata, query string, args ...string) []string {
+
+	// The restore script leaves the restored data directory at "${pgdata}_bootstrap"
+	// along with the temporary configuration it used to start PostgreSQL. Recovery
+	// has finished by then, so the "--install-missing" option of pg_amcheck is able
+	// to create the amcheck extension in the restored databases.
+	// - https://www.postgresql.org/docs/current/app-pgamcheck.html
+
+	const verifyScript = `declare -r pgdata="$1_bootstrap" query="$2"
+shift 2
+"$@"
+export PGDATA="${pgdata}" PGHOST='/tmp'
+pg_ctl start --silent --timeout=31536000 --wait --options='--config-file=/tmp/postgres.restore.conf'
+
+status=0
+if [ -n "${query}" ]; then
+psql --no-psqlrc --set=ON_ERROR_STOP=1 --command="${query}" || status=$?
+elif [ "$(< "${pgdata}/PG_VERSION")" -ge 14 ]; then
+pg_amcheck --all --install-missing || status=$?
+else
+echo 'pg_amcheck requires PostgreSQL 14 or later; PostgreSQL started successfully'
+fi
+
+pg_ctl stop --silent --wait --timeout=31536000
+exit "${status}"`
+
+	return append([]string{"bash", "-ceu", "--", verifyScript, "-", pgdata, query},
+		RestoreCommand(pgdata, args...)...)
+}
+
 // populatePGInstanceConfigurationMap returns options representing the pgBackRest configuration for
 // a PostgreSQL instance
 func populatePGInstanceConfigurationMap(
@@ -282,6 +318,10 @@ func populatePGInstanceConfigurationMap(
 			}
 		}
 
+		for option, val := range getRepoRetentionConfigs(repo) {
+			global.Set(option, val)
+		}
+
 		// Only "volume" (i.e. PVC-based) repos should ever have a repo host configured.  This
 		// means cloud-based repos (S3, GCS or Azure) should not have a repo host configured.
 		if repoHostName != "" && repo.Volume != nil {
@@ -334,6 +374,10 @@ func populateRepoHostConfigurationMap(
 			}
 		}
 
+		for option, val := range getRepoRetentionConfigs(repo) {
+			global.Set(option, val)
+		}
+
 		if !pgBackRestLogPathSet && repo.Volume != nil {
 			// pgBackRest will log to the first configured repo volume when commands
 			// are run on the pgBackRest repo host. With our previous check in
@@ -394,6 +438,49 @@ func getExternalRepoConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
 	return repoConfigs
 }
 
+// getRepoRetentionConfigs returns a map containing the retention settings for a pgBackRest
+// repository as defined in the PostgresCluster spec
+func getRepoRetentionConfigs(repo v1beta1.PGBackRestRepo) map[string]string {
+
+	repoConfigs := make(map[string]string)
+
+	if retention := repo.Retention; retention != nil {
+		if retention.Full != nil {
+			repoConfigs[repo.Name+"-retention-full"] = fmt.Sprint(*retention.Full)
+		}
+		if retention.FullType != "" {
+			repoConfigs[repo.Name+"-retention-full-type"] = retention.FullType
+		}
+		if retention.Differential != nil {
+			repoConfigs[repo.Name+"-retention-diff"] = fmt.Sprint(*retention.Differential)
+		}
+		if retention.Archive != nil {
+			repoConfigs[repo.Name+"-retention-archive"] = fmt.Sprint(*retention.Archive)
+		}
+		if retention.ArchiveType != "" {
+			repoConfigs[repo.Name+"-retention-archive-type"] = retention.ArchiveType
+		}
+	}
+
+	return repoConfigs
+}
+
+// RetentionOptions returns the pgBackRest options that determine which backups and WAL are
+// kept in repo, including any set for it in spec.backups.pgbackrest.global.
+func RetentionOptions(
+	postgresCluster *v1beta1.PostgresCluster, repo v1beta1.PGBackRestRepo,
+) map[string]string {
+	options := getRepoRetentionConfigs(repo)
+
+	for option, val := range postgresCluster.Spec.Backups.PGBackRest.Global {
+		if strings.HasPrefix(option, repo.Name+"-retention-") {
+			options[option] = val
+		}
+	}
+
+	return options
+}
+
 // reloadCommand returns an entrypoint that convinces the pgBackRest TLS server
 // to reload its options and certificate files when they change. The process
 // will appear as name in `ps` and `top`.
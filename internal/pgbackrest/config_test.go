@@ -159,6 +159,51 @@ pg1-socket-path = /tmp/postgres
 		`, "\t\n")+"\n")
 	})
 
+	t.Run("Retention", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Backups.PGBackRest.Global = map[string]string{
+			"repo2-retention-full": "9",
+		}
+		cluster.Spec.Backups.PGBackRest.Repos = []v1beta1.PGBackRestRepo{
+			{
+				Name:   "repo1",
+				Volume: &v1beta1.RepoPVC{},
+				Retention: &v1beta1.PGBackRestRetention{
+					Full:         initialize.Int32(14),
+					FullType:     "time",
+					Differential: initialize.Int32(3),
+					Archive:      initialize.Int32(2),
+					ArchiveType:  "diff",
+				},
+			},
+			{
+				Name: "repo2",
+				GCS:  &v1beta1.RepoGCS{Bucket: "g-bucket"},
+				Retention: &v1beta1.PGBackRestRetention{
+					Full: initialize.Int32(2),
+				},
+			},
+		}
+
+		configmap := CreatePGBackRestConfigMapIntent(cluster,
+			"repo-hostname", "abcde12345", "pod-service-name", "test-ns",
+			[]string{"some-instance"})
+
+		for _, key := range []string{"pgbackrest_instance.conf", "pgbackrest_repo.conf"} {
+			assert.Assert(t, strings.Contains(configmap.Data[key], strings.Trim(`
+repo1-retention-archive = 2
+repo1-retention-archive-type = diff
+repo1-retention-diff = 3
+repo1-retention-full = 14
+repo1-retention-full-type = time
+			`, "\t\n")), "%s:\n%s", key, configmap.Data[key])
+
+			// Global options take precedence.
+			assert.Assert(t, strings.Contains(configmap.Data[key], "\nrepo2-retention-full = 9\n"),
+				"%s:\n%s", key, configmap.Data[key])
+		}
+	})
+
 	t.Run("CustomMetadata", func(t *testing.T) {
 		cluster := cluster.DeepCopy()
 		cluster.Spec.Metadata = &v1beta1.Metadata{
@@ -318,6 +363,28 @@ func TestRestoreCommandPrettyYAML(t *testing.T) {
 		"expected literal block scalar, got:\n%s", b)
 }
 
+func TestVerifyCommand(t *testing.T) {
+	pgdata := "/pgdata/pg13"
+	command := VerifyCommand(pgdata, "SELECT 1", "--stanza="+DefaultStanzaName, "--repo=1")
+
+	assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+	assert.DeepEqual(t, command[4:7], []string{"-", pgdata, "SELECT 1"})
+	assert.DeepEqual(t, command[7:],
+		RestoreCommand(pgdata, "--stanza="+DefaultStanzaName, "--repo=1"))
+
+	t.Run("ShellCheck", func(t *testing.T) {
+		shellcheck := require.ShellCheck(t)
+
+		dir := t.TempDir()
+		file := filepath.Join(dir, "script.bash")
+		assert.NilError(t, os.WriteFile(file, []byte(command[3]), 0o600))
+
+		cmd := exec.Command(shellcheck, "--enable=all", file)
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, "%q\n%s", cmd.Args, output)
+	})
+}
+
 func TestServerConfig(t *testing.T) {
 	cluster := &v1beta1.PostgresCluster{}
 	cluster.UID = "shoe"
@@ -360,3 +427,26 @@ log-level-stderr = error
 log-timestamp = n
 `)
 }
+
+func TestRetentionOptions(t *testing.T) {
+	cluster := &v1beta1.PostgresCluster{}
+	cluster.Spec.Backups.PGBackRest.Global = map[string]string{
+		"repo1-retention-diff": "4",
+		"repo2-retention-full": "9",
+		"repo1-path":           "/elsewhere",
+	}
+
+	repo := v1beta1.PGBackRestRepo{Name: "repo1"}
+	assert.DeepEqual(t, RetentionOptions(cluster, repo), map[string]string{
+		"repo1-retention-diff": "4",
+	})
+
+	repo.Retention = &v1beta1.PGBackRestRetention{
+		Full:         initialize.Int32(2),
+		Differential: initialize.Int32(1),
+	}
+	assert.DeepEqual(t, RetentionOptions(cluster, repo), map[string]string{
+		"repo1-retention-full": "2",
+		"repo1-retention-diff": "4",
+	})
+}
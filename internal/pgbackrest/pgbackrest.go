@@ -18,9 +18,12 @@ package pgbackrest
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -98,3 +101,177 @@ fi
 
 	return false, nil
 }
+
+// InfoBackup is one backup reported by the pgBackRest "info" command.
+// - https://pgbackrest.org/command.html#command-info
+type InfoBackup struct {
+	Label string `json:"label"`
+	Type  string `json:"type"`
+
+	// Error is true when pgBackRest found problems, such as page checksum
+	// failures, while taking the backup.
+	Error bool `json:"error"`
+
+	// Archive is the range of WAL files needed to restore the backup.
+	Archive struct {
+		Start string `json:"start"`
+		Stop  string `json:"stop"`
+	} `json:"archive"`
+
+	Database struct {
+		RepoKey int `json:"repo-key"`
+	} `json:"database"`
+
+	// LSN is the range of log sequence numbers during the backup.
+	LSN struct {
+		Start string `json:"start"`
+		Stop  string `json:"stop"`
+	} `json:"lsn"`
+
+	Info struct {
+		Size       int64 `json:"size"`
+		Repository struct {
+			Delta int64 `json:"delta"`
+			Size  int64 `json:"size"`
+		} `json:"repository"`
+	} `json:"info"`
+
+	// Timestamp is when the backup started and stopped in seconds since the
+	// Unix epoch.
+	Timestamp struct {
+		Start int64 `json:"start"`
+		Stop  int64 `json:"stop"`
+	} `json:"timestamp"`
+}
+
+// InfoArchive is the range of WAL files in one repository for one version of
+// the database, as reported by the pgBackRest "info" command.
+type InfoArchive struct {
+	// ID identifies the database version, e.g. "14-1", and is the name of
+	// its directory in the archive of the stanza.
+	ID string `json:"id"`
+
+	Database struct {
+		ID      int `json:"id"`
+		RepoKey int `json:"repo-key"`
+	} `json:"database"`
+
+	// Min and Max are the oldest and newest WAL files in the archive.
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+// InfoStanza is one stanza reported by the pgBackRest "info" command.
+type InfoStanza struct {
+	Name    string        `json:"name"`
+	Archive []InfoArchive `json:"archive"`
+	Backup  []InfoBackup  `json:"backup"`
+}
+
+// Info runs the pgBackRest "info" command and returns the backups of the
+// default stanza in every repository, oldest first.
+func (exec Executor) Info(ctx context.Context) (*InfoStanza, error) {
+	var stdout, stderr bytes.Buffer
+
+	err := exec(ctx, nil, &stdout, &stderr,
+		"pgbackrest", "info", "--output=json", "--stanza="+DefaultStanzaName)
+	if err != nil {
+		return nil, errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+
+	var stanzas []InfoStanza
+	if err := json.Unmarshal(stdout.Bytes(), &stanzas); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for i := range stanzas {
+		if stanzas[i].Name == DefaultStanzaName {
+			return &stanzas[i], nil
+		}
+	}
+	return &InfoStanza{Name: DefaultStanzaName}, nil
+}
+
+// ArchiveTime runs the pgBackRest "repo-ls" command to find when the WAL file
+// named segment was stored in the archive of repository repoKey. The archive
+// is that of the database version archiveID, e.g. "14-1". It returns the zero
+// Time when the file is not there.
+// - https://pgbackrest.org/command.html#command-repo-ls
+func (exec Executor) ArchiveTime(
+	ctx context.Context, repoKey int, archiveID, segment string,
+) (time.Time, error) {
+	var stdout, stderr bytes.Buffer
+
+	// WAL files are stored in directories named for the first sixteen
+	// characters of their names. Stored files have a checksum suffix and
+	// possibly a compression extension.
+	if len(segment) < 16 {
+		return time.Time{}, errors.Errorf("unexpected WAL file name %q", segment)
+	}
+	directory := strings.Join([]string{
+		"archive", DefaultStanzaName, archiveID, segment[:16]}, "/")
+
+	err := exec(ctx, nil, &stdout, &stderr,
+		"pgbackrest", "repo-ls", "--output=json", fmt.Sprintf("--repo=%d", repoKey),
+		"--filter=^"+segment, directory)
+	if err != nil {
+		return time.Time{}, errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+
+	var files map[string]struct {
+		Type string `json:"type"`
+		Time int64  `json:"time"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &files); err != nil {
+		return time.Time{}, errors.WithStack(err)
+	}
+
+	var stored time.Time
+	for _, file := range files {
+		if file.Type == "file" && file.Time > 0 && time.Unix(file.Time, 0).After(stored) {
+			stored = time.Unix(file.Time, 0).UTC()
+		}
+	}
+	return stored, nil
+}
+
+// Expire runs the pgBackRest "expire" command against the repository named
+// repoName, e.g. "repo1", with options given on the command line. Options on
+// the command line take precedence over those in configuration files, so this
+// applies retention options before any changes to those files propagate.
+// - https://pgbackrest.org/command.html#command-expire
+func (exec Executor) Expire(ctx context.Context, repoName string, options map[string]string) error {
+	var stderr bytes.Buffer
+
+	command := []string{"pgbackrest", "expire",
+		"--stanza=" + DefaultStanzaName, "--repo=" + strings.TrimPrefix(repoName, "repo")}
+
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		command = append(command, "--"+key+"="+options[key])
+	}
+
+	if err := exec(ctx, nil, nil, &stderr, command...); err != nil {
+		return errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+	return nil
+}
+
+// ExpireSet runs the pgBackRest "expire" command to remove the backup labeled
+// label, and any backups that depend on it, from the repository named repoName.
+// - https://pgbackrest.org/command.html#command-expire
+func (exec Executor) ExpireSet(ctx context.Context, repoName, label string) error {
+	var stderr bytes.Buffer
+
+	if err := exec(ctx, nil, nil, &stderr, "pgbackrest", "expire",
+		"--stanza="+DefaultStanzaName, "--repo="+strings.TrimPrefix(repoName, "repo"),
+		"--set="+label,
+	); err != nil {
+		return errors.WithStack(fmt.Errorf("%w: %v", err, stderr.String()))
+	}
+	return nil
+}
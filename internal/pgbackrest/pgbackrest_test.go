@@ -17,11 +17,13 @@ package pgbackrest
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 
@@ -72,3 +74,213 @@ fi
 	output, err := cmd.CombinedOutput()
 	assert.NilError(t, err, "%q\n%s", cmd.Args, output)
 }
+
+func TestInfo(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, _, stderr io.Writer, _ ...string,
+		) error {
+			_, _ = stderr.Write([]byte("ERROR: [055]: unable to load info file"))
+			return errors.New("exit status 55")
+		}
+
+		_, err := Executor(exec).Info(ctx)
+		assert.ErrorContains(t, err, "exit status 55: ERROR: [055]")
+	})
+
+	t.Run("Backups", func(t *testing.T) {
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, _ io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdin == nil)
+			assert.DeepEqual(t, command,
+				[]string{"pgbackrest", "info", "--output=json", "--stanza=db"})
+
+			_, err := stdout.Write([]byte(`[{
+				"name": "other", "backup": [{"label": "nope"}]
+			}, {
+				"name": "db",
+				"archive": [{
+					"database": {"id": 1, "repo-key": 2},
+					"id": "14-1",
+					"max": "000000010000000000000005",
+					"min": "000000010000000000000001"
+				}],
+				"backup": [{
+					"archive": {"start": "000000010000000000000002", "stop": "000000010000000000000003"},
+					"database": {"id": 1, "repo-key": 2},
+					"error": true,
+					"info": {"repository": {"delta": 2048, "size": 4096}, "size": 31000000},
+					"label": "20230101-000000F",
+					"lsn": {"start": "0/2000028", "stop": "0/3000050"},
+					"timestamp": {"start": 1672531200, "stop": 1672531260},
+					"type": "full"
+				}]
+			}]`))
+			return err
+		}
+
+		stanza, err := Executor(exec).Info(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, stanza.Name, "db")
+		assert.Equal(t, len(stanza.Backup), 1)
+
+		backup := stanza.Backup[0]
+		assert.Equal(t, backup.Label, "20230101-000000F")
+		assert.Equal(t, backup.Type, "full")
+		assert.Equal(t, backup.Error, true)
+		assert.Equal(t, backup.Archive.Start, "000000010000000000000002")
+		assert.Equal(t, backup.Archive.Stop, "000000010000000000000003")
+		assert.Equal(t, backup.Database.RepoKey, 2)
+		assert.Equal(t, backup.LSN.Start, "0/2000028")
+		assert.Equal(t, backup.LSN.Stop, "0/3000050")
+		assert.Equal(t, backup.Info.Size, int64(31000000))
+		assert.Equal(t, backup.Info.Repository.Delta, int64(2048))
+		assert.Equal(t, backup.Timestamp.Start, int64(1672531200))
+		assert.Equal(t, backup.Timestamp.Stop, int64(1672531260))
+
+		assert.Equal(t, len(stanza.Archive), 1)
+		archive := stanza.Archive[0]
+		assert.Equal(t, archive.ID, "14-1")
+		assert.Equal(t, archive.Database.ID, 1)
+		assert.Equal(t, archive.Database.RepoKey, 2)
+		assert.Equal(t, archive.Min, "000000010000000000000001")
+		assert.Equal(t, archive.Max, "000000010000000000000005")
+	})
+
+	t.Run("NoStanza", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, err := stdout.Write([]byte(`[]`))
+			return err
+		}
+
+		stanza, err := Executor(exec).Info(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, stanza.Name, "db")
+		assert.Equal(t, len(stanza.Backup), 0)
+	})
+}
+
+func TestArchiveTime(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, _, stderr io.Writer, _ ...string,
+		) error {
+			_, _ = stderr.Write([]byte("ERROR: [037]: repo-ls command requires"))
+			return errors.New("exit status 37")
+		}
+
+		_, err := Executor(exec).ArchiveTime(ctx, 1, "14-1", "000000010000000000000005")
+		assert.ErrorContains(t, err, "exit status 37: ERROR: [037]")
+	})
+
+	t.Run("Stored", func(t *testing.T) {
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, _ io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdin == nil)
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "repo-ls", "--output=json", "--repo=2",
+				"--filter=^000000010000000000000005",
+				"archive/db/14-1/0000000100000000",
+			})
+
+			_, err := stdout.Write([]byte(`{
+				"000000010000000000000005-1a2b3c.gz": {"size": 1024, "time": 1672617700, "type": "file"}
+			}`))
+			return err
+		}
+
+		stored, err := Executor(exec).ArchiveTime(ctx, 2, "14-1", "000000010000000000000005")
+		assert.NilError(t, err)
+		assert.Equal(t, stored, time.Unix(1672617700, 0).UTC())
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			_, err := stdout.Write([]byte(`{}`))
+			return err
+		}
+
+		stored, err := Executor(exec).ArchiveTime(ctx, 1, "14-1", "000000010000000000000005")
+		assert.NilError(t, err)
+		assert.Assert(t, stored.IsZero())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := Executor(nil).ArchiveTime(ctx, 1, "14-1", "0001")
+		assert.ErrorContains(t, err, `unexpected WAL file name "0001"`)
+	})
+}
+
+func TestExpire(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, _, stderr io.Writer, _ ...string,
+		) error {
+			_, _ = stderr.Write([]byte("ERROR: [031]: invalid option"))
+			return errors.New("exit status 31")
+		}
+
+		err := Executor(exec).Expire(ctx, "repo1", nil)
+		assert.ErrorContains(t, err, "exit status 31: ERROR: [031]")
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdin == nil)
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "expire", "--stanza=db", "--repo=3",
+				"--repo3-retention-diff=2", "--repo3-retention-full=5",
+			})
+			return nil
+		}
+
+		assert.NilError(t, Executor(exec).Expire(ctx, "repo3", map[string]string{
+			"repo3-retention-full": "5",
+			"repo3-retention-diff": "2",
+		}))
+	})
+}
+
+func TestExpireSet(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Error", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, _, stderr io.Writer, _ ...string,
+		) error {
+			_, _ = stderr.Write([]byte("ERROR: [075]: backup set does not exist"))
+			return errors.New("exit status 75")
+		}
+
+		err := Executor(exec).ExpireSet(ctx, "repo1", "20230101-000000F")
+		assert.ErrorContains(t, err, "exit status 75: ERROR: [075]")
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdin == nil)
+			assert.DeepEqual(t, command, []string{
+				"pgbackrest", "expire", "--stanza=db", "--repo=2", "--set=20230101-000000F",
+			})
+			return nil
+		}
+
+		assert.NilError(t, Executor(exec).ExpireSet(ctx, "repo2", "20230101-000000F"))
+	})
+}
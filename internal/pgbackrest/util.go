@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"sort"
 
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/util/rand"
@@ -100,6 +101,31 @@ func CalculateConfigHashes(
 	return repoConfigHashes, configHash, nil
 }
 
+// RetentionHash returns a hash of the retention options of a repository, as
+// returned by RetentionOptions. It is empty when there are no options.
+func RetentionHash(options map[string]string) (string, error) {
+	if len(options) == 0 {
+		return "", nil
+	}
+
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash, err := safeHash32(func(w io.Writer) (err error) {
+		for _, key := range keys {
+			_, err = fmt.Fprintf(w, "%s=%s\n", key, options[key])
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+	return hash, errors.WithStack(err)
+}
+
 // safeHash32 runs content and returns a short alphanumeric string that
 // represents everything written to w. The string is unlikely to have bad words
 // and is safe to store in the Kubernetes API. This is the same algorithm used
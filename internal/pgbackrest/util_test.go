@@ -132,3 +132,21 @@ func TestCalculateConfigHashes(t *testing.T) {
 		assert.Assert(t, hashMap[repo] != configHashMap[repo])
 	}
 }
+
+func TestRetentionHash(t *testing.T) {
+	empty, err := RetentionHash(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, empty, "")
+
+	one, err := RetentionHash(map[string]string{"repo1-retention-full": "2"})
+	assert.NilError(t, err)
+	assert.Assert(t, one != "")
+
+	again, err := RetentionHash(map[string]string{"repo1-retention-full": "2"})
+	assert.NilError(t, err)
+	assert.Equal(t, one, again, "expected the same hash for the same options")
+
+	two, err := RetentionHash(map[string]string{"repo1-retention-full": "3"})
+	assert.NilError(t, err)
+	assert.Assert(t, one != two)
+}
@@ -91,29 +91,17 @@ func EnableExporterInPostgreSQL(ctx context.Context, exec postgres.Executor,
 	monitoringSecret *corev1.Secret, database, setup string) error {
 	log := logging.FromContext(ctx)
 
-	stdout, stderr, err := exec.ExecInAllDatabases(ctx,
-		strings.Join([]string{
-			// Quiet NOTICE messages from IF EXISTS statements.
-			// - https://www.postgresql.org/docs/current/runtime-config-client.html
-			`SET client_min_messages = WARNING;`,
-
-			// Exporter expects that extension(s) to be installed in all databases
-			// pg_stat_statements: https://access.crunchydata.com/documentation/pgmonitor/latest/exporter/
-			"CREATE EXTENSION IF NOT EXISTS pg_stat_statements;",
-
-			// Run idempotent update
-			"ALTER EXTENSION pg_stat_statements UPDATE;",
-		}, "\n"),
-		map[string]string{
-			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
-			"QUIET":         "on", // Do not print successful commands to stdout.
-		},
-	)
-
-	log.V(1).Info("applied pgMonitor objects", "database", "current and future databases", "stdout", stdout, "stderr", stderr)
-
-	// NOTE: Setup is run last to ensure that the setup sql is used in the hash
+	// Exporter expects that extension(s) to be installed in all databases
+	// pg_stat_statements: https://access.crunchydata.com/documentation/pgmonitor/latest/exporter/
+	err := postgres.WriteExtensionsInAllDatabases(ctx, exec, []postgres.Extension{
+		{Name: "pg_stat_statements", Update: true},
+	})
+
+	// Setup creates the `monitor` schema in which pgnodemx is installed below.
+	// The SQL and variables of every statement here, including setup, are part
+	// of the revision hash that decides when to run them again.
 	if err == nil {
+		var stdout, stderr string
 		stdout, stderr, err = exec.ExecInDatabasesFromQuery(ctx,
 			`SELECT :'database'`,
 			strings.Join([]string{
@@ -125,15 +113,6 @@ func EnableExporterInPostgreSQL(ctx context.Context, exec postgres.Executor,
 				// to the PostgreSQL version
 				setup,
 
-				// pgnodemx: https://github.com/CrunchyData/pgnodemx
-				// The `monitor` schema is hard-coded in the setup SQL files
-				// from pgMonitor configuration
-				// https://github.com/CrunchyData/pgmonitor/blob/master/postgres_exporter/common/queries_nodemx.yml
-				"CREATE EXTENSION IF NOT EXISTS pgnodemx WITH SCHEMA monitor;",
-
-				// Run idempotent update
-				"ALTER EXTENSION pgnodemx UPDATE;",
-
 				// ccp_monitoring user is created in Setup.sql without a
 				// password; update the password and ensure that the ROLE
 				// can login to the database
@@ -152,5 +131,15 @@ func EnableExporterInPostgreSQL(ctx context.Context, exec postgres.Executor,
 		log.V(1).Info("applied pgMonitor objects", "database", database, "stdout", stdout, "stderr", stderr)
 	}
 
+	// pgnodemx: https://github.com/CrunchyData/pgnodemx
+	// The `monitor` schema is hard-coded in the setup SQL files
+	// from pgMonitor configuration
+	// https://github.com/CrunchyData/pgmonitor/blob/master/postgres_exporter/common/queries_nodemx.yml
+	if err == nil {
+		err = postgres.WriteExtensionsInDatabases(ctx, exec, map[string][]postgres.Extension{
+			database: {{Name: "pgnodemx", Schema: "monitor", Update: true}},
+		})
+	}
+
 	return err
 }
@@ -16,10 +16,13 @@
 package pgmonitor
 
 import (
+	"context"
+	"io"
 	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
@@ -99,3 +102,58 @@ func TestPostgreSQLParameters(t *testing.T) {
 		assert.Assert(t, strings.Contains(libs, "daisy"))
 	})
 }
+
+func TestEnableExporterInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{Data: map[string][]byte{"verifier": []byte("SCRAM-SHA-256$xyz")}}
+
+	var commands [][]string
+	var stdins []string
+	exec := func(
+		_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+	) error {
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+
+		commands = append(commands, command)
+		stdins = append(stdins, string(b))
+		return nil
+	}
+
+	assert.NilError(t, EnableExporterInPostgreSQL(ctx, exec, secret,
+		"postgres", "CREATE SCHEMA IF NOT EXISTS monitor;"))
+	assert.Equal(t, len(commands), 3)
+
+	// pg_stat_statements is installed in every database.
+	assert.DeepEqual(t, commands[0][5:], []string{
+		`SET search_path = '';SELECT datname FROM pg_catalog.pg_database WHERE datallowconn AND datname NOT IN ('template0')`,
+		`--set=ON_ERROR_STOP=on`,
+		`--set=QUIET=on`,
+		`--set=extensions=[{"name":"pg_stat_statements","update":true}]`,
+	})
+
+	// Setup runs in the exporter database before the password is set.
+	assert.DeepEqual(t, commands[1][5:], []string{
+		`SELECT :'database'`,
+		`--set=ON_ERROR_STOP=on`,
+		`--set=QUIET=on`,
+		`--set=database=postgres`,
+		`--set=username=ccp_monitoring`,
+		`--set=verifier=SCRAM-SHA-256$xyz`,
+	})
+	assert.Equal(t, stdins[1], strings.Join([]string{
+		`SET client_min_messages = WARNING;`,
+		`CREATE SCHEMA IF NOT EXISTS monitor;`,
+		`ALTER ROLE :"username" LOGIN PASSWORD :'verifier';`,
+	}, "\n"))
+
+	// pgnodemx is installed after setup creates its schema.
+	assert.DeepEqual(t, commands[2][5:], []string{
+		`SELECT pg_catalog.json_object_keys(:'extensions')`,
+		`--set=ON_ERROR_STOP=on`,
+		`--set=QUIET=on`,
+		`--set=extensions={"postgres":[{"name":"pgnodemx","schema":"monitor","update":true}]}`,
+	})
+	assert.Assert(t, strings.Contains(stdins[2],
+		`pg_catalog.json_extract_path(:'extensions', pg_catalog.current_database())`))
+}
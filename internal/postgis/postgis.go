@@ -17,9 +17,7 @@ package postgis
 
 import (
 	"context"
-	"strings"
 
-	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/internal/postgres"
 )
 
@@ -29,25 +27,10 @@ import (
 //   - fuzzystrmatch
 //   - postgis_tiger_geocoder
 func EnableInPostgreSQL(ctx context.Context, exec postgres.Executor) error {
-	log := logging.FromContext(ctx)
-
-	stdout, stderr, err := exec.ExecInAllDatabases(ctx,
-		strings.Join([]string{
-			// Quiet NOTICE messages from IF NOT EXISTS statements.
-			// - https://www.postgresql.org/docs/current/runtime-config-client.html
-			`SET client_min_messages = WARNING;`,
-
-			`CREATE EXTENSION IF NOT EXISTS postgis;`,
-			`CREATE EXTENSION IF NOT EXISTS postgis_topology;`,
-			`CREATE EXTENSION IF NOT EXISTS fuzzystrmatch;`,
-			`CREATE EXTENSION IF NOT EXISTS postgis_tiger_geocoder;`,
-		}, "\n"),
-		map[string]string{
-			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
-			"QUIET":         "on", // Do not print successful statements to stdout.
-		})
-
-	log.V(1).Info("enabled PostGIS and related extensions", "stdout", stdout, "stderr", stderr)
-
-	return err
+	return postgres.WriteExtensionsInAllDatabases(ctx, exec, []postgres.Extension{
+		{Name: "postgis"},
+		{Name: "postgis_topology"},
+		{Name: "fuzzystrmatch"},
+		{Name: "postgis_tiger_geocoder"},
+	})
 }
@@ -19,7 +19,6 @@ import (
 	"context"
 	"errors"
 	"io"
-	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -33,17 +32,49 @@ func TestEnableInPostgreSQL(t *testing.T) {
 		assert.Assert(t, stdout != nil, "should capture stdout")
 		assert.Assert(t, stderr != nil, "should capture stderr")
 
-		assert.Assert(t, strings.Contains(strings.Join(command, "\n"),
-			`SELECT datname FROM pg_catalog.pg_database`,
-		), "expected all databases and templates")
+		assert.DeepEqual(t, command[5:], []string{
+			`SET search_path = '';SELECT datname FROM pg_catalog.pg_database WHERE datallowconn AND datname NOT IN ('template0')`,
+			`--set=ON_ERROR_STOP=on`,
+			`--set=QUIET=on`,
+			`--set=extensions=[{"name":"postgis"},{"name":"postgis_topology"},{"name":"fuzzystrmatch"},{"name":"postgis_tiger_geocoder"}]`,
+		})
 
 		b, err := io.ReadAll(stdin)
 		assert.NilError(t, err)
-		assert.Equal(t, string(b), `SET client_min_messages = WARNING;
-CREATE EXTENSION IF NOT EXISTS postgis;
-CREATE EXTENSION IF NOT EXISTS postgis_topology;
-CREATE EXTENSION IF NOT EXISTS fuzzystrmatch;
-CREATE EXTENSION IF NOT EXISTS postgis_tiger_geocoder;`)
+		assert.Equal(t, string(b), `
+SET search_path TO '';
+
+-- Quiet NOTICE messages from IF NOT EXISTS and UPDATE statements.
+-- - https://www.postgresql.org/docs/current/runtime-config-client.html
+SET client_min_messages = WARNING;
+
+-- Keep the order of the input; some extensions depend on others.
+CREATE TEMPORARY TABLE input AS
+SELECT * FROM ROWS FROM (pg_catalog.json_to_recordset(:'extensions'::json)
+       AS (name text, schema text, version text, update boolean))
+  WITH ORDINALITY AS input (name, schema, version, update, id);
+
+-- Extensions without a schema are created in the default creation schema,
+-- so restore the search_path before creating them. Everything below is
+-- schema-qualified.
+RESET search_path;
+
+SELECT pg_catalog.concat_ws(' ', 'CREATE EXTENSION IF NOT EXISTS',
+       pg_catalog.quote_ident(input.name),
+       'SCHEMA ' || pg_catalog.quote_ident(input.schema),
+       'VERSION ' || pg_catalog.quote_literal(input.version))
+  FROM pg_temp.input
+ ORDER BY input.id
+\gexec
+
+SELECT pg_catalog.concat_ws(' ', 'ALTER EXTENSION',
+       pg_catalog.quote_ident(input.name), 'UPDATE',
+       'TO ' || pg_catalog.quote_literal(input.version))
+  FROM pg_temp.input
+ WHERE input.update
+ ORDER BY input.id
+\gexec
+`)
 
 		return expected
 	}
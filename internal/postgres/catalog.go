@@ -0,0 +1,366 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ParameterContext indicates when changes to a parameter take effect. These
+// are the values of the "context" column of the "pg_settings" view.
+// - https://www.postgresql.org/docs/current/view-pg-settings.html
+type ParameterContext string
+
+const (
+	// ParameterInternal parameters cannot be changed.
+	ParameterInternal ParameterContext = "internal"
+
+	// ParameterPostmaster parameters take effect when PostgreSQL restarts.
+	ParameterPostmaster ParameterContext = "postmaster"
+
+	// ParameterSighup parameters take effect when PostgreSQL reloads.
+	ParameterSighup ParameterContext = "sighup"
+
+	ParameterSuperuserBackend ParameterContext = "superuser-backend"
+	ParameterBackend          ParameterContext = "backend"
+	ParameterSuperuser        ParameterContext = "superuser"
+	ParameterUser             ParameterContext = "user"
+)
+
+// ParameterType is the data type of a parameter. These are the values of
+// the "vartype" column of the "pg_settings" view.
+type ParameterType string
+
+const (
+	ParameterBool    ParameterType = "bool"
+	ParameterEnum    ParameterType = "enum"
+	ParameterInteger ParameterType = "integer"
+	ParameterReal    ParameterType = "real"
+	ParameterString  ParameterType = "string"
+)
+
+// ParameterDefinition describes a PostgreSQL parameter in one major version.
+type ParameterDefinition struct {
+	Name    string
+	Type    ParameterType
+	Context ParameterContext
+
+	// Unit is the implicit unit of numeric values. Memory units are "B", "kB",
+	// "8kB", and "MB". Time units are "ms", "s", and "min".
+	Unit string
+
+	// Min and Max are the range of numeric values in Unit.
+	Min, Max float64
+
+	// Values are the accepted values of an enum. PostgreSQL accepts some
+	// aliases that are not listed here; see [ParameterDefinition.Validate].
+	Values []string
+}
+
+var (
+	ErrParameterReadOnly = errors.New("parameter cannot be changed")
+	ErrParameterUnknown  = errors.New("unrecognized configuration parameter")
+)
+
+// LookupParameter returns the definition of parameter name in the major
+// version of PostgreSQL and whether or not it is known.
+func LookupParameter(version int, name string) (ParameterDefinition, bool) {
+	definition, ok := parameterCatalog[version][strings.ToLower(name)]
+	return definition, ok
+}
+
+// ValidateParameter returns an error when value is not valid for parameter
+// name in the major version of PostgreSQL. Customized options, which have a
+// dot in their name, are not checked. Nothing is checked in a major version
+// that is not in the catalog.
+// - https://www.postgresql.org/docs/current/runtime-config-custom.html
+func ValidateParameter(version int, name, value string) error {
+	if _, ok := parameterCatalog[version]; !ok || strings.Contains(name, ".") {
+		return nil
+	}
+
+	definition, ok := LookupParameter(version, name)
+	if !ok {
+		return fmt.Errorf("%w %q in PostgreSQL %d", ErrParameterUnknown, name, version)
+	}
+	return definition.Validate(value)
+}
+
+// Validate returns an error when value cannot be assigned to the parameter.
+// It follows the parsing rules of PostgreSQL. The "pg_settings" view does not
+// list the Boolean aliases of enums, so those are accepted whenever an enum
+// accepts "on" or "off".
+// - https://www.postgresql.org/docs/current/config-setting.html#CONFIG-SETTING-NAMES-VALUES
+func (d ParameterDefinition) Validate(value string) error {
+	if d.Context == ParameterInternal {
+		return fmt.Errorf("%w: %q", ErrParameterReadOnly, d.Name)
+	}
+
+	switch d.Type {
+	case ParameterBool:
+		if !parameterBool(value) {
+			return fmt.Errorf("%q requires a Boolean value", d.Name)
+		}
+
+	case ParameterEnum:
+		for _, allowed := range d.Values {
+			if strings.EqualFold(strings.TrimSpace(value), allowed) {
+				return nil
+			}
+		}
+		for _, allowed := range d.Values {
+			if (allowed == "on" || allowed == "off") && parameterBool(value) {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid value for %q, expected one of %q", d.Name, d.Values)
+
+	case ParameterInteger, ParameterReal:
+		number, ok := parameterNumber(value, d.Unit)
+		if !ok {
+			if d.Unit != "" {
+				return fmt.Errorf("invalid value for %q, expected a number with optional unit, base unit is %q", d.Name, d.Unit)
+			}
+			return fmt.Errorf("invalid value for %q, expected a number", d.Name)
+		}
+		if d.Type == ParameterInteger {
+			number = math.Round(number)
+		}
+		if number < d.Min || number > d.Max {
+			return fmt.Errorf("%v%s is outside the valid range for %q (%v .. %v)",
+				number, d.Unit, d.Name, d.Min, d.Max)
+		}
+	}
+
+	return nil
+}
+
+// parameterBool returns whether or not value is a Boolean accepted by PostgreSQL.
+// - https://git.postgresql.org/gitweb/?p=postgresql.git;f=src/backend/utils/adt/bool.c;hb=REL_13_0#l30
+func parameterBool(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return false
+	}
+	for _, word := range []string{"true", "false", "yes", "no"} {
+		if strings.HasPrefix(word, value) {
+			return true
+		}
+	}
+	switch value {
+	case "on", "of", "off", "1", "0":
+		return true
+	}
+	return false
+}
+
+// parameterUnits are the units of numeric values, longest first, and their
+// multipliers in bytes or milliseconds.
+// - https://git.postgresql.org/gitweb/?p=postgresql.git;f=src/backend/utils/misc/guc.c;hb=REL_13_0#l831
+var parameterUnits = []struct {
+	unit, kind string
+	multiplier float64
+}{
+	{"min", "time", 60000}, {"ms", "time", 1}, {"us", "time", 0.001},
+	{"kB", "memory", 1 << 10}, {"MB", "memory", 1 << 20},
+	{"GB", "memory", 1 << 30}, {"TB", "memory", 1 << 40},
+	{"B", "memory", 1}, {"s", "time", 1000}, {"h", "time", 3600000}, {"d", "time", 86400000},
+}
+
+// parameterNumber parses value as a number with an optional unit and converts
+// it to base, the implicit unit of a parameter.
+func parameterNumber(value, base string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if number, ok := parameterFloat(value); ok || base == "" {
+		return number, ok
+	}
+
+	// The base unit may have a multiplier of its own, e.g. "8kB".
+	baseUnit := strings.TrimLeft(base, "0123456789")
+	baseMultiplier := 1.0
+	if n, err := strconv.Atoi(strings.TrimSuffix(base, baseUnit)); err == nil {
+		baseMultiplier = float64(n)
+	}
+	baseKind := ""
+	for _, u := range parameterUnits {
+		if u.unit == baseUnit {
+			baseKind, baseMultiplier = u.kind, baseMultiplier*u.multiplier
+		}
+	}
+
+	// Units are case-sensitive and must match the kind of the base unit.
+	for _, u := range parameterUnits {
+		if strings.HasSuffix(value, u.unit) {
+			number, ok := parameterFloat(strings.TrimSpace(strings.TrimSuffix(value, u.unit)))
+			return number * u.multiplier / baseMultiplier, ok && u.kind == baseKind
+		}
+	}
+	return 0, false
+}
+
+// parameterFloat parses value as a decimal or hexadecimal number.
+func parameterFloat(value string) (float64, bool) {
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return number, !math.IsNaN(number) && !math.IsInf(number, 0)
+	}
+	if integer, err := strconv.ParseInt(value, 0, 64); err == nil {
+		return float64(integer), true
+	}
+	return 0, false
+}
+
+// parameterFiles are the parameters of each major version of PostgreSQL as
+// reported by its "pg_settings" view. Use "make generate-postgres-parameters"
+// to update them.
+//
+//go:embed parameters/*.csv
+var parameterFiles embed.FS
+
+// parameterCatalog are the definitions of PostgreSQL parameters by major
+// version and name.
+var parameterCatalog = func() map[int]map[string]ParameterDefinition {
+	files, err := parameterFiles.ReadDir("parameters")
+	if err != nil {
+		panic(err)
+	}
+
+	catalog := make(map[int]map[string]ParameterDefinition, len(files))
+	for _, file := range files {
+		var version int
+		if _, err := fmt.Sscanf(file.Name(), "pg%d.csv", &version); err != nil {
+			panic(fmt.Errorf("unexpected file %q: %w", file.Name(), err))
+		}
+
+		f, err := parameterFiles.Open(path.Join("parameters", file.Name()))
+		if err == nil {
+			catalog[version], err = parseParameterDefinitions(f)
+			_ = f.Close()
+		}
+		if err != nil {
+			panic(fmt.Errorf("%s: %w", file.Name(), err))
+		}
+	}
+	return catalog
+}()
+
+// parseParameterDefinitions reads the CSV output of a query on "pg_settings"
+// that has at least the "name", "vartype", "context", "unit", "min_val",
+// "max_val", and "enumvals" columns.
+func parseParameterDefinitions(r io.Reader) (map[string]ParameterDefinition, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("missing header")
+	}
+
+	column := map[string]int{}
+	for i, name := range records[0] {
+		column[name] = i
+	}
+	for _, name := range []string{
+		"name", "vartype", "context", "unit", "min_val", "max_val", "enumvals",
+	} {
+		if _, ok := column[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	definitions := make(map[string]ParameterDefinition, len(records)-1)
+	for _, record := range records[1:] {
+		definition := ParameterDefinition{
+			Name:    record[column["name"]],
+			Type:    ParameterType(record[column["vartype"]]),
+			Context: ParameterContext(record[column["context"]]),
+			Unit:    record[column["unit"]],
+		}
+
+		if s := record[column["min_val"]]; s != "" {
+			if definition.Min, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("%q: %w", definition.Name, err)
+			}
+		}
+		if s := record[column["max_val"]]; s != "" {
+			if definition.Max, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("%q: %w", definition.Name, err)
+			}
+		}
+		if s := record[column["enumvals"]]; s != "" {
+			if definition.Values, err = parseTextArray(s); err != nil {
+				return nil, fmt.Errorf("%q: %w", definition.Name, err)
+			}
+		}
+
+		definitions[definition.Name] = definition
+	}
+	return definitions, nil
+}
+
+// parseTextArray parses the text representation of a one-dimensional
+// PostgreSQL array. Elements are double-quoted when they are empty or contain
+// special characters, and backslashes escape characters within quotes.
+// - https://www.postgresql.org/docs/current/arrays.html#ARRAYS-IO
+func parseTextArray(s string) ([]string, error) {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, fmt.Errorf("malformed array %q", s)
+	}
+	s = s[1 : len(s)-1]
+
+	var elements []string
+	for len(s) > 0 {
+		var element strings.Builder
+		if s[0] == '"' {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+				if i < len(s) {
+					element.WriteByte(s[i])
+				}
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated quote in array %q", s)
+			}
+			s = s[i+1:]
+		} else {
+			i := strings.IndexByte(s, ',')
+			if i < 0 {
+				i = len(s)
+			}
+			element.WriteString(s[:i])
+			s = s[i:]
+		}
+
+		elements = append(elements, element.String())
+		if len(s) > 0 {
+			if s[0] != ',' {
+				return nil, fmt.Errorf("malformed array element %q", s)
+			}
+			s = s[1:]
+		}
+	}
+	return elements, nil
+}
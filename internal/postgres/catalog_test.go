@@ -0,0 +1,212 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLookupParameter(t *testing.T) {
+	definition, ok := LookupParameter(14, "Shared_Buffers")
+	assert.Assert(t, ok)
+	assert.Equal(t, definition.Name, "shared_buffers")
+	assert.Equal(t, definition.Context, ParameterPostmaster)
+
+	// Some parameters exist only in some versions.
+	_, ok = LookupParameter(12, "wal_keep_size")
+	assert.Assert(t, !ok)
+	_, ok = LookupParameter(13, "wal_keep_size")
+	assert.Assert(t, ok)
+	_, ok = LookupParameter(13, "wal_keep_segments")
+	assert.Assert(t, !ok)
+
+	// Some parameters change between versions.
+	definition, _ = LookupParameter(13, "restore_command")
+	assert.Equal(t, definition.Context, ParameterPostmaster)
+	definition, _ = LookupParameter(14, "restore_command")
+	assert.Equal(t, definition.Context, ParameterSighup)
+
+	_, ok = LookupParameter(14, "no_such_thing")
+	assert.Assert(t, !ok)
+}
+
+func TestParameterCatalog(t *testing.T) {
+	// Every supported major version has a catalog.
+	for version := 10; version <= 15; version++ {
+		assert.Assert(t, len(parameterCatalog[version]) > 200, "PostgreSQL %d", version)
+	}
+
+	// Parameters added in each version are there.
+	for version, names := range map[int][]string{
+		10: {"allow_in_place_tablespaces", "data_sync_retry", "replacement_sort_tuples"},
+		13: {"backtrace_functions", "logical_decoding_work_mem"},
+		14: {"debug_discard_caches", "logical_decoding_work_mem"},
+		15: {"logical_decoding_work_mem", "recursive_worktable_factor"},
+	} {
+		for _, name := range names {
+			_, ok := LookupParameter(version, name)
+			assert.Assert(t, ok, "PostgreSQL %d: %q", version, name)
+		}
+	}
+
+	definition, _ := LookupParameter(13, "logical_decoding_work_mem")
+	assert.Equal(t, definition.Context, ParameterUser)
+	assert.Equal(t, definition.Unit, "kB")
+	assert.Equal(t, definition.Min, float64(64))
+
+	definition, _ = LookupParameter(15, "recursive_worktable_factor")
+	assert.Equal(t, definition.Type, ParameterReal)
+	assert.Equal(t, definition.Min, 0.001)
+	assert.Equal(t, definition.Max, float64(1e6))
+
+	// Aliases that PostgreSQL hides are not listed.
+	for version := 10; version <= 15; version++ {
+		definition, _ := LookupParameter(version, "wal_level")
+		assert.DeepEqual(t, definition.Values, []string{"minimal", "replica", "logical"})
+	}
+
+	definition, _ = LookupParameter(14, "ssl_max_protocol_version")
+	assert.DeepEqual(t, definition.Values,
+		[]string{"", "TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3"})
+
+	definition, _ = LookupParameter(14, "seq_page_cost")
+	assert.Equal(t, definition.Type, ParameterReal)
+	assert.Equal(t, definition.Min, float64(0))
+	assert.Assert(t, definition.Max > 1e300)
+}
+
+func TestParseParameterDefinitions(t *testing.T) {
+	definitions, err := parseParameterDefinitions(strings.NewReader(strings.Join([]string{
+		`name,vartype,context,unit,min_val,max_val,enumvals,extra`,
+		`work_mem,integer,user,kB,64,2147483647,,x`,
+		`xmloption,enum,user,,,,"{content,document}",y`,
+	}, "\n")))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, definitions, map[string]ParameterDefinition{
+		"work_mem": {
+			Name: "work_mem", Type: ParameterInteger, Context: ParameterUser,
+			Unit: "kB", Min: 64, Max: 2147483647,
+		},
+		"xmloption": {
+			Name: "xmloption", Type: ParameterEnum, Context: ParameterUser,
+			Values: []string{"content", "document"},
+		},
+	})
+
+	_, err = parseParameterDefinitions(strings.NewReader("name,vartype\nwork_mem,integer"))
+	assert.ErrorContains(t, err, `missing column "context"`)
+
+	_, err = parseParameterDefinitions(strings.NewReader(strings.Join([]string{
+		`name,vartype,context,unit,min_val,max_val,enumvals`,
+		`work_mem,integer,user,kB,lots,2147483647,`,
+	}, "\n")))
+	assert.ErrorContains(t, err, `"work_mem"`)
+}
+
+func TestParseTextArray(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		output []string
+	}{
+		{`{}`, nil},
+		{`{one}`, []string{"one"}},
+		{`{one,two-three}`, []string{"one", "two-three"}},
+		{`{"",TLSv1}`, []string{"", "TLSv1"}},
+		{`{"a b","c,d","e\"f","g\\h"}`, []string{"a b", "c,d", `e"f`, `g\h`}},
+	} {
+		output, err := parseTextArray(tt.input)
+		assert.NilError(t, err, "%q", tt.input)
+		assert.DeepEqual(t, output, tt.output)
+	}
+
+	for _, input := range []string{``, `one`, `{"one}`, `{"one"two}`} {
+		_, err := parseTextArray(input)
+		assert.Assert(t, err != nil, "%q", input)
+	}
+}
+
+func TestValidateParameter(t *testing.T) {
+	for _, tt := range []struct {
+		version     int
+		name, value string
+		valid       bool
+	}{
+		{14, "jit", "on", true},
+		{14, "jit", "Of", true},
+		{14, "jit", "t", true},
+		{14, "jit", "o", false},
+		{14, "jit", "maybe", false},
+
+		{14, "huge_pages", "TRY", true},
+		{14, "huge_pages", "sometimes", false},
+		{14, "synchronous_commit", "true", true},
+		{14, "synchronous_commit", "remote_apply", true},
+		{14, "xmloption", "yes", false},
+		{13, "password_encryption", "md5", true},
+		{14, "password_encryption", "on", false},
+		{14, "password_encryption", "scram-sha-256", true},
+
+		{14, "max_connections", "100", true},
+		{14, "max_connections", "0", false},
+		{14, "max_connections", "1e3", true},
+		{14, "max_connections", "100kB", false},
+		{14, "max_connections", "lots", false},
+
+		{14, "shared_buffers", "128MB", true},
+		{14, "shared_buffers", "16384", true},
+		{14, "shared_buffers", "64kB", false},
+		{14, "shared_buffers", "128 MB", true},
+		{14, "shared_buffers", "128mb", false},
+		{14, "shared_buffers", "128ms", false},
+
+		{14, "statement_timeout", "1min", true},
+		{14, "statement_timeout", "500", true},
+		{14, "statement_timeout", "-1", false},
+		{14, "checkpoint_timeout", "10s", false},
+		{14, "checkpoint_timeout", "1h", true},
+
+		{14, "random_page_cost", "1.1", true},
+		{14, "random_page_cost", "-1", false},
+		{14, "random_page_cost", "NaN", false},
+		{14, "checkpoint_completion_target", "0.9", true},
+		{14, "checkpoint_completion_target", "1.5", false},
+
+		{14, "log_line_prefix", "%m [%p]", true},
+		{14, "pgaudit.log", "all", true},
+		{14, "block_size", "8192", false},
+	} {
+		err := ValidateParameter(tt.version, tt.name, tt.value)
+		if tt.valid {
+			assert.NilError(t, err, "%v %q=%q", tt.version, tt.name, tt.value)
+		} else {
+			assert.Assert(t, err != nil, "%v %q=%q", tt.version, tt.name, tt.value)
+		}
+	}
+
+	err := ValidateParameter(14, "no_such_thing", "1")
+	assert.Assert(t, errors.Is(err, ErrParameterUnknown))
+	assert.ErrorContains(t, err, `"no_such_thing" in PostgreSQL 14`)
+
+	err = ValidateParameter(14, "block_size", "1")
+	assert.Assert(t, errors.Is(err, ErrParameterReadOnly))
+
+	// Versions without a catalog are not checked.
+	assert.NilError(t, ValidateParameter(99, "no_such_thing", "1"))
+}
@@ -19,8 +19,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // CreateDatabasesInPostgreSQL calls exec to create databases that do not exist
@@ -81,3 +83,275 @@ SELECT pg_catalog.format('CREATE DATABASE %I',
 
 	return err
 }
+
+// WriteDatabasesInPostgreSQL calls exec to create databases that do not exist
+// in PostgreSQL using the encoding, locale, and template in their specification.
+// Once they exist, it updates their connection limits.
+func WriteDatabasesInPostgreSQL(
+	ctx context.Context, exec Executor, databases []v1beta1.PostgresDatabaseSpec,
+) error {
+	log := logging.FromContext(ctx)
+
+	var err error
+	var sql bytes.Buffer
+
+	// Prevent unexpected dereferences by emptying "search_path". The "pg_catalog"
+	// schema is still searched, and only temporary objects can be created.
+	// - https://www.postgresql.org/docs/current/runtime-config-client.html#GUC-SEARCH-PATH
+	_, _ = sql.WriteString(`SET search_path TO '';`)
+
+	// Fill a temporary table with the JSON of the database specifications.
+	// "\copy" reads from subsequent lines until the special line "\.".
+	// - https://www.postgresql.org/docs/current/app-psql.html#APP-PSQL-META-COMMANDS-COPY
+	_, _ = sql.WriteString(`
+CREATE TEMPORARY TABLE input (id serial, data json);
+\copy input (data) from stdin with (format text)
+`)
+
+	encoder := json.NewEncoder(&sql)
+	encoder.SetEscapeHTML(false)
+
+	for i := range databases {
+		spec := databases[i]
+
+		// Omit unspecified fields so they are NULL in the SQL below.
+		data := map[string]interface{}{
+			"database": spec.Name,
+		}
+		if spec.ConnectionLimit != nil {
+			data["connection_limit"] = *spec.ConnectionLimit
+		}
+		if spec.Encoding != "" {
+			data["encoding"] = spec.Encoding
+		}
+		if spec.LCCollate != "" {
+			data["lc_collate"] = spec.LCCollate
+		}
+		if spec.LCCType != "" {
+			data["lc_ctype"] = spec.LCCType
+		}
+		if spec.Template != "" {
+			data["template"] = spec.Template
+		}
+
+		if err == nil {
+			err = encoder.Encode(data)
+		}
+	}
+	_, _ = sql.WriteString(`\.` + "\n")
+
+	// Create databases that do not already exist. The "||" operator returns
+	// NULL when either side is NULL, and "concat_ws" skips NULL arguments,
+	// so unspecified options are left out of the statement.
+	// - https://www.postgresql.org/docs/current/sql-createdatabase.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.concat_ws(' ', 'CREATE DATABASE',
+       pg_catalog.quote_ident(pg_catalog.json_extract_path_text(input.data, 'database')),
+       'TEMPLATE ' || pg_catalog.quote_ident(pg_catalog.json_extract_path_text(input.data, 'template')),
+       'ENCODING ' || pg_catalog.quote_literal(pg_catalog.json_extract_path_text(input.data, 'encoding')),
+       'LC_COLLATE ' || pg_catalog.quote_literal(pg_catalog.json_extract_path_text(input.data, 'lc_collate')),
+       'LC_CTYPE ' || pg_catalog.quote_literal(pg_catalog.json_extract_path_text(input.data, 'lc_ctype')))
+  FROM input
+ WHERE NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_database
+       WHERE datname = pg_catalog.json_extract_path_text(input.data, 'database'))
+ ORDER BY input.id
+\gexec
+`)
+
+	// Set any connection limits from the specification.
+	// - https://www.postgresql.org/docs/current/sql-alterdatabase.html
+	_, _ = sql.WriteString(`
+SELECT pg_catalog.format('ALTER DATABASE %I WITH CONNECTION LIMIT %s',
+       pg_catalog.json_extract_path_text(input.data, 'database'),
+       pg_catalog.json_extract_path_text(input.data, 'connection_limit')::integer)
+  FROM input
+ WHERE pg_catalog.json_extract_path_text(input.data, 'connection_limit') IS NOT NULL
+ ORDER BY input.id
+\gexec
+`)
+
+	stdout, stderr, err := exec.Exec(ctx, &sql,
+		map[string]string{
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("wrote PostgreSQL databases", "stdout", stdout, "stderr", stderr)
+
+	return err
+}
+
+// WriteDatabaseOwnersInPostgreSQL calls exec to change the owner of databases
+// that specify one. The databases and their owners must already exist.
+func WriteDatabaseOwnersInPostgreSQL(
+	ctx context.Context, exec Executor, databases []v1beta1.PostgresDatabaseSpec,
+) error {
+	log := logging.FromContext(ctx)
+
+	owners := make(map[string]string, len(databases))
+	for _, spec := range databases {
+		if spec.Owner != "" {
+			owners[string(spec.Name)] = string(spec.Owner)
+		}
+	}
+	input, err := json.Marshal(owners)
+
+	// Change the owner of databases that are owned by someone else. The new
+	// owner gains every privilege on the database, including the ability to
+	// drop it.
+	// - https://www.postgresql.org/docs/current/sql-alterdatabase.html
+	sql := strings.TrimSpace(`
+SET search_path TO '';
+SELECT pg_catalog.format('ALTER DATABASE %I OWNER TO %I', d.datname, o.value)
+  FROM pg_catalog.json_each_text(:'owners') AS o
+  JOIN pg_catalog.pg_database AS d ON d.datname = o.key
+ WHERE pg_catalog.pg_get_userbyid(d.datdba) <> o.value
+ ORDER BY d.datname
+\gexec
+`)
+
+	if err == nil {
+		var stdout, stderr string
+		stdout, stderr, err = exec.Exec(ctx, strings.NewReader(sql),
+			map[string]string{
+				"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+				"QUIET":         "on", // Do not print successful statements to stdout.
+				"owners":        string(input),
+			})
+
+		log.V(1).Info("wrote PostgreSQL database owners", "stdout", stdout, "stderr", stderr)
+	}
+
+	return err
+}
+
+// Extension is a PostgreSQL extension to install in a database.
+type Extension struct {
+	Name string `json:"name"`
+
+	// The schema in which to install the extension. When empty, the extension
+	// is installed in the schema it specifies or the default creation schema.
+	Schema string `json:"schema,omitempty"`
+
+	// The version to install and, with Update, to update to. When empty, the
+	// version is the default or latest version available.
+	Version string `json:"version,omitempty"`
+
+	// Whether or not an extension that is already installed should be
+	// updated to Version.
+	Update bool `json:"update,omitempty"`
+}
+
+// WriteExtensionsInPostgreSQL calls exec to install the extensions specified
+// for each database and then update them to their specified version. An
+// update without a version goes to the latest version available.
+func WriteExtensionsInPostgreSQL(
+	ctx context.Context, exec Executor, databases []v1beta1.PostgresDatabaseSpec,
+) error {
+	extensions := make(map[string][]Extension, len(databases))
+	for _, spec := range databases {
+		for _, extension := range spec.Extensions {
+			extensions[string(spec.Name)] = append(extensions[string(spec.Name)], Extension{
+				Name:    string(extension.Name),
+				Schema:  string(extension.Schema),
+				Version: extension.Version,
+				Update:  true,
+			})
+		}
+	}
+	return WriteExtensionsInDatabases(ctx, exec, extensions)
+}
+
+// WriteExtensionsInDatabases calls exec to install extensions in the databases
+// that are the keys of extensions.
+func WriteExtensionsInDatabases(
+	ctx context.Context, exec Executor, extensions map[string][]Extension,
+) error {
+	log := logging.FromContext(ctx)
+
+	// Each database reads its own list from this JSON object.
+	input, err := json.Marshal(extensions)
+
+	if err == nil {
+		var stdout, stderr string
+		stdout, stderr, err = exec.ExecInDatabasesFromQuery(ctx,
+			`SELECT pg_catalog.json_object_keys(:'extensions')`,
+			extensionsSQL(`pg_catalog.json_extract_path(:'extensions', pg_catalog.current_database())`),
+			map[string]string{
+				"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+				"QUIET":         "on", // Do not print successful statements to stdout.
+				"extensions":    string(input),
+			})
+
+		log.V(1).Info("wrote PostgreSQL extensions", "stdout", stdout, "stderr", stderr)
+	}
+
+	return err
+}
+
+// WriteExtensionsInAllDatabases calls exec to install extensions in every
+// database, including "template1" so that future databases have them, too.
+func WriteExtensionsInAllDatabases(
+	ctx context.Context, exec Executor, extensions []Extension,
+) error {
+	log := logging.FromContext(ctx)
+
+	input, err := json.Marshal(extensions)
+
+	if err == nil {
+		var stdout, stderr string
+		stdout, stderr, err = exec.ExecInAllDatabases(ctx,
+			extensionsSQL(`:'extensions'::json`),
+			map[string]string{
+				"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+				"QUIET":         "on", // Do not print successful statements to stdout.
+				"extensions":    string(input),
+			})
+
+		log.V(1).Info("wrote PostgreSQL extensions", "stdout", stdout, "stderr", stderr)
+	}
+
+	return err
+}
+
+// extensionsSQL returns SQL that installs and updates the extensions in the
+// JSON array produced by input.
+func extensionsSQL(input string) string {
+	// - https://www.postgresql.org/docs/current/sql-createextension.html
+	// - https://www.postgresql.org/docs/current/sql-alterextension.html
+	return `
+SET search_path TO '';
+
+-- Quiet NOTICE messages from IF NOT EXISTS and UPDATE statements.
+-- - https://www.postgresql.org/docs/current/runtime-config-client.html
+SET client_min_messages = WARNING;
+
+-- Keep the order of the input; some extensions depend on others.
+CREATE TEMPORARY TABLE input AS
+SELECT * FROM ROWS FROM (pg_catalog.json_to_recordset(` + input + `)
+       AS (name text, schema text, version text, update boolean))
+  WITH ORDINALITY AS input (name, schema, version, update, id);
+
+-- Extensions without a schema are created in the default creation schema,
+-- so restore the search_path before creating them. Everything below is
+-- schema-qualified.
+RESET search_path;
+
+SELECT pg_catalog.concat_ws(' ', 'CREATE EXTENSION IF NOT EXISTS',
+       pg_catalog.quote_ident(input.name),
+       'SCHEMA ' || pg_catalog.quote_ident(input.schema),
+       'VERSION ' || pg_catalog.quote_literal(input.version))
+  FROM pg_temp.input
+ ORDER BY input.id
+\gexec
+
+SELECT pg_catalog.concat_ws(' ', 'ALTER EXTENSION',
+       pg_catalog.quote_ident(input.name), 'UPDATE',
+       'TO ' || pg_catalog.quote_literal(input.version))
+  FROM pg_temp.input
+ WHERE input.update
+ ORDER BY input.id
+\gexec
+`
+}
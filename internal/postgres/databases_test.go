@@ -24,7 +24,9 @@ import (
 
 	"gotest.tools/v3/assert"
 
+	"github.com/crunchydata/postgres-operator/internal/initialize"
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestCreateDatabasesInPostgreSQL(t *testing.T) {
@@ -101,3 +103,248 @@ SELECT pg_catalog.format('CREATE DATABASE %I',
 		assert.Equal(t, calls, 1)
 	})
 }
+
+func TestWriteDatabasesInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			return expected
+		}
+
+		assert.Equal(t, expected, WriteDatabasesInPostgreSQL(ctx, exec, nil))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimLeft(`
+SET search_path TO '';
+CREATE TEMPORARY TABLE input (id serial, data json);
+\copy input (data) from stdin with (format text)
+\.
+
+SELECT pg_catalog.concat_ws(' ', 'CREATE DATABASE',
+       pg_catalog.quote_ident(pg_catalog.json_extract_path_text(input.data, 'database')),
+       'TEMPLATE ' || pg_catalog.quote_ident(pg_catalog.json_extract_path_text(input.data, 'template')),
+       'ENCODING ' || pg_catalog.quote_literal(pg_catalog.json_extract_path_text(input.data, 'encoding')),
+       'LC_COLLATE ' || pg_catalog.quote_literal(pg_catalog.json_extract_path_text(input.data, 'lc_collate')),
+       'LC_CTYPE ' || pg_catalog.quote_literal(pg_catalog.json_extract_path_text(input.data, 'lc_ctype')))
+  FROM input
+ WHERE NOT EXISTS (
+       SELECT 1 FROM pg_catalog.pg_database
+       WHERE datname = pg_catalog.json_extract_path_text(input.data, 'database'))
+ ORDER BY input.id
+\gexec
+
+SELECT pg_catalog.format('ALTER DATABASE %I WITH CONNECTION LIMIT %s',
+       pg_catalog.json_extract_path_text(input.data, 'database'),
+       pg_catalog.json_extract_path_text(input.data, 'connection_limit')::integer)
+  FROM input
+ WHERE pg_catalog.json_extract_path_text(input.data, 'connection_limit') IS NOT NULL
+ ORDER BY input.id
+\gexec
+`, "\n"))
+			return nil
+		}
+
+		assert.NilError(t, WriteDatabasesInPostgreSQL(ctx, exec, nil))
+		assert.Equal(t, calls, 1)
+
+		assert.NilError(t, WriteDatabasesInPostgreSQL(ctx, exec, []v1beta1.PostgresDatabaseSpec{}))
+		assert.Equal(t, calls, 2)
+	})
+
+	t.Run("OptionalFields", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(string(b), `
+\copy input (data) from stdin with (format text)
+{"database":"only-name"}
+{"connection_limit":-1,"database":"everything","encoding":"UTF8","lc_collate":"C","lc_ctype":"en_US.utf8","template":"template0"}
+\.
+`))
+			return nil
+		}
+
+		assert.NilError(t, WriteDatabasesInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresDatabaseSpec{
+				{Name: "only-name", Owner: "ignored"},
+				{
+					Name:            "everything",
+					ConnectionLimit: initialize.Int32(-1),
+					Encoding:        "UTF8",
+					LCCollate:       "C",
+					LCCType:         "en_US.utf8",
+					Template:        "template0",
+				},
+			},
+		))
+		assert.Equal(t, calls, 1)
+	})
+}
+
+func TestWriteDatabaseOwnersInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			return expected
+		}
+
+		assert.Equal(t, expected, WriteDatabaseOwnersInPostgreSQL(ctx, exec, nil))
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimSpace(`
+SET search_path TO '';
+SELECT pg_catalog.format('ALTER DATABASE %I OWNER TO %I', d.datname, o.value)
+  FROM pg_catalog.json_each_text(:'owners') AS o
+  JOIN pg_catalog.pg_database AS d ON d.datname = o.key
+ WHERE pg_catalog.pg_get_userbyid(d.datdba) <> o.value
+ ORDER BY d.datname
+\gexec`))
+			assert.Assert(t, cmp.Contains(command, `--set=owners={"db1":"app","db3":"other"}`))
+			return nil
+		}
+
+		assert.NilError(t, WriteDatabaseOwnersInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresDatabaseSpec{
+				{Name: "db3", Owner: "other"},
+				{Name: "db2"},
+				{Name: "db1", Owner: "app"},
+			},
+		))
+		assert.Equal(t, calls, 1)
+	})
+}
+
+func TestWriteExtensionsInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			return expected
+		}
+
+		assert.Equal(t, expected, WriteExtensionsInPostgreSQL(ctx, exec, nil))
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+
+			// Only databases with extensions are visited.
+			assert.Assert(t, cmp.Contains(command,
+				`SELECT pg_catalog.json_object_keys(:'extensions')`))
+			assert.Assert(t, cmp.Contains(command, `--set=extensions=`+
+				`{"db1":[{"name":"postgis","version":"3.3.2","update":true},`+
+				`{"name":"pg_trgm","schema":"public","update":true}]}`))
+
+			assert.Assert(t, cmp.Contains(string(b),
+				`pg_catalog.json_extract_path(:'extensions', pg_catalog.current_database())`))
+			assert.Assert(t, cmp.Contains(string(b), `
+SELECT pg_catalog.concat_ws(' ', 'CREATE EXTENSION IF NOT EXISTS',
+       pg_catalog.quote_ident(input.name),
+       'SCHEMA ' || pg_catalog.quote_ident(input.schema),
+       'VERSION ' || pg_catalog.quote_literal(input.version))
+  FROM pg_temp.input
+ ORDER BY input.id
+\gexec
+`))
+			assert.Assert(t, cmp.Contains(string(b), `
+SELECT pg_catalog.concat_ws(' ', 'ALTER EXTENSION',
+       pg_catalog.quote_ident(input.name), 'UPDATE',
+       'TO ' || pg_catalog.quote_literal(input.version))
+  FROM pg_temp.input
+ WHERE input.update
+ ORDER BY input.id
+\gexec
+`))
+			return nil
+		}
+
+		assert.NilError(t, WriteExtensionsInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresDatabaseSpec{
+				{
+					Name: "db1",
+					Extensions: []v1beta1.PostgresExtensionSpec{
+						{Name: "postgis", Version: "3.3.2"},
+						{Name: "pg_trgm", Schema: "public"},
+					},
+				},
+				{Name: "db2"},
+			},
+		))
+		assert.Equal(t, calls, 1)
+	})
+}
+
+func TestWriteExtensionsInAllDatabases(t *testing.T) {
+	ctx := context.Background()
+
+	calls := 0
+	exec := func(
+		_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+	) error {
+		calls++
+
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+
+		assert.Assert(t, cmp.Contains(strings.Join(command, "\n"),
+			`SELECT datname FROM pg_catalog.pg_database`,
+		), "expected all databases and templates")
+		assert.Assert(t, cmp.Contains(command, `--set=extensions=`+
+			`[{"name":"first"},{"name":"second","schema":"monitor","update":true}]`))
+		assert.Assert(t, cmp.Contains(string(b), `pg_catalog.json_to_recordset(:'extensions'::json)`))
+		return nil
+	}
+
+	assert.NilError(t, WriteExtensionsInAllDatabases(ctx, exec, []Extension{
+		{Name: "first"},
+		{Name: "second", Schema: "monitor", Update: true},
+	}))
+	assert.Equal(t, calls, 1)
+}
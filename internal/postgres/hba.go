@@ -17,7 +17,10 @@ package postgres
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // NewHBAs returns HostBasedAuthentication records required by this package.
@@ -46,8 +49,62 @@ func NewHBAs() HBAs {
 	}
 }
 
-// HBAs is a pairing of HostBasedAuthentication records.
-type HBAs struct{ Mandatory, Default []HostBasedAuthentication }
+// HBAs are groups of HostBasedAuthentication records: those that are required,
+// those specified in the cluster spec, and those that are recommended.
+type HBAs struct{ Mandatory, Specified, Default []HostBasedAuthentication }
+
+// AuthenticationHBAs populates outHBAs with the rules in the authentication
+// section of cluster.
+func AuthenticationHBAs(cluster *v1beta1.PostgresCluster, outHBAs *HBAs) {
+	if cluster.Spec.Authentication == nil {
+		return
+	}
+
+	for _, rule := range cluster.Spec.Authentication.Rules {
+		hba := NewHBA()
+
+		switch rule.Connection {
+		case "local":
+			hba.Local()
+		case "host":
+			hba.TCP()
+		case "hostnossl":
+			hba.NoSSL()
+		default:
+			hba.TLS()
+		}
+
+		if len(rule.Databases) > 0 {
+			names := make([]string, len(rule.Databases))
+			for i := range rule.Databases {
+				names[i] = string(rule.Databases[i])
+			}
+			hba.Databases(names...)
+		}
+		if len(rule.Users) > 0 {
+			names := make([]string, len(rule.Users))
+			for i := range rule.Users {
+				names[i] = string(rule.Users[i])
+			}
+			hba.Users(names...)
+		}
+
+		switch rule.Network {
+		case "":
+		case "samenet":
+			hba.SameNetwork()
+		default:
+			hba.Network(rule.Network)
+		}
+
+		hba.Method(rule.Method)
+		if len(rule.Options) > 0 {
+			hba.Options(rule.Options)
+		}
+
+		outHBAs.Specified = append(outHBAs.Specified, *hba)
+	}
+}
 
 // HostBasedAuthentication represents a single record for pg_hba.conf.
 // - https://www.postgresql.org/docs/current/auth-pg-hba-conf.html
@@ -88,6 +145,27 @@ func (hba *HostBasedAuthentication) Database(name string) *HostBasedAuthenticati
 	return hba
 }
 
+// UserHBAs populates outHBAs with the rules needed by the users in cluster.
+// Users with a client certificate must use it on every TLS connection.
+func UserHBAs(cluster *v1beta1.PostgresCluster, outHBAs *HBAs) {
+	for _, user := range cluster.Spec.Users {
+		if user.ClientCertificate {
+			outHBAs.Mandatory = append(outHBAs.Mandatory,
+				*NewHBA().TLS().User(string(user.Name)).Method("cert"))
+		}
+	}
+}
+
+// Databases makes hba match connections made to any of the specified databases.
+func (hba *HostBasedAuthentication) Databases(names ...string) *HostBasedAuthentication {
+	quoted := make([]string, len(names))
+	for i := range names {
+		quoted[i] = hba.quote(names[i])
+	}
+	hba.database = strings.Join(quoted, ",")
+	return hba
+}
+
 // Local makes hba match connection attempts using Unix-domain sockets.
 func (hba *HostBasedAuthentication) Local() *HostBasedAuthentication {
 	hba.origin = "local"
@@ -114,9 +192,18 @@ func (hba *HostBasedAuthentication) NoSSL() *HostBasedAuthentication {
 
 // Options specifies any options for the authentication method.
 func (hba *HostBasedAuthentication) Options(opts map[string]string) *HostBasedAuthentication {
+	// The map iteration below is nondeterministic. Sort the keys so that
+	// the options are always written in the same order.
+	// - https://golang.org/ref/spec#For_range
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	hba.options = ""
-	for k, v := range opts {
-		hba.options = fmt.Sprintf("%s %s=%s", hba.options, k, hba.quote(v))
+	for _, k := range keys {
+		hba.options = fmt.Sprintf("%s %s=%s", hba.options, k, hba.quote(opts[k]))
 	}
 	return hba
 }
@@ -158,6 +245,16 @@ func (hba *HostBasedAuthentication) User(name string) *HostBasedAuthentication {
 	return hba
 }
 
+// Users makes hba match connections by any of the specified users.
+func (hba *HostBasedAuthentication) Users(names ...string) *HostBasedAuthentication {
+	quoted := make([]string, len(names))
+	for i := range names {
+		quoted[i] = hba.quote(names[i])
+	}
+	hba.user = strings.Join(quoted, ",")
+	return hba
+}
+
 // String returns hba formatted for the pg_hba.conf file without a newline.
 func (hba HostBasedAuthentication) String() string {
 	if hba.origin == "local" {
@@ -22,6 +22,7 @@ import (
 	"gotest.tools/v3/assert"
 
 	"github.com/crunchydata/postgres-operator/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestNewHBAs(t *testing.T) {
@@ -70,4 +71,82 @@ func TestHostBasedAuthentication(t *testing.T) {
 
 	assert.Equal(t, `hostnossl all all all reject`,
 		NewHBA().NoSSL().Method("reject").String())
+
+	assert.Equal(t, `hostssl "one","two" "a","b" all md5`,
+		NewHBA().TLS().Databases("one", "two").Users("a", "b").Method("md5").String())
+
+	// Options are sorted.
+	assert.Equal(t, `host all all all ldap  ldapbasedn="dc=example" ldapport="389" ldapserver="ldap"`,
+		NewHBA().TCP().Method("ldap").Options(map[string]string{
+			"ldapserver": "ldap", "ldapbasedn": "dc=example", "ldapport": "389",
+		}).String())
+}
+
+func TestAuthenticationHBAs(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+
+	// Nothing specified.
+	hbas := HBAs{}
+	AuthenticationHBAs(cluster, &hbas)
+	assert.Assert(t, hbas.Specified == nil)
+
+	cluster.Spec.Authentication = &v1beta1.PostgresAuthenticationSpec{
+		Rules: []v1beta1.PostgresHBARuleSpec{
+			{Method: "scram-sha-256"},
+			{
+				Connection: "local",
+				Databases:  []v1beta1.PostgresIdentifier{"app"},
+				Method:     "peer",
+				Options:    map[string]string{"map": "admins"},
+			},
+			{
+				Connection: "host",
+				Users:      []v1beta1.PostgresIdentifier{"alice", "bob"},
+				Network:    "10.0.0.0/8",
+				Method:     "gss",
+				Options:    map[string]string{"include_realm": "0"},
+			},
+			{
+				Connection: "hostnossl",
+				Network:    "samenet",
+				Method:     "reject",
+			},
+			{
+				Connection: "hostssl",
+				Method:     "cert",
+				Options:    map[string]string{"clientname": "DN"},
+			},
+		},
+	}
+
+	hbas = HBAs{}
+	AuthenticationHBAs(cluster, &hbas)
+
+	printed := make([]string, len(hbas.Specified))
+	for i := range hbas.Specified {
+		printed[i] = hbas.Specified[i].String()
+	}
+	assert.DeepEqual(t, printed, []string{
+		`hostssl all all all scram-sha-256`,
+		`local "app" all peer  map="admins"`,
+		`host all "alice","bob" "10.0.0.0/8" gss  include_realm="0"`,
+		`hostnossl all all samenet reject`,
+		`hostssl all all all cert  clientname="DN"`,
+	})
+}
+
+func TestUserHBAs(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.Users = []v1beta1.PostgresUserSpec{
+		{Name: "app", ClientCertificate: true},
+		{Name: "other"},
+	}
+
+	hbas := HBAs{Mandatory: []HostBasedAuthentication{*NewHBA().Local().Method("peer")}}
+	UserHBAs(cluster, &hbas)
+
+	assert.Equal(t, len(hbas.Mandatory), 2)
+	assert.Equal(t, hbas.Mandatory[1].String(), `hostssl all "app" all cert`)
+	assert.Assert(t, hbas.Specified == nil)
+	assert.Assert(t, hbas.Default == nil)
 }
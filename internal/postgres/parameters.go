@@ -16,13 +16,21 @@
 package postgres
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/crunchydata/postgres-operator/internal/logging"
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 // NewParameters returns ParameterSets required by this package.
 func NewParameters() Parameters {
 	parameters := Parameters{
 		Mandatory: NewParameterSet(),
+		Specified: NewParameterSet(),
 		Default:   NewParameterSet(),
 	}
 
@@ -59,8 +67,115 @@ func NewParameters() Parameters {
 	return parameters
 }
 
-// Parameters is a pairing of ParameterSets.
-type Parameters struct{ Mandatory, Default *ParameterSet }
+// Parameters are groups of ParameterSets: those that are required, those
+// specified in the cluster spec, and those that are recommended.
+type Parameters struct{ Mandatory, Specified, Default *ParameterSet }
+
+// ConfigParameters populates outParameters with the parameters in the config
+// section of cluster. Parameters that are not known to the PostgreSQL version
+// of cluster and values that are not valid there are skipped. It returns an
+// error for each of those.
+func ConfigParameters(cluster *v1beta1.PostgresCluster, outParameters *Parameters) []error {
+	if outParameters.Specified == nil {
+		outParameters.Specified = NewParameterSet()
+	}
+
+	names := make([]string, 0, len(cluster.Spec.Config.Parameters))
+	for name := range cluster.Spec.Config.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		value := cluster.Spec.Config.Parameters[name]
+		err := ValidateParameter(cluster.Spec.PostgresVersion, name, value.String())
+
+		if err == nil {
+			outParameters.Specified.Add(name, value.String())
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ErrParameterClusterWide is returned for parameters that cannot differ between
+// instances of a cluster.
+var ErrParameterClusterWide = errors.New("parameter must be the same on every instance")
+
+// clusterWideParameters must be the same on every instance of a cluster.
+// Patroni takes these from its dynamic configuration and ignores them in the
+// configuration of each instance, in part because PostgreSQL requires some to
+// be at least as large on a standby as they are on the primary. The operator
+// adds to shared_preload_libraries, so that is here, too.
+// - https://github.com/zalando/patroni/blob/v3.0.2/patroni/postgresql/config.py#L293
+// - https://www.postgresql.org/docs/current/hot-standby.html#HOT-STANDBY-ADMIN
+var clusterWideParameters = map[string]bool{
+	"cluster_name":              true,
+	"hot_standby":               true,
+	"listen_addresses":          true,
+	"max_connections":           true,
+	"max_locks_per_transaction": true,
+	"max_prepared_transactions": true,
+	"max_replication_slots":     true,
+	"max_wal_senders":           true,
+	"max_worker_processes":      true,
+	"port":                      true,
+	"shared_preload_libraries":  true,
+	"track_commit_timestamp":    true,
+	"wal_keep_segments":         true,
+	"wal_keep_size":             true,
+	"wal_level":                 true,
+	"wal_log_hints":             true,
+}
+
+// ParameterClusterWide returns whether or not parameter name must be the same
+// on every instance of a cluster.
+func ParameterClusterWide(name string) bool {
+	return clusterWideParameters[strings.ToLower(name)]
+}
+
+// InstanceConfigParameters returns the parameters in the config section of
+// instance. Parameters in inParameters.Mandatory are skipped. Parameters that
+// must be the same on every instance, that are not known to the PostgreSQL
+// version of cluster, or whose values are not valid there are skipped, too.
+// It returns an error for each of those.
+func InstanceConfigParameters(
+	cluster *v1beta1.PostgresCluster, instance *v1beta1.PostgresInstanceSetSpec,
+	inParameters Parameters,
+) (*ParameterSet, []error) {
+	out := NewParameterSet()
+	if instance.Config == nil {
+		return out, nil
+	}
+
+	names := make([]string, 0, len(instance.Config.Parameters))
+	for name := range instance.Config.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if inParameters.Mandatory != nil && inParameters.Mandatory.Has(name) {
+			continue
+		}
+
+		value := instance.Config.Parameters[name]
+		err := ValidateParameter(cluster.Spec.PostgresVersion, name, value.String())
+
+		if err == nil && ParameterClusterWide(name) {
+			err = fmt.Errorf("%w: %q", ErrParameterClusterWide, name)
+		}
+		if err == nil {
+			out.Add(name, value.String())
+		} else {
+			errs = append(errs, fmt.Errorf("instance set %q: %w", instance.Name, err))
+		}
+	}
+	return out, errs
+}
 
 // ParameterSet is a collection of PostgreSQL parameters.
 // - https://www.postgresql.org/docs/current/config-setting.html
@@ -119,3 +234,33 @@ func (ps ParameterSet) Value(name string) string {
 	value, _ := ps.Get(name)
 	return value
 }
+
+// PendingRestartParameters calls exec to return the names of parameters that
+// have changed in the configuration files but take effect only after
+// PostgreSQL restarts.
+// - https://www.postgresql.org/docs/current/view-pg-settings.html
+func PendingRestartParameters(ctx context.Context, exec Executor) ([]string, error) {
+	log := logging.FromContext(ctx)
+
+	// Print one name per line.
+	sql := strings.TrimSpace(`
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+SELECT name FROM pg_catalog.pg_settings WHERE pending_restart ORDER BY name
+`)
+
+	stdout, stderr, err := exec.Exec(ctx, strings.NewReader(sql),
+		map[string]string{
+			"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+			"QUIET":         "on", // Do not print successful statements to stdout.
+		})
+
+	log.V(1).Info("read pending restart parameters", "stdout", stdout, "stderr", stderr)
+
+	var names []string
+	if err == nil {
+		names = strings.Fields(stdout)
+	}
+	return names, err
+}
@@ -16,9 +16,15 @@
 package postgres
 
 import (
+	"context"
+	"errors"
+	"io"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
 func TestNewParameters(t *testing.T) {
@@ -34,6 +40,7 @@ func TestNewParameters(t *testing.T) {
 
 		"wal_level": "logical",
 	})
+	assert.DeepEqual(t, parameters.Specified.AsMap(), map[string]string{})
 	assert.DeepEqual(t, parameters.Default.AsMap(), map[string]string{
 		"jit": "off",
 
@@ -41,6 +48,78 @@ func TestNewParameters(t *testing.T) {
 	})
 }
 
+func TestConfigParameters(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 14
+
+	parameters := Parameters{}
+	assert.Assert(t, len(ConfigParameters(cluster, &parameters)) == 0)
+	assert.DeepEqual(t, parameters.Specified.AsMap(), map[string]string{})
+
+	cluster.Spec.Config.Parameters = map[string]v1beta1.PostgresParameterValue{
+		"max_connections":        "200",
+		"Work_Mem":               "8MB",
+		"jit":                    "sometimes",
+		"not_in_the_catalog":     "x",
+		"pg_stat_statements.max": "5000",
+	}
+
+	errs := ConfigParameters(cluster, &parameters)
+	assert.DeepEqual(t, parameters.Specified.AsMap(), map[string]string{
+		"max_connections":        "200",
+		"work_mem":               "8MB",
+		"pg_stat_statements.max": "5000",
+	})
+
+	// Errors are sorted by parameter name. Invalid values and unknown
+	// parameters are skipped alike.
+	assert.Equal(t, len(errs), 2)
+	assert.ErrorContains(t, errs[0], `"jit"`)
+	assert.Assert(t, errors.Is(errs[1], ErrParameterUnknown))
+}
+
+func TestInstanceConfigParameters(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 14
+
+	instance := new(v1beta1.PostgresInstanceSetSpec)
+	instance.Name = "analytics"
+
+	parameters, errs := InstanceConfigParameters(cluster, instance, NewParameters())
+	assert.Assert(t, len(errs) == 0)
+	assert.DeepEqual(t, parameters.AsMap(), map[string]string{})
+
+	instance.Config = &v1beta1.PostgresInstanceConfig{
+		Parameters: map[string]v1beta1.PostgresParameterValue{
+			"Shared_Buffers":     "8GB",
+			"max_connections":    "500",
+			"ssl":                "off",
+			"work_mem":           "4 mb",
+			"not_in_the_catalog": "x",
+		},
+	}
+
+	parameters, errs = InstanceConfigParameters(cluster, instance, NewParameters())
+	assert.DeepEqual(t, parameters.AsMap(), map[string]string{
+		"shared_buffers": "8GB",
+	})
+
+	// Errors are sorted by parameter name. Mandatory parameters are skipped
+	// without error.
+	assert.Equal(t, len(errs), 3)
+	assert.Assert(t, errors.Is(errs[0], ErrParameterClusterWide))
+	assert.ErrorContains(t, errs[0], `instance set "analytics"`)
+	assert.Assert(t, errors.Is(errs[1], ErrParameterUnknown))
+	assert.ErrorContains(t, errs[2], `"work_mem"`)
+}
+
+func TestParameterClusterWide(t *testing.T) {
+	assert.Assert(t, ParameterClusterWide("max_connections"))
+	assert.Assert(t, ParameterClusterWide("Max_WAL_Senders"))
+	assert.Assert(t, !ParameterClusterWide("shared_buffers"))
+	assert.Assert(t, !ParameterClusterWide("work_mem"))
+}
+
 func TestParameterSet(t *testing.T) {
 	ps := NewParameterSet()
 
@@ -68,3 +147,54 @@ func TestParameterSet(t *testing.T) {
 	ps2.Add("x", "n")
 	assert.Assert(t, ps2.Value("x") != ps.Value("x"))
 }
+
+func TestPendingRestartParameters(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			return expected
+		}
+
+		_, err := PendingRestartParameters(ctx, exec)
+		assert.Equal(t, expected, err)
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, _ io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimSpace(`
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+SELECT name FROM pg_catalog.pg_settings WHERE pending_restart ORDER BY name`))
+
+			_, err = stdout.Write([]byte("max_connections\nshared_buffers\n"))
+			return err
+		}
+
+		names, err := PendingRestartParameters(ctx, exec)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, names, []string{"max_connections", "shared_buffers"})
+	})
+
+	t.Run("None", func(t *testing.T) {
+		exec := func(
+			_ context.Context, _ io.Reader, stdout, _ io.Writer, _ ...string,
+		) error {
+			return nil
+		}
+
+		names, err := PendingRestartParameters(ctx, exec)
+		assert.NilError(t, err)
+		assert.Assert(t, len(names) == 0)
+	})
+}
@@ -19,13 +19,19 @@ import (
 
 	// #nosec G501
 	"crypto/md5"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // ErrMD5PasswordInvalid is returned when the password attributes are invalid
 var ErrMD5PasswordInvalid = errors.New(`invalid password attributes. must provide "username" and "password"`)
 
+// ErrMD5VerifierInvalid is returned when a verifier is not "md5" followed by
+// 32 hexadecimal characters
+var ErrMD5VerifierInvalid = errors.New(`invalid MD5 verifier`)
+
 // MD5Password implements the PostgresPassword interface for hashing passwords
 // using the PostgreSQL MD5 method
 type MD5Password struct {
@@ -53,3 +59,18 @@ func NewMD5Password(username, password string) *MD5Password {
 		username: username,
 	}
 }
+
+// Verify returns true when verifier was built from the username and password
+// of m. An error is returned when verifier is not in the MD5 format.
+func (m *MD5Password) Verify(verifier string) (bool, error) {
+	// PostgreSQL stores the hash as 32 lowercase hexadecimal characters
+	if len(verifier) != 35 || !strings.HasPrefix(verifier, "md5") ||
+		strings.Trim(verifier[3:], "0123456789abcdef") != "" {
+		return false, ErrMD5VerifierInvalid
+	}
+
+	// Build never returns an error
+	computed, _ := m.Build()
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(verifier)) == 1, nil
+}
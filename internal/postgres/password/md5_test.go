@@ -16,6 +16,7 @@ package password
 */
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -65,3 +66,49 @@ func TestNewMD5Password(t *testing.T) {
 		return
 	}
 }
+
+func TestMD5Verify(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		credentialList := []([]string){
+			[]string{`hippo`, `datalake`, `md50587128989adb8f28a0a132c39af1b64`},
+			[]string{`híppo`, `øásis`, `md5b52b986c3cff88dde7b952a8abd5995b`},
+		}
+
+		for _, credentials := range credentialList {
+			t.Run(fmt.Sprintf("%s:%s", credentials[0], credentials[1]), func(t *testing.T) {
+				md5 := NewMD5Password(credentials[0], credentials[1])
+
+				if ok, err := md5.Verify(credentials[2]); err != nil || !ok {
+					t.Errorf("expected match: %t %v", ok, err)
+				}
+
+				// the username is part of the hash
+				md5.username = "zebra"
+				if ok, err := md5.Verify(credentials[2]); err != nil || ok {
+					t.Errorf("expected mismatch: %t %v", ok, err)
+				}
+			})
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		verifiers := []string{
+			``,
+			`md5`,
+			`datalake`,
+			`md50587128989ADB8F28A0A132C39AF1B64`,
+			`md50587128989adb8f28a0a132c39af1b6`,
+			`SCRAM-SHA-256$4096:aDFwcDBwNHJ0eTIwMjA=$xHkOo65LX9eBB8a6v+axqvs3+aMBTH0sCT7w/Nxzh5M=:PXuFoeJNuAGSeExskYSqkwUyiUJu8LPC9DgwDWQ9ARQ=`,
+		}
+
+		for _, verifier := range verifiers {
+			t.Run(verifier, func(t *testing.T) {
+				md5 := NewMD5Password("hippo", "datalake")
+
+				if _, err := md5.Verify(verifier); !errors.Is(err, ErrMD5VerifierInvalid) {
+					t.Errorf("expected error, got %v", err)
+				}
+			})
+		}
+	})
+}
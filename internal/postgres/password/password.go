@@ -17,6 +17,7 @@ package password
 
 import (
 	"errors"
+	"strings"
 )
 
 // PasswordType helps to specify the type of password method (e.g. md5)
@@ -43,6 +44,11 @@ type PostgresPassword interface {
 	//
 	// If the build does error, return an empty string
 	Build() (string, error)
+
+	// Verify returns true when the provided verifier was built from the same
+	// plaintext and other attributes of the interface implementor. If the
+	// verifier is not in the expected format, it returns an error.
+	Verify(verifier string) (bool, error)
 }
 
 // NewPostgresPassword accepts a type of password (e.g. md5) which is used to
@@ -61,3 +67,28 @@ func NewPostgresPassword(passwordType PasswordType, username, password string) (
 		return NewSCRAMPassword(password), nil
 	}
 }
+
+// Verify returns true when verifier, a password as stored by PostgreSQL, was
+// built from the username and password. The password type is determined by
+// the format of verifier.
+//
+// An error is returned if verifier is not in a known or valid format
+func Verify(username, password, verifier string) (bool, error) {
+	var passwordType PasswordType
+
+	switch {
+	default:
+		return false, ErrPasswordType
+	case strings.HasPrefix(verifier, "SCRAM-SHA-256$"):
+		passwordType = SCRAM
+	case strings.HasPrefix(verifier, "md5"):
+		passwordType = MD5
+	}
+
+	builder, err := NewPostgresPassword(passwordType, username, password)
+	if err != nil {
+		return false, err
+	}
+
+	return builder.Verify(verifier)
+}
@@ -70,3 +70,39 @@ func TestNewPostgresPassword(t *testing.T) {
 		}
 	})
 }
+
+func TestVerify(t *testing.T) {
+	username := "hippo"
+	password := "datalake"
+
+	t.Run("md5", func(t *testing.T) {
+		if ok, err := Verify(username, password, "md50587128989adb8f28a0a132c39af1b64"); err != nil || !ok {
+			t.Errorf("expected match: %t %v", ok, err)
+		}
+		if ok, err := Verify("zebra", password, "md50587128989adb8f28a0a132c39af1b64"); err != nil || ok {
+			t.Errorf("expected mismatch: %t %v", ok, err)
+		}
+	})
+
+	t.Run("scram", func(t *testing.T) {
+		verifier, err := NewSCRAMPassword(password).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := Verify(username, password, verifier); err != nil || !ok {
+			t.Errorf("expected match: %t %v", ok, err)
+		}
+		if ok, err := Verify(username, "other", verifier); err != nil || ok {
+			t.Errorf("expected mismatch: %t %v", ok, err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, verifier := range []string{"", "datalake", "some$thing"} {
+			if _, err := Verify(username, password, verifier); !errors.Is(err, ErrPasswordType) {
+				t.Errorf("expected error for %q, got %v", verifier, err)
+			}
+		}
+	})
+}
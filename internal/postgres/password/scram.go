@@ -23,6 +23,8 @@ import (
 	"errors"
 	"fmt"
 	"hash"
+	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -69,6 +71,8 @@ var (
 	ErrSCRAMPasswordInvalid = errors.New(`invalid password attributes. must provide "password"`)
 	// ErrSCRAMSaltLengthInvalid is returned when the salt length is less than 1
 	ErrSCRAMSaltLengthInvalid = errors.New(`salt length must be at least 1`)
+	// ErrSCRAMVerifierInvalid is returned when a verifier cannot be parsed
+	ErrSCRAMVerifierInvalid = errors.New(`invalid SCRAM-SHA-256 verifier`)
 )
 
 // SCRAMPassword contains the building blocks to build a PostgreSQL SCRAM
@@ -100,11 +104,41 @@ func (s *SCRAMPassword) Build() (string, error) {
 		return "", err
 	}
 
+	storedKey, serverKey := s.keys(salt, s.Iterations)
+
+	// finally, we can build the scram verified!
+	verifier := fmt.Sprintf(scramVerifierFormat,
+		s.Iterations, s.encode(salt), s.encode(storedKey), s.encode(serverKey))
+
+	return verifier, nil
+}
+
+// Verify returns true when verifier was built from the password of s. The
+// salt and iteration count are taken from verifier, so the Iterations and
+// SaltLength of s are ignored. An error is returned when verifier cannot be
+// parsed.
+func (s *SCRAMPassword) Verify(verifier string) (bool, error) {
+	iterations, salt, storedKey, serverKey, err := scramParseVerifier(verifier)
+	if err != nil {
+		return false, err
+	}
+
+	// recompute the keys using the same salt and iteration count, then compare
+	// them in constant time
+	computedStoredKey, computedServerKey := s.keys(salt, iterations)
+
+	return hmac.Equal(storedKey, computedStoredKey) &&
+		hmac.Equal(serverKey, computedServerKey), nil
+}
+
+// keys returns the stored key and server key for the password of s using salt
+// and the number of PBKDF2 iterations
+func (s *SCRAMPassword) keys(salt []byte, iterations int) ([]byte, []byte) {
 	// before generating the salted password, we have to normalize the password
 	// using SASLprep
 	password := s.saslPrep()
 
-	saltedPassword := pbkdf2.Key([]byte(password), salt, s.Iterations, scramDefaultHash().Size(), scramDefaultHash)
+	saltedPassword := pbkdf2.Key([]byte(password), salt, iterations, scramDefaultHash().Size(), scramDefaultHash)
 
 	// time to create the HMAC generated values (client key, server key)
 	clientKey := s.hmac(scramDefaultHash, saltedPassword, scramClientKeyMessage)
@@ -113,11 +147,7 @@ func (s *SCRAMPassword) Build() (string, error) {
 	// get the stored key, which is the hash of the client key
 	storedKey := s.hash(scramDefaultHash, clientKey)
 
-	// finally, we can build the scram verified!
-	verifier := fmt.Sprintf(scramVerifierFormat,
-		s.Iterations, s.encode(salt), s.encode(storedKey), s.encode(serverKey))
-
-	return verifier, nil
+	return storedKey, serverKey
 }
 
 // encode creates a base64 encoding of a value that's returned as a string
@@ -188,6 +218,48 @@ func (s *SCRAMPassword) saslPrep() string {
 	return cleanedPassword
 }
 
+// scramParseVerifier splits a verifier that follows scramVerifierFormat into
+// its iteration count, salt, stored key, and server key. It follows the
+// checks in "parse_scram_secret" of the PostgreSQL source:
+//
+// https://git.postgresql.org/gitweb/?p=postgresql.git;a=blob;f=src/backend/libpq/auth-scram.c
+func scramParseVerifier(verifier string) (int, []byte, []byte, []byte, error) {
+	// the verifier is "<DIGEST>$<ITERATIONS>:<SALT>$<STORED_KEY>:<SERVER_KEY>"
+	parts := strings.Split(verifier, "$")
+	if len(parts) != 3 || parts[0] != "SCRAM-SHA-256" {
+		return 0, nil, nil, nil, ErrSCRAMVerifierInvalid
+	}
+
+	iterationsSalt := strings.Split(parts[1], ":")
+	keys := strings.Split(parts[2], ":")
+	if len(iterationsSalt) != 2 || len(keys) != 2 {
+		return 0, nil, nil, nil, ErrSCRAMVerifierInvalid
+	}
+
+	iterations, err := strconv.Atoi(iterationsSalt[0])
+	if err != nil || iterations < 1 {
+		return 0, nil, nil, nil, ErrSCRAMVerifierInvalid
+	}
+
+	// the salt can be any length, but the keys must be the size of the hash
+	salt, err := base64.StdEncoding.DecodeString(iterationsSalt[1])
+	if err != nil || len(salt) == 0 {
+		return 0, nil, nil, nil, ErrSCRAMVerifierInvalid
+	}
+
+	storedKey, err := base64.StdEncoding.DecodeString(keys[0])
+	if err != nil || len(storedKey) != scramDefaultHash().Size() {
+		return 0, nil, nil, nil, ErrSCRAMVerifierInvalid
+	}
+
+	serverKey, err := base64.StdEncoding.DecodeString(keys[1])
+	if err != nil || len(serverKey) != scramDefaultHash().Size() {
+		return 0, nil, nil, nil, ErrSCRAMVerifierInvalid
+	}
+
+	return iterations, salt, storedKey, serverKey, nil
+}
+
 // NewSCRAMPassword constructs a new SCRAMPassword struct with sane defaults
 func NewSCRAMPassword(password string) *SCRAMPassword {
 	return &SCRAMPassword{
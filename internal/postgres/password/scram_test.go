@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -130,6 +131,67 @@ func TestSCRAMBuild(t *testing.T) {
 	})
 }
 
+func TestSCRAMVerify(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		credentialList := []([]string){
+			[]string{`datalake`, `SCRAM-SHA-256$4096:aDFwcDBwNHJ0eTIwMjA=$xHkOo65LX9eBB8a6v+axqvs3+aMBTH0sCT7w/Nxzh5M=:PXuFoeJNuAGSeExskYSqkwUyiUJu8LPC9DgwDWQ9ARQ=`},
+			[]string{`øásis`, `SCRAM-SHA-256$4096:aDFwcDBwNHJ0eTIwMjA=$ySGUcYGGJXsigb0a24AfSqNRpGM+zqwlkfuzdlWCV9k=:GDITAfQzF7M9aJaP5OK04b6bT+XQ+wjU3qiGC2ERxeA=`},
+		}
+
+		for _, credentials := range credentialList {
+			t.Run(credentials[0], func(t *testing.T) {
+				scram := NewSCRAMPassword(credentials[0])
+
+				if ok, err := scram.Verify(credentials[1]); err != nil || !ok {
+					t.Errorf("expected match: %t %v", ok, err)
+				}
+
+				scram = NewSCRAMPassword(credentials[0] + "x")
+				if ok, err := scram.Verify(credentials[1]); err != nil || ok {
+					t.Errorf("expected mismatch: %t %v", ok, err)
+				}
+			})
+		}
+	})
+
+	t.Run("other iterations and salt", func(t *testing.T) {
+		scram := NewSCRAMPassword("datalake")
+		scram.Iterations = 10
+		scram.SaltLength = 5
+
+		verifier, err := scram.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := NewSCRAMPassword("datalake").Verify(verifier); err != nil || !ok {
+			t.Errorf("expected match: %t %v", ok, err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		verifiers := []string{
+			``,
+			`datalake`,
+			`md50587128989adb8f28a0a132c39af1b64`,
+			`SCRAM-SHA-1$4096:aDFwcDBwNHJ0eTIwMjA=$xHkOo65LX9eBB8a6v+axqvs3+aMBTH0sCT7w/Nxzh5M=:PXuFoeJNuAGSeExskYSqkwUyiUJu8LPC9DgwDWQ9ARQ=`,
+			`SCRAM-SHA-256$0:aDFwcDBwNHJ0eTIwMjA=$xHkOo65LX9eBB8a6v+axqvs3+aMBTH0sCT7w/Nxzh5M=:PXuFoeJNuAGSeExskYSqkwUyiUJu8LPC9DgwDWQ9ARQ=`,
+			`SCRAM-SHA-256$4096:$xHkOo65LX9eBB8a6v+axqvs3+aMBTH0sCT7w/Nxzh5M=:PXuFoeJNuAGSeExskYSqkwUyiUJu8LPC9DgwDWQ9ARQ=`,
+			`SCRAM-SHA-256$4096:aDFwcDBwNHJ0eTIwMjA=$xHkOo65LX9eBB8a6v+axqvs3:PXuFoeJNuAGSeExskYSqkwUyiUJu8LPC9DgwDWQ9ARQ=`,
+			`SCRAM-SHA-256$4096:aDFwcDBwNHJ0eTIwMjA=$xHkOo65LX9eBB8a6v+axqvs3+aMBTH0sCT7w/Nxzh5M=`,
+			`SCRAM-SHA-256$4096:aDFwcDBwNHJ0eTIwMjA=$xHkOo65LX9eBB8a6v+axqvs3+aMBTH0sCT7w/Nxzh5M=:!!!`,
+		}
+
+		for _, verifier := range verifiers {
+			t.Run(verifier, func(t *testing.T) {
+				if _, err := NewSCRAMPassword("datalake").Verify(verifier); !errors.Is(err, ErrSCRAMVerifierInvalid) {
+					t.Errorf("expected error, got %v", err)
+				}
+			})
+		}
+	})
+}
+
 func TestSCRAMEncode(t *testing.T) {
 	t.Run("valid", func(t *testing.T) {
 		scram := SCRAMPassword{}
@@ -0,0 +1,183 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// AutoTuneParameters returns parameters computed from the memory, CPU, and
+// volumes of the instance sets of cluster when autoTune is enabled there.
+// Every instance shares these parameters, so they are computed from the
+// smallest resources of any set. Parameters that are in inParameters or in
+// the dynamic configuration of Patroni are left out, so that those take
+// precedence. Callers should add the result to the Default parameters.
+func AutoTuneParameters(cluster *v1beta1.PostgresCluster, inParameters Parameters) *ParameterSet {
+	out := NewParameterSet()
+	if !cluster.Spec.Config.AutoTune || len(cluster.Spec.InstanceSets) == 0 {
+		return out
+	}
+
+	// Parameters in the spec of the cluster, including those in the dynamic
+	// configuration of Patroni. Parameters of an instance set override these
+	// defaults on its own instances.
+	explicit := NewParameterSet()
+	for _, set := range []*ParameterSet{inParameters.Mandatory, inParameters.Specified} {
+		if set != nil {
+			for name, value := range set.AsMap() {
+				explicit.Add(name, value)
+			}
+		}
+	}
+	if cluster.Spec.Patroni != nil {
+		if section, ok := cluster.Spec.Patroni.DynamicConfiguration["postgresql"].(map[string]interface{}); ok {
+			if parameters, ok := section["parameters"].(map[string]interface{}); ok {
+				for name, value := range parameters {
+					explicit.Add(name, fmt.Sprint(value))
+				}
+			}
+		}
+	}
+
+	add := func(name, value string) {
+		if !explicit.Has(name) {
+			out.Add(name, value)
+		}
+	}
+
+	// Containers are limited by their memory limit. Without one, use the
+	// amount of memory that is guaranteed. Skip memory parameters when any
+	// set has neither.
+	bytes := smallest(cluster, func(set *v1beta1.PostgresInstanceSetSpec) int64 {
+		memory := quantity(set.Resources, corev1.ResourceMemory)
+		return memory.Value()
+	})
+	if bytes > 0 {
+		// https://www.postgresql.org/docs/current/runtime-config-resource.html#GUC-SHARED-BUFFERS
+		shared := bytes / 4
+		add("shared_buffers", kilobytes(shared))
+		add("effective_cache_size", kilobytes(bytes/4*3))
+
+		// VACUUM and CREATE INDEX benefit little beyond a gigabyte.
+		maintenance := bytes / 16
+		if maintenance > 1<<30 {
+			maintenance = 1 << 30
+		}
+		add("maintenance_work_mem", kilobytes(maintenance))
+
+		// Leave room for a few sorts or hashes in every connection. The value
+		// is kept between 64kB, the minimum, and 1GB.
+		work := (bytes - shared) / (explicitInt(explicit, "max_connections", 100) * 3)
+		if work < 64<<10 {
+			work = 64 << 10
+		}
+		if work > 1<<30 {
+			work = 1 << 30
+		}
+		add("work_mem", kilobytes(work))
+	}
+
+	// Huge pages are available only when the container requests them.
+	// Use them only when every set requests some; otherwise leave the
+	// PostgreSQL default. PostgreSQL cannot start with "on" when its shared
+	// memory does not fit in the huge pages of the container, and shared_buffers
+	// is sized from all its memory, so fall back to normal pages with "try".
+	// - https://docs.k8s.io/tasks/manage-hugepages/scheduling-hugepages/
+	// - https://www.postgresql.org/docs/current/kernel-resources.html#LINUX-HUGE-PAGES
+	hugePages := smallest(cluster, func(set *v1beta1.PostgresInstanceSetSpec) int64 {
+		for name := range set.Resources.Limits {
+			if strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix) {
+				return 1
+			}
+		}
+		return 0
+	})
+	if hugePages > 0 {
+		add("huge_pages", "try")
+	}
+
+	// https://www.postgresql.org/docs/current/runtime-config-resource.html#GUC-MAX-PARALLEL-WORKERS
+	// Parallel workers come from those of max_worker_processes.
+	if workers := smallest(cluster, func(set *v1beta1.PostgresInstanceSetSpec) int64 {
+		cpu := quantity(set.Resources, corev1.ResourceCPU)
+		return cpu.MilliValue() / 1000
+	}); workers > 0 {
+		if limit := explicitInt(explicit, "max_worker_processes", 8); workers > limit {
+			workers = limit
+		}
+		add("max_parallel_workers", strconv.FormatInt(workers, 10))
+	}
+
+	// Let WAL grow to a quarter of the volume that holds it between checkpoints.
+	// - https://www.postgresql.org/docs/current/wal-configuration.html
+	if storage := smallest(cluster, func(set *v1beta1.PostgresInstanceSetSpec) int64 {
+		volume := set.DataVolumeClaimSpec
+		if set.WALVolumeClaimSpec != nil {
+			volume = *set.WALVolumeClaimSpec
+		}
+		return volume.Resources.Requests.Storage().Value()
+	}); storage/4 >= 64<<20 {
+		add("max_wal_size", strconv.FormatInt(storage/4>>20, 10)+"MB")
+	}
+
+	return out
+}
+
+// smallest returns the smallest value of measure across the instance sets
+// of cluster.
+func smallest(
+	cluster *v1beta1.PostgresCluster, measure func(*v1beta1.PostgresInstanceSetSpec) int64,
+) int64 {
+	var result int64
+	for i := range cluster.Spec.InstanceSets {
+		if value := measure(&cluster.Spec.InstanceSets[i]); i == 0 || value < result {
+			result = value
+		}
+	}
+	return result
+}
+
+// explicitInt returns the integer value of parameter name in parameters or
+// fallback when it is missing or not an integer.
+func explicitInt(parameters *ParameterSet, name string, fallback int64) int64 {
+	if value, ok := parameters.Get(name); ok {
+		if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// kilobytes returns bytes as a PostgreSQL memory value in kilobytes.
+func kilobytes(bytes int64) string {
+	return strconv.FormatInt(bytes>>10, 10) + "kB"
+}
+
+// quantity returns the limit of name in resources or, when there is none,
+// its request.
+func quantity(resources corev1.ResourceRequirements, name corev1.ResourceName) resource.Quantity {
+	if q, ok := resources.Limits[name]; ok {
+		return q
+	}
+	return resources.Requests[name]
+}
@@ -0,0 +1,192 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package postgres
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+func TestAutoTuneParameters(t *testing.T) {
+	cluster := new(v1beta1.PostgresCluster)
+	cluster.Spec.PostgresVersion = 14
+	cluster.Spec.InstanceSets = []v1beta1.PostgresInstanceSetSpec{{Name: "instance1"}}
+
+	instance := &cluster.Spec.InstanceSets[0]
+	instance.Resources.Limits = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("4"),
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+	}
+	instance.DataVolumeClaimSpec.Resources.Requests = corev1.ResourceList{
+		corev1.ResourceStorage: resource.MustParse("100Gi"),
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		tuned := AutoTuneParameters(cluster, NewParameters())
+		assert.DeepEqual(t, tuned.AsMap(), map[string]string{})
+	})
+
+	cluster.Spec.Config.AutoTune = true
+
+	t.Run("Enabled", func(t *testing.T) {
+		tuned := AutoTuneParameters(cluster, NewParameters())
+		assert.DeepEqual(t, tuned.AsMap(), map[string]string{
+			"effective_cache_size": "6291456kB",
+			"maintenance_work_mem": "524288kB",
+			"max_parallel_workers": "4",
+			"max_wal_size":         "25600MB",
+			"shared_buffers":       "2097152kB",
+			"work_mem":             "20971kB",
+		})
+
+		// Every value is valid.
+		for name, value := range tuned.AsMap() {
+			assert.NilError(t, ValidateParameter(cluster.Spec.PostgresVersion, name, value))
+		}
+	})
+
+	t.Run("NoInstanceSets", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.InstanceSets = nil
+
+		tuned := AutoTuneParameters(cluster, NewParameters())
+		assert.DeepEqual(t, tuned.AsMap(), map[string]string{})
+	})
+
+	t.Run("Requests", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.InstanceSets[0].Resources = corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+		}
+
+		tuned := AutoTuneParameters(cluster, NewParameters())
+		assert.DeepEqual(t, tuned.AsMap(), map[string]string{
+			"effective_cache_size": "786432kB",
+			"maintenance_work_mem": "65536kB",
+			"max_wal_size":         "25600MB",
+			"shared_buffers":       "262144kB",
+			"work_mem":             "2621kB",
+		})
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		instance := &cluster.Spec.InstanceSets[0]
+		instance.Resources.Limits = corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("64"),
+			corev1.ResourceMemory: resource.MustParse("2Ti"),
+			"hugepages-2Mi":       resource.MustParse("1Gi"),
+		}
+		instance.WALVolumeClaimSpec = &corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("8Gi"),
+				},
+			},
+		}
+
+		// Shared buffers do not fit in the huge pages, so PostgreSQL must be
+		// able to start without them.
+		tuned := AutoTuneParameters(cluster, NewParameters())
+		assert.DeepEqual(t, tuned.AsMap(), map[string]string{
+			"effective_cache_size": "1610612736kB",
+			"huge_pages":           "try",
+			"maintenance_work_mem": "1048576kB",
+			"max_parallel_workers": "8",
+			"max_wal_size":         "2048MB",
+			"shared_buffers":       "536870912kB",
+			"work_mem":             "1048576kB",
+		})
+
+		for name, value := range tuned.AsMap() {
+			assert.NilError(t, ValidateParameter(cluster.Spec.PostgresVersion, name, value))
+		}
+	})
+
+	t.Run("SmallestInstanceSet", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.InstanceSets[0].Resources.Limits["hugepages-2Mi"] = resource.MustParse("1Gi")
+		cluster.Spec.InstanceSets = append(cluster.Spec.InstanceSets,
+			v1beta1.PostgresInstanceSetSpec{
+				Name: "small",
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("2"),
+						corev1.ResourceMemory: resource.MustParse("2Gi"),
+					},
+				},
+				DataVolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage: resource.MustParse("10Gi"),
+						},
+					},
+				},
+			})
+
+		// Huge pages stay at their default because one set has none.
+		tuned := AutoTuneParameters(cluster, NewParameters())
+		assert.DeepEqual(t, tuned.AsMap(), map[string]string{
+			"effective_cache_size": "1572864kB",
+			"maintenance_work_mem": "131072kB",
+			"max_parallel_workers": "2",
+			"max_wal_size":         "2560MB",
+			"shared_buffers":       "524288kB",
+			"work_mem":             "5242kB",
+		})
+
+		// Memory is not tuned when any set has no memory limit or request.
+		cluster.Spec.InstanceSets[1].Resources = corev1.ResourceRequirements{}
+		tuned = AutoTuneParameters(cluster, NewParameters())
+		assert.DeepEqual(t, tuned.AsMap(), map[string]string{
+			"max_wal_size": "2560MB",
+		})
+	})
+
+	t.Run("Explicit", func(t *testing.T) {
+		cluster := cluster.DeepCopy()
+		cluster.Spec.Patroni = &v1beta1.PatroniSpec{
+			DynamicConfiguration: map[string]interface{}{
+				"postgresql": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"effective_cache_size": "1GB",
+						"max_worker_processes": float64(2),
+					},
+				},
+			},
+		}
+
+		parameters := NewParameters()
+		parameters.Specified.Add("max_connections", "1000")
+		parameters.Specified.Add("shared_buffers", "1GB")
+
+		tuned := AutoTuneParameters(cluster, parameters)
+		assert.DeepEqual(t, tuned.AsMap(), map[string]string{
+			"maintenance_work_mem": "524288kB",
+			"max_parallel_workers": "2",
+			"max_wal_size":         "25600MB",
+			"work_mem":             "2097kB",
+		})
+	})
+}
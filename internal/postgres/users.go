@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/crunchydata/postgres-operator/internal/logging"
 	"github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
@@ -132,3 +133,393 @@ SELECT pg_catalog.format('GRANT ALL PRIVILEGES ON DATABASE %I TO %I',
 
 	return err
 }
+
+// DisableUsersInPostgreSQL calls exec to prevent users from logging into
+// PostgreSQL and to end their current sessions. Users that do not exist are
+// ignored.
+func DisableUsersInPostgreSQL(
+	ctx context.Context, exec Executor, users []string,
+) error {
+	log := logging.FromContext(ctx)
+
+	roles, err := json.Marshal(users)
+
+	// Prevent new sessions then end existing ones. The "postgres" user is
+	// never disabled.
+	// - https://www.postgresql.org/docs/current/sql-alterrole.html
+	// - https://www.postgresql.org/docs/current/functions-admin.html#FUNCTIONS-ADMIN-SIGNAL
+	sql := strings.TrimSpace(`
+SET search_path TO '';
+SELECT pg_catalog.format('ALTER ROLE %I NOLOGIN', rolname)
+  FROM pg_catalog.pg_roles
+ WHERE rolname IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND rolname <> 'postgres'
+ ORDER BY rolname
+\gexec
+SELECT pg_catalog.pg_terminate_backend(pid)
+  FROM pg_catalog.pg_stat_activity
+ WHERE usename IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND usename <> 'postgres';
+`)
+
+	if err == nil {
+		var stdout, stderr string
+		stdout, stderr, err = exec.Exec(ctx, strings.NewReader(sql),
+			map[string]string{
+				"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+				"QUIET":         "on", // Do not print successful statements to stdout.
+				"roles":         string(roles),
+			})
+
+		log.V(1).Info("disabled PostgreSQL users", "stdout", stdout, "stderr", stderr)
+	}
+
+	return err
+}
+
+// DropUsersInPostgreSQL calls exec to drop users from PostgreSQL. Objects
+// owned by the users are first given to owner, and any privileges granted to
+// them are revoked in every database. Users that do not exist are ignored, as
+// are the "postgres" user and owner; callers should not expect those dropped.
+func DropUsersInPostgreSQL(
+	ctx context.Context, exec Executor, users []string, owner string,
+) error {
+	log := logging.FromContext(ctx)
+
+	roles, err := json.Marshal(users)
+
+	// REASSIGN OWNED and DROP OWNED affect only the current database and
+	// shared objects, so run them in every database before dropping the users.
+	// The "postgres" user is never dropped.
+	// - https://www.postgresql.org/docs/current/role-removal.html
+	// - https://www.postgresql.org/docs/current/sql-reassign-owned.html
+	// - https://www.postgresql.org/docs/current/sql-drop-owned.html
+	const reassign = `
+SET search_path TO '';
+SELECT pg_catalog.format('REASSIGN OWNED BY %I TO %I', rolname, :'owner')
+  FROM pg_catalog.pg_roles
+ WHERE rolname IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND rolname NOT IN ('postgres', :'owner')
+ ORDER BY rolname
+\gexec
+SELECT pg_catalog.format('DROP OWNED BY %I', rolname)
+  FROM pg_catalog.pg_roles
+ WHERE rolname IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND rolname NOT IN ('postgres', :'owner')
+ ORDER BY rolname
+\gexec
+`
+
+	// End any sessions of the users then drop them.
+	// - https://www.postgresql.org/docs/current/sql-droprole.html
+	drop := strings.TrimSpace(`
+SET search_path TO '';
+SELECT pg_catalog.pg_terminate_backend(pid)
+  FROM pg_catalog.pg_stat_activity
+ WHERE usename IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND usename NOT IN ('postgres', :'owner');
+SELECT pg_catalog.format('DROP ROLE IF EXISTS %I', rolname)
+  FROM pg_catalog.pg_roles
+ WHERE rolname IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND rolname NOT IN ('postgres', :'owner')
+ ORDER BY rolname
+\gexec
+`)
+
+	variables := map[string]string{
+		"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+		"QUIET":         "on", // Do not print successful statements to stdout.
+		"owner":         owner,
+		"roles":         string(roles),
+	}
+
+	if err == nil {
+		var stdout, stderr string
+		stdout, stderr, err = exec.ExecInAllDatabases(ctx, reassign, variables)
+
+		log.V(1).Info("reassigned PostgreSQL objects", "stdout", stdout, "stderr", stderr)
+	}
+	if err == nil {
+		var stdout, stderr string
+		stdout, stderr, err = exec.Exec(ctx, strings.NewReader(drop), variables)
+
+		log.V(1).Info("dropped PostgreSQL users", "stdout", stdout, "stderr", stderr)
+	}
+
+	return err
+}
+
+// WriteUserPrivilegesInPostgreSQL calls exec to grant users with a privileges
+// specification exactly the privileges specified on their databases and the
+// schemas in them. It also grants and revokes their membership in other roles.
+// The users, roles, databases, and schemas must already exist.
+func WriteUserPrivilegesInPostgreSQL(
+	ctx context.Context, exec Executor, users []v1beta1.PostgresUserSpec,
+) error {
+	log := logging.FromContext(ctx)
+
+	// join formats privileges for a GRANT statement, using fallback when
+	// there are none. An empty result is NULL in the SQL below.
+	join := func(privileges []string, fallback string) interface{} {
+		if len(privileges) == 0 && fallback == "" {
+			return nil
+		}
+		if len(privileges) == 0 {
+			return fallback
+		}
+		return strings.Join(privileges, ", ")
+	}
+
+	roles := []map[string]interface{}{}
+	schemas := map[string][]map[string]interface{}{}
+
+	for i := range users {
+		spec := users[i]
+
+		// The "postgres" user is always a superuser.
+		if spec.Privileges == nil || spec.Name == "postgres" {
+			continue
+		}
+
+		var onDatabases []string
+		for _, privilege := range spec.Privileges.Databases {
+			onDatabases = append(onDatabases, string(privilege))
+		}
+		roles = append(roles, map[string]interface{}{
+			"databases":  spec.Databases,
+			"privileges": join(onDatabases, "CONNECT"),
+			"roles":      spec.Privileges.Roles,
+			"username":   spec.Name,
+		})
+
+		for _, schema := range spec.Privileges.Schemas {
+			var onSchema, onTables, onSequences []string
+			for _, privilege := range schema.Schema {
+				onSchema = append(onSchema, string(privilege))
+			}
+			for _, privilege := range schema.Tables {
+				onTables = append(onTables, string(privilege))
+			}
+			for _, privilege := range schema.Sequences {
+				onSequences = append(onSequences, string(privilege))
+			}
+
+			// Default privileges are for the database owner when no users
+			// are specified. That is filled in by the SQL below.
+			data := map[string]interface{}{
+				"default_for":  nil,
+				"on_schema":    join(onSchema, "USAGE"),
+				"on_sequences": join(onSequences, ""),
+				"on_tables":    join(onTables, ""),
+				"schema":       schema.Name,
+				"username":     spec.Name,
+			}
+			if len(schema.DefaultPrivilegesFor) > 0 {
+				data["default_for"] = schema.DefaultPrivilegesFor
+			}
+			for _, database := range spec.Databases {
+				schemas[string(database)] = append(schemas[string(database)], data)
+			}
+		}
+	}
+
+	rolesJSON, err := json.Marshal(roles)
+	schemasJSON, _ := json.Marshal(schemas)
+
+	// Revoke privileges on each database then grant those specified. Do the
+	// same for membership in other roles. Do this in a transaction so that
+	// access is never missing while another session is connected.
+	// - https://www.postgresql.org/docs/current/sql-grant.html
+	// - https://www.postgresql.org/docs/current/sql-revoke.html
+	sql := strings.TrimSpace(`
+SET search_path TO '';
+CREATE TEMPORARY TABLE input AS
+SELECT * FROM pg_catalog.json_to_recordset(:'users')
+    AS (username text, databases json, privileges text, roles json);
+BEGIN;
+SELECT pg_catalog.format('REVOKE ALL PRIVILEGES ON DATABASE %I FROM %I',
+       d.name, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.databases) AS d (name)
+\gexec
+SELECT pg_catalog.format('GRANT %s ON DATABASE %I TO %I',
+       input.privileges, d.name, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.databases) AS d (name)
+\gexec
+SELECT pg_catalog.format('REVOKE %I FROM %I',
+       pg_catalog.pg_get_userbyid(m.roleid), input.username)
+  FROM input
+  JOIN pg_catalog.pg_roles AS r ON r.rolname = input.username
+  JOIN pg_catalog.pg_auth_members AS m ON m.member = r.oid
+ WHERE pg_catalog.pg_get_userbyid(m.roleid) NOT IN (
+       SELECT pg_catalog.json_array_elements_text(input.roles))
+\gexec
+SELECT pg_catalog.format('GRANT %I TO %I', g.name, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.roles) AS g (name)
+\gexec
+COMMIT;
+`)
+
+	// Privileges on schemas and the objects in them are granted separately
+	// in each database. Default privileges apply to objects created later by
+	// the owner of the database or by the users specified.
+	// - https://www.postgresql.org/docs/current/sql-alterdefaultprivileges.html
+	const databasesQuery = `SELECT pg_catalog.json_object_keys(:'schemas')`
+	const schemasSQL = `
+SET search_path TO '';
+CREATE TEMPORARY TABLE input AS
+SELECT * FROM pg_catalog.json_to_recordset(
+       pg_catalog.json_extract_path(:'schemas', pg_catalog.current_database()))
+    AS (username text, schema text,
+        on_schema text, on_tables text, on_sequences text, default_for json);
+UPDATE input SET default_for = pg_catalog.json_build_array(pg_catalog.pg_get_userbyid(d.datdba))
+  FROM pg_catalog.pg_database AS d
+ WHERE input.default_for IS NULL AND d.datname = pg_catalog.current_database();
+BEGIN;
+SELECT pg_catalog.format('REVOKE ALL PRIVILEGES ON SCHEMA %I FROM %I', input.schema, input.username)
+  FROM input
+\gexec
+SELECT pg_catalog.format('REVOKE ALL PRIVILEGES ON ALL TABLES IN SCHEMA %I FROM %I', input.schema, input.username)
+  FROM input
+\gexec
+SELECT pg_catalog.format('REVOKE ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA %I FROM %I', input.schema, input.username)
+  FROM input
+\gexec
+SELECT pg_catalog.format('ALTER DEFAULT PRIVILEGES FOR ROLE %I IN SCHEMA %I REVOKE ALL PRIVILEGES ON TABLES FROM %I',
+       r.name, input.schema, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.default_for) AS r (name)
+\gexec
+SELECT pg_catalog.format('ALTER DEFAULT PRIVILEGES FOR ROLE %I IN SCHEMA %I REVOKE ALL PRIVILEGES ON SEQUENCES FROM %I',
+       r.name, input.schema, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.default_for) AS r (name)
+\gexec
+SELECT pg_catalog.format('GRANT %s ON SCHEMA %I TO %I', input.on_schema, input.schema, input.username)
+  FROM input
+\gexec
+SELECT pg_catalog.format('GRANT %s ON ALL TABLES IN SCHEMA %I TO %I', input.on_tables, input.schema, input.username)
+  FROM input WHERE input.on_tables IS NOT NULL
+\gexec
+SELECT pg_catalog.format('GRANT %s ON ALL SEQUENCES IN SCHEMA %I TO %I', input.on_sequences, input.schema, input.username)
+  FROM input WHERE input.on_sequences IS NOT NULL
+\gexec
+SELECT pg_catalog.format('ALTER DEFAULT PRIVILEGES FOR ROLE %I IN SCHEMA %I GRANT %s ON TABLES TO %I',
+       r.name, input.schema, input.on_tables, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.default_for) AS r (name)
+ WHERE input.on_tables IS NOT NULL
+\gexec
+SELECT pg_catalog.format('ALTER DEFAULT PRIVILEGES FOR ROLE %I IN SCHEMA %I GRANT %s ON SEQUENCES TO %I',
+       r.name, input.schema, input.on_sequences, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.default_for) AS r (name)
+ WHERE input.on_sequences IS NOT NULL
+\gexec
+COMMIT;
+`
+
+	if err == nil {
+		var stdout, stderr string
+		stdout, stderr, err = exec.Exec(ctx, strings.NewReader(sql),
+			map[string]string{
+				"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+				"QUIET":         "on", // Do not print successful statements to stdout.
+				"users":         string(rolesJSON),
+			})
+
+		log.V(1).Info("wrote PostgreSQL user privileges", "stdout", stdout, "stderr", stderr)
+	}
+	if err == nil && len(schemas) > 0 {
+		var stdout, stderr string
+		stdout, stderr, err = exec.ExecInDatabasesFromQuery(ctx, databasesQuery, schemasSQL,
+			map[string]string{
+				"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+				"QUIET":         "on", // Do not print successful statements to stdout.
+				"schemas":       string(schemasJSON),
+			})
+
+		log.V(1).Info("wrote PostgreSQL schema privileges", "stdout", stdout, "stderr", stderr)
+	}
+
+	return err
+}
+
+// WriteAlternateUsersInPostgreSQL calls exec so that each alternate user in
+// alternates acts as its user: the alternate becomes a member of the user and
+// assumes that role when it logs in. Objects created by an alternate are owned
+// by its user. Both users must already exist.
+func WriteAlternateUsersInPostgreSQL(
+	ctx context.Context, exec Executor, alternates map[string]string,
+) error {
+	log := logging.FromContext(ctx)
+
+	input, err := json.Marshal(alternates)
+
+	// - https://www.postgresql.org/docs/current/role-membership.html
+	// - https://www.postgresql.org/docs/current/sql-alterrole.html
+	sql := strings.TrimSpace(`
+SET search_path TO '';
+SELECT pg_catalog.format('GRANT %I TO %I', a.value, a.key)
+  FROM pg_catalog.json_each_text(:'alternates') AS a
+ WHERE NOT pg_catalog.pg_has_role(a.key, a.value, 'MEMBER')
+ ORDER BY a.key
+\gexec
+SELECT pg_catalog.format('ALTER ROLE %I SET role TO %L', a.key, a.value)
+  FROM pg_catalog.json_each_text(:'alternates') AS a
+ ORDER BY a.key
+\gexec
+`)
+
+	if err == nil {
+		var stdout, stderr string
+		stdout, stderr, err = exec.Exec(ctx, strings.NewReader(sql),
+			map[string]string{
+				"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+				"QUIET":         "on", // Do not print successful statements to stdout.
+				"alternates":    string(input),
+			})
+
+		log.V(1).Info("wrote PostgreSQL alternate users", "stdout", stdout, "stderr", stderr)
+	}
+
+	return err
+}
+
+// ComparePasswordsInPostgreSQL calls exec to compare the stored password of
+// each user in verifiers to its verifier. It returns the names of users whose
+// password is different, in order. Users that do not exist are ignored.
+func ComparePasswordsInPostgreSQL(
+	ctx context.Context, exec Executor, verifiers map[string]string,
+) ([]string, error) {
+	log := logging.FromContext(ctx)
+
+	input, err := json.Marshal(verifiers)
+
+	// Print only the value of a single JSON array.
+	// - https://www.postgresql.org/docs/current/catalog-pg-authid.html
+	// - https://www.postgresql.org/docs/current/app-psql.html#APP-PSQL-META-COMMAND-PSET
+	sql := strings.TrimSpace(`
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+SELECT COALESCE(pg_catalog.json_agg(v.key ORDER BY v.key), '[]')
+  FROM pg_catalog.json_each_text(:'verifiers') AS v
+  JOIN pg_catalog.pg_authid AS a ON a.rolname = v.key
+ WHERE a.rolpassword IS DISTINCT FROM v.value
+`)
+
+	var different []string
+	if err == nil {
+		var stdout, stderr string
+		stdout, stderr, err = exec.Exec(ctx, strings.NewReader(sql),
+			map[string]string{
+				"ON_ERROR_STOP": "on", // Abort when any one statement fails.
+				"QUIET":         "on", // Do not print successful statements to stdout.
+				"verifiers":     string(input),
+			})
+
+		log.V(1).Info("compared PostgreSQL passwords", "stdout", stdout, "stderr", stderr)
+
+		if err == nil {
+			err = json.Unmarshal([]byte(stdout), &different)
+		}
+	}
+
+	return different, err
+}
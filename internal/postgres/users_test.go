@@ -169,3 +169,345 @@ COMMIT;`))
 		assert.Equal(t, calls, 1)
 	})
 }
+
+func TestDisableUsersInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			assert.Assert(t, cmp.Contains(command, `--set=roles=["one","two"]`))
+			return expected
+		}
+
+		assert.Equal(t, expected,
+			DisableUsersInPostgreSQL(ctx, exec, []string{"one", "two"}))
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimSpace(`
+SET search_path TO '';
+SELECT pg_catalog.format('ALTER ROLE %I NOLOGIN', rolname)
+  FROM pg_catalog.pg_roles
+ WHERE rolname IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND rolname <> 'postgres'
+ ORDER BY rolname
+\gexec
+SELECT pg_catalog.pg_terminate_backend(pid)
+  FROM pg_catalog.pg_stat_activity
+ WHERE usename IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND usename <> 'postgres';`))
+			assert.Assert(t, cmp.Contains(command, `--set=roles=null`))
+			return nil
+		}
+
+		assert.NilError(t, DisableUsersInPostgreSQL(ctx, exec, nil))
+		assert.Equal(t, calls, 1)
+	})
+}
+
+func TestDropUsersInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			return expected
+		}
+
+		assert.Equal(t, expected,
+			DropUsersInPostgreSQL(ctx, exec, []string{"one"}, "postgres"))
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(command, `--set=owner=someone`))
+			assert.Assert(t, cmp.Contains(command, `--set=roles=["one","two"]`))
+
+			switch calls {
+			case 1:
+				// Objects are reassigned and dropped in every database.
+				assert.Equal(t, command[0], "bash")
+				assert.Assert(t, cmp.Contains(string(b), `
+SELECT pg_catalog.format('REASSIGN OWNED BY %I TO %I', rolname, :'owner')
+  FROM pg_catalog.pg_roles
+ WHERE rolname IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND rolname NOT IN ('postgres', :'owner')
+ ORDER BY rolname
+\gexec
+SELECT pg_catalog.format('DROP OWNED BY %I', rolname)
+`))
+			case 2:
+				assert.Equal(t, command[0], "psql")
+				assert.Assert(t, cmp.Contains(string(b), `
+SELECT pg_catalog.format('DROP ROLE IF EXISTS %I', rolname)
+  FROM pg_catalog.pg_roles
+ WHERE rolname IN (SELECT pg_catalog.json_array_elements_text(:'roles'))
+   AND rolname NOT IN ('postgres', :'owner')
+ ORDER BY rolname
+\gexec`))
+			}
+			return nil
+		}
+
+		assert.NilError(t, DropUsersInPostgreSQL(ctx, exec, []string{"one", "two"}, "someone"))
+		assert.Equal(t, calls, 2)
+	})
+}
+
+func TestWriteUserPrivilegesInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			return expected
+		}
+
+		assert.Equal(t, expected, WriteUserPrivilegesInPostgreSQL(ctx, exec, nil))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimSpace(`
+SET search_path TO '';
+CREATE TEMPORARY TABLE input AS
+SELECT * FROM pg_catalog.json_to_recordset(:'users')
+    AS (username text, databases json, privileges text, roles json);
+BEGIN;
+SELECT pg_catalog.format('REVOKE ALL PRIVILEGES ON DATABASE %I FROM %I',
+       d.name, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.databases) AS d (name)
+\gexec
+SELECT pg_catalog.format('GRANT %s ON DATABASE %I TO %I',
+       input.privileges, d.name, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.databases) AS d (name)
+\gexec
+SELECT pg_catalog.format('REVOKE %I FROM %I',
+       pg_catalog.pg_get_userbyid(m.roleid), input.username)
+  FROM input
+  JOIN pg_catalog.pg_roles AS r ON r.rolname = input.username
+  JOIN pg_catalog.pg_auth_members AS m ON m.member = r.oid
+ WHERE pg_catalog.pg_get_userbyid(m.roleid) NOT IN (
+       SELECT pg_catalog.json_array_elements_text(input.roles))
+\gexec
+SELECT pg_catalog.format('GRANT %I TO %I', g.name, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.roles) AS g (name)
+\gexec
+COMMIT;`))
+			assert.Assert(t, cmp.Contains(command, `--set=users=[]`))
+			return nil
+		}
+
+		// Users without privileges and the "postgres" user are skipped.
+		assert.NilError(t, WriteUserPrivilegesInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresUserSpec{
+				{Name: "no-privileges", Databases: []v1beta1.PostgresIdentifier{"db1"}},
+				{Name: "postgres", Privileges: &v1beta1.PostgresPrivilegesSpec{}},
+			},
+		))
+		assert.Equal(t, calls, 1)
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+
+			switch calls {
+			case 1:
+				assert.Assert(t, cmp.Contains(command, `--set=users=[`+
+					`{"databases":["db1","db2"],"privileges":"CONNECT","roles":null,"username":"reader"},`+
+					`{"databases":["db1"],"privileges":"CONNECT, TEMPORARY","roles":["pg_monitor"],"username":"writer"}]`))
+			case 2:
+				assert.Assert(t, cmp.Contains(command, `SELECT pg_catalog.json_object_keys(:'schemas')`))
+				assert.Assert(t, cmp.Contains(command, `--set=schemas={`+
+					`"db1":[`+
+					`{"default_for":null,"on_schema":"USAGE","on_sequences":null,"on_tables":"SELECT","schema":"app","username":"reader"},`+
+					`{"default_for":["app","migrations"],"on_schema":"CREATE, USAGE","on_sequences":"USAGE, UPDATE","on_tables":"SELECT, INSERT","schema":"app","username":"writer"}],`+
+					`"db2":[`+
+					`{"default_for":null,"on_schema":"USAGE","on_sequences":null,"on_tables":"SELECT","schema":"app","username":"reader"}]}`))
+
+				assert.Assert(t, cmp.Contains(string(b), `
+UPDATE input SET default_for = pg_catalog.json_build_array(pg_catalog.pg_get_userbyid(d.datdba))
+  FROM pg_catalog.pg_database AS d
+ WHERE input.default_for IS NULL AND d.datname = pg_catalog.current_database();
+BEGIN;
+`))
+				assert.Assert(t, cmp.Contains(string(b), `
+SELECT pg_catalog.format('ALTER DEFAULT PRIVILEGES FOR ROLE %I IN SCHEMA %I GRANT %s ON TABLES TO %I',
+       r.name, input.schema, input.on_tables, input.username)
+  FROM input, pg_catalog.json_array_elements_text(input.default_for) AS r (name)
+ WHERE input.on_tables IS NOT NULL
+\gexec
+`))
+			}
+			return nil
+		}
+
+		assert.NilError(t, WriteUserPrivilegesInPostgreSQL(ctx, exec,
+			[]v1beta1.PostgresUserSpec{
+				{
+					Name:      "reader",
+					Databases: []v1beta1.PostgresIdentifier{"db1", "db2"},
+					Privileges: &v1beta1.PostgresPrivilegesSpec{
+						Schemas: []v1beta1.PostgresSchemaPrivilegesSpec{{
+							Name:   "app",
+							Tables: []v1beta1.PostgresTablePrivilege{"SELECT"},
+						}},
+					},
+				},
+				{
+					Name:      "writer",
+					Databases: []v1beta1.PostgresIdentifier{"db1"},
+					Privileges: &v1beta1.PostgresPrivilegesSpec{
+						Databases: []v1beta1.PostgresDatabasePrivilege{"CONNECT", "TEMPORARY"},
+						Roles:     []v1beta1.PostgresIdentifier{"pg_monitor"},
+						Schemas: []v1beta1.PostgresSchemaPrivilegesSpec{{
+							Name:                 "app",
+							Schema:               []v1beta1.PostgresSchemaPrivilege{"CREATE", "USAGE"},
+							Tables:               []v1beta1.PostgresTablePrivilege{"SELECT", "INSERT"},
+							Sequences:            []v1beta1.PostgresSequencePrivilege{"USAGE", "UPDATE"},
+							DefaultPrivilegesFor: []v1beta1.PostgresIdentifier{"app", "migrations"},
+						}},
+					},
+				},
+			},
+		))
+		assert.Equal(t, calls, 2)
+	})
+}
+
+func TestWriteAlternateUsersInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			return expected
+		}
+
+		assert.Equal(t, expected, WriteAlternateUsersInPostgreSQL(ctx, exec, nil))
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, _, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimSpace(`
+SET search_path TO '';
+SELECT pg_catalog.format('GRANT %I TO %I', a.value, a.key)
+  FROM pg_catalog.json_each_text(:'alternates') AS a
+ WHERE NOT pg_catalog.pg_has_role(a.key, a.value, 'MEMBER')
+ ORDER BY a.key
+\gexec
+SELECT pg_catalog.format('ALTER ROLE %I SET role TO %L', a.key, a.value)
+  FROM pg_catalog.json_each_text(:'alternates') AS a
+ ORDER BY a.key
+\gexec`))
+			assert.Assert(t, cmp.Contains(command, `--set=alternates={"app-alternate":"app"}`))
+			return nil
+		}
+
+		assert.NilError(t, WriteAlternateUsersInPostgreSQL(ctx, exec,
+			map[string]string{"app-alternate": "app"}))
+		assert.Equal(t, calls, 1)
+	})
+}
+
+func TestComparePasswordsInPostgreSQL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Arguments", func(t *testing.T) {
+		expected := errors.New("pass-through")
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Assert(t, stderr != nil, "should capture stderr")
+			return expected
+		}
+
+		_, err := ComparePasswordsInPostgreSQL(ctx, exec, nil)
+		assert.Equal(t, expected, err)
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		calls := 0
+		exec := func(
+			_ context.Context, stdin io.Reader, stdout, _ io.Writer, command ...string,
+		) error {
+			calls++
+
+			b, err := io.ReadAll(stdin)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), strings.TrimSpace(`
+SET search_path TO '';
+\pset format unaligned
+\pset tuples_only on
+SELECT COALESCE(pg_catalog.json_agg(v.key ORDER BY v.key), '[]')
+  FROM pg_catalog.json_each_text(:'verifiers') AS v
+  JOIN pg_catalog.pg_authid AS a ON a.rolname = v.key
+ WHERE a.rolpassword IS DISTINCT FROM v.value`))
+			assert.Assert(t, cmp.Contains(command,
+				`--set=verifiers={"app":"SCRAM-SHA-256$some","other":"md5abc"}`))
+
+			_, err = stdout.Write([]byte("[\"app\"]\n"))
+			return err
+		}
+
+		different, err := ComparePasswordsInPostgreSQL(ctx, exec,
+			map[string]string{"app": "SCRAM-SHA-256$some", "other": "md5abc"})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, different, []string{"app"})
+		assert.Equal(t, calls, 1)
+	})
+}
@@ -32,6 +32,10 @@ const (
 	// Feature gates should be listed in alphabetical, case-sensitive
 	// (upper before any lower case character) order.
 	//
+	// Enables the mutating and validating admission webhooks served by PGO.
+	// These require a Service and a serving certificate for the webhook server.
+	AdmissionWebhooks featuregate.Feature = "AdmissionWebhooks"
+	//
 	BridgeIdentifiers featuregate.Feature = "BridgeIdentifiers"
 	//
 	// Enables support of custom sidecars for PostgreSQL instance Pods
@@ -39,6 +43,12 @@ const (
 	//
 	// Enables support of custom sidecars for pgBouncer Pods
 	PGBouncerSidecars featuregate.Feature = "PGBouncerSidecars"
+	//
+	// Enables calling the Patroni REST API directly rather than through
+	// "patronictl" in instance Pods. PGO must be able to reach instance Pods
+	// by their DNS names. Without it, PGO executes "patronictl" in an instance
+	// Pod every 30 seconds to report Patroni members in status.
+	PatroniRESTAPI featuregate.Feature = "PatroniRESTAPI"
 )
 
 // pgoFeatures consists of all known PGO feature keys.
@@ -49,9 +59,11 @@ const (
 //
 // - https://releases.k8s.io/v1.20.0/pkg/features/kube_features.go#L729-732
 var pgoFeatures = map[featuregate.Feature]featuregate.FeatureSpec{
+	AdmissionWebhooks: {Default: false, PreRelease: featuregate.Alpha},
 	BridgeIdentifiers: {Default: false, PreRelease: featuregate.Alpha},
 	InstanceSidecars:  {Default: false, PreRelease: featuregate.Alpha},
 	PGBouncerSidecars: {Default: false, PreRelease: featuregate.Alpha},
+	PatroniRESTAPI:    {Default: false, PreRelease: featuregate.Alpha},
 }
 
 // DefaultMutableFeatureGate is a mutable, shared global FeatureGate.
@@ -15,7 +15,21 @@
 
 package v1beta1
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 type PatroniSpec struct {
+	// The kind of Kubernetes object Patroni uses for its distributed configuration
+	// store (DCS) and leader elections. With ConfigMaps, Patroni writes no
+	// Endpoints and the primary Service selects the leader Pod by its label.
+	// Changing this value stops every instance while Patroni switches.
+	// More info: https://patroni.readthedocs.io/en/latest/kubernetes.html
+	// +optional
+	// +kubebuilder:default=Endpoints
+	// +kubebuilder:validation:Enum={Endpoints,ConfigMaps}
+	DCS string `json:"dcs,omitempty"`
+
 	// Patroni dynamic configuration settings. Changes to this value will be
 	// automatically reloaded without validation. Changes to certain PostgreSQL
 	// parameters cause PostgreSQL to restart.
@@ -53,12 +67,22 @@ type PatroniSpec struct {
 	// +optional
 	Switchover *PatroniSwitchover `json:"switchover,omitempty"`
 
-	// TODO(cbandy): Add UseConfigMaps bool, default false.
+	// Synchronous replication settings. These take precedence over the same
+	// settings in dynamicConfiguration.
+	// More info: https://patroni.readthedocs.io/en/latest/replication_modes.html
+	// +optional
+	SynchronousReplication *PatroniSynchronousReplication `json:"synchronousReplication,omitempty"`
+
 	// TODO(cbandy): Allow other DCS: etcd, raft, etc?
 	// N.B. changing this will cause downtime.
-	// - https://patroni.readthedocs.io/en/latest/kubernetes.html
 }
 
+// PatroniSpec DCS kinds.
+const (
+	PatroniDCSConfigMaps = "ConfigMaps"
+	PatroniDCSEndpoints  = "Endpoints"
+)
+
 type PatroniSwitchover struct {
 
 	// Whether or not the operator should allow switchovers in a PostgresCluster
@@ -88,12 +112,95 @@ const (
 	PatroniSwitchoverTypeSwitchover = "Switchover"
 )
 
+type PatroniSynchronousReplication struct {
+	// How Patroni replicates to standbys. "Enabled" keeps nodeCount standbys
+	// synchronous. "Quorum" commits when any nodeCount standbys confirm and
+	// requires Patroni v4 or later. "Disabled" replicates asynchronously.
+	// +optional
+	// +kubebuilder:default=Enabled
+	// +kubebuilder:validation:Enum={Disabled,Enabled,Quorum}
+	Mode string `json:"mode,omitempty"`
+
+	// Whether or not the primary stops accepting writes when fewer than
+	// nodeCount synchronous standbys are attached.
+	// +optional
+	Strict bool `json:"strict,omitempty"`
+
+	// The number of standbys that must confirm each transaction.
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	NodeCount *int32 `json:"nodeCount,omitempty"`
+
+	// The names of instance sets whose instances may become synchronous
+	// standbys. When empty, any instance may. Changes take effect as each
+	// instance restarts.
+	// +optional
+	// +listType=set
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// PatroniSynchronousReplication modes.
+const (
+	PatroniSynchronousModeDisabled = "Disabled"
+	PatroniSynchronousModeEnabled  = "Enabled"
+	PatroniSynchronousModeQuorum   = "Quorum"
+)
+
+// Default sets the mode and node count when they are not specified.
+func (s *PatroniSynchronousReplication) Default() {
+	if s.Mode == "" {
+		s.Mode = PatroniSynchronousModeEnabled
+	}
+	if s.NodeCount == nil {
+		s.NodeCount = new(int32)
+		*s.NodeCount = 1
+	}
+}
+
+// PatroniTags are Patroni tags applied to every instance of an instance set.
+// More info: https://patroni.readthedocs.io/en/latest/yaml_configuration.html#tags
+type PatroniTags struct {
+	// Whether or not instances of this set are prevented from becoming primary.
+	// Use it for replicas that should never win an election, such as those
+	// for disaster recovery or reporting.
+	// +optional
+	NoFailover bool `json:"noFailover,omitempty"`
+
+	// Whether or not instances of this set are excluded from the replica
+	// Service of the cluster. Changing this value on any instance set causes
+	// every instance of the cluster to restart.
+	// +optional
+	NoLoadBalance bool `json:"noLoadBalance,omitempty"`
+
+	// Whether or not instances of this set are prevented from becoming
+	// synchronous standbys.
+	// +optional
+	NoSync bool `json:"noSync,omitempty"`
+
+	// Whether or not new replicas prefer to clone their data from an instance
+	// of this set rather than from the primary.
+	// +optional
+	CloneFrom bool `json:"cloneFrom,omitempty"`
+
+	// The name of another instance set from which instances of this set
+	// stream WAL, rather than from the primary. This is cascading replication.
+	// Instances stream from the primary when that instance set has no instances.
+	// +optional
+	ReplicateFrom string `json:"replicateFrom,omitempty"`
+}
+
 // Default sets the default values for certain Patroni configuration attributes,
 // including:
+// - Kind of DCS
 // - Lock Lease Duration
 // - Patroni's API port
 // - Frequency of syncing with Kube API
+// - Synchronous replication mode and node count
 func (s *PatroniSpec) Default() {
+	if s.DCS == "" {
+		s.DCS = PatroniDCSEndpoints
+	}
 	if s.LeaderLeaseDurationSeconds == nil {
 		s.LeaderLeaseDurationSeconds = new(int32)
 		*s.LeaderLeaseDurationSeconds = 30
@@ -106,6 +213,9 @@ func (s *PatroniSpec) Default() {
 		s.SyncPeriodSeconds = new(int32)
 		*s.SyncPeriodSeconds = 10
 	}
+	if s.SynchronousReplication != nil {
+		s.SynchronousReplication.Default()
+	}
 }
 
 type PatroniStatus struct {
@@ -118,6 +228,11 @@ type PatroniStatus struct {
 	// +optional
 	SystemIdentifier string `json:"systemIdentifier,omitempty"`
 
+	// The kind of Kubernetes object Patroni is configured to use for its DCS.
+	// This differs from spec.patroni.dcs until every instance has stopped.
+	// +optional
+	DCS string `json:"dcs,omitempty"`
+
 	// Tracks the execution of the switchover requests.
 	// +optional
 	Switchover *string `json:"switchover,omitempty"`
@@ -126,3 +241,35 @@ type PatroniStatus struct {
 	// +optional
 	SwitchoverTimeline *int64 `json:"switchoverTimeline,omitempty"`
 }
+
+// PatroniMemberStatus is one Patroni member as reported by Patroni's "GET /cluster" endpoint.
+type PatroniMemberStatus struct {
+	// The name of the member, which is also the name of its Pod.
+	Name string `json:"name"`
+
+	// The role of the member: leader, replica, sync_standby, or standby_leader.
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// The state of the member's PostgreSQL, e.g. running or streaming.
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// The PostgreSQL timeline of the member.
+	// +optional
+	Timeline int64 `json:"timeline,omitempty"`
+
+	// The number of bytes of WAL this replica has yet to replay from the leader.
+	// It is absent on the leader and when Patroni cannot tell.
+	// +optional
+	ReplicationLagBytes *int64 `json:"replicationLagBytes,omitempty"`
+
+	// Whether or not PostgreSQL must restart for a parameter to take effect.
+	// +optional
+	PendingRestart bool `json:"pendingRestart,omitempty"`
+
+	// The last time PGO asked Patroni about this member and found it. This is
+	// not a heartbeat of the member itself.
+	// +optional
+	ObservedTime *metav1.Time `json:"observedTime,omitempty"`
+}
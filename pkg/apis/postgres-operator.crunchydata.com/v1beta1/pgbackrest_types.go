@@ -279,6 +279,72 @@ type PGBackRestBackupSchedules struct {
 	Incremental *string `json:"incremental,omitempty"`
 }
 
+// PGBackRestRetention defines which backups and WAL pgBackRest keeps in a repository.
+// Backups that depend on an expired backup expire with it.
+// More info: https://pgbackrest.org/configuration.html#section-repository
+type PGBackRestRetention struct {
+	// The number of full backups to keep or, when fullType is "time", the number
+	// of days to keep full backups.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	Full *int32 `json:"full,omitempty"`
+
+	// Whether full is a number of backups ("count") or a number of days ("time").
+	// Defaults to "count".
+	// +optional
+	// +kubebuilder:validation:Enum={count,time}
+	FullType string `json:"fullType,omitempty"`
+
+	// The number of differential backups to keep.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	Differential *int32 `json:"differential,omitempty"`
+
+	// The number of backups of archiveType for which to keep WAL. WAL needed to
+	// make any remaining backup consistent is always kept. Defaults to the
+	// retention of full or differential backups.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=9999999
+	Archive *int32 `json:"archive,omitempty"`
+
+	// The type of backup counted by archive: "full", "diff", or "incr".
+	// Defaults to "full".
+	// +optional
+	// +kubebuilder:validation:Enum={full,diff,incr}
+	ArchiveType string `json:"archiveType,omitempty"`
+}
+
+// PGBackRestVerify defines a restore drill: a Job that restores the latest backup in a
+// repository to a temporary volume, starts PostgreSQL once recovery reaches a consistent
+// state, and checks the restored database.
+type PGBackRestVerify struct {
+	// Defines the Cron schedule for the restore drill.
+	// Follows the standard Cron schedule syntax:
+	// https://k8s.io/docs/concepts/workloads/controllers/cron-jobs/#cron-schedule-syntax
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=6
+	Schedule string `json:"schedule"`
+
+	// SQL that must succeed against the restored "postgres" database. When empty,
+	// pg_amcheck checks the indexes and tables of every database on PostgreSQL 14
+	// and later; earlier versions are only checked for a successful start.
+	// +optional
+	Query string `json:"query,omitempty"`
+
+	// Defines a PersistentVolumeClaim spec for the temporary volume that holds the
+	// restored database. It must be large enough for the database and its WAL.
+	// Defaults to the dataVolumeClaimSpec of the first instance set.
+	// +optional
+	VolumeClaimSpec *corev1.PersistentVolumeClaimSpec `json:"volumeClaimSpec,omitempty"`
+
+	// Resource requirements for the restore drill container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
 // PGBackRestStatus defines the status of pgBackRest within a PostgresCluster
 type PGBackRestStatus struct {
 
@@ -303,6 +369,11 @@ type PGBackRestStatus struct {
 	// Status information for in-place restores
 	// +optional
 	Restore *PGBackRestJobStatus `json:"restore,omitempty"`
+
+	// The last time the operator read the backups of every repository from
+	// pgBackRest. See status.pgbackrest.repos[].backups.
+	// +optional
+	InventoryTime *metav1.Time `json:"inventoryTime,omitempty"`
 }
 
 // PGBackRestRepo represents a pgBackRest repository.  Only one of its members may be specified.
@@ -322,6 +393,18 @@ type PGBackRestRepo struct {
 	// +optional
 	BackupSchedules *PGBackRestBackupSchedules `json:"schedules,omitempty"`
 
+	// Defines how long pgBackRest keeps backups and WAL in the repository. Changes
+	// are applied by running the pgBackRest expire command.
+	// More info: https://pgbackrest.org/user-guide.html#retention
+	// +optional
+	Retention *PGBackRestRetention `json:"retention,omitempty"`
+
+	// Defines a schedule for restoring the latest backup in this repository to a
+	// temporary volume and checking the restored database. Instances of the
+	// cluster are not affected.
+	// +optional
+	Verify *PGBackRestVerify `json:"verify,omitempty"`
+
 	// Represents a pgBackRest repository that is created using Azure storage
 	// +optional
 	Azure *RepoAzure `json:"azure,omitempty"`
@@ -418,6 +501,114 @@ type RepoStatus struct {
 	// commands accordingly.
 	// +optional
 	RepoOptionsHash string `json:"repoOptionsHash,omitempty"`
+
+	// A hash of the retention options last applied to the repository by the
+	// pgBackRest expire command
+	// +optional
+	RetentionHash string `json:"retentionHash,omitempty"`
+
+	// The newest backups in this repository as last reported by pgBackRest,
+	// oldest first. At most 20 are reported; see backupCount.
+	// +optional
+	// +listType=map
+	// +listMapKey=label
+	Backups []PGBackRestBackupInfo `json:"backups,omitempty"`
+
+	// The number of backups in this repository as last reported by pgBackRest,
+	// including those that are not in backups.
+	// +optional
+	BackupCount int32 `json:"backupCount,omitempty"`
+
+	// The earliest point in time this repository can restore to: the end of
+	// its oldest backup.
+	// +optional
+	OldestRestorableTime *metav1.Time `json:"oldestRestorableTime,omitempty"`
+
+	// The latest point in time this repository can restore to as far as the
+	// operator knows: the end of its newest backup or, when later, the time
+	// this repository stored its newest WAL file.
+	// +optional
+	NewestRestorableTime *metav1.Time `json:"newestRestorableTime,omitempty"`
+
+	// The total size, in bytes, of the backups in this repository, not
+	// including archived WAL.
+	// +optional
+	SizeBytes *int64 `json:"sizeBytes,omitempty"`
+
+	// The result of the most recent restore drill of this repository.
+	// +optional
+	Verify *PGBackRestVerifyStatus `json:"verify,omitempty"`
+}
+
+// PGBackRestVerifyStatus describes the most recent restore drill of a repository.
+type PGBackRestVerifyStatus struct {
+	// The name of the Job that ran the restore drill.
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// When the restore drill started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// When the restore drill finished, whether or not it passed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Whether or not the backup was restored and passed its checks. This field
+	// is not set while the restore drill is running.
+	// +optional
+	Passed *bool `json:"passed,omitempty"`
+}
+
+// PGBackRestBackupInfo is one backup in a pgBackRest repository.
+// More info: https://pgbackrest.org/command.html#command-info
+type PGBackRestBackupInfo struct {
+	// The pgBackRest label of this backup, e.g. "20230101-000000F".
+	// +kubebuilder:validation:Required
+	Label string `json:"label"`
+
+	// The type of this backup: full, diff, or incr.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// When this backup started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// When this backup finished.
+	// +optional
+	StopTime *metav1.Time `json:"stopTime,omitempty"`
+
+	// The size, in bytes, of the database when this backup was taken.
+	// +optional
+	DatabaseSizeBytes int64 `json:"databaseSizeBytes,omitempty"`
+
+	// The size, in bytes, of this backup in the repository, not including
+	// files of prior backups that it references.
+	// +optional
+	RepositorySizeBytes int64 `json:"repositorySizeBytes,omitempty"`
+
+	// The first WAL segment needed to restore this backup.
+	// +optional
+	WALStart string `json:"walStart,omitempty"`
+
+	// The last WAL segment needed to restore this backup.
+	// +optional
+	WALStop string `json:"walStop,omitempty"`
+
+	// The log sequence number at which this backup started.
+	// +optional
+	LSNStart string `json:"lsnStart,omitempty"`
+
+	// The log sequence number at which this backup finished. A restore of this
+	// backup is consistent at this LSN.
+	// +optional
+	LSNStop string `json:"lsnStop,omitempty"`
+
+	// Whether or not pgBackRest found errors, such as page checksum failures,
+	// while taking this backup.
+	// +optional
+	Error bool `json:"error,omitempty"`
 }
 
 // PGBackRestDataSource defines a pgBackRest configuration specifically for restoring from cloud-based data source
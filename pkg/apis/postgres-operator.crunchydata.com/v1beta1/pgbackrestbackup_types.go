@@ -0,0 +1,106 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PGBackRestBackupSpec defines the desired state of PGBackRestBackup
+type PGBackRestBackupSpec struct {
+
+	// The name of the PostgresCluster to back up
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	PostgresClusterName string `json:"postgresClusterName"`
+
+	// The name of the pgBackRest repository in which to store the backup
+	// +required
+	// +kubebuilder:validation:Pattern=^repo[1-4]
+	RepoName string `json:"repoName"`
+
+	// The type of backup to take: "full", "diff", or "incr". Defaults to "full".
+	// More info: https://pgbackrest.org/user-guide.html#concept/backup
+	// +optional
+	// +kubebuilder:validation:Enum={full,diff,incr}
+	Type string `json:"type,omitempty"`
+
+	// Command line options to include when running the pgBackRest backup command.
+	// The repository and type of backup are set by repoName and type.
+	// More info: https://pgbackrest.org/command.html#command-backup
+	// +optional
+	Options []string `json:"options,omitempty"`
+
+	// Whether or not to remove the backup from its repository when this object
+	// is deleted. Backups that depend on it are removed as well.
+	// More info: https://pgbackrest.org/command.html#command-expire
+	// +optional
+	ExpireOnDelete bool `json:"expireOnDelete,omitempty"`
+}
+
+// PGBackRestBackupStatus defines the observed state of PGBackRestBackup
+type PGBackRestBackupStatus struct {
+	// conditions represent the observations of PGBackRestBackup's current state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// observedGeneration represents the .metadata.generation on which the status was based.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// The name of the Job that runs the backup
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// When the backup Job started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// When the backup Job completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// The backup in the repository, once it is complete
+	// +optional
+	Backup *PGBackRestBackupInfo `json:"backup,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// PGBackRestBackup is the Schema for the pgbackrestbackups API
+type PGBackRestBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PGBackRestBackupSpec   `json:"spec,omitempty"`
+	Status PGBackRestBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PGBackRestBackupList contains a list of PGBackRestBackup
+type PGBackRestBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PGBackRestBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PGBackRestBackup{}, &PGBackRestBackupList{})
+}
@@ -15,6 +15,14 @@
 
 package v1beta1
 
+import (
+	"bytes"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // PostgreSQL identifiers are limited in length but may contain any character.
 // More info: https://www.postgresql.org/docs/current/sql-syntax-lexical.html#SQL-SYNTAX-IDENTIFIERS
 //
@@ -22,6 +30,170 @@ package v1beta1
 // +kubebuilder:validation:MaxLength=63
 type PostgresIdentifier string
 
+// PostgresParameterValue is the value of a PostgreSQL parameter. It can be
+// written as a string, a number, or a Boolean. Numbers keep the text they were
+// written with, and Booleans become "on" or "off".
+// More info: https://www.postgresql.org/docs/current/config-setting.html
+//
+// +kubebuilder:pruning:PreserveUnknownFields
+// +kubebuilder:validation:Type=""
+type PostgresParameterValue string
+
+// String returns the value as PostgreSQL reads it.
+func (v PostgresParameterValue) String() string { return string(v) }
+
+// UnmarshalJSON reads a JSON string, number, or Boolean. Any other JSON value
+// is kept as written.
+func (v *PostgresParameterValue) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	var s string
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*v = ""
+	case bytes.Equal(data, []byte("true")):
+		*v = "on"
+	case bytes.Equal(data, []byte("false")):
+		*v = "off"
+	case json.Unmarshal(data, &s) == nil:
+		*v = PostgresParameterValue(s)
+	default:
+		*v = PostgresParameterValue(data)
+	}
+	return nil
+}
+
+type PostgresAuthenticationSpec struct {
+	// Whether or not to keep the recommended rules after those specified.
+	// The recommended rules allow password authentication to any database
+	// over TLS. When unset, they are kept only when no other rules are
+	// specified here or in spec.patroni.dynamicConfiguration.
+	// +optional
+	Defaults *bool `json:"defaults,omitempty"`
+
+	// Rules that determine how clients can connect and authenticate, in order.
+	// The first rule that matches a connection is used. These come after the
+	// rules required by the operator and before any in
+	// spec.patroni.dynamicConfiguration.
+	// More info: https://www.postgresql.org/docs/current/auth-pg-hba-conf.html
+	// +listType=atomic
+	// +optional
+	Rules []PostgresHBARuleSpec `json:"rules,omitempty"`
+}
+
+type PostgresHBARuleSpec struct {
+	// The kind of connection this rule matches: "local" for Unix-domain
+	// sockets, "host" for TCP/IP, "hostssl" for TCP/IP with TLS, or "hostnossl"
+	// for TCP/IP without TLS. Defaults to "hostssl".
+	// +kubebuilder:default=hostssl
+	// +kubebuilder:validation:Enum={local,host,hostssl,hostnossl}
+	// +optional
+	Connection string `json:"connection,omitempty"`
+
+	// Databases this rule matches. Defaults to all databases.
+	// +listType=set
+	// +optional
+	Databases []PostgresIdentifier `json:"databases,omitempty"`
+
+	// Users this rule matches. Defaults to all users.
+	// +listType=set
+	// +optional
+	Users []PostgresIdentifier `json:"users,omitempty"`
+
+	// The client addresses this rule matches in CIDR notation, or "samenet"
+	// for any subnet to which the server is directly connected. Defaults to
+	// all addresses. This field must be empty for "local" connections.
+	// +optional
+	Network string `json:"network,omitempty"`
+
+	// The authentication method to use when a connection matches this rule.
+	// More info: https://www.postgresql.org/docs/current/auth-methods.html
+	// +kubebuilder:validation:Enum={trust,reject,scram-sha-256,md5,password,gss,sspi,ident,peer,ldap,radius,cert,pam,bsd}
+	Method string `json:"method"`
+
+	// Options for the authentication method. Each method accepts only its
+	// own options, such as "ldapserver" for ldap, "clientname" for cert, and
+	// "include_realm" for gss. Any method over TLS accepts "clientcert".
+	// More info: https://www.postgresql.org/docs/current/auth-pg-hba-conf.html
+	// +mapType=granular
+	// +optional
+	Options map[string]string `json:"options,omitempty"`
+}
+
+type PostgresDatabaseSpec struct {
+	// The name of this PostgreSQL database.
+	// +kubebuilder:validation:Type=string
+	Name PostgresIdentifier `json:"name"`
+
+	// The PostgreSQL user that owns this database. The user must exist in
+	// PostgreSQL, either in the users list or created some other way.
+	// Defaults to the user that created the database, "postgres".
+	// +kubebuilder:validation:Type=string
+	// +optional
+	Owner PostgresIdentifier `json:"owner,omitempty"`
+
+	// Character set encoding of this database. This is used only when the
+	// database is created and cannot be changed afterward.
+	// More info: https://www.postgresql.org/docs/current/multibyte.html
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	Encoding string `json:"encoding,omitempty"`
+
+	// Collation order (LC_COLLATE) of this database. This is used only when the
+	// database is created and cannot be changed afterward.
+	// More info: https://www.postgresql.org/docs/current/locale.html
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	LCCollate string `json:"lcCollate,omitempty"`
+
+	// Character classification (LC_CTYPE) of this database. This is used only
+	// when the database is created and cannot be changed afterward.
+	// More info: https://www.postgresql.org/docs/current/locale.html
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	LCCType string `json:"lcCType,omitempty"`
+
+	// The database from which this database is copied when it is created.
+	// Defaults to "template1". Use "template0" to choose an encoding or locale
+	// that differs from "template1".
+	// More info: https://www.postgresql.org/docs/current/manage-ag-templatedbs.html
+	// +kubebuilder:validation:Type=string
+	// +optional
+	Template PostgresIdentifier `json:"template,omitempty"`
+
+	// How many concurrent connections can be made to this database. The
+	// value -1 means no limit. When unset, the limit is not changed.
+	// +kubebuilder:validation:Minimum=-1
+	// +optional
+	ConnectionLimit *int32 `json:"connectionLimit,omitempty"`
+
+	// Extensions to install in this database and keep at their specified
+	// version. Removing an extension from this list does NOT drop it.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Extensions []PostgresExtensionSpec `json:"extensions,omitempty"`
+}
+
+type PostgresExtensionSpec struct {
+	// The name of the extension to install.
+	// More info: https://www.postgresql.org/docs/current/sql-createextension.html
+	// +kubebuilder:validation:Type=string
+	Name PostgresIdentifier `json:"name"`
+
+	// The schema in which to install the extension. This is used only when the
+	// extension is installed. Defaults to the schema chosen by the extension.
+	// +kubebuilder:validation:Type=string
+	// +optional
+	Schema PostgresIdentifier `json:"schema,omitempty"`
+
+	// The version of the extension to install or update to. Defaults to the
+	// latest version available in the PostgreSQL image.
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
 type PostgresPasswordSpec struct {
 	// Type of password to generate. Defaults to ASCII. Valid options are ASCII
 	// and AlphaNumeric.
@@ -30,6 +202,40 @@ type PostgresPasswordSpec struct {
 	// +kubebuilder:default=ASCII
 	// +kubebuilder:validation:Enum={ASCII,AlphaNumeric}
 	Type string `json:"type"`
+
+	// Generate a new password on a schedule. When unset, a password is
+	// generated only when the Secret lacks one.
+	// +optional
+	Rotation *PostgresPasswordRotationSpec `json:"rotation,omitempty"`
+
+	// A key of an existing Secret in the same namespace that contains the
+	// password of this user. The value may be a plaintext password or a
+	// SCRAM-SHA-256 verifier. When set, no password is generated and changes
+	// to the Secret are applied in PostgreSQL. Cannot be used with rotation.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+type PostgresPasswordRotationSpec struct {
+	// Number of seconds between each new password.
+	// +kubebuilder:validation:Minimum=3600
+	IntervalSeconds int32 `json:"intervalSeconds"`
+
+	// Number of seconds that the previous password continues to work after a
+	// new one is generated. When set, this user alternates with a second
+	// PostgreSQL user that has "-alternate" appended to its name and acts as
+	// this user. The Secret always contains the newest user and password.
+	// Must be less than intervalSeconds.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	GracePeriodSeconds *int32 `json:"gracePeriodSeconds,omitempty"`
+
+	// Whether or not PostgreSQL should reject a password once it is due to be
+	// replaced. When true, each password is valid until its next rotation plus
+	// any grace period. This field is ignored for the "postgres" user.
+	// More info: https://www.postgresql.org/docs/current/sql-createrole.html
+	// +optional
+	Expire bool `json:"expire,omitempty"`
 }
 
 // PostgresPasswordSpec types.
@@ -51,8 +257,9 @@ type PostgresUserSpec struct {
 	Name PostgresIdentifier `json:"name"`
 
 	// Databases to which this user can connect and create objects. Removing a
-	// database from this list does NOT revoke access. This field is ignored for
-	// the "postgres" user.
+	// database from this list does NOT revoke access. The privileges field
+	// can limit access to these databases. This field is ignored for the
+	// "postgres" user.
 	// +listType=set
 	// +optional
 	Databases []PostgresIdentifier `json:"databases,omitempty"`
@@ -67,4 +274,150 @@ type PostgresUserSpec struct {
 	// Properties of the password generated for this user.
 	// +optional
 	Password *PostgresPasswordSpec `json:"password,omitempty"`
+
+	// Privileges to grant this user instead of ALL PRIVILEGES on its databases.
+	// When set, the privileges of this user on its databases, on the schemas
+	// listed, and its membership in other roles are kept as specified. This
+	// field is ignored for the "postgres" user.
+	// +optional
+	Privileges *PostgresPrivilegesSpec `json:"privileges,omitempty"`
+
+	// Whether or not to issue a client certificate for this user, signed by
+	// the cluster certificate authority and with this user as its common name.
+	// The Secret gets "tls.crt", "tls.key", and "ca.crt", and its URIs require
+	// "sslmode=verify-full". When true, this user authenticates using that
+	// certificate on every TLS connection, so its password works only on
+	// connections without TLS. PgBouncer cannot log in as this user, so the
+	// Secret has no "pgbouncer-" values.
+	// More info: https://www.postgresql.org/docs/current/auth-cert.html
+	// +optional
+	ClientCertificate bool `json:"clientCertificate,omitempty"`
+}
+
+type PostgresPrivilegesSpec struct {
+	// Privileges on each database in the databases list. Other privileges on
+	// those databases are revoked. Defaults to CONNECT.
+	// More info: https://www.postgresql.org/docs/current/ddl-priv.html
+	// +kubebuilder:default={CONNECT}
+	// +listType=set
+	// +optional
+	Databases []PostgresDatabasePrivilege `json:"databases,omitempty"`
+
+	// Roles in which this user is a member. Membership in any other role is
+	// revoked.
+	// More info: https://www.postgresql.org/docs/current/role-membership.html
+	// +listType=set
+	// +optional
+	Roles []PostgresIdentifier `json:"roles,omitempty"`
+
+	// Privileges on schemas and the objects in them in each database in the
+	// databases list. Removing a schema from this list does NOT revoke access.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Schemas []PostgresSchemaPrivilegesSpec `json:"schemas,omitempty"`
+}
+
+type PostgresSchemaPrivilegesSpec struct {
+	// The name of the schema. The schema must already exist.
+	// +kubebuilder:validation:Type=string
+	Name PostgresIdentifier `json:"name"`
+
+	// Privileges on the schema itself. Defaults to USAGE.
+	// +kubebuilder:default={USAGE}
+	// +listType=set
+	// +optional
+	Schema []PostgresSchemaPrivilege `json:"schema,omitempty"`
+
+	// Privileges on every table, view, and materialized view in the schema,
+	// including those created later.
+	// +listType=set
+	// +optional
+	Tables []PostgresTablePrivilege `json:"tables,omitempty"`
+
+	// Privileges on every sequence in the schema, including those created later.
+	// +listType=set
+	// +optional
+	Sequences []PostgresSequencePrivilege `json:"sequences,omitempty"`
+
+	// Users whose tables and sequences created later in the schema are granted
+	// to this user. Defaults to the owner of each database.
+	// More info: https://www.postgresql.org/docs/current/sql-alterdefaultprivileges.html
+	// +listType=set
+	// +optional
+	DefaultPrivilegesFor []PostgresIdentifier `json:"defaultPrivilegesFor,omitempty"`
+}
+
+// +kubebuilder:validation:Enum={CONNECT,CREATE,TEMPORARY}
+type PostgresDatabasePrivilege string
+
+// +kubebuilder:validation:Enum={CREATE,USAGE}
+type PostgresSchemaPrivilege string
+
+// +kubebuilder:validation:Enum={SELECT,INSERT,UPDATE,DELETE,TRUNCATE,REFERENCES,TRIGGER}
+type PostgresTablePrivilege string
+
+// +kubebuilder:validation:Enum={SELECT,UPDATE,USAGE}
+type PostgresSequencePrivilege string
+
+type PostgresUserRemovalSpec struct {
+	// What to do with a user after it is removed from the users list.
+	// "Retain" leaves the user and its access in PostgreSQL.
+	// "NoLogin" prevents the user from connecting and ends its sessions.
+	// "Drop" reassigns the objects it owns, revokes its privileges, and drops it.
+	// The "postgres" user is always retained.
+	// +kubebuilder:default=Retain
+	// +kubebuilder:validation:Enum={Retain,NoLogin,Drop}
+	Policy string `json:"policy"`
+
+	// The user that receives ownership of objects owned by a dropped user.
+	// Defaults to "postgres".
+	// +kubebuilder:validation:Type=string
+	// +optional
+	ReassignOwnedTo PostgresIdentifier `json:"reassignOwnedTo,omitempty"`
+}
+
+// PostgresUserRemovalSpec policies.
+const (
+	PostgresUserRemovalPolicyDrop    = "Drop"
+	PostgresUserRemovalPolicyNoLogin = "NoLogin"
+	PostgresUserRemovalPolicyRetain  = "Retain"
+)
+
+type PostgresUserRemovalStatus struct {
+	// The name of the PostgreSQL user that was removed from the spec.
+	Name string `json:"name"`
+
+	// The removal policy for this user. The policy of a user that has not
+	// been applied follows the current spec.
+	Policy string `json:"policy"`
+
+	// Whether or not the policy has been applied in PostgreSQL.
+	// +optional
+	Applied bool `json:"applied"`
+
+	// When the policy was applied in PostgreSQL.
+	// +optional
+	AppliedTime *metav1.Time `json:"appliedTime,omitempty"`
+
+	// Details about the most recent failure to apply the policy.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type PostgresUserPasswordStatus struct {
+	// The name of the user in the spec.
+	Name string `json:"name"`
+
+	// The PostgreSQL user with the newest password. This is either the user
+	// in the spec or its alternate.
+	// +optional
+	ActiveUser string `json:"activeUser,omitempty"`
+
+	// When the newest password was generated.
+	RotatedTime metav1.Time `json:"rotatedTime"`
+
+	// When the next password will be generated.
+	// +optional
+	NextRotationTime *metav1.Time `json:"nextRotationTime,omitempty"`
 }
@@ -0,0 +1,68 @@
+/*
+ Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func TestPostgresParameterValue(t *testing.T) {
+	t.Parallel()
+
+	// Convert YAML to JSON the way clients do before sending it to Kubernetes.
+	b, err := yaml.YAMLToJSON([]byte(`{
+		parameters: {
+			checkpoint_completion_target: 0.9,
+			jit: on,
+			log_line_prefix: '%m [%p]',
+			max_connections: 200,
+			random_page_cost: 1.10,
+			ssl: false,
+			vacuum_cost_limit: 1e3,
+			wal_compression: 'lz4',
+		},
+	}`))
+	assert.NilError(t, err)
+
+	var config PostgresAdditionalConfig
+	assert.NilError(t, json.Unmarshal(b, &config))
+
+	assert.DeepEqual(t, config.Parameters, map[string]PostgresParameterValue{
+		"checkpoint_completion_target": "0.9",
+		"jit":                          "on",
+		"log_line_prefix":              "%m [%p]",
+		"max_connections":              "200",
+		"random_page_cost":             "1.1",
+		"ssl":                          "off",
+		"vacuum_cost_limit":            "1000",
+		"wal_compression":              "lz4",
+	})
+
+	// Numbers in JSON keep their text.
+	assert.NilError(t, json.Unmarshal([]byte(
+		`{"parameters":{"random_page_cost":1.10,"vacuum_cost_limit":1e3}}`), &config))
+	assert.Equal(t, config.Parameters["random_page_cost"], PostgresParameterValue("1.10"))
+	assert.Equal(t, config.Parameters["vacuum_cost_limit"], PostgresParameterValue("1e3"))
+
+	// Values are written back as strings.
+	b, err = json.Marshal(map[string]PostgresParameterValue{"jit": "on", "work_mem": "4MB"})
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), `{"jit":"on","work_mem":"4MB"}`)
+}
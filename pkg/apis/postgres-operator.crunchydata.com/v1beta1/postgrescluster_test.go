@@ -56,6 +56,7 @@ spec:
   config: {}
   instances: null
   patroni:
+    dcs: Endpoints
     leaderLeaseDurationSeconds: 30
     port: 8008
     syncPeriodSeconds: 10
@@ -94,6 +95,7 @@ spec:
     replicas: 1
     resources: {}
   patroni:
+    dcs: Endpoints
     leaderLeaseDurationSeconds: 30
     port: 8008
     syncPeriodSeconds: 10
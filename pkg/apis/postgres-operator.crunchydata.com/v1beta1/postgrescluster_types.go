@@ -148,6 +148,10 @@ type PostgresClusterSpec struct {
 	// +optional
 	Service *ServiceSpec `json:"service,omitempty"`
 
+	// Specification of the service that exposes PostgreSQL replica instances.
+	// +optional
+	ReplicaService *ServiceSpec `json:"replicaService,omitempty"`
+
 	// Whether or not the PostgreSQL cluster should be stopped.
 	// When this is true, workloads are scaled to zero and CronJobs
 	// are suspended.
@@ -165,15 +169,33 @@ type PostgresClusterSpec struct {
 	// +optional
 	SupplementalGroups []int64 `json:"supplementalGroups,omitempty"`
 
+	// Databases to create inside PostgreSQL and the properties they should
+	// have. Databases listed here are created in addition to those named in
+	// the users list. Removing a database from this list does NOT drop it.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Databases []PostgresDatabaseSpec `json:"databases,omitempty"`
+
 	// Users to create inside PostgreSQL and the databases they should access.
 	// The default creates one user that can access one database matching the
 	// PostgresCluster name. An empty list creates no users. Removing a user
-	// from this list does NOT drop the user nor revoke their access.
+	// from this list does NOT drop the user nor revoke their access unless
+	// userRemoval says otherwise.
 	// +listType=map
 	// +listMapKey=name
 	// +optional
 	Users []PostgresUserSpec `json:"users,omitempty"`
 
+	// What happens to a PostgreSQL user after it is removed from the users list.
+	// The default retains the user and its access.
+	// +optional
+	UserRemoval *PostgresUserRemovalSpec `json:"userRemoval,omitempty"`
+
+	// How clients are allowed to connect and authenticate to PostgreSQL.
+	// +optional
+	Authentication *PostgresAuthenticationSpec `json:"authentication,omitempty"`
+
 	Config PostgresAdditionalConfig `json:"config,omitempty"`
 }
 
@@ -327,6 +349,11 @@ type PostgresClusterStatus struct {
 	// Identifies the databases that have been installed into PostgreSQL.
 	DatabaseRevision string `json:"databaseRevision,omitempty"`
 
+	// PostgreSQL parameters the operator computed when spec.config.autoTune
+	// is enabled. These are defaults for every instance.
+	// +optional
+	AutoTunedParameters map[string]string `json:"autoTunedParameters,omitempty"`
+
 	// Current state of PostgreSQL instances.
 	// +listType=map
 	// +listMapKey=name
@@ -365,6 +392,36 @@ type PostgresClusterStatus struct {
 	// Identifies the users that have been installed into PostgreSQL.
 	UsersRevision string `json:"usersRevision,omitempty"`
 
+	// The PostgreSQL users, including alternates, that were last installed
+	// from the spec. Users that leave the spec are removed from this list
+	// after their removal policy is applied.
+	// +listType=set
+	// +optional
+	Users []string `json:"users,omitempty"`
+
+	// Users that were removed from the spec and what happened to them.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	RemovedUsers []PostgresUserRemovalStatus `json:"removedUsers,omitempty"`
+
+	// Users with rotating passwords and when their passwords were rotated.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	UserPasswords []PostgresUserPasswordStatus `json:"userPasswords,omitempty"`
+
+	// The last time the passwords of users in PostgreSQL were compared to the
+	// verifiers in their Secrets.
+	// +optional
+	UserPasswordsCheckedTime *metav1.Time `json:"userPasswordsCheckedTime,omitempty"`
+
+	// Users whose passwords in PostgreSQL differed from their Secrets at the
+	// last comparison. Their passwords are written again from their Secrets.
+	// +listType=set
+	// +optional
+	UserPasswordsDrifted []string `json:"userPasswordsDrifted,omitempty"`
+
 	// Current state of PostgreSQL cluster monitoring tool configuration
 	// +optional
 	Monitoring MonitoringStatus `json:"monitoring,omitempty"`
@@ -379,8 +436,9 @@ type PostgresClusterStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 
 	// conditions represent the observations of postgrescluster's current state.
-	// Known .status.conditions.type are: "PersistentVolumeResizing",
-	// "Progressing", "ProxyAvailable"
+	// Known .status.conditions.type are: "ParametersApplied",
+	// "ParametersValid", "PersistentVolumeResizing", "Progressing",
+	// "ProxyAvailable", "SynchronousStandbysAttached"
 	// +optional
 	// +listType=map
 	// +listMapKey=type
@@ -392,7 +450,11 @@ type PostgresClusterStatus struct {
 const (
 	PersistentVolumeResizing   = "PersistentVolumeResizing"
 	PostgresClusterProgressing = "Progressing"
+	PostgresParametersApplied  = "ParametersApplied"
+	PostgresParametersValid    = "ParametersValid"
 	ProxyAvailable             = "ProxyAvailable"
+
+	SynchronousStandbysAttached = "SynchronousStandbysAttached"
 )
 
 type PostgresInstanceSetSpec struct {
@@ -433,6 +495,11 @@ type PostgresInstanceSetSpec struct {
 	// +optional
 	Containers []corev1.Container `json:"containers,omitempty"`
 
+	// Configuration of PostgreSQL on instances of this set. Changes take
+	// effect as each instance restarts.
+	// +optional
+	Config *PostgresInstanceConfig `json:"config,omitempty"`
+
 	// Defines a PersistentVolumeClaim for PostgreSQL data.
 	// More info: https://kubernetes.io/docs/concepts/storage/persistent-volumes
 	// +kubebuilder:validation:Required
@@ -444,6 +511,11 @@ type PostgresInstanceSetSpec struct {
 	// +optional
 	PriorityClassName *string `json:"priorityClassName,omitempty"`
 
+	// Patroni tags of every instance in this set. Changes take effect as each
+	// instance restarts.
+	// +optional
+	PatroniTags *PatroniTags `json:"patroniTags,omitempty"`
+
 	// Number of desired PostgreSQL pods.
 	// +optional
 	// +kubebuilder:default=1
@@ -455,6 +527,22 @@ type PostgresInstanceSetSpec struct {
 	// +optional
 	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
 
+	// Specification of a service that exposes only the replica instances of
+	// this set. Use it to direct some read traffic, such as reporting, to these
+	// instances. The replica service of the cluster continues to include them.
+	// +optional
+	ReplicaService *ServiceSpec `json:"replicaService,omitempty"`
+
+	// Number of seconds instances of this set wait before replaying changes
+	// from the primary. A delayed replica can recover from human error, such as
+	// an accidental DROP TABLE, without restoring a backup. These instances
+	// never become primary and are excluded from the replica Service of the
+	// cluster. Changes take effect as each instance restarts.
+	// More info: https://www.postgresql.org/docs/current/runtime-config-replication.html#GUC-RECOVERY-MIN-APPLY-DELAY
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	ReplicationDelaySeconds *int32 `json:"replicationDelaySeconds,omitempty"`
+
 	// Compute resources of a PostgreSQL container.
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
@@ -513,6 +601,15 @@ type PostgresInstanceSetStatus struct {
 	// Total number of pods that have the desired specification.
 	// +optional
 	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+
+	// The Patroni members of this set as last reported by Patroni. PGO asks
+	// Patroni about its members every 30 seconds. Unless the PatroniRESTAPI
+	// feature gate is enabled, each time is a "patronictl list" command that
+	// PGO executes in one of the database containers.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Members []PatroniMemberStatus `json:"members,omitempty"`
 }
 
 // PostgresProxySpec is a union of the supported PostgreSQL proxies.
@@ -581,6 +678,37 @@ type PostgresUserInterfaceStatus struct {
 
 type PostgresAdditionalConfig struct {
 	Files []corev1.VolumeProjection `json:"files,omitempty"`
+
+	// Whether or not the operator tunes some parameters according to the
+	// resources and volumes of the smallest instance set: shared_buffers,
+	// effective_cache_size, maintenance_work_mem, work_mem, max_wal_size,
+	// max_parallel_workers, and huge_pages. Parameters set anywhere else in
+	// the spec take precedence. Computed values are reported in
+	// status.autoTunedParameters.
+	// +optional
+	AutoTune bool `json:"autoTune,omitempty"`
+
+	// Configuration parameters for the PostgreSQL server. These take precedence
+	// over any in spec.patroni.dynamicConfiguration. Names and values are
+	// checked against the parameters of spec.postgresVersion; those that are
+	// not valid are skipped and reported in the "ParametersValid" condition.
+	// Some parameters are managed by the operator and cannot be changed.
+	// More info: https://www.postgresql.org/docs/current/runtime-config.html
+	// +optional
+	// +mapType=granular
+	Parameters map[string]PostgresParameterValue `json:"parameters,omitempty"`
+}
+
+type PostgresInstanceConfig struct {
+	// Configuration parameters for PostgreSQL on instances of this set. These
+	// take precedence over those in spec.config.parameters and in
+	// spec.patroni.dynamicConfiguration. Parameters that must be the same on
+	// every instance, such as max_connections, and those managed by the
+	// operator cannot be changed here.
+	// More info: https://www.postgresql.org/docs/current/runtime-config.html
+	// +optional
+	// +mapType=granular
+	Parameters map[string]PostgresParameterValue `json:"parameters,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,140 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresRestoreSpec defines the desired state of PostgresRestore
+type PostgresRestoreSpec struct {
+
+	// The name of the PostgresCluster to restore in-place
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	PostgresClusterName string `json:"postgresClusterName"`
+
+	// The name of the pgBackRest repository that contains the backups to restore
+	// +required
+	// +kubebuilder:validation:Pattern=^repo[1-4]
+	RepoName string `json:"repoName"`
+
+	// The point to which PostgreSQL is restored. Defaults to the end of the WAL
+	// archived in the repository. PostgreSQL is always promoted once it reaches
+	// the target; the restore Job finishes recovery before the cluster starts.
+	// +optional
+	Target *PostgresRestoreTarget `json:"target,omitempty"`
+
+	// Command line options to include when running the pgBackRest restore command.
+	// The repository, type, and target of the restore are set by the fields above.
+	// More info: https://pgbackrest.org/command.html#command-restore
+	// +optional
+	Options []string `json:"options,omitempty"`
+
+	// Resource requirements for the pgBackRest restore Job.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Scheduling constraints of the pgBackRest restore Job.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Priority class name for the pgBackRest restore Job pod.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/pod-priority-preemption/
+	// +optional
+	PriorityClassName *string `json:"priorityClassName,omitempty"`
+
+	// Tolerations of the pgBackRest restore Job.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// PostgresRestoreTarget is the point to which PostgreSQL is restored. Only one
+// of time, lsn, and xid can be set.
+// More info: https://pgbackrest.org/command.html#command-restore/category-command/option-type
+type PostgresRestoreTarget struct {
+	// Restore the transactions committed up to this time.
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+
+	// Restore the WAL up to this location, e.g. "0/3000060".
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9A-Fa-f]{1,8}/[0-9A-Fa-f]{1,8}$`
+	LSN string `json:"lsn,omitempty"`
+
+	// Restore the transactions up to this transaction ID.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+$`
+	XID string `json:"xid,omitempty"`
+
+	// The label of the backup to restore, e.g. "20230101-000000F". When time,
+	// lsn, and xid are not set, PostgreSQL is restored to the end of this backup.
+	// +optional
+	Backup string `json:"backup,omitempty"`
+
+	// Whether or not to stop just before the target rather than just after it.
+	// +optional
+	Exclusive bool `json:"exclusive,omitempty"`
+}
+
+// PostgresRestoreStatus defines the observed state of PostgresRestore
+type PostgresRestoreStatus struct {
+	// conditions represent the observations of PostgresRestore's current state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// observedGeneration represents the .metadata.generation on which the status was based.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// When the restore Job started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// When the restore Job completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// PostgresRestore is the Schema for the postgresrestores API
+type PostgresRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PostgresRestoreSpec   `json:"spec,omitempty"`
+	Status PostgresRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PostgresRestoreList contains a list of PostgresRestore
+type PostgresRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresRestore{}, &PostgresRestoreList{})
+}
@@ -55,6 +55,53 @@ type ServiceSpec struct {
 	// +kubebuilder:default=ClusterIP
 	// +kubebuilder:validation:Enum={ClusterIP,NodePort,LoadBalancer}
 	Type string `json:"type"`
+
+	// Whether or not this Service routes external traffic to node-local or
+	// cluster-wide endpoints. Only allowed when type is NodePort or LoadBalancer.
+	// More info: https://kubernetes.io/docs/reference/networking/virtual-ips/#external-traffic-policy
+	// +optional
+	// +kubebuilder:validation:Enum={Cluster,Local}
+	ExternalTrafficPolicy *corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+
+	// The IP families of this Service, in order. The first family cannot change
+	// after the Service is created.
+	// More info: https://kubernetes.io/docs/concepts/services-networking/dual-stack/
+	// +optional
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=2
+	IPFamilies []corev1.IPFamily `json:"ipFamilies,omitempty"`
+
+	// Whether or not this Service uses one or two IP families.
+	// More info: https://kubernetes.io/docs/concepts/services-networking/dual-stack/
+	// +optional
+	// +kubebuilder:validation:Enum={SingleStack,PreferDualStack,RequireDualStack}
+	IPFamilyPolicy *corev1.IPFamilyPolicyType `json:"ipFamilyPolicy,omitempty"`
+
+	// The class of load balancer implementation this Service belongs to. Only
+	// allowed when type is LoadBalancer. Changing it recreates the Service.
+	// More info: https://kubernetes.io/docs/concepts/services-networking/service/#load-balancer-class
+	// +optional
+	LoadBalancerClass *string `json:"loadBalancerClass,omitempty"`
+
+	// Client address ranges, in CIDR notation, allowed to connect through the
+	// load balancer. Only allowed when type is LoadBalancer.
+	// More info: https://kubernetes.io/docs/tasks/access-application-cluster/create-external-load-balancer/#restrict-access-for-loadbalancer-service
+	// +optional
+	// +listType=atomic
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// Whether or not connections from one client go to the same endpoint.
+	// More info: https://kubernetes.io/docs/reference/networking/virtual-ips/#session-affinity
+	// +optional
+	// +kubebuilder:validation:Enum={ClientIP,None}
+	SessionAffinity *corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+
+	// The maximum number of seconds a ClientIP session sticks to one endpoint.
+	// Only allowed when sessionAffinity is ClientIP. Defaults to 10800.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	SessionAffinityTimeoutSeconds *int32 `json:"sessionAffinityTimeoutSeconds,omitempty"`
 }
 
 // Sidecar defines the configuration of a sidecar container
@@ -449,6 +449,88 @@ func (in *PGBackRestArchive) DeepCopy() *PGBackRestArchive {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestBackup) DeepCopyInto(out *PGBackRestBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackup.
+func (in *PGBackRestBackup) DeepCopy() *PGBackRestBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PGBackRestBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestBackupInfo) DeepCopyInto(out *PGBackRestBackupInfo) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.StopTime != nil {
+		in, out := &in.StopTime, &out.StopTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupInfo.
+func (in *PGBackRestBackupInfo) DeepCopy() *PGBackRestBackupInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestBackupInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestBackupList) DeepCopyInto(out *PGBackRestBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PGBackRestBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupList.
+func (in *PGBackRestBackupList) DeepCopy() *PGBackRestBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PGBackRestBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestBackupSchedules) DeepCopyInto(out *PGBackRestBackupSchedules) {
 	*out = *in
@@ -479,6 +561,61 @@ func (in *PGBackRestBackupSchedules) DeepCopy() *PGBackRestBackupSchedules {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestBackupSpec) DeepCopyInto(out *PGBackRestBackupSpec) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupSpec.
+func (in *PGBackRestBackupSpec) DeepCopy() *PGBackRestBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestBackupStatus) DeepCopyInto(out *PGBackRestBackupStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Backup != nil {
+		in, out := &in.Backup, &out.Backup
+		*out = new(PGBackRestBackupInfo)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestBackupStatus.
+func (in *PGBackRestBackupStatus) DeepCopy() *PGBackRestBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestDataSource) DeepCopyInto(out *PGBackRestDataSource) {
 	*out = *in
@@ -583,6 +720,16 @@ func (in *PGBackRestRepo) DeepCopyInto(out *PGBackRestRepo) {
 		*out = new(PGBackRestBackupSchedules)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(PGBackRestRetention)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(PGBackRestVerify)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Azure != nil {
 		in, out := &in.Azure, &out.Azure
 		*out = new(RepoAzure)
@@ -690,6 +837,36 @@ func (in *PGBackRestRestore) DeepCopy() *PGBackRestRestore {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestRetention) DeepCopyInto(out *PGBackRestRetention) {
+	*out = *in
+	if in.Full != nil {
+		in, out := &in.Full, &out.Full
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Differential != nil {
+		in, out := &in.Differential, &out.Differential
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Archive != nil {
+		in, out := &in.Archive, &out.Archive
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestRetention.
+func (in *PGBackRestRetention) DeepCopy() *PGBackRestRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBackRestScheduledBackupStatus) DeepCopyInto(out *PGBackRestScheduledBackupStatus) {
 	*out = *in
@@ -761,13 +938,19 @@ func (in *PGBackRestStatus) DeepCopyInto(out *PGBackRestStatus) {
 	if in.Repos != nil {
 		in, out := &in.Repos, &out.Repos
 		*out = make([]RepoStatus, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Restore != nil {
 		in, out := &in.Restore, &out.Restore
 		*out = new(PGBackRestJobStatus)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.InventoryTime != nil {
+		in, out := &in.InventoryTime, &out.InventoryTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestStatus.
@@ -780,6 +963,55 @@ func (in *PGBackRestStatus) DeepCopy() *PGBackRestStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestVerify) DeepCopyInto(out *PGBackRestVerify) {
+	*out = *in
+	if in.VolumeClaimSpec != nil {
+		in, out := &in.VolumeClaimSpec, &out.VolumeClaimSpec
+		*out = new(v1.PersistentVolumeClaimSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestVerify.
+func (in *PGBackRestVerify) DeepCopy() *PGBackRestVerify {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestVerify)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBackRestVerifyStatus) DeepCopyInto(out *PGBackRestVerifyStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Passed != nil {
+		in, out := &in.Passed, &out.Passed
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBackRestVerifyStatus.
+func (in *PGBackRestVerifyStatus) DeepCopy() *PGBackRestVerifyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBackRestVerifyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PGBouncerConfiguration) DeepCopyInto(out *PGBouncerConfiguration) {
 	*out = *in
@@ -1090,6 +1322,30 @@ func (in *PGUpgradeStatus) DeepCopy() *PGUpgradeStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatroniMemberStatus) DeepCopyInto(out *PatroniMemberStatus) {
+	*out = *in
+	if in.ReplicationLagBytes != nil {
+		in, out := &in.ReplicationLagBytes, &out.ReplicationLagBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ObservedTime != nil {
+		in, out := &in.ObservedTime, &out.ObservedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniMemberStatus.
+func (in *PatroniMemberStatus) DeepCopy() *PatroniMemberStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PatroniMemberStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PatroniSpec) DeepCopyInto(out *PatroniSpec) {
 	*out = *in
@@ -1114,6 +1370,11 @@ func (in *PatroniSpec) DeepCopyInto(out *PatroniSpec) {
 		*out = new(PatroniSwitchover)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SynchronousReplication != nil {
+		in, out := &in.SynchronousReplication, &out.SynchronousReplication
+		*out = new(PatroniSynchronousReplication)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniSpec.
@@ -1171,6 +1432,46 @@ func (in *PatroniSwitchover) DeepCopy() *PatroniSwitchover {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatroniSynchronousReplication) DeepCopyInto(out *PatroniSynchronousReplication) {
+	*out = *in
+	if in.NodeCount != nil {
+		in, out := &in.NodeCount, &out.NodeCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Candidates != nil {
+		in, out := &in.Candidates, &out.Candidates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniSynchronousReplication.
+func (in *PatroniSynchronousReplication) DeepCopy() *PatroniSynchronousReplication {
+	if in == nil {
+		return nil
+	}
+	out := new(PatroniSynchronousReplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatroniTags) DeepCopyInto(out *PatroniTags) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniTags.
+func (in *PatroniTags) DeepCopy() *PatroniTags {
+	if in == nil {
+		return nil
+	}
+	out := new(PatroniTags)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresAdditionalConfig) DeepCopyInto(out *PostgresAdditionalConfig) {
 	*out = *in
@@ -1181,6 +1482,13 @@ func (in *PostgresAdditionalConfig) DeepCopyInto(out *PostgresAdditionalConfig)
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]PostgresParameterValue, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresAdditionalConfig.
@@ -1193,6 +1501,33 @@ func (in *PostgresAdditionalConfig) DeepCopy() *PostgresAdditionalConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresAuthenticationSpec) DeepCopyInto(out *PostgresAuthenticationSpec) {
+	*out = *in
+	if in.Defaults != nil {
+		in, out := &in.Defaults, &out.Defaults
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]PostgresHBARuleSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresAuthenticationSpec.
+func (in *PostgresAuthenticationSpec) DeepCopy() *PostgresAuthenticationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresAuthenticationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresCluster) DeepCopyInto(out *PostgresCluster) {
 	*out = *in
@@ -1376,6 +1711,11 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = new(ServiceSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ReplicaService != nil {
+		in, out := &in.ReplicaService, &out.ReplicaService
+		*out = new(ServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Shutdown != nil {
 		in, out := &in.Shutdown, &out.Shutdown
 		*out = new(bool)
@@ -1391,13 +1731,30 @@ func (in *PostgresClusterSpec) DeepCopyInto(out *PostgresClusterSpec) {
 		*out = make([]int64, len(*in))
 		copy(*out, *in)
 	}
-	if in.Users != nil {
-		in, out := &in.Users, &out.Users
-		*out = make([]PostgresUserSpec, len(*in))
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PostgresDatabaseSpec, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]PostgresUserSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UserRemoval != nil {
+		in, out := &in.UserRemoval, &out.UserRemoval
+		*out = new(PostgresUserRemovalSpec)
+		**out = **in
+	}
+	if in.Authentication != nil {
+		in, out := &in.Authentication, &out.Authentication
+		*out = new(PostgresAuthenticationSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Config.DeepCopyInto(&out.Config)
 }
 
@@ -1414,10 +1771,19 @@ func (in *PostgresClusterSpec) DeepCopy() *PostgresClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresClusterStatus) DeepCopyInto(out *PostgresClusterStatus) {
 	*out = *in
+	if in.AutoTunedParameters != nil {
+		in, out := &in.AutoTunedParameters, &out.AutoTunedParameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.InstanceSets != nil {
 		in, out := &in.InstanceSets, &out.InstanceSets
 		*out = make([]PostgresInstanceSetStatus, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	in.Patroni.DeepCopyInto(&out.Patroni)
 	if in.PGBackRest != nil {
@@ -1431,6 +1797,34 @@ func (in *PostgresClusterStatus) DeepCopyInto(out *PostgresClusterStatus) {
 		*out = new(PostgresUserInterfaceStatus)
 		**out = **in
 	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RemovedUsers != nil {
+		in, out := &in.RemovedUsers, &out.RemovedUsers
+		*out = make([]PostgresUserRemovalStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UserPasswords != nil {
+		in, out := &in.UserPasswords, &out.UserPasswords
+		*out = make([]PostgresUserPasswordStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UserPasswordsCheckedTime != nil {
+		in, out := &in.UserPasswordsCheckedTime, &out.UserPasswordsCheckedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.UserPasswordsDrifted != nil {
+		in, out := &in.UserPasswordsDrifted, &out.UserPasswordsDrifted
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	out.Monitoring = in.Monitoring
 	if in.DatabaseInitSQL != nil {
 		in, out := &in.DatabaseInitSQL, &out.DatabaseInitSQL
@@ -1456,6 +1850,100 @@ func (in *PostgresClusterStatus) DeepCopy() *PostgresClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresDatabaseSpec) DeepCopyInto(out *PostgresDatabaseSpec) {
+	*out = *in
+	if in.ConnectionLimit != nil {
+		in, out := &in.ConnectionLimit, &out.ConnectionLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Extensions != nil {
+		in, out := &in.Extensions, &out.Extensions
+		*out = make([]PostgresExtensionSpec, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresDatabaseSpec.
+func (in *PostgresDatabaseSpec) DeepCopy() *PostgresDatabaseSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresDatabaseSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresExtensionSpec) DeepCopyInto(out *PostgresExtensionSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresExtensionSpec.
+func (in *PostgresExtensionSpec) DeepCopy() *PostgresExtensionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresExtensionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresHBARuleSpec) DeepCopyInto(out *PostgresHBARuleSpec) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresHBARuleSpec.
+func (in *PostgresHBARuleSpec) DeepCopy() *PostgresHBARuleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresHBARuleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresInstanceConfig) DeepCopyInto(out *PostgresInstanceConfig) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]PostgresParameterValue, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresInstanceConfig.
+func (in *PostgresInstanceConfig) DeepCopy() *PostgresInstanceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresInstanceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 	*out = *in
@@ -1476,12 +1964,22 @@ func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(PostgresInstanceConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	in.DataVolumeClaimSpec.DeepCopyInto(&out.DataVolumeClaimSpec)
 	if in.PriorityClassName != nil {
 		in, out := &in.PriorityClassName, &out.PriorityClassName
 		*out = new(string)
 		**out = **in
 	}
+	if in.PatroniTags != nil {
+		in, out := &in.PatroniTags, &out.PatroniTags
+		*out = new(PatroniTags)
+		**out = **in
+	}
 	if in.Replicas != nil {
 		in, out := &in.Replicas, &out.Replicas
 		*out = new(int32)
@@ -1492,6 +1990,16 @@ func (in *PostgresInstanceSetSpec) DeepCopyInto(out *PostgresInstanceSetSpec) {
 		*out = new(intstr.IntOrString)
 		**out = **in
 	}
+	if in.ReplicaService != nil {
+		in, out := &in.ReplicaService, &out.ReplicaService
+		*out = new(ServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReplicationDelaySeconds != nil {
+		in, out := &in.ReplicationDelaySeconds, &out.ReplicationDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
 	in.Resources.DeepCopyInto(&out.Resources)
 	if in.Sidecars != nil {
 		in, out := &in.Sidecars, &out.Sidecars
@@ -1532,6 +2040,13 @@ func (in *PostgresInstanceSetSpec) DeepCopy() *PostgresInstanceSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresInstanceSetStatus) DeepCopyInto(out *PostgresInstanceSetStatus) {
 	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]PatroniMemberStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresInstanceSetStatus.
@@ -1544,9 +2059,39 @@ func (in *PostgresInstanceSetStatus) DeepCopy() *PostgresInstanceSetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresPasswordRotationSpec) DeepCopyInto(out *PostgresPasswordRotationSpec) {
+	*out = *in
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresPasswordRotationSpec.
+func (in *PostgresPasswordRotationSpec) DeepCopy() *PostgresPasswordRotationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresPasswordRotationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresPasswordSpec) DeepCopyInto(out *PostgresPasswordSpec) {
 	*out = *in
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(PostgresPasswordRotationSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresPasswordSpec.
@@ -1559,6 +2104,38 @@ func (in *PostgresPasswordSpec) DeepCopy() *PostgresPasswordSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresPrivilegesSpec) DeepCopyInto(out *PostgresPrivilegesSpec) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]PostgresDatabasePrivilege, len(*in))
+		copy(*out, *in)
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
+	if in.Schemas != nil {
+		in, out := &in.Schemas, &out.Schemas
+		*out = make([]PostgresSchemaPrivilegesSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresPrivilegesSpec.
+func (in *PostgresPrivilegesSpec) DeepCopy() *PostgresPrivilegesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresPrivilegesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresProxySpec) DeepCopyInto(out *PostgresProxySpec) {
 	*out = *in
@@ -1595,6 +2172,192 @@ func (in *PostgresProxyStatus) DeepCopy() *PostgresProxyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestore) DeepCopyInto(out *PostgresRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestore.
+func (in *PostgresRestore) DeepCopy() *PostgresRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreList) DeepCopyInto(out *PostgresRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PostgresRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreList.
+func (in *PostgresRestoreList) DeepCopy() *PostgresRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreSpec) DeepCopyInto(out *PostgresRestoreSpec) {
+	*out = *in
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(PostgresRestoreTarget)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PriorityClassName != nil {
+		in, out := &in.PriorityClassName, &out.PriorityClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreSpec.
+func (in *PostgresRestoreSpec) DeepCopy() *PostgresRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreStatus) DeepCopyInto(out *PostgresRestoreStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreStatus.
+func (in *PostgresRestoreStatus) DeepCopy() *PostgresRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreTarget) DeepCopyInto(out *PostgresRestoreTarget) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreTarget.
+func (in *PostgresRestoreTarget) DeepCopy() *PostgresRestoreTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresSchemaPrivilegesSpec) DeepCopyInto(out *PostgresSchemaPrivilegesSpec) {
+	*out = *in
+	if in.Schema != nil {
+		in, out := &in.Schema, &out.Schema
+		*out = make([]PostgresSchemaPrivilege, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tables != nil {
+		in, out := &in.Tables, &out.Tables
+		*out = make([]PostgresTablePrivilege, len(*in))
+		copy(*out, *in)
+	}
+	if in.Sequences != nil {
+		in, out := &in.Sequences, &out.Sequences
+		*out = make([]PostgresSequencePrivilege, len(*in))
+		copy(*out, *in)
+	}
+	if in.DefaultPrivilegesFor != nil {
+		in, out := &in.DefaultPrivilegesFor, &out.DefaultPrivilegesFor
+		*out = make([]PostgresIdentifier, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresSchemaPrivilegesSpec.
+func (in *PostgresSchemaPrivilegesSpec) DeepCopy() *PostgresSchemaPrivilegesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresSchemaPrivilegesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresStandbySpec) DeepCopyInto(out *PostgresStandbySpec) {
 	*out = *in
@@ -1631,6 +2394,60 @@ func (in *PostgresUserInterfaceStatus) DeepCopy() *PostgresUserInterfaceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserPasswordStatus) DeepCopyInto(out *PostgresUserPasswordStatus) {
+	*out = *in
+	in.RotatedTime.DeepCopyInto(&out.RotatedTime)
+	if in.NextRotationTime != nil {
+		in, out := &in.NextRotationTime, &out.NextRotationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserPasswordStatus.
+func (in *PostgresUserPasswordStatus) DeepCopy() *PostgresUserPasswordStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserPasswordStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserRemovalSpec) DeepCopyInto(out *PostgresUserRemovalSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserRemovalSpec.
+func (in *PostgresUserRemovalSpec) DeepCopy() *PostgresUserRemovalSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserRemovalSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresUserRemovalStatus) DeepCopyInto(out *PostgresUserRemovalStatus) {
+	*out = *in
+	if in.AppliedTime != nil {
+		in, out := &in.AppliedTime, &out.AppliedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserRemovalStatus.
+func (in *PostgresUserRemovalStatus) DeepCopy() *PostgresUserRemovalStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresUserRemovalStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresUserSpec) DeepCopyInto(out *PostgresUserSpec) {
 	*out = *in
@@ -1642,7 +2459,12 @@ func (in *PostgresUserSpec) DeepCopyInto(out *PostgresUserSpec) {
 	if in.Password != nil {
 		in, out := &in.Password, &out.Password
 		*out = new(PostgresPasswordSpec)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Privileges != nil {
+		in, out := &in.Privileges, &out.Privileges
+		*out = new(PostgresPrivilegesSpec)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -1736,6 +2558,31 @@ func (in *RepoS3) DeepCopy() *RepoS3 {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoStatus) DeepCopyInto(out *RepoStatus) {
 	*out = *in
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]PGBackRestBackupInfo, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OldestRestorableTime != nil {
+		in, out := &in.OldestRestorableTime, &out.OldestRestorableTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NewestRestorableTime != nil {
+		in, out := &in.NewestRestorableTime, &out.NewestRestorableTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SizeBytes != nil {
+		in, out := &in.SizeBytes, &out.SizeBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(PGBackRestVerifyStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepoStatus.
@@ -1770,6 +2617,41 @@ func (in *ServiceSpec) DeepCopyInto(out *ServiceSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.ExternalTrafficPolicy != nil {
+		in, out := &in.ExternalTrafficPolicy, &out.ExternalTrafficPolicy
+		*out = new(v1.ServiceExternalTrafficPolicyType)
+		**out = **in
+	}
+	if in.IPFamilies != nil {
+		in, out := &in.IPFamilies, &out.IPFamilies
+		*out = make([]v1.IPFamily, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPFamilyPolicy != nil {
+		in, out := &in.IPFamilyPolicy, &out.IPFamilyPolicy
+		*out = new(v1.IPFamilyPolicyType)
+		**out = **in
+	}
+	if in.LoadBalancerClass != nil {
+		in, out := &in.LoadBalancerClass, &out.LoadBalancerClass
+		*out = new(string)
+		**out = **in
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SessionAffinity != nil {
+		in, out := &in.SessionAffinity, &out.SessionAffinity
+		*out = new(v1.ServiceAffinity)
+		**out = **in
+	}
+	if in.SessionAffinityTimeoutSeconds != nil {
+		in, out := &in.SessionAffinityTimeoutSeconds, &out.SessionAffinityTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceSpec.